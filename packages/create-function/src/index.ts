@@ -1,35 +1,77 @@
 #!/usr/bin/env node
 
 import { existsSync, mkdirSync, writeFileSync, readFileSync, readdirSync, statSync, copyFileSync } from 'node:fs'
-import { join, dirname } from 'node:path'
+import { join, dirname, basename, resolve, relative } from 'node:path'
+import { spawnSync } from 'node:child_process'
 import { fileURLToPath } from 'node:url'
 
 const __filename = fileURLToPath(import.meta.url)
 const __dirname = dirname(__filename)
 
-const SUPPORTED_LANGUAGES = ['typescript', 'rust', 'python', 'go', 'assemblyscript'] as const
-type SupportedLanguage = typeof SUPPORTED_LANGUAGES[number]
+// Used when --lang is omitted; Go was the only language in early releases
+const DEFAULT_LANGUAGE = 'go'
+
+const SUPPORTED_TRIGGERS = ['http', 'cron', 'queue', 'email'] as const
+type SupportedTrigger = typeof SUPPORTED_TRIGGERS[number]
+
+// Languages whose templates can be scaffolded with non-HTTP triggers
+const TRIGGER_LANGUAGES: readonly string[] = ['go']
 
 interface ParsedArgs {
   projectName: string | undefined
   lang: string | undefined
+  trigger: string | undefined
+  name: string | undefined
+  kv: string[]
+  r2: string[]
+  force: boolean
 }
 
 function parseArgs(args: string[]): ParsedArgs {
   let projectName: string | undefined
   let lang: string | undefined
+  let trigger: string | undefined
+  let name: string | undefined
+  const kv: string[] = []
+  const r2: string[] = []
+  let force = false
 
   for (let i = 0; i < args.length; i++) {
     const arg = args[i]
     if (arg === '--lang' && i + 1 < args.length) {
       lang = args[i + 1]
       i++ // Skip the next arg since we consumed it
+    } else if (arg === '--trigger' && i + 1 < args.length) {
+      trigger = args[i + 1]
+      i++
+    } else if (arg === '--name' && i + 1 < args.length) {
+      name = args[i + 1]
+      i++
+    } else if (arg === '--kv' && i + 1 < args.length) {
+      kv.push(args[i + 1])
+      i++
+    } else if (arg === '--r2' && i + 1 < args.length) {
+      r2.push(args[i + 1])
+      i++
+    } else if (arg === '--force') {
+      force = true
     } else if (!arg.startsWith('-') && !projectName) {
       projectName = arg
     }
   }
 
-  return { projectName, lang }
+  return { projectName, lang, trigger, name, kv, r2, force }
+}
+
+/**
+ * Parse a comma-separated --trigger value (e.g. "http,cron") into a
+ * de-duplicated list in canonical order. Returns the unknown names if any.
+ */
+function parseTriggers(value: string | undefined): { triggers: SupportedTrigger[]; unknown: string[] } {
+  const requested = (value ?? 'http').split(',').map((t) => t.trim()).filter(Boolean)
+  const unknown = requested.filter((t) => !SUPPORTED_TRIGGERS.includes(t as SupportedTrigger))
+  const triggers = SUPPORTED_TRIGGERS.filter((t) => requested.includes(t))
+  return { triggers, unknown }
 }
 
 function getCompatibilityDate(): string {
@@ -40,35 +82,42 @@ function getCompatibilityDate(): string {
   return `${year}-${month}-${day}`
 }
 
-function getTemplateDir(lang: SupportedLanguage): string {
+function getTemplateDir(...segments: string[]): string {
   // In development (from src), templates are at ../templates
   // In production (from dist), templates are at ../templates
-  const templatesDir = join(__dirname, '..', 'templates', lang)
+  const templatesDir = join(__dirname, '..', 'templates', ...segments)
   if (existsSync(templatesDir)) {
     return templatesDir
   }
 
   // Fallback for when running from dist directory
-  const distTemplatesDir = join(__dirname, '..', '..', 'templates', lang)
+  const distTemplatesDir = join(__dirname, '..', '..', 'templates', ...segments)
   if (existsSync(distTemplatesDir)) {
     return distTemplatesDir
   }
 
-  throw new Error(`Template directory not found for language: ${lang}`)
+  throw new Error(`Template directory not found: ${segments.join('/')}`)
 }
 
-function processTemplateContent(content: string, projectName: string, compatibilityDate: string): string {
+/**
+ * Values substituted for {{placeholders}} in template files. project_name
+ * names packages and modules; worker_name is the Worker's name in
+ * wrangler.toml, which --name can set apart from the directory name.
+ */
+interface TemplateVars {
+  project_name: string
+  worker_name: string
+  compatibility_date: string
+}
+
+function processTemplateContent(content: string, vars: TemplateVars): string {
   return content
-    .replace(/\{\{project_name\}\}/g, projectName)
-    .replace(/\{\{compatibility_date\}\}/g, compatibilityDate)
+    .replace(/\{\{project_name\}\}/g, vars.project_name)
+    .replace(/\{\{worker_name\}\}/g, vars.worker_name)
+    .replace(/\{\{compatibility_date\}\}/g, vars.compatibility_date)
 }
 
-function copyTemplateRecursive(
-  srcDir: string,
-  destDir: string,
-  projectName: string,
-  compatibilityDate: string
-): void {
+function copyTemplateRecursive(srcDir: string, destDir: string, vars: TemplateVars): void {
   const entries = readdirSync(srcDir)
 
   for (const entry of entries) {
@@ -78,61 +127,336 @@ function copyTemplateRecursive(
 
     if (stat.isDirectory()) {
       mkdirSync(destPath, { recursive: true })
-      copyTemplateRecursive(srcPath, destPath, projectName, compatibilityDate)
+      copyTemplateRecursive(srcPath, destPath, vars)
     } else {
       // Read file content and process template variables
       const content = readFileSync(srcPath, 'utf-8')
-      const processedContent = processTemplateContent(content, projectName, compatibilityDate)
-      writeFileSync(destPath, processedContent)
+      writeFileSync(destPath, processTemplateContent(content, vars))
     }
   }
 }
 
-function isValidLanguage(lang: string): lang is SupportedLanguage {
-  return SUPPORTED_LANGUAGES.includes(lang as SupportedLanguage)
+/**
+ * List the languages that have a template, i.e. every directory under
+ * templates/ except shared ones like _triggers whose names start with "_".
+ */
+function getSupportedLanguages(): string[] {
+  const root = getTemplateDir()
+  return readdirSync(root)
+    .filter((entry) => !entry.startsWith('_') && statSync(join(root, entry)).isDirectory())
+    .sort()
+}
+
+// Trigger-specific template files live in templates/_triggers/<lang>/<trigger>/.
+// A wrangler.fragment.toml in that directory is appended to wrangler.toml
+// instead of being copied.
+const WRANGLER_FRAGMENT = 'wrangler.fragment.toml'
+
+interface GoImport {
+  path: string
+  name?: string
+}
+
+/**
+ * How each trigger is registered in the generated Go main. Handler logic
+ * lives in the trigger's template files; main only wires it to the runtime.
+ */
+interface GoTriggerWiring {
+  imports: GoImport[]
+  setup: string[]
+  // Blocking registration for a main with only this trigger; triggers
+  // without one always register non-blocking and signal readiness
+  serve?: string
+  serveNonBlock: string
+  adapter?: string
+  // Worker entry module the trigger provides in place of the build's
+  // worker.mjs, for events syumai/workers does not dispatch
+  entry?: string
+}
+
+const GO_FUNCTIONS_MODULE = 'github.com/dot-do/functions/packages/functions-go'
+const GO_FUNCTIONS_IMPORT: GoImport = { path: GO_FUNCTIONS_MODULE, name: 'functions' }
+const GO_WORKERS_IMPORT: GoImport = { path: 'github.com/syumai/workers' }
+
+const GO_TRIGGERS: Record<SupportedTrigger, GoTriggerWiring> = {
+  http: {
+    imports: [{ path: 'net/http' }, GO_WORKERS_IMPORT],
+    setup: ['http.HandleFunc("/", handleRequest)'],
+    serve: 'workers.Serve(nil)',
+    serveNonBlock: 'workers.ServeNonBlock(nil)',
+  },
+  cron: {
+    imports: [{ path: 'context' }, GO_FUNCTIONS_IMPORT, { path: 'github.com/syumai/workers/cloudflare/cron' }],
+    setup: [],
+    serve: 'cron.ScheduleTask(runScheduled)',
+    serveNonBlock: 'cron.ScheduleTaskNonBlock(runScheduled)',
+    adapter: `// runScheduled adapts the workers cron task to handleScheduled.
+func runScheduled(ctx context.Context) error {
+	event, err := cron.NewEvent(ctx)
+	if err != nil {
+		return err
+	}
+	return handleScheduled(ctx, functions.ScheduledEvent{Cron: event.Cron, ScheduledTime: event.ScheduledTime})
+}`,
+  },
+  queue: {
+    imports: [
+      { path: 'context' },
+      { path: 'encoding/json' },
+      { path: 'syscall/js' },
+      GO_FUNCTIONS_IMPORT,
+      { path: 'github.com/syumai/workers/cloudflare/queues' },
+    ],
+    setup: [],
+    serve: 'queues.Consume(consumeQueue)',
+    serveNonBlock: 'queues.ConsumeNonBlock(consumeQueue)',
+    adapter: `// consumeQueue adapts a workers message batch to handleQueue.
+func consumeQueue(batch *queues.MessageBatch) error {
+	msgs := make([]*functions.QueueMessage, len(batch.Messages))
+	for i, m := range batch.Messages {
+		m := m
+		body := json.RawMessage(js.Global().Get("JSON").Call("stringify", m.Body).String())
+		msgs[i] = functions.NewQueueMessage(m.ID, m.Timestamp, body, m.Attempts, m.Ack, func() { m.Retry() })
+	}
+	return handleQueue(context.Background(), &functions.QueueBatch{Queue: batch.Queue, Messages: msgs})
+}`,
+  },
+  email: {
+    imports: [GO_FUNCTIONS_IMPORT],
+    setup: [],
+    serveNonBlock: 'functions.HandleEmail(handleEmail)',
+    entry: './email.mjs',
+  },
+}
+
+function renderGoImports(imports: GoImport[]): string {
+  const unique = new Map<string, GoImport>()
+  for (const imp of imports) {
+    unique.set(imp.path, imp)
+  }
+  const spec = (imp: GoImport) => (imp.name ? `\t${imp.name} "${imp.path}"` : `\t"${imp.path}"`)
+  const sorted = [...unique.values()].sort((a, b) => a.path.localeCompare(b.path))
+  // Standard library paths have no dot in their first element
+  const std = sorted.filter((imp) => !imp.path.split('/')[0].includes('.'))
+  const external = sorted.filter((imp) => imp.path.split('/')[0].includes('.'))
+  const groups = [std, external].filter((group) => group.length > 0)
+  return `import (\n${groups.map((group) => group.map(spec).join('\n')).join('\n\n')}\n)`
+}
+
+/**
+ * Render main.go for the requested triggers. A single trigger uses the
+ * blocking workers API; several triggers register without blocking and then
+ * signal readiness so one binary serves all of them.
+ *
+ * main.go is the only file that imports syumai/workers, and it is built
+ * only for js/wasm, so the handler files compile and `go test` natively.
+ */
+function renderGoMain(triggers: SupportedTrigger[]): string {
+  const wirings = triggers.map((t) => GO_TRIGGERS[t])
+  const imports = wirings.flatMap((w) => w.imports)
+  const body = wirings.flatMap((w) => w.setup)
+
+  const single = wirings.length === 1 ? wirings[0].serve : undefined
+  if (single) {
+    body.push(single)
+  } else {
+    imports.push(GO_WORKERS_IMPORT)
+    body.push(...wirings.map((w) => w.serveNonBlock), 'workers.Ready()', 'select {}')
+  }
+
+  const adapters = wirings.flatMap((w) => (w.adapter ? [w.adapter] : []))
+  const sections = [
+    '//go:build js && wasm',
+    'package main',
+    renderGoImports(imports),
+    `func main() {\n${body.map((line) => `\t${line}`).join('\n')}\n}`,
+    ...adapters,
+  ]
+  return sections.join('\n\n') + '\n'
+}
+
+function applyGoTriggers(projectDir: string, triggers: SupportedTrigger[], vars: TemplateVars): void {
+  const wranglerPath = join(projectDir, 'wrangler.toml')
+
+  for (const trigger of triggers) {
+    const triggerDir = getTemplateDir('_triggers', 'go', trigger)
+    for (const entry of readdirSync(triggerDir)) {
+      const content = processTemplateContent(readFileSync(join(triggerDir, entry), 'utf-8'), vars)
+      if (entry === WRANGLER_FRAGMENT) {
+        writeFileSync(wranglerPath, readFileSync(wranglerPath, 'utf-8') + content)
+      } else {
+        writeFileSync(join(projectDir, entry), content)
+      }
+    }
+
+    const workerEntry = GO_TRIGGERS[trigger].entry
+    if (workerEntry) {
+      const wrangler = readFileSync(wranglerPath, 'utf-8')
+      writeFileSync(wranglerPath, wrangler.replace(/^main = .*$/m, `main = "${workerEntry}"`))
+    }
+  }
+
+  writeFileSync(join(projectDir, 'main.go'), renderGoMain(triggers))
+}
+
+/** The functions-go module of the checkout create-function runs from, if any. */
+function findLocalFunctionsGo(): string | undefined {
+  // src/ and dist/ both sit in packages/create-function, next to functions-go
+  const dir = join(__dirname, '..', '..', 'functions-go')
+  return existsSync(join(dir, 'go.mod')) ? dir : undefined
+}
+
+/**
+ * Pin functions-go in the new project's go.mod and fill in go.sum. The
+ * module has no tagged releases, so a project scaffolded from a checkout is
+ * pointed at it with a replace directive, and any other gets the newest
+ * commit's pseudo-version from `go get @latest`.
+ *
+ * Returns the commands still to run if Go is missing or a step failed.
+ */
+function resolveGoModules(projectDir: string): string[] {
+  const steps: string[][] = []
+  const local = findLocalFunctionsGo()
+  if (local) {
+    let path = relative(projectDir, local)
+    if (!path.startsWith('.')) path = `./${path}`
+    const goModPath = join(projectDir, 'go.mod')
+    writeFileSync(
+      goModPath,
+      readFileSync(goModPath, 'utf-8') +
+        `\nrequire ${GO_FUNCTIONS_MODULE} v0.0.0-00010101000000-000000000000\n\nreplace ${GO_FUNCTIONS_MODULE} => ${path}\n`
+    )
+  } else {
+    steps.push(['get', `${GO_FUNCTIONS_MODULE}@latest`])
+  }
+  steps.push(['mod', 'tidy'])
+
+  for (let i = 0; i < steps.length; i++) {
+    const result = spawnSync('go', steps[i], { cwd: projectDir, stdio: 'pipe', encoding: 'utf-8' })
+    if (result.error || result.status !== 0) {
+      const reason = result.error ? result.error.message : result.stderr.trim()
+      console.warn(`Warning: go ${steps[i].join(' ')} failed: ${reason}`)
+      return steps.slice(i).map((args) => `go ${args.join(' ')}`)
+    }
+  }
+  return []
+}
+
+// Binding names become identifiers in Worker code, e.g. env.MY_KV
+const BINDING_NAME = /^[A-Za-z_][A-Za-z0-9_]*$/
+
+interface Bindings {
+  kv: string[]
+  r2: string[]
+}
+
+/**
+ * Render the wrangler.toml sections for the bindings given with --kv and
+ * --r2. KV namespace IDs are only known once the namespace is created, so
+ * the id is a placeholder to replace; the R2 bucket name is derived from
+ * the Worker name.
+ */
+function renderWranglerBindings(bindings: Bindings, workerName: string): string {
+  const sections = [
+    ...bindings.kv.map(
+      (binding) => `
+# Create the namespace with: wrangler kv namespace create ${binding}
+# then replace the id below with the one it prints.
+[[kv_namespaces]]
+binding = "${binding}"
+id = "REPLACE_WITH_${binding}_ID"
+`
+    ),
+    ...bindings.r2.map(
+      (binding) => `
+# Create the bucket with: wrangler r2 bucket create ${r2BucketName(workerName, binding)}
+[[r2_buckets]]
+binding = "${binding}"
+bucket_name = "${r2BucketName(workerName, binding)}"
+`
+    ),
+  ]
+  return sections.join('')
+}
+
+/** Derive a bucket name, which R2 limits to lowercase letters, digits and dashes. */
+function r2BucketName(workerName: string, binding: string): string {
+  return `${workerName}-${binding}`.toLowerCase().replace(/[^a-z0-9-]+/g, '-')
 }
 
 function main() {
   const args = process.argv.slice(2)
-  const { projectName, lang } = parseArgs(args)
+  const { projectName, lang = DEFAULT_LANGUAGE, trigger, name, kv, r2, force } = parseArgs(args)
+  const languages = getSupportedLanguages()
 
   if (!projectName) {
     console.error('Error: Project name is required')
-    console.error('Usage: npx create-function <project-name> --lang <language>')
-    console.error(`Supported languages: ${SUPPORTED_LANGUAGES.join(', ')}`)
+    console.error(
+      'Usage: npx create-function <project-name> [--lang <language>] [--name <worker-name>] [--kv <binding>] [--r2 <binding>] [--force]'
+    )
+    console.error(`Supported languages: ${languages.join(', ')} (default: ${DEFAULT_LANGUAGE})`)
+    process.exit(1)
+  }
+
+  if (!languages.includes(lang)) {
+    console.error(`Error: Unsupported language "${lang}".`)
+    console.error(`Supported languages: ${languages.join(', ')}`)
     process.exit(1)
   }
 
-  if (!lang) {
-    console.error('Error: --lang flag is required')
-    console.error('Usage: npx create-function <project-name> --lang <language>')
-    console.error(`Supported languages: ${SUPPORTED_LANGUAGES.join(', ')}`)
+  const { triggers, unknown } = parseTriggers(trigger)
+  if (unknown.length > 0 || triggers.length === 0) {
+    console.error(`Error: Unsupported trigger "${unknown.join(', ') || trigger}".`)
+    console.error(`Supported triggers: ${SUPPORTED_TRIGGERS.join(', ')}`)
     process.exit(1)
   }
 
-  if (!isValidLanguage(lang)) {
-    console.error(`Error: Unsupported language "${lang}".`)
-    console.error(`Supported languages: ${SUPPORTED_LANGUAGES.join(', ')}`)
+  if (trigger !== undefined && !TRIGGER_LANGUAGES.includes(lang)) {
+    console.error(`Error: --trigger is not supported for ${lang}.`)
+    console.error(`Languages with trigger support: ${TRIGGER_LANGUAGES.join(', ')}`)
     process.exit(1)
   }
 
-  const projectDir = join(process.cwd(), projectName)
+  const invalidBindings = [...kv, ...r2].filter((binding) => !BINDING_NAME.test(binding))
+  if (invalidBindings.length > 0) {
+    console.error(`Error: Invalid binding name "${invalidBindings.join(', ')}".`)
+    console.error('Binding names must start with a letter or underscore and contain only letters, digits and underscores.')
+    process.exit(1)
+  }
+
+  const projectDir = resolve(process.cwd(), projectName)
+  const vars: TemplateVars = {
+    project_name: basename(projectDir),
+    worker_name: name ?? basename(projectDir),
+    compatibility_date: getCompatibilityDate(),
+  }
 
-  // Check if directory already exists
-  if (existsSync(projectDir)) {
-    console.error(`Error: Directory "${projectName}" already exists`)
+  // Check if directory already exists; --force scaffolds into it, replacing
+  // wrangler.toml and any other file the template provides
+  if (existsSync(projectDir) && !force) {
+    const hasWrangler = existsSync(join(projectDir, 'wrangler.toml'))
+    console.error(`Error: Directory "${projectName}" already exists${hasWrangler ? ' and has a wrangler.toml' : ''}`)
+    console.error('Pass --force to overwrite its files.')
     process.exit(1)
   }
 
   // Get template directory
   const templateDir = getTemplateDir(lang)
-  const compatibilityDate = getCompatibilityDate()
 
   // Create project directory
   mkdirSync(projectDir, { recursive: true })
 
   // Copy template files with variable substitution
-  copyTemplateRecursive(templateDir, projectDir, projectName, compatibilityDate)
+  copyTemplateRecursive(templateDir, projectDir, vars)
+
+  if (lang === 'go') {
+    applyGoTriggers(projectDir, triggers, vars)
+  }
+
+  const wranglerPath = join(projectDir, 'wrangler.toml')
+  writeFileSync(wranglerPath, readFileSync(wranglerPath, 'utf-8') + renderWranglerBindings({ kv, r2 }, vars.worker_name))
+
+  const pendingGoSteps = lang === 'go' ? resolveGoModules(projectDir) : []
 
   console.log(`Created ${projectName} successfully!`)
   console.log()
@@ -156,11 +480,31 @@ function main() {
       break
     case 'go':
       console.log('  # Ensure you have Go and TinyGo installed')
-      console.log('  go mod tidy')
+      for (const step of pendingGoSteps) {
+        console.log(`  ${step}`)
+      }
+      console.log('  go test ./...')
       console.log('  make build')
       console.log('  wrangler dev')
+      if (triggers.includes('cron')) {
+        console.log('  # Test the cron trigger with: wrangler dev --test-scheduled')
+        console.log('  # then: curl "http://localhost:8787/__scheduled?cron=*/30+*+*+*+*"')
+      }
+      if (triggers.includes('queue')) {
+        console.log(`  # Create the queue before deploying: wrangler queues create ${vars.worker_name}-queue`)
+      }
+      if (triggers.includes('email')) {
+        console.log(`  # After deploying, route an address to the Worker: Email Routing > Routing rules > Send to a Worker > ${vars.worker_name}`)
+      }
       break
   }
+
+  for (const binding of kv) {
+    console.log(`  # Create the ${binding} namespace and set its id in wrangler.toml: wrangler kv namespace create ${binding}`)
+  }
+  for (const binding of r2) {
+    console.log(`  # Create the ${binding} bucket before deploying: wrangler r2 bucket create ${r2BucketName(vars.worker_name, binding)}`)
+  }
 }
 
 main()
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// handleScheduled runs for every schedule listed under [triggers] in
+// wrangler.toml.
+func handleScheduled(ctx context.Context, event functions.ScheduledEvent) error {
+	log.Printf("cron %q fired, scheduled for %s", event.Cron, event.ScheduledTime.Format(time.RFC3339))
+	return nil
+}
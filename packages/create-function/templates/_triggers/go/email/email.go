@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// forwardTo receives every message that is not rejected. It must be a
+// verified destination address in Email Routing.
+const forwardTo = "team@example.com"
+
+// handleEmail runs for every message that an Email Routing rule sends to
+// this Worker. Only the header has been read when it is called, so
+// routing on headers never reads the body.
+func handleEmail(ctx context.Context, msg *functions.EmailMessage) error {
+	log.Printf("email from %s to %s: %q", msg.From, msg.To, msg.Subject())
+	if strings.Contains(strings.ToLower(msg.Headers.Get("X-Spam-Flag")), "yes") {
+		msg.Reject("message was flagged as spam")
+		return nil
+	}
+	return msg.Forward(forwardTo)
+}
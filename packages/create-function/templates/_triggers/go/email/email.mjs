@@ -0,0 +1,47 @@
+// Worker entry point for the email trigger. The build's worker.mjs, which
+// syumai/workers generates, serves the other triggers; it has no email
+// handler, so email events start a Go instance here the same way it does
+// for the others, and hand the message to the handler main.go registers
+// with functions.HandleEmail.
+import "./build/wasm_exec.js";
+import { createRuntimeContext, loadModule } from "./build/runtime.mjs";
+import worker from "./build/worker.mjs";
+
+let mod;
+
+// run instantiates the Go program for one event with its own runtime
+// context, which Go reads bindings from and registers its handlers on, and
+// resolves once main has signalled that it is ready.
+async function run(ctx) {
+  if (mod === undefined) {
+    mod = await loadModule();
+  }
+  const go = new Go();
+
+  let ready;
+  const readyPromise = new Promise((resolve) => {
+    ready = resolve;
+  });
+  const instance = new WebAssembly.Instance(mod, {
+    ...go.importObject,
+    workers: {
+      ready: () => {
+        ready();
+      },
+    },
+  });
+  go.run(instance, ctx);
+  await readyPromise;
+}
+
+export default {
+  ...worker,
+  async email(message, env, ctx) {
+    const binding = {};
+    await run(createRuntimeContext({ env, ctx, binding }));
+    if (typeof binding.handleEmail !== "function") {
+      throw new Error("no email handler registered; main.go must call functions.HandleEmail");
+    }
+    await binding.handleEmail(message);
+  },
+};
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dot-do/functions/packages/functions-go/functest"
+)
+
+const sampleEmail = "From: Alice <alice@example.com>\r\n" +
+	"To: support@example.com\r\n" +
+	"Subject: Order 42\r\n" +
+	"\r\n" +
+	"Where is my order?\r\n"
+
+func TestHandleEmailForwards(t *testing.T) {
+	msg := functest.NewMockEmail("alice@example.com", "support@example.com", sampleEmail)
+	if err := handleEmail(context.Background(), msg.EmailMessage); err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Forwarded(); len(got) != 1 || got[0] != forwardTo {
+		t.Fatalf("forwarded to %v, want %s", got, forwardTo)
+	}
+}
+
+func TestHandleEmailRejectsSpam(t *testing.T) {
+	msg := functest.NewMockEmail("spam@example.net", "support@example.com", "X-Spam-Flag: YES\r\n"+sampleEmail)
+	if err := handleEmail(context.Background(), msg.EmailMessage); err != nil {
+		t.Fatal(err)
+	}
+	if reason, ok := msg.Rejected(); !ok || reason == "" {
+		t.Fatal("spam was not rejected")
+	}
+	if got := msg.Forwarded(); len(got) != 0 {
+		t.Fatalf("spam was forwarded to %v", got)
+	}
+}
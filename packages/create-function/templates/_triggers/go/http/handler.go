@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+type helloResponse struct {
+	Message string `json:"message"`
+}
+
+func handleRequest(w http.ResponseWriter, r *http.Request) {
+	functions.WriteJSON(w, http.StatusOK, helloResponse{Message: "Hello World!"})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dot-do/functions/packages/functions-go/functest"
+)
+
+// handler.go builds on every platform, so handlers are tested with a plain
+// `go test ./...`; only main.go needs the WASM toolchain.
+func TestHandleRequest(t *testing.T) {
+	resp := functest.Do(http.HandlerFunc(handleRequest), functest.NewRequest(http.MethodGet, "/", nil))
+	if resp.Status() != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.Status())
+	}
+
+	var body helloResponse
+	if err := resp.JSON(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != "Hello World!" {
+		t.Fatalf("message = %q, want %q", body.Message, "Hello World!")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// job is the message format this consumer expects. Producers enqueue it
+// with functions.NewQueue("QUEUE") and Send.
+type job struct {
+	Task string `json:"task"`
+}
+
+// handleQueue receives batches from the queue listed under
+// [[queues.consumers]] in wrangler.toml. Acking each message as it succeeds
+// means a failure retries only the messages that were not acked.
+func handleQueue(ctx context.Context, batch *functions.QueueBatch) error {
+	for _, msg := range batch.Messages {
+		var j job
+		if err := msg.Decode(&j); err != nil {
+			// A malformed message will never succeed; drop it.
+			log.Printf("dropping malformed message %s: %v", msg.ID, err)
+			msg.Ack()
+			continue
+		}
+		log.Printf("processing %q from %s (attempt %d)", j.Task, batch.Queue, msg.Attempts)
+		msg.Ack()
+	}
+	return nil
+}
@@ -2,4 +2,4 @@ module {{project_name}}
 
 go 1.21
 
-require github.com/syumai/workers v0.26.0
+require github.com/syumai/workers v0.31.0
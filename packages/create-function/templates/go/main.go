@@ -1,17 +0,0 @@
-package main
-
-import (
-	"net/http"
-
-	"github.com/syumai/workers"
-)
-
-func main() {
-	http.HandleFunc("/", handleRequest)
-	workers.Serve(nil)
-}
-
-func handleRequest(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
-	w.Write([]byte("Hello World!"))
-}
@@ -0,0 +1,116 @@
+package functions
+
+import "fmt"
+
+// Limits on a single Analytics Engine data point.
+const (
+	analyticsMaxIndexes    = 1
+	analyticsMaxIndexBytes = 96
+	analyticsMaxBlobs      = 20
+	analyticsMaxBlobBytes  = 16 << 10 // all blobs together
+	analyticsMaxDoubles    = 20
+)
+
+// AnalyticsEngine is a Cloudflare Workers Analytics Engine dataset, for
+// writing custom metrics that are queried later with SQL.
+//
+// The binding name is the `binding` of an `[[analytics_engine_datasets]]`
+// entry in wrangler.toml:
+//
+//	[[analytics_engine_datasets]]
+//	binding = "METRICS"
+//	dataset = "api_requests"
+//
+// which is opened with NewAnalyticsEngine("METRICS").
+type AnalyticsEngine struct {
+	binding string
+	ae      analyticsBackend
+}
+
+// AnalyticsDataPoint is one event written to Analytics Engine. In SQL the
+// index is column index1, and blobs and doubles are columns blob1 to
+// blob20 and double1 to double20, in order.
+type AnalyticsDataPoint struct {
+	// Indexes holds at most one index of up to 96 bytes, the key that
+	// samples are grouped by, such as a customer ID.
+	Indexes []string
+	// Blobs holds up to 20 strings, such as a path or a country code,
+	// totalling at most 16 KiB.
+	Blobs []string
+	// Doubles holds up to 20 numbers, such as a latency or a byte count.
+	Doubles []float64
+}
+
+// analyticsBackend is the platform-specific half of AnalyticsEngine.
+type analyticsBackend interface {
+	writeDataPoint(dp AnalyticsDataPoint) error
+}
+
+// NewAnalyticsEngine opens the Analytics Engine dataset bound to the Worker
+// under binding. It returns ErrBindingNotFound if no such binding is
+// configured.
+func NewAnalyticsEngine(binding string) (*AnalyticsEngine, error) {
+	ae, err := openAnalyticsEngine(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalyticsEngine{binding: binding, ae: ae}, nil
+}
+
+// NewAnalyticsEngineWithWriter returns an AnalyticsEngine that passes each
+// valid data point to write instead of a dataset, for tests and local
+// development. name takes the place of the binding name in errors.
+func NewAnalyticsEngineWithWriter(name string, write func(dp AnalyticsDataPoint) error) *AnalyticsEngine {
+	return &AnalyticsEngine{binding: name, ae: analyticsWriter(write)}
+}
+
+// WriteDataPoint queues dp to be written to the dataset. It returns an
+// error, and writes nothing, if dp exceeds the limits documented on
+// AnalyticsDataPoint.
+//
+// Writes are fire-and-forget: WriteDataPoint returns before the data point
+// is stored, and there is no delivery guarantee, so a nil error does not
+// mean the point will be queryable. Analytics Engine may also sample
+// heavily written indexes. A Worker invocation can write at most 250 data
+// points; the runtime rejects any beyond that.
+func (a *AnalyticsEngine) WriteDataPoint(dp AnalyticsDataPoint) error {
+	if err := validateDataPoint(dp); err != nil {
+		return fmt.Errorf("functions: Analytics Engine %s writeDataPoint: %w", a.binding, err)
+	}
+	if err := a.ae.writeDataPoint(dp); err != nil {
+		return fmt.Errorf("functions: Analytics Engine %s writeDataPoint: %w", a.binding, err)
+	}
+	return nil
+}
+
+func validateDataPoint(dp AnalyticsDataPoint) error {
+	if len(dp.Indexes) > analyticsMaxIndexes {
+		return fmt.Errorf("data point has %d indexes, the limit is %d", len(dp.Indexes), analyticsMaxIndexes)
+	}
+	for _, idx := range dp.Indexes {
+		if len(idx) > analyticsMaxIndexBytes {
+			return fmt.Errorf("index is %d bytes, the limit is %d", len(idx), analyticsMaxIndexBytes)
+		}
+	}
+	if len(dp.Blobs) > analyticsMaxBlobs {
+		return fmt.Errorf("data point has %d blobs, the limit is %d", len(dp.Blobs), analyticsMaxBlobs)
+	}
+	total := 0
+	for _, b := range dp.Blobs {
+		total += len(b)
+	}
+	if total > analyticsMaxBlobBytes {
+		return fmt.Errorf("blobs total %d bytes, the limit is %d", total, analyticsMaxBlobBytes)
+	}
+	if len(dp.Doubles) > analyticsMaxDoubles {
+		return fmt.Errorf("data point has %d doubles, the limit is %d", len(dp.Doubles), analyticsMaxDoubles)
+	}
+	return nil
+}
+
+// analyticsWriter backs NewAnalyticsEngineWithWriter.
+type analyticsWriter func(dp AnalyticsDataPoint) error
+
+func (f analyticsWriter) writeDataPoint(dp AnalyticsDataPoint) error {
+	return f(dp)
+}
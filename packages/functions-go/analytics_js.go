@@ -0,0 +1,44 @@
+//go:build js && wasm
+
+package functions
+
+import "syscall/js"
+
+type jsAnalyticsEngine struct {
+	ae js.Value
+}
+
+func openAnalyticsEngine(binding string) (analyticsBackend, error) {
+	ae, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsAnalyticsEngine{ae: ae}, nil
+}
+
+func (a *jsAnalyticsEngine) writeDataPoint(dp AnalyticsDataPoint) (err error) {
+	// writeDataPoint throws if the runtime rejects the data point.
+	defer func() {
+		if r := recover(); r != nil {
+			err = jsPanicError(r)
+		}
+	}()
+	point := js.Global().Get("Object").New()
+	point.Set("indexes", stringsToJS(dp.Indexes))
+	point.Set("blobs", stringsToJS(dp.Blobs))
+	doubles := js.Global().Get("Array").New(len(dp.Doubles))
+	for i, d := range dp.Doubles {
+		doubles.SetIndex(i, d)
+	}
+	point.Set("doubles", doubles)
+	a.ae.Call("writeDataPoint", point)
+	return nil
+}
+
+func stringsToJS(ss []string) js.Value {
+	arr := js.Global().Get("Array").New(len(ss))
+	for i, s := range ss {
+		arr.SetIndex(i, s)
+	}
+	return arr
+}
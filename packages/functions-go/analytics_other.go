@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openAnalyticsEngine(binding string) (analyticsBackend, error) {
+	return nil, fmt.Errorf("%w: %q (Analytics Engine is only available in the Workers runtime; use NewAnalyticsEngineWithWriter to record data points in-process)", ErrBindingNotFound, binding)
+}
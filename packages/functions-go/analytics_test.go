@@ -0,0 +1,69 @@
+package functions
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyticsEngineWriteDataPoint(t *testing.T) {
+	var got []AnalyticsDataPoint
+	ae := NewAnalyticsEngineWithWriter("METRICS", func(dp AnalyticsDataPoint) error {
+		got = append(got, dp)
+		return nil
+	})
+	dp := AnalyticsDataPoint{
+		Indexes: []string{"customer-42"},
+		Blobs:   []string{"/checkout", "PT"},
+		Doubles: []float64{12.5, 1},
+	}
+	if err := ae.WriteDataPoint(dp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []AnalyticsDataPoint{dp}) {
+		t.Fatalf("written = %+v", got)
+	}
+}
+
+func TestAnalyticsEngineLimits(t *testing.T) {
+	tests := map[string]AnalyticsDataPoint{
+		"two indexes": {Indexes: []string{"a", "b"}},
+		"long index":  {Indexes: []string{strings.Repeat("x", 97)}},
+		"21 blobs":    {Blobs: make([]string, 21)},
+		"large blobs": {Blobs: []string{strings.Repeat("x", 10<<10), strings.Repeat("x", 7<<10)}},
+		"21 doubles":  {Doubles: make([]float64, 21)},
+	}
+	for name, dp := range tests {
+		t.Run(name, func(t *testing.T) {
+			written := false
+			ae := NewAnalyticsEngineWithWriter("METRICS", func(AnalyticsDataPoint) error {
+				written = true
+				return nil
+			})
+			if err := ae.WriteDataPoint(dp); err == nil {
+				t.Fatal("over-limit data point accepted")
+			}
+			if written {
+				t.Fatal("over-limit data point was written")
+			}
+		})
+	}
+
+	full := AnalyticsDataPoint{
+		Indexes: []string{strings.Repeat("x", 96)},
+		Blobs:   make([]string, 20),
+		Doubles: make([]float64, 20),
+	}
+	full.Blobs[0] = strings.Repeat("x", 16<<10)
+	ae := NewAnalyticsEngineWithWriter("METRICS", func(AnalyticsDataPoint) error { return nil })
+	if err := ae.WriteDataPoint(full); err != nil {
+		t.Fatalf("data point at every limit rejected: %v", err)
+	}
+}
+
+func TestNewAnalyticsEngineNative(t *testing.T) {
+	if _, err := NewAnalyticsEngine("METRICS"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
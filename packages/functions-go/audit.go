@@ -0,0 +1,195 @@
+package functions
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAuditBodyBytes is how much of each body AuditLog captures, if
+// AuditOptions.MaxBodyBytes is zero.
+const DefaultMaxAuditBodyBytes int64 = 64 << 10
+
+// auditRedacted replaces the values of redacted headers.
+const auditRedacted = "[REDACTED]"
+
+// AuditOptions configures the AuditLog middleware.
+type AuditOptions struct {
+	// Sink receives one entry per audited request. It is required.
+	Sink AuditSink
+
+	// Paths lists the URL path prefixes whose requests are audited, such as
+	// "/api/payments/". If empty, every request is.
+	Paths []string
+
+	// MaxBodyBytes is how much of the request and of the response body is
+	// captured. If zero, DefaultMaxAuditBodyBytes is used.
+	MaxBodyBytes int64
+
+	// KeepPrefix, if set, keeps the first MaxBodyBytes of a longer body in
+	// the entry. Otherwise a body over MaxBodyBytes is left out, so that
+	// part of a payload is never taken for the whole of it.
+	KeepPrefix bool
+
+	// RedactHeaders lists headers whose values are replaced with
+	// "[REDACTED]" in entries, in addition to Authorization, Cookie,
+	// Proxy-Authorization and Set-Cookie, which always are.
+	RedactHeaders []string
+}
+
+// AuditSink receives audit entries, for example to write them to R2 or a
+// queue. It runs after the response has been written, with the request's
+// context.
+type AuditSink func(ctx context.Context, entry AuditEntry)
+
+// AuditEntry records one request and its response.
+type AuditEntry struct {
+	Time      time.Time
+	Duration  time.Duration
+	RequestID string
+	Method    string
+	// URL is the request URL as received, with its query.
+	URL    string
+	Status int
+
+	// RequestHeader and ResponseHeader are copies with redacted values.
+	RequestHeader  http.Header
+	ResponseHeader http.Header
+
+	// RequestBody and ResponseBody hold each body. The Truncated flags
+	// report one longer than MaxBodyBytes, which is nil unless KeepPrefix
+	// kept its start.
+	RequestBody       []byte
+	RequestTruncated  bool
+	ResponseBody      []byte
+	ResponseTruncated bool
+}
+
+// AuditLog returns middleware that records requests and their responses,
+// bodies included, for compliance logging:
+//
+//	audited := functions.AuditLog(functions.AuditOptions{
+//		Paths: []string{"/api/payments/"},
+//		Sink: func(ctx context.Context, e functions.AuditEntry) {
+//			b, _ := json.Marshal(e)
+//			auditQueue.Send(ctx, json.RawMessage(b))
+//		},
+//	})
+//
+// The request body is copied as the handler reads it, so the handler sees
+// exactly the bytes the client sent, and whatever part of the first
+// MaxBodyBytes it leaves unread is read once it returns. The response body
+// is copied as it is written. Neither copy grows past MaxBodyBytes; a larger
+// body is left out of the entry with its Truncated flag set, or leaves its
+// start there if KeepPrefix is set.
+//
+// AuditLog panics if opts.Sink is nil.
+func AuditLog(opts AuditOptions) Middleware {
+	if opts.Sink == nil {
+		panic("functions: AuditLog needs a Sink")
+	}
+	maxBody := opts.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = DefaultMaxAuditBodyBytes
+	}
+	redact := map[string]bool{"Authorization": true, "Cookie": true, "Proxy-Authorization": true, "Set-Cookie": true}
+	for _, h := range opts.RedactHeaders {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auditedPath(r.URL.Path, opts.Paths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			entry := AuditEntry{
+				Time:          start,
+				RequestID:     RequestID(r.Context()),
+				Method:        r.Method,
+				URL:           r.URL.String(),
+				RequestHeader: redactHeader(r.Header, redact),
+			}
+
+			var reqBody *auditBody
+			if r.Body != nil && r.Body != http.NoBody {
+				reqBody = &auditBody{rc: r.Body, buf: limitedBuffer{max: maxBody}}
+				r.Body = reqBody
+			}
+			aw := newCopyWriter(w, maxBody)
+			next.ServeHTTP(aw, r)
+
+			if reqBody != nil {
+				reqBody.drain()
+				entry.RequestBody, entry.RequestTruncated = auditedBody(&reqBody.buf, opts.KeepPrefix)
+			}
+			entry.Status = aw.Status()
+			entry.ResponseHeader = redactHeader(aw.header(), redact)
+			entry.ResponseBody, entry.ResponseTruncated = auditedBody(&aw.body, opts.KeepPrefix)
+			entry.Duration = time.Since(start)
+			opts.Sink(r.Context(), entry)
+		})
+	}
+}
+
+// auditedBody returns what an entry records of a captured body.
+func auditedBody(b *limitedBuffer, keepPrefix bool) (body []byte, truncated bool) {
+	if b.truncated && !keepPrefix {
+		return nil, true
+	}
+	return b.bytes(), b.truncated
+}
+
+func auditedPath(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeader(h http.Header, redact map[string]bool) http.Header {
+	out := h.Clone()
+	for k := range out {
+		if redact[k] {
+			out[k] = []string{auditRedacted}
+		}
+	}
+	return out
+}
+
+// auditBody passes a request body through to the handler unchanged while
+// keeping a copy of it.
+type auditBody struct {
+	rc  io.ReadCloser
+	buf limitedBuffer
+	eof bool
+}
+
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	b.buf.write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *auditBody) Close() error { return b.rc.Close() }
+
+// drain reads what the handler left of the body, as far as the copy has
+// room for, so that the entry has the body the client sent.
+func (b *auditBody) drain() {
+	if b.eof || b.buf.truncated {
+		return
+	}
+	room := b.buf.max - int64(b.buf.buf.Len())
+	io.Copy(io.Discard, io.LimitReader(b, room+1))
+}
@@ -0,0 +1,144 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func auditedHandler(t *testing.T, opts AuditOptions, h http.HandlerFunc) (http.Handler, func() []AuditEntry) {
+	t.Helper()
+	var entries []AuditEntry
+	opts.Sink = func(ctx context.Context, e AuditEntry) { entries = append(entries, e) }
+	return AuditLog(opts)(h), func() []AuditEntry { return entries }
+}
+
+func TestAuditLogCapturesBothSides(t *testing.T) {
+	var seen []byte
+	h, entries := auditedHandler(t, AuditOptions{RedactHeaders: []string{"x-api-key"}}, func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":"ch_1",`)
+		io.WriteString(w, `"amount":100}`)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/charges?dry_run=1", strings.NewReader(`{"amount":100}`))
+	r.Header.Set("Authorization", "Bearer t0ken")
+	r.Header.Set("X-Api-Key", "k3y")
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if string(seen) != `{"amount":100}` {
+		t.Errorf("handler read %q", seen)
+	}
+	if w.Body.String() != `{"id":"ch_1","amount":100}` || w.Result().Cookies()[0].Value != "secret" {
+		t.Errorf("client got %q %v; the response must be unchanged", w.Body, w.Header())
+	}
+
+	e := entries()
+	if len(e) != 1 {
+		t.Fatalf("got %d entries", len(e))
+	}
+	got := e[0]
+	if got.Method != http.MethodPost || got.URL != "/charges?dry_run=1" || got.Status != http.StatusCreated {
+		t.Errorf("entry = %s %s %d", got.Method, got.URL, got.Status)
+	}
+	if string(got.RequestBody) != `{"amount":100}` || string(got.ResponseBody) != `{"id":"ch_1","amount":100}` {
+		t.Errorf("bodies = %q / %q", got.RequestBody, got.ResponseBody)
+	}
+	if got.RequestTruncated || got.ResponseTruncated {
+		t.Error("small bodies marked truncated")
+	}
+	for _, h := range []string{"Authorization", "X-Api-Key"} {
+		if v := got.RequestHeader.Get(h); v != "[REDACTED]" {
+			t.Errorf("request %s = %q", h, v)
+		}
+	}
+	if got.RequestHeader.Get("Content-Type") != "application/json" {
+		t.Errorf("request header = %v", got.RequestHeader)
+	}
+	if got.ResponseHeader.Get("Set-Cookie") != "[REDACTED]" || got.ResponseHeader.Get("Content-Type") != "application/json" {
+		t.Errorf("response header = %v", got.ResponseHeader)
+	}
+	if r.Header.Get("Authorization") != "Bearer t0ken" {
+		t.Error("redaction changed the request itself")
+	}
+}
+
+func TestAuditLogLeavesBodyUnchanged(t *testing.T) {
+	// Binary data larger than the cap, read in small odd-sized chunks.
+	body := make([]byte, 10_000)
+	rand.New(rand.NewSource(1)).Read(body)
+
+	var seen bytes.Buffer
+	h, entries := auditedHandler(t, AuditOptions{MaxBodyBytes: 4096}, func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 7)
+		for {
+			n, err := r.Body.Read(buf)
+			seen.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		w.Write(body)
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/blob", bytes.NewReader(body)))
+
+	if !bytes.Equal(seen.Bytes(), body) {
+		t.Fatalf("handler read %d bytes differing from the %d sent", seen.Len(), len(body))
+	}
+	e := entries()[0]
+	if e.RequestBody != nil || !e.RequestTruncated {
+		t.Errorf("request body: %d bytes, truncated %v; want none and truncated", len(e.RequestBody), e.RequestTruncated)
+	}
+	if e.ResponseBody != nil || !e.ResponseTruncated {
+		t.Errorf("response body: %d bytes, truncated %v; want none and truncated", len(e.ResponseBody), e.ResponseTruncated)
+	}
+}
+
+func TestAuditLogKeepPrefix(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789"), 1000)
+	h, entries := auditedHandler(t, AuditOptions{MaxBodyBytes: 4096, KeepPrefix: true}, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(body)
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/blob", bytes.NewReader(body)))
+
+	e := entries()[0]
+	if !bytes.Equal(e.RequestBody, body[:4096]) || !e.RequestTruncated {
+		t.Errorf("request body: %d bytes, truncated %v; want the first 4096 and truncated", len(e.RequestBody), e.RequestTruncated)
+	}
+	if !bytes.Equal(e.ResponseBody, body[:4096]) || !e.ResponseTruncated {
+		t.Errorf("response body: %d bytes, truncated %v; want the first 4096 and truncated", len(e.ResponseBody), e.ResponseTruncated)
+	}
+}
+
+func TestAuditLogReadsWhatHandlerSkipped(t *testing.T) {
+	h, entries := auditedHandler(t, AuditOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized) // rejects without reading
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/charges", strings.NewReader("amount=100")))
+
+	e := entries()[0]
+	if string(e.RequestBody) != "amount=100" || e.Status != http.StatusUnauthorized {
+		t.Errorf("entry = %d %q", e.Status, e.RequestBody)
+	}
+}
+
+func TestAuditLogPaths(t *testing.T) {
+	h, entries := auditedHandler(t, AuditOptions{Paths: []string{"/api/payments/"}}, func(w http.ResponseWriter, r *http.Request) {})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/payments/1", nil))
+
+	if e := entries(); len(e) != 1 || e[0].URL != "/api/payments/1" || e[0].Status != http.StatusOK {
+		t.Fatalf("entries = %+v", e)
+	}
+}
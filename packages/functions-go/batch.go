@@ -0,0 +1,219 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxBatchRequests is the most sub-requests BatchHandler accepts in one
+	// batch.
+	MaxBatchRequests = 20
+
+	// BatchTimeout bounds the time BatchHandler waits for a whole batch.
+	BatchTimeout = 10 * time.Second
+)
+
+// batchRequest is one element of the array a batch is posted as.
+type batchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchResponse is one element of the array BatchHandler answers with.
+type batchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type batchKey struct{}
+
+// BatchHandler returns a handler that serves several requests to router in
+// one round trip. It accepts a POST whose body is a JSON array of
+// sub-requests:
+//
+//	[
+//		{"method": "GET", "path": "/users/42"},
+//		{"method": "POST", "path": "/events", "body": {"type": "view"}}
+//	]
+//
+// and answers with an array of their responses, in the same order:
+//
+//	[
+//		{"status": 200, "headers": {"Content-Type": "application/json"}, "body": {"id": "42"}},
+//		{"status": 202}
+//	]
+//
+// Sub-requests run concurrently and in memory, dispatched straight to
+// router. They carry the batch request's headers, so its credentials apply
+// to each, overridden by their own headers. A body is sent as JSON. A
+// response body is embedded as is if it is JSON, and as a string otherwise.
+//
+// Middleware wrapped around the router does not see the sub-requests; it
+// sees the batch request, which is served by BatchHandler. Register the
+// handlers with the middleware they need instead.
+//
+// A batch has at most MaxBatchRequests entries. Sub-requests that have not
+// finished after BatchTimeout get a 504 entry, and a sub-request that
+// panics gets a 500 entry; neither keeps the others from completing. A
+// sub-request cannot itself be a batch.
+func BatchHandler(router *Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			WriteError(w, r, &Error{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: "batches must be POSTed"})
+			return
+		}
+		if r.Context().Value(batchKey{}) != nil {
+			WriteError(w, r, BadRequest("batches cannot be nested"))
+			return
+		}
+		var reqs []batchRequest
+		if err := DecodeJSON(r, &reqs); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if len(reqs) > MaxBatchRequests {
+			WriteError(w, r, BadRequest(fmt.Sprintf("a batch has at most %d requests", MaxBatchRequests)))
+			return
+		}
+		subs := make([]*http.Request, len(reqs))
+		for i, br := range reqs {
+			sub, err := newBatchSubrequest(r, br)
+			if err != nil {
+				WriteError(w, r, BadRequest(fmt.Sprintf("request %d: %v", i, err)))
+				return
+			}
+			subs[i] = sub
+		}
+
+		ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), batchKey{}, true), BatchTimeout)
+		defer cancel()
+		type result struct {
+			i    int
+			resp batchResponse
+		}
+		finished := make(chan result, len(subs))
+		for i, sub := range subs {
+			sub = sub.WithContext(ctx)
+			go func(i int, sub *http.Request) {
+				finished <- result{i, serveBatchSubrequest(router, sub)}
+			}(i, sub)
+		}
+
+		resps := make([]batchResponse, len(subs))
+		done := make([]bool, len(subs))
+	collect:
+		for range subs {
+			select {
+			case res := <-finished:
+				resps[res.i], done[res.i] = res.resp, true
+			case <-ctx.Done():
+				break collect
+			}
+		}
+		for i, ok := range done {
+			if !ok {
+				// The sub-request may still be running, so the entry is
+				// written against the batch request.
+				rec := &serviceRecorder{header: make(http.Header)}
+				WriteError(rec, r, &Error{Status: http.StatusGatewayTimeout, Code: "batch_timeout", Message: "the request did not finish within the batch's time limit"})
+				resps[i] = batchResponseFrom(rec)
+			}
+		}
+		WriteJSON(w, http.StatusOK, resps)
+	})
+}
+
+// newBatchSubrequest builds the request br describes, as a server would have
+// received it alongside the batch request r.
+func newBatchSubrequest(r *http.Request, br batchRequest) (*http.Request, error) {
+	if !strings.HasPrefix(br.Path, "/") {
+		return nil, fmt.Errorf("path %q must start with '/'", br.Path)
+	}
+	u, err := r.URL.Parse(br.Path)
+	if err != nil {
+		return nil, err
+	}
+	method := br.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	sub := r.Clone(r.Context())
+	sub.Method = strings.ToUpper(method)
+	sub.URL = u
+	sub.RequestURI = u.RequestURI()
+	sub.Header.Del("Content-Type")
+	sub.Header.Del("Content-Length")
+	sub.Header.Del("Content-Encoding")
+	for k, v := range br.Headers {
+		sub.Header.Set(k, v)
+	}
+	sub.Body, sub.ContentLength = http.NoBody, 0
+	if len(br.Body) > 0 && !bytes.Equal(br.Body, []byte("null")) {
+		sub.Body = io.NopCloser(bytes.NewReader(br.Body))
+		sub.ContentLength = int64(len(br.Body))
+		if sub.Header.Get("Content-Type") == "" {
+			sub.Header.Set("Content-Type", "application/json")
+		}
+	}
+	return sub, nil
+}
+
+// serveBatchSubrequest runs one sub-request, turning a panic into a 500 as
+// Recover would.
+func serveBatchSubrequest(router *Router, sub *http.Request) (resp batchResponse) {
+	rec := &serviceRecorder{header: make(http.Header)}
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		stack := debug.Stack()
+		slog.Default().Error("batch sub-request panic",
+			"method", sub.Method,
+			"path", sub.URL.Path,
+			"panic", fmt.Sprint(recovered),
+			"stack", string(stack),
+		)
+		reportAsync(sub.Context(), func(ctx context.Context, rep Reporter) {
+			rep.CapturePanic(ctx, recovered, stack)
+		})
+		rec = &serviceRecorder{header: make(http.Header)}
+		writePanicResponse(rec, sub)
+		resp = batchResponseFrom(rec)
+	}()
+	router.ServeHTTP(rec, sub)
+	return batchResponseFrom(rec)
+}
+
+func batchResponseFrom(rec *serviceRecorder) batchResponse {
+	rec.WriteHeader(http.StatusOK)
+	resp := batchResponse{Status: rec.status}
+	if len(rec.sent) > 0 {
+		resp.Headers = make(map[string]string, len(rec.sent))
+		for k, v := range rec.sent {
+			resp.Headers[k] = strings.Join(v, ", ")
+		}
+	}
+	body := rec.body.Bytes()
+	switch {
+	case len(body) == 0:
+	case isJSONMediaType(rec.sent.Get("Content-Type")) && json.Valid(body):
+		resp.Body = json.RawMessage(bytes.TrimSpace(body))
+	default:
+		resp.Body, _ = json.Marshal(string(body))
+	}
+	return resp
+}
@@ -0,0 +1,160 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func batchRouter() *Router {
+	rt := NewRouter()
+	rt.Get("/users/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]string{"id": Param(r, "id"), "auth": r.Header.Get("Authorization")})
+	}))
+	rt.Post("/echo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Type", r.Header.Get("Content-Type"))
+		io.Copy(w, r.Body)
+	}))
+	rt.Get("/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rt.Get("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	return rt
+}
+
+func postBatch(t *testing.T, h http.Handler, ctx context.Context, body string) (*httptest.ResponseRecorder, []batchResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer t")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var resps []batchResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resps); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w, resps
+}
+
+func TestBatchMixed(t *testing.T) {
+	h := BatchHandler(batchRouter())
+	w, resps := postBatch(t, h, context.Background(), `[
+		{"method": "GET", "path": "/users/42"},
+		{"method": "GET", "path": "/missing"},
+		{"method": "POST", "path": "/echo", "body": {"a": 1}},
+		{"path": "/users/7", "headers": {"Authorization": "Bearer other"}}
+	]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if len(resps) != 4 {
+		t.Fatalf("got %d responses, want 4", len(resps))
+	}
+
+	if resps[0].Status != http.StatusOK || string(resps[0].Body) != `{"auth":"Bearer t","id":"42"}` {
+		t.Errorf("user response = %d %s", resps[0].Status, resps[0].Body)
+	}
+	if !strings.HasPrefix(resps[0].Headers["Content-Type"], "application/json") {
+		t.Errorf("user Content-Type = %q", resps[0].Headers["Content-Type"])
+	}
+	if resps[1].Status != http.StatusNotFound {
+		t.Errorf("missing status = %d, want 404", resps[1].Status)
+	}
+	if resps[2].Status != http.StatusOK || string(resps[2].Body) != `"{\"a\": 1}"` {
+		t.Errorf("echo response = %d %s", resps[2].Status, resps[2].Body)
+	}
+	if got := resps[2].Headers["X-Type"]; got != "application/json" {
+		t.Errorf("echo request Content-Type = %q", got)
+	}
+	if string(resps[3].Body) != `{"auth":"Bearer other","id":"7"}` {
+		t.Errorf("override response = %s", resps[3].Body)
+	}
+}
+
+func TestBatchIsolatesPanic(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+
+	h := BatchHandler(batchRouter())
+	_, resps := postBatch(t, h, context.Background(), `[{"path": "/panic"}, {"path": "/users/1"}]`)
+	if len(resps) != 2 {
+		t.Fatalf("got %d responses, want 2", len(resps))
+	}
+	if resps[0].Status != http.StatusInternalServerError {
+		t.Errorf("panic status = %d, want 500", resps[0].Status)
+	}
+	if resps[1].Status != http.StatusOK {
+		t.Errorf("status after panic = %d, want 200", resps[1].Status)
+	}
+}
+
+func TestBatchTimeout(t *testing.T) {
+	h := BatchHandler(batchRouter())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, resps := postBatch(t, h, ctx, `[{"path": "/slow"}, {"path": "/users/1"}]`)
+	if len(resps) != 2 {
+		t.Fatalf("got %d responses, want 2", len(resps))
+	}
+	if resps[0].Status != http.StatusGatewayTimeout {
+		t.Errorf("slow status = %d, want 504", resps[0].Status)
+	}
+	if resps[1].Status != http.StatusOK {
+		t.Errorf("fast status = %d, want 200", resps[1].Status)
+	}
+}
+
+func TestBatchRejects(t *testing.T) {
+	rt := batchRouter()
+	rt.Post("/batch", BatchHandler(rt))
+	h := BatchHandler(rt)
+
+	tooMany := make([]string, MaxBatchRequests+1)
+	for i := range tooMany {
+		tooMany[i] = `{"path": "/users/1"}`
+	}
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{"not an array", `{"path": "/users/1"}`},
+		{"too many", "[" + strings.Join(tooMany, ",") + "]"},
+		{"relative path", `[{"path": "users/1"}]`},
+	} {
+		w, _ := postBatch(t, h, context.Background(), tt.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.name, w.Code)
+		}
+	}
+
+	_, resps := postBatch(t, h, context.Background(), `[{"method": "POST", "path": "/batch", "body": []}]`)
+	if len(resps) != 1 || resps[0].Status != http.StatusBadRequest {
+		t.Errorf("nested batch = %+v, want a 400 entry", resps)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/batch", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: status = %d, Allow = %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestBatchEmpty(t *testing.T) {
+	w, resps := postBatch(t, BatchHandler(batchRouter()), context.Background(), `[]`)
+	if w.Code != http.StatusOK || len(resps) != 0 {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != "[]" {
+		t.Errorf("body = %s, want []", got)
+	}
+}
@@ -0,0 +1,240 @@
+package functions
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxFormBytes bounds the in-memory part of multipart forms parsed by
+// BindForm.
+const DefaultMaxFormBytes int64 = 32 << 20
+
+// MissingParamsError reports required parameters that were absent or empty.
+type MissingParamsError struct {
+	Params []string
+}
+
+func (e *MissingParamsError) Error() string {
+	return "functions: missing required parameters: " + strings.Join(e.Params, ", ")
+}
+
+// ParamError reports a parameter whose value could not be converted to the
+// type of its field.
+type ParamError struct {
+	Param string
+	Value string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("functions: invalid value %q for parameter %q: %v", e.Value, e.Param, e.Err)
+}
+
+func (e *ParamError) Unwrap() error { return e.Err }
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// BindQuery copies the URL query parameters of r into v, a pointer to a
+// struct.
+//
+// Fields are matched by their query tag, or by field name when untagged,
+// ignoring case; a tag of "-" skips the field. Supported field types are
+// strings, booleans, integers, floats, time.Time in RFC 3339 format,
+// time.Duration, types implementing encoding.TextUnmarshaler, pointers to
+// those, and slices of those, which collect repeated parameters:
+//
+//	type listParams struct {
+//		Limit  int      `query:"limit"`
+//		Tags   []string `query:"tag"`
+//		Cursor string   `query:"cursor,required"`
+//	}
+//
+// Parameters that are absent leave their field unchanged. If any field
+// marked required is absent or empty, a *MissingParamsError naming all of
+// them is returned. A value that doesn't convert is a *ParamError.
+//
+// BindQuery does not read the request body, so it can be combined with
+// DecodeJSON and Param in the same handler.
+func BindQuery(r *http.Request, v any) error {
+	return bindValues(r.URL.Query(), v, "query")
+}
+
+// BindForm is like BindQuery but reads the form values in the request body,
+// either URL-encoded or multipart. Fields are matched by their form tag,
+// falling back to the query tag so one struct can serve both.
+func BindForm(r *http.Request, v any) error {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var err error
+	if ct == "multipart/form-data" {
+		err = r.ParseMultipartForm(DefaultMaxFormBytes)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return fmt.Errorf("functions: parsing form: %w", err)
+	}
+	return bindValues(r.PostForm, v, "form", "query")
+}
+
+func bindValues(values url.Values, v any, tags ...string) error {
+	dv := reflect.ValueOf(v)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("functions: bind destination must be a pointer to a struct, got %T", v)
+	}
+
+	// Merge keys differing only in case, in sorted order so repeated
+	// values always come out the same way.
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lower := make(map[string][]string, len(values))
+	for _, k := range keys {
+		lk := strings.ToLower(k)
+		lower[lk] = append(lower[lk], values[k]...)
+	}
+
+	var missing []string
+	if err := bindStruct(dv.Elem(), lower, tags, &missing); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return &MissingParamsError{Params: missing}
+	}
+	return nil
+}
+
+func bindStruct(sv reflect.Value, values map[string][]string, tags []string, missing *[]string) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		tag, tagged := bindTag(field, tags)
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && !tagged && field.Type.Kind() == reflect.Struct {
+			if err := bindStruct(sv.Field(i), values, tags, missing); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		vs := values[strings.ToLower(name)]
+		if len(vs) == 0 || (len(vs) == 1 && vs[0] == "") {
+			if opts == "required" {
+				*missing = append(*missing, name)
+			}
+			continue
+		}
+		if err := setParam(sv.Field(i), vs); err != nil {
+			value := vs[0]
+			if pe, ok := err.(*ParamError); ok {
+				value, err = pe.Value, pe.Err
+			}
+			return &ParamError{Param: name, Value: value, Err: err}
+		}
+	}
+	return nil
+}
+
+func bindTag(field reflect.StructField, tags []string) (string, bool) {
+	for _, t := range tags {
+		if tag, ok := field.Tag.Lookup(t); ok {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+func setParam(fv reflect.Value, vs []string) error {
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		out := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setScalar(out.Index(i), s); err != nil {
+				return &ParamError{Value: s, Err: err}
+			}
+		}
+		fv.Set(out)
+		return nil
+	}
+	return setScalar(fv, vs[len(vs)-1])
+}
+
+func setScalar(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Pointer {
+		p := reflect.New(fv.Type().Elem())
+		if err := setScalar(p.Elem(), s); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	if fv.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return errors.New("want an RFC 3339 time")
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("want a boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return errors.New("want a duration")
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("want an integer of %d bits", fv.Type().Bits())
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("want an unsigned integer of %d bits", fv.Type().Bits())
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return errors.New("want a number")
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
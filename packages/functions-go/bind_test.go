@@ -0,0 +1,156 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type searchParams struct {
+	Query   string    `query:"q,required"`
+	Limit   int       `query:"limit"`
+	Exact   bool      `query:"exact"`
+	MinRank float64   `query:"min_rank"`
+	Since   time.Time `query:"since"`
+	Tags    []string  `query:"tag"`
+	IDs     []int64   `query:"id"`
+	Page    *int      `query:"page"`
+	Timeout time.Duration
+	Ignored string `query:"-"`
+}
+
+func TestBindQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?q=go&limit=20&exact=true&min_rank=0.5&since=2024-05-01T10:00:00Z&tag=a&tag=b&ID=1&id=2&page=3&timeout=2s&Ignored=x", nil)
+
+	var p searchParams
+	if err := BindQuery(r, &p); err != nil {
+		t.Fatal(err)
+	}
+	page := 3
+	want := searchParams{
+		Query:   "go",
+		Limit:   20,
+		Exact:   true,
+		MinRank: 0.5,
+		Since:   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Tags:    []string{"a", "b"},
+		IDs:     []int64{1, 2},
+		Page:    &page,
+		Timeout: 2 * time.Second,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("got %+v\nwant %+v", p, want)
+	}
+}
+
+func TestBindQueryMissingRequired(t *testing.T) {
+	type params struct {
+		A string `query:"a,required"`
+		B int    `query:"b,required"`
+		C string `query:"c"`
+	}
+	var p params
+	err := BindQuery(httptest.NewRequest(http.MethodGet, "/?a=&c=x", nil), &p)
+
+	var missing *MissingParamsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("err = %v, want *MissingParamsError", err)
+	}
+	if !reflect.DeepEqual(missing.Params, []string{"a", "b"}) {
+		t.Fatalf("missing = %v", missing.Params)
+	}
+	if p.C != "x" {
+		t.Fatalf("C = %q; present fields should still be bound", p.C)
+	}
+}
+
+func TestBindQueryConversionErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		param string
+		value string
+	}{
+		{"limit=ten", "limit", "ten"},
+		{"exact=maybe", "exact", "maybe"},
+		{"min_rank=high", "min_rank", "high"},
+		{"since=yesterday", "since", "yesterday"},
+		{"id=1&id=x", "id", "x"},
+		{"page=first", "page", "first"},
+	}
+	for _, tt := range tests {
+		var p searchParams
+		err := BindQuery(httptest.NewRequest(http.MethodGet, "/?q=go&"+tt.query, nil), &p)
+		var pe *ParamError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: err = %v, want *ParamError", tt.query, err)
+			continue
+		}
+		if pe.Param != tt.param || pe.Value != tt.value {
+			t.Errorf("%s: ParamError{Param: %q, Value: %q}, want %q, %q", tt.query, pe.Param, pe.Value, tt.param, tt.value)
+		}
+	}
+}
+
+func TestBindQueryRejectsNonStruct(t *testing.T) {
+	var s string
+	if err := BindQuery(httptest.NewRequest(http.MethodGet, "/", nil), &s); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	type signup struct {
+		Email  string   `form:"email,required"`
+		Age    int      `query:"age"`
+		Topics []string `form:"topic"`
+	}
+	body := url.Values{"email": {"a@example.com"}, "age": {"30"}, "topic": {"go", "wasm"}}
+	r := httptest.NewRequest(http.MethodPost, "/?email=ignored@example.com", strings.NewReader(body.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var s signup
+	if err := BindForm(r, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := signup{Email: "a@example.com", Age: 30, Topics: []string{"go", "wasm"}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("got %+v, want %+v", s, want)
+	}
+}
+
+// TestBindAlongsideJSON binds path, query and body values in one handler.
+func TestBindAlongsideJSON(t *testing.T) {
+	type query struct {
+		DryRun bool `query:"dry_run"`
+	}
+	type body struct {
+		Name string `json:"name"`
+	}
+
+	rt := NewRouter()
+	rt.Put("/items/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q query
+		var b body
+		if err := BindQuery(r, &q); err != nil {
+			t.Error(err)
+		}
+		if err := DecodeJSON(r, &b); err != nil {
+			t.Error(err)
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"id": Param(r, "id"), "dry_run": q.DryRun, "name": b.Name})
+	}))
+
+	r := httptest.NewRequest(http.MethodPut, "/items/7?dry_run=1", strings.NewReader(`{"name":"lamp"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	if got := strings.TrimSpace(w.Body.String()); got != `{"dry_run":true,"id":"7","name":"lamp"}` {
+		t.Fatalf("body = %s", got)
+	}
+}
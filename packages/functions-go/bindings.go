@@ -0,0 +1,7 @@
+package functions
+
+import "errors"
+
+// ErrBindingNotFound is returned when a named binding is not configured for
+// the Worker, or when bindings are used outside the Workers runtime.
+var ErrBindingNotFound = errors.New("functions: binding not found")
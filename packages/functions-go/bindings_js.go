@@ -0,0 +1,126 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall/js"
+)
+
+// runtimeEnv returns the env object of the current Worker invocation, which
+// github.com/syumai/workers publishes on the global runtime context.
+func runtimeEnv() js.Value {
+	rc := js.Global().Get("context")
+	if rc.IsUndefined() || rc.IsNull() {
+		return js.Undefined()
+	}
+	return rc.Get("env")
+}
+
+// lookupBinding returns the env binding with the given name.
+func lookupBinding(name string) (js.Value, error) {
+	env := runtimeEnv()
+	if env.IsUndefined() {
+		return js.Value{}, fmt.Errorf("%w: %q (no runtime env; is the handler running under workers.Serve?)", ErrBindingNotFound, name)
+	}
+	v := env.Get(name)
+	if v.IsUndefined() || v.IsNull() {
+		return js.Value{}, fmt.Errorf("%w: %q", ErrBindingNotFound, name)
+	}
+	return v, nil
+}
+
+// awaitPromise blocks until p settles or ctx is done.
+func awaitPromise(ctx context.Context, p js.Value) (js.Value, error) {
+	type result struct {
+		v   js.Value
+		err error
+	}
+	ch := make(chan result, 1)
+
+	var onResolve, onReject js.Func
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			onResolve.Release()
+			onReject.Release()
+		})
+	}
+	onResolve = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		v := js.Undefined()
+		if len(args) > 0 {
+			v = args[0]
+		}
+		ch <- result{v: v}
+		release()
+		return nil
+	})
+	onReject = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		err := errors.New("functions: promise rejected")
+		if len(args) > 0 {
+			err = jsError(args[0])
+		}
+		ch <- result{err: err}
+		release()
+		return nil
+	})
+	p.Call("then", onResolve, onReject)
+
+	select {
+	case res := <-ch:
+		return res.v, res.err
+	case <-ctx.Done():
+		return js.Undefined(), ctx.Err()
+	}
+}
+
+// jsError converts a thrown JavaScript value into a Go error.
+func jsError(v js.Value) error {
+	if v.Type() == js.TypeObject {
+		if msg := v.Get("message"); msg.Type() == js.TypeString {
+			return errors.New(msg.String())
+		}
+	}
+	return errors.New(v.String())
+}
+
+func isNullish(v js.Value) bool {
+	return v.IsUndefined() || v.IsNull()
+}
+
+// bytesToJS copies b into a new ArrayBuffer.
+func bytesToJS(b []byte) js.Value {
+	u8 := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(u8, b)
+	return u8.Get("buffer")
+}
+
+// bytesFromJS copies an ArrayBuffer or typed array into a Go slice.
+func bytesFromJS(v js.Value) []byte {
+	u8 := js.Global().Get("Uint8Array").New(v)
+	b := make([]byte, u8.Get("byteLength").Int())
+	js.CopyBytesToGo(b, u8)
+	return b
+}
+
+// valueToJS converts a JSON-serializable Go value into a JavaScript value.
+func valueToJS(v any) (js.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return js.Value{}, err
+	}
+	return js.Global().Get("JSON").Call("parse", string(b)), nil
+}
+
+// rawJSONFromJS serializes a JavaScript value as JSON, returning nil for
+// null or undefined.
+func rawJSONFromJS(v js.Value) json.RawMessage {
+	if isNullish(v) {
+		return nil
+	}
+	return json.RawMessage(js.Global().Get("JSON").Call("stringify", v).String())
+}
@@ -0,0 +1,325 @@
+package functions
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long the Cache middleware keeps a response whose
+// Cache-Control sets no lifetime, if CacheOptions.TTL is zero.
+const DefaultCacheTTL = 5 * time.Minute
+
+// DefaultMaxCacheBodyBytes is the largest response body the Cache
+// middleware stores, if CacheOptions.MaxBodyBytes is zero.
+const DefaultMaxCacheBodyBytes int64 = 1 << 20
+
+// CacheOptions configures the Cache middleware.
+type CacheOptions struct {
+	// TTL is how long a response is kept when its Cache-Control has neither
+	// s-maxage nor max-age. If zero, DefaultCacheTTL is used.
+	TTL time.Duration
+
+	// Paths lists the URL path prefixes whose GET requests are cached, such
+	// as "/api/products/". If empty, every path is.
+	Paths []string
+
+	// KeyFunc returns the key a request's response is stored under. If nil,
+	// CacheKey(r) is used, which keys on the URL alone. Keys must be
+	// absolute URLs, as the Cloudflare Cache API stores responses by URL;
+	// CacheKey builds one that varies by request headers too. Requests for
+	// which it returns "" bypass the cache.
+	KeyFunc func(r *http.Request) string
+
+	// Store holds the responses. If nil, DefaultCacheStore is used.
+	Store CacheStore
+
+	// MaxBodyBytes is the largest response body that is stored. If zero,
+	// DefaultMaxCacheBodyBytes is used.
+	MaxBodyBytes int64
+
+	// Logger receives store errors. If nil, slog.Default is used.
+	Logger *slog.Logger
+}
+
+// CachedResponse is a response held in a CacheStore.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// CacheStore holds responses by key.
+type CacheStore interface {
+	// Match returns the response stored under key, or nil if there is none
+	// or it has expired.
+	Match(ctx context.Context, key string) (*CachedResponse, error)
+
+	// Put stores resp under key for ttl, replacing any earlier response.
+	Put(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+
+	// Delete removes the response stored under key, if any.
+	Delete(ctx context.Context, key string) error
+}
+
+// Cache returns middleware that serves GET requests from a cache of earlier
+// responses, for read-heavy endpoints:
+//
+//	products := functions.Cache(functions.CacheOptions{
+//		TTL:     time.Minute,
+//		KeyFunc: func(r *http.Request) string { return functions.CacheKey(r, "Accept-Language") },
+//	})
+//	rt.Handle(http.MethodGet, "/products/:id", products(getProduct))
+//
+// On a hit the stored response is written without calling the handler; on
+// a miss the handler runs and its response is stored on the way out. Either
+// way the response carries X-Cache: HIT or MISS. A response is stored only
+// if all of these hold:
+//
+//   - its status is 2xx, other than 206 Partial Content;
+//   - it has no Set-Cookie header and no Vary: *;
+//   - its Cache-Control has none of no-store, no-cache and private;
+//   - its body was not flushed early and is at most MaxBodyBytes long;
+//   - the request had no Authorization header, unless Cache-Control marks
+//     the response public or sets s-maxage.
+//
+// It is kept for the s-maxage or max-age of its Cache-Control, in that
+// order, or else for TTL. Requests that are not GET, that fall outside
+// Paths, that ask for a Range, or that send Cache-Control: no-store go
+// straight to the handler. If the store fails, the request is served by
+// the handler and the error logged.
+func Cache(opts CacheOptions) Middleware {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	maxBody := opts.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = DefaultMaxCacheBodyBytes
+	}
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string { return CacheKey(r) }
+	}
+	store := opts.Store
+	if store == nil {
+		store = DefaultCacheStore()
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cacheableRequest(r, opts.Paths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cached, err := store.Match(r.Context(), key)
+			if err != nil {
+				logger.Warn("cache lookup failed", "key", key, "error", err)
+			}
+			if cached != nil {
+				h := w.Header()
+				for k, vs := range cached.Header {
+					h[k] = append([]string(nil), vs...)
+				}
+				h.Set("X-Cache", "HIT")
+				w.WriteHeader(cached.Status)
+				w.Write(cached.Body)
+				return
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			cw := newCopyWriter(w, maxBody)
+			next.ServeHTTP(cw, r)
+
+			lifetime, ok := cacheLifetime(r, cw, ttl)
+			if !ok {
+				return
+			}
+			header := cw.sent.Clone()
+			header.Del("X-Cache")
+			resp := &CachedResponse{Status: cw.Status(), Header: header, Body: cw.body.bytes()}
+			if err := store.Put(r.Context(), key, resp, lifetime); err != nil {
+				logger.Warn("cache store failed", "key", key, "error", err)
+			}
+		})
+	}
+}
+
+// CacheKey returns the absolute URL of r, with the values of the named
+// request headers added as query parameters, for use as a cache key that
+// varies by those headers.
+func CacheKey(r *http.Request, vary ...string) string {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if len(vary) > 0 {
+		q := u.Query()
+		for _, name := range vary {
+			q.Set("vary-"+strings.ToLower(name), r.Header.Get(name))
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+func cacheableRequest(r *http.Request, paths []string) bool {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return false
+	}
+	if cacheControlHas(r.Header.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheLifetime reports whether the response recorded by cw may be stored,
+// and for how long.
+func cacheLifetime(r *http.Request, cw *copyWriter, ttl time.Duration) (time.Duration, bool) {
+	status := cw.Status()
+	if !cw.Written() || status > 299 || status == http.StatusPartialContent || !cw.complete() {
+		return 0, false
+	}
+	h := cw.sent
+	if h.Get("Set-Cookie") != "" || h.Get("Vary") == "*" {
+		return 0, false
+	}
+	cc := h.Get("Cache-Control")
+	if cacheControlHas(cc, "no-store") || cacheControlHas(cc, "no-cache") || cacheControlHas(cc, "private") {
+		return 0, false
+	}
+	sMaxAge, hasSMaxAge := cacheControlSeconds(cc, "s-maxage")
+	if r.Header.Get("Authorization") != "" && !hasSMaxAge && !cacheControlHas(cc, "public") {
+		return 0, false
+	}
+	if hasSMaxAge {
+		ttl = sMaxAge
+	} else if maxAge, ok := cacheControlSeconds(cc, "max-age"); ok {
+		ttl = maxAge
+	}
+	return ttl, ttl > 0
+}
+
+// cacheControlHas reports whether the Cache-Control value cc contains the
+// directive name, with or without an argument.
+func cacheControlHas(cc, name string) bool {
+	for _, d := range strings.Split(cc, ",") {
+		d, _, _ = strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControlSeconds returns the argument of the directive name in the
+// Cache-Control value cc.
+func cacheControlSeconds(cc, name string) (time.Duration, bool) {
+	for _, d := range strings.Split(cc, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(d), "=")
+		if !ok || !strings.EqualFold(k, name) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		return time.Duration(n) * time.Second, true
+	}
+	return 0, false
+}
+
+// MemoryCacheStore is a CacheStore held in process memory. Each Worker
+// isolate has its own, so it suits tests and local development; in the
+// Workers runtime DefaultCacheStore is shared by every isolate in a data
+// center.
+type MemoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	swept   time.Time
+	now     func() time.Time
+}
+
+type memoryCacheEntry struct {
+	resp    CachedResponse
+	expires time.Time
+}
+
+// NewMemoryCacheStore returns an empty MemoryCacheStore.
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{entries: make(map[string]memoryCacheEntry), now: time.Now}
+}
+
+// Match implements CacheStore.
+func (s *MemoryCacheStore) Match(ctx context.Context, key string) (*CachedResponse, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if !now.Before(e.expires) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return &CachedResponse{Status: e.resp.Status, Header: e.resp.Header.Clone(), Body: e.resp.Body}, nil
+}
+
+// Put implements CacheStore.
+func (s *MemoryCacheStore) Put(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.swept) >= time.Minute {
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	s.entries[key] = memoryCacheEntry{
+		resp: CachedResponse{
+			Status: resp.Status,
+			Header: resp.Header.Clone(),
+			Body:   append([]byte(nil), resp.Body...),
+		},
+		expires: now.Add(ttl),
+	}
+	return nil
+}
+
+// Delete implements CacheStore.
+func (s *MemoryCacheStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
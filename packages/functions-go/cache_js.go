@@ -0,0 +1,86 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"syscall/js"
+	"time"
+)
+
+// cacheControlKeep carries a stored response's own Cache-Control through the
+// Cache API, which is given one with the entry's lifetime instead.
+const cacheControlKeep = "X-Functions-Cache-Control"
+
+// DefaultCacheStore returns the Cloudflare Cache API's default cache,
+// caches.default, which is shared by every Worker isolate in a data center
+// but not across data centers. Outside the Workers runtime it returns a
+// process-wide MemoryCacheStore.
+func DefaultCacheStore() CacheStore {
+	return &jsCacheStore{cache: js.Global().Get("caches").Get("default")}
+}
+
+type jsCacheStore struct {
+	cache js.Value
+}
+
+func (s *jsCacheStore) Match(ctx context.Context, key string) (*CachedResponse, error) {
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	v, err := awaitPromise(ctx, s.cache.Call("match", key))
+	if err != nil || isNullish(v) {
+		return nil, err
+	}
+	resp := responseFromJS(ctx, v)
+	buf, err := awaitPromise(ctx, v.Call("arrayBuffer"))
+	if err != nil {
+		return nil, err
+	}
+	h := resp.Header
+	h.Del("Cache-Control")
+	if cc := h.Get(cacheControlKeep); cc != "" {
+		h.Set("Cache-Control", cc)
+	}
+	h.Del(cacheControlKeep)
+	return &CachedResponse{Status: resp.StatusCode, Header: h, Body: bytesFromJS(buf)}, nil
+}
+
+func (s *jsCacheStore) Put(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	if err := takeSubrequest(ctx); err != nil {
+		return err
+	}
+	headers := js.Global().Get("Headers").New()
+	for k, vs := range resp.Header {
+		if http.CanonicalHeaderKey(k) == "Cache-Control" {
+			continue
+		}
+		for _, v := range vs {
+			headers.Call("append", k, v)
+		}
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "" {
+		headers.Call("set", cacheControlKeep, cc)
+	}
+	headers.Call("set", "Cache-Control", "s-maxage="+strconv.FormatInt(max(int64(ttl/time.Second), 1), 10))
+
+	init := js.Global().Get("Object").New()
+	init.Set("status", resp.Status)
+	init.Set("headers", headers)
+	body := js.Null()
+	if len(resp.Body) > 0 {
+		body = bytesToJS(resp.Body)
+	}
+	_, err := awaitPromise(ctx, s.cache.Call("put", key, js.Global().Get("Response").New(body, init)))
+	return err
+}
+
+func (s *jsCacheStore) Delete(ctx context.Context, key string) error {
+	if err := takeSubrequest(ctx); err != nil {
+		return err
+	}
+	_, err := awaitPromise(ctx, s.cache.Call("delete", key))
+	return err
+}
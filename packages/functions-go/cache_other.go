@@ -0,0 +1,13 @@
+//go:build !js || !wasm
+
+package functions
+
+var defaultCacheStore = NewMemoryCacheStore()
+
+// DefaultCacheStore returns the Cloudflare Cache API's default cache,
+// caches.default, which is shared by every Worker isolate in a data center
+// but not across data centers. Outside the Workers runtime it returns a
+// process-wide MemoryCacheStore.
+func DefaultCacheStore() CacheStore {
+	return defaultCacheStore
+}
@@ -0,0 +1,176 @@
+package functions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// countingHandler counts its calls and reports the count in its body.
+type countingHandler struct {
+	calls int
+	fn    func(w http.ResponseWriter, r *http.Request)
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	if h.fn != nil {
+		h.fn(w, r)
+	}
+	w.Write([]byte("call " + strconv.Itoa(h.calls)))
+}
+
+func cacheGet(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func newTestCache() (*MemoryCacheStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryCacheStore()
+	store.now = clock.now
+	return store, clock
+}
+
+func TestCacheHitAndExpiry(t *testing.T) {
+	store, clock := newTestCache()
+	next := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+	}}
+	h := Cache(CacheOptions{TTL: time.Minute, Store: store})(next)
+
+	w := cacheGet(h, "/products/1")
+	if w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "call 1" {
+		t.Fatalf("first: %s %q", w.Header().Get("X-Cache"), w.Body)
+	}
+	w = cacheGet(h, "/products/1")
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "call 1" || next.calls != 1 {
+		t.Fatalf("second: %s %q after %d calls", w.Header().Get("X-Cache"), w.Body, next.calls)
+	}
+	if w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("hit headers = %v", w.Header())
+	}
+	if w := cacheGet(h, "/products/2"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("different URL was a hit")
+	}
+
+	clock.advance(time.Minute)
+	if w := cacheGet(h, "/products/1"); w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "call 3" {
+		t.Fatalf("after TTL: %s %q", w.Header().Get("X-Cache"), w.Body)
+	}
+}
+
+func TestCacheHonorsCacheControl(t *testing.T) {
+	store, clock := newTestCache()
+	next := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=10")
+	}}
+	h := Cache(CacheOptions{TTL: time.Hour, Store: store})(next)
+
+	cacheGet(h, "/")
+	clock.advance(9 * time.Second)
+	if w := cacheGet(h, "/"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("not a hit within max-age")
+	}
+	clock.advance(time.Second)
+	if w := cacheGet(h, "/"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("hit after max-age, want the handler's lifetime to override TTL")
+	}
+}
+
+func TestCacheSkipsUncacheableResponses(t *testing.T) {
+	tests := map[string]func(w http.ResponseWriter, r *http.Request){
+		"set-cookie": func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		},
+		"no-store": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+		},
+		"private": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		},
+		"error status": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		"not found": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+		"flushed": func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+		},
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			store, _ := newTestCache()
+			next := &countingHandler{fn: fn}
+			h := Cache(CacheOptions{Store: store})(next)
+			cacheGet(h, "/")
+			if w := cacheGet(h, "/"); w.Header().Get("X-Cache") != "MISS" || next.calls != 2 {
+				t.Fatalf("second request: %s after %d calls, want MISS", w.Header().Get("X-Cache"), next.calls)
+			}
+		})
+	}
+}
+
+func TestCacheBypass(t *testing.T) {
+	store, _ := newTestCache()
+	next := &countingHandler{}
+	h := Cache(CacheOptions{Store: store, Paths: []string{"/api/"}})(next)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/items", nil),
+		httptest.NewRequest(http.MethodGet, "/other", nil),
+	} {
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Header().Get("X-Cache") != "" {
+				t.Fatalf("%s %s: X-Cache = %q, want the cache bypassed", r.Method, r.URL, w.Header().Get("X-Cache"))
+			}
+		}
+	}
+	cacheGet(h, "/api/items", "Cache-Control", "no-store")
+	if w := cacheGet(h, "/api/items"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("request with no-store was stored")
+	}
+	cacheGet(h, "/api/me", "Authorization", "Bearer x")
+	if w := cacheGet(h, "/api/me"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("response to an authorized request was stored")
+	}
+}
+
+func TestCacheKeyFunc(t *testing.T) {
+	store, _ := newTestCache()
+	next := &countingHandler{}
+	h := Cache(CacheOptions{
+		Store:   store,
+		KeyFunc: func(r *http.Request) string { return CacheKey(r, "Accept-Language") },
+	})(next)
+
+	cacheGet(h, "/", "Accept-Language", "en")
+	if w := cacheGet(h, "/", "Accept-Language", "pt"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("pt served the en response")
+	}
+	if w := cacheGet(h, "/", "Accept-Language", "en"); w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "call 1" {
+		t.Fatalf("en: %s %q", w.Header().Get("X-Cache"), w.Body)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/a?b=1", nil)
+	r.Host = "example.com"
+	r.Header.Set("Accept-Language", "en")
+	if got := CacheKey(r); got != "https://example.com/a?b=1" {
+		t.Errorf("CacheKey = %q", got)
+	}
+	if got := CacheKey(r, "Accept-Language"); got != "https://example.com/a?b=1&vary-accept-language=en" {
+		t.Errorf("CacheKey(Accept-Language) = %q", got)
+	}
+}
@@ -0,0 +1,311 @@
+package functions
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is the smallest response body Compress encodes when
+// CompressOptions.MinSize is zero.
+const DefaultCompressMinSize = 1024
+
+// CompressOptions configures the Compress middleware.
+type CompressOptions struct {
+	// MinSize is the smallest body, in bytes, worth compressing. Smaller
+	// responses are sent as is. If zero, DefaultCompressMinSize is used.
+	MinSize int
+
+	// Level is the gzip compression level. If zero, gzip.DefaultCompression
+	// is used.
+	Level int
+
+	// Brotli, if set, creates a brotli encoder writing to w, and "br" is
+	// negotiated in preference to gzip. The standard library has no brotli
+	// implementation, so plug one in, for example:
+	//
+	//	Brotli: func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+	//
+	// If the returned writer has a Flush() error method it is used when the
+	// handler flushes.
+	Brotli func(w io.Writer) io.WriteCloser
+}
+
+// Compress returns middleware that compresses response bodies with brotli or
+// gzip, according to the request's Accept-Encoding.
+//
+// Responses are left alone if they are smaller than the minimum size, are
+// already compressed media such as images, video or archives, or already set
+// a Content-Encoding. A compressed response has its Content-Length removed.
+// Flushing commits to compressing, so streamed responses are encoded
+// regardless of size. If the handler panics, a response it has not yet
+// committed is discarded rather than sent.
+func Compress(opts CompressOptions) Middleware {
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultCompressMinSize
+	}
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	gzipPool := sync.Pool{New: func() any {
+		// NewWriterLevel only fails for an invalid level.
+		zw, err := gzip.NewWriterLevel(io.Discard, level)
+		if err != nil {
+			panic("functions: invalid gzip level " + strconv.Itoa(level))
+		}
+		return zw
+	}}
+	// Fail at construction rather than on the first request.
+	gzipPool.Put(gzipPool.Get())
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), opts.Brotli != nil)
+			if encoding == "" || r.Method == http.MethodHead {
+				addVary(w.Header(), "Accept-Encoding")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{rec: NewResponseRecorder(w), encoding: encoding, minSize: opts.MinSize}
+			cw.newEncoder = func(dst io.Writer) io.WriteCloser {
+				if encoding == "br" {
+					return opts.Brotli(dst)
+				}
+				zw := gzipPool.Get().(*gzip.Writer)
+				zw.Reset(dst)
+				return &pooledGzip{Writer: zw, pool: &gzipPool}
+			}
+			next.ServeHTTP(cw, r)
+			// Not deferred: if next panics, whatever it buffered is
+			// dropped uncommitted, so that an outer Recover can still
+			// send its 500.
+			cw.close()
+		})
+	}
+}
+
+// negotiateEncoding picks "br" or "gzip" from an Accept-Encoding header,
+// preferring the higher q-value and brotli on a tie. It returns "" if
+// neither is acceptable.
+func negotiateEncoding(accept string, brotli bool) string {
+	var gz, br, any float64 = -1, -1, -1
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "x-gzip":
+			gz = q
+		case "br":
+			br = q
+		case "*":
+			any = q
+		}
+	}
+	if gz < 0 {
+		gz = any
+	}
+	if br < 0 {
+		br = any
+	}
+	if !brotli {
+		br = 0
+	}
+	switch {
+	case br > 0 && br >= gz:
+		return "br"
+	case gz > 0:
+		return "gzip"
+	}
+	return ""
+}
+
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), value) || strings.TrimSpace(f) == "*" {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+// incompressible reports whether a content type is already compressed.
+func incompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "font/woff"):
+		return true
+	}
+	switch mediaType {
+	case "application/zip", "application/gzip", "application/x-gzip",
+		"application/zstd", "application/x-7z-compressed", "application/x-rar-compressed",
+		"application/pdf":
+		return true
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is worth compressing, then either encodes it or passes it through.
+// The status is held back with the buffered body; rec commits the response
+// once that is decided.
+type compressWriter struct {
+	rec        *ResponseRecorder
+	encoding   string
+	minSize    int
+	newEncoder func(io.Writer) io.WriteCloser
+
+	status int
+	buf    []byte
+	enc    io.WriteCloser
+}
+
+func (cw *compressWriter) Header() http.Header { return cw.rec.Header() }
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.rec.Written() || cw.status != 0 {
+		return
+	}
+	if status < 200 {
+		cw.rec.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.rec.Written() {
+		if len(cw.buf)+len(p) < cw.minSize {
+			cw.buf = append(cw.buf, p...)
+			return len(p), nil
+		}
+		cw.buf = append(cw.buf, p...)
+		if err := cw.commit(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.rec.Write(p)
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.rec.Written() {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.commit(true)
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	cw.rec.Flush()
+}
+
+// Hijack takes over the connection, as for a WebSocket. Nothing buffered is
+// sent, and nothing is compressed once the connection is hijacked.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, brw, err := cw.rec.Hijack()
+	if err == nil {
+		cw.buf = nil
+	}
+	return c, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.rec }
+
+// commit sends the headers and any buffered body. big reports whether the
+// body has reached the minimum size.
+func (cw *compressWriter) commit(big bool) error {
+	h := cw.rec.Header()
+	addVary(h, "Accept-Encoding")
+
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff now: once encoded the body would no longer be recognisable.
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if big && cw.compressible() {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		cw.enc = cw.newEncoder(cw.rec)
+	}
+	cw.rec.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.rec.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	switch {
+	case cw.status == http.StatusNoContent, cw.status == http.StatusNotModified,
+		cw.status == http.StatusPartialContent:
+		return false
+	}
+	h := cw.rec.Header()
+	return h.Get("Content-Encoding") == "" && !incompressible(h.Get("Content-Type"))
+}
+
+func (cw *compressWriter) close() {
+	if !cw.rec.Written() {
+		if cw.status == 0 {
+			// The handler wrote nothing; let the server send its default.
+			addVary(cw.rec.Header(), "Accept-Encoding")
+			return
+		}
+		cw.commit(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
+
+// pooledGzip returns its gzip.Writer to the pool when closed.
+type pooledGzip struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+func (z *pooledGzip) Close() error {
+	err := z.Writer.Close()
+	z.Writer.Reset(io.Discard)
+	z.pool.Put(z.Writer)
+	return err
+}
@@ -0,0 +1,215 @@
+package functions
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var largeJSON = `{"items":[` + strings.Repeat(`{"name":"widget","price":9.99},`, 200) + `{}]}`
+
+func compressRequest(t *testing.T, mw Middleware, accept string, h http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		r.Header.Set("Accept-Encoding", accept)
+	}
+	w := httptest.NewRecorder()
+	mw(h).ServeHTTP(w, r)
+	return w
+}
+
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCompressGzipRoundTrip(t *testing.T) {
+	w := compressRequest(t, Compress(CompressOptions{}), "gzip, deflate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(largeJSON)))
+		w.WriteHeader(http.StatusCreated)
+		// Write in pieces straddling the size threshold.
+		io.WriteString(w, largeJSON[:100])
+		io.WriteString(w, largeJSON[100:])
+	})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("Content-Encoding = %q", ce)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		t.Fatalf("Content-Length = %q, want it removed", cl)
+	}
+	if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+		t.Fatalf("Vary = %q", v)
+	}
+	if w.Body.Len() >= len(largeJSON) {
+		t.Fatalf("compressed size %d is not smaller than %d", w.Body.Len(), len(largeJSON))
+	}
+	if got := gunzip(t, w.Body.Bytes()); got != largeJSON {
+		t.Fatal("decompressed body does not match")
+	}
+}
+
+// fakeBrotli stands in for a real brotli encoder; it gzips under the hood so
+// the test can round-trip the body.
+func fakeBrotli(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+
+func TestCompressPrefersBrotli(t *testing.T) {
+	mw := Compress(CompressOptions{Brotli: fakeBrotli})
+	handler := func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, largeJSON) }
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"gzip, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0.1", "gzip"},
+		{"*", "br"},
+		{"identity", ""},
+		{"gzip;q=0", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		w := compressRequest(t, mw, tt.accept, handler)
+		if got := w.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want %q", tt.accept, got, tt.want)
+			continue
+		}
+		if tt.want != "" && gunzip(t, w.Body.Bytes()) != largeJSON {
+			t.Errorf("Accept-Encoding %q: body does not round-trip", tt.accept)
+		}
+	}
+
+	// Without a brotli encoder, br is never offered.
+	w := compressRequest(t, Compress(CompressOptions{}), "br", handler)
+	if ce := w.Header().Get("Content-Encoding"); ce != "" {
+		t.Fatalf("Content-Encoding = %q without a brotli encoder", ce)
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"below min size", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":true}`)
+		}},
+		{"image", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, largeJSON)
+		}},
+		{"handler encoded", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, largeJSON)
+		}},
+		{"no content", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := httptest.NewRecorder()
+			tt.handler(ref, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			w := compressRequest(t, Compress(CompressOptions{}), "gzip", tt.handler)
+			if w.Code != ref.Code {
+				t.Fatalf("status = %d, want %d", w.Code, ref.Code)
+			}
+			if ce, refCE := w.Header().Get("Content-Encoding"), ref.Header().Get("Content-Encoding"); ce != refCE {
+				t.Fatalf("Content-Encoding = %q, want %q", ce, refCE)
+			}
+			if !bytes.Equal(w.Body.Bytes(), ref.Body.Bytes()) {
+				t.Fatal("body was modified")
+			}
+		})
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	w := compressRequest(t, Compress(CompressOptions{}), "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "data: 2\n\n")
+	})
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("flushed = %v, Content-Encoding = %q", w.Flushed, w.Header().Get("Content-Encoding"))
+	}
+	if got := gunzip(t, w.Body.Bytes()); got != "data: 1\n\ndata: 2\n\n" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+func TestCompressPanicLeavesResponseToRecover(t *testing.T) {
+	h := Recover(RecoverOptions{Logger: discardLogger()})(Compress(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		panic("boom")
+	})))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "partial") {
+		t.Fatalf("body = %q, want the partial response dropped", w.Body.String())
+	}
+}
+
+func TestCompressWebSocket(t *testing.T) {
+	done := make(chan error, 1)
+	h := Compress(CompressOptions{})(echoHandler(done))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// dialWS sends no Accept-Encoding, which would bypass Compress.
+		r.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c := dialWS(t, srv.URL)
+	msg := strings.Repeat("uncompressed ", 200)
+	c.send(true, TextMessage, []byte(msg))
+	if op, p := c.recv(); op != TextMessage || string(p) != msg {
+		t.Fatalf("got %d, %d bytes", op, len(p))
+	}
+	c.send(true, CloseMessage, binary.BigEndian.AppendUint16(nil, CloseNormalClosure))
+	c.recv()
+	<-done
+}
+
+func TestCompressSniffsContentType(t *testing.T) {
+	html := "<!DOCTYPE html><html><body>" + strings.Repeat("<p>hello</p>", 200) + "</body></html>"
+	w := compressRequest(t, Compress(CompressOptions{}), "gzip", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, html)
+	})
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if gunzip(t, w.Body.Bytes()) != html {
+		t.Fatal("body does not round-trip")
+	}
+}
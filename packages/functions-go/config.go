@@ -0,0 +1,192 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ConfigFieldError reports a configuration field LoadConfig could not load:
+// its value did not convert to the field's type, or failed validation.
+type ConfigFieldError struct {
+	// Field is the path of the field, named as Validate names it, such as
+	// "DB.Port".
+	Field string
+	// Env is the environment variable the field is read from, or "" for a
+	// field without an env tag.
+	Env string
+	// Err is the conversion error, or the FieldError of the failed rule.
+	Err error
+}
+
+func (e ConfigFieldError) Error() string {
+	if e.Env == "" {
+		return fmt.Sprintf("%s: %v", e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Env, e.Field, e.Err)
+}
+
+func (e ConfigFieldError) Unwrap() error { return e.Err }
+
+// ConfigError is returned by LoadConfig for configuration with invalid
+// fields. It lists one ConfigFieldError per field: those whose value did
+// not convert, then those that failed validation, each in struct field
+// order.
+type ConfigError []ConfigFieldError
+
+func (e ConfigError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "functions: invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// LoadConfig fills v, a pointer to a struct, from Env, and then checks it
+// with Validate. It is meant to run once at startup:
+//
+//	type config struct {
+//		APIKey  string        `env:"API_KEY" validate:"required"`
+//		Timeout time.Duration `env:"TIMEOUT" default:"30s"`
+//		DB      dbConfig      `env:"DB_"`
+//	}
+//
+//	type dbConfig struct {
+//		Host string `env:"HOST" validate:"required"`
+//		Port int    `env:"PORT" default:"5432"`
+//	}
+//
+// Each field with an env tag is read from the environment variable it
+// names, or if that is unset or empty, from its default tag. A field with
+// neither keeps its value, so v may come with defaults already set. The
+// env tag of a struct field is a prefix for the names inside it, DB_HOST
+// and DB_PORT above; struct fields without one are read with their
+// parent's prefix. A nil pointer to a struct is only allocated if one of
+// the variables inside it is set, so optional sections can stay nil.
+//
+// Values convert as BindQuery converts parameters, so durations are written
+// like "30s"; slices take comma-separated lists.
+//
+// LoadConfig reports every field that fails: if some do not convert or do
+// not pass their validate rules, it returns a ConfigError listing all of
+// them. A malformed validate tag is reported as a plain error.
+func LoadConfig(v any) error {
+	return loadConfig(Env, v)
+}
+
+func loadConfig(env Environment, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("functions: LoadConfig destination must be a pointer to a struct, got %T", v)
+	}
+	l := configLoader{env: env, vars: make(map[string]string), failed: make(map[string]bool)}
+	l.loadStruct(rv.Elem(), "", "")
+
+	err := Validate(v)
+	var verrs ValidationErrors
+	if err != nil && !errors.As(err, &verrs) {
+		return err
+	}
+	for _, fe := range verrs {
+		if !l.failed[fe.Field] {
+			l.errs = append(l.errs, ConfigFieldError{Field: fe.Field, Env: l.vars[fe.Field], Err: fe})
+		}
+	}
+	if len(l.errs) > 0 {
+		return l.errs
+	}
+	return nil
+}
+
+type configLoader struct {
+	env    Environment
+	vars   map[string]string // environment variable of each field path
+	failed map[string]bool   // field paths that did not convert
+	errs   ConfigError
+}
+
+// loadStruct loads the fields of sv. path is the Validate-style path of sv
+// and prefix the prefix of the environment variables inside it. It reports
+// whether any of those variables was set.
+func (l *configLoader) loadStruct(sv reflect.Value, path, prefix string) bool {
+	st := sv.Type()
+	set := false
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		name, tagged := sf.Tag.Lookup("env")
+		if name == "-" {
+			continue
+		}
+		fv := sv.Field(i)
+		if sf.Anonymous && !tagged && sf.Type.Kind() == reflect.Struct {
+			set = l.loadStruct(fv, path, prefix) || set
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		fieldPath := path + validateFieldName(sf)
+		if isConfigStruct(sf.Type) {
+			if fv.Kind() == reflect.Pointer && fv.IsNil() {
+				// A nil section stays nil unless something in it is set.
+				p := reflect.New(sf.Type.Elem())
+				if l.loadStruct(p.Elem(), fieldPath+".", prefix+name) {
+					fv.Set(p)
+					set = true
+				}
+				continue
+			}
+			set = l.loadStruct(reflect.Indirect(fv), fieldPath+".", prefix+name) || set
+			continue
+		}
+		if !tagged {
+			continue
+		}
+
+		key := prefix + name
+		l.vars[fieldPath] = key
+		s, ok := l.env.Lookup(key)
+		if ok && s != "" {
+			set = true
+		} else if s, ok = sf.Tag.Lookup("default"); !ok {
+			continue
+		}
+		if err := setConfigValue(fv, s); err != nil {
+			l.failed[fieldPath] = true
+			l.errs = append(l.errs, ConfigFieldError{Field: fieldPath, Env: key, Err: err})
+		}
+	}
+	return set
+}
+
+// isConfigStruct reports whether a field of type t holds nested
+// configuration rather than a single value.
+func isConfigStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setConfigValue converts s into fv, splitting it on commas for slices. The
+// value itself is left out of errors, as it may be a secret.
+func setConfigValue(fv reflect.Value, s string) error {
+	var vs []string
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		for _, part := range strings.Split(s, ",") {
+			vs = append(vs, strings.TrimSpace(part))
+		}
+	} else {
+		if fv.Kind() != reflect.String {
+			s = strings.TrimSpace(s)
+		}
+		vs = []string{s}
+	}
+	err := setParam(fv, vs)
+	var pe *ParamError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return err
+}
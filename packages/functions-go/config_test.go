@@ -0,0 +1,129 @@
+package functions
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDBConfig struct {
+	Host string `env:"HOST" validate:"required"`
+	Port int    `env:"PORT" default:"5432" validate:"min=1,max=65535"`
+}
+
+type testConfig struct {
+	APIKey   string        `env:"API_KEY" validate:"required"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"30s"`
+	Debug    bool          `env:"DEBUG"`
+	Origins  []string      `env:"ORIGINS"`
+	Region   string        `env:"REGION"`
+	DB       testDBConfig  `env:"DB_"`
+	Replica  *testDBConfig `env:"REPLICA_"`
+	internal string
+}
+
+func TestLoadConfig(t *testing.T) {
+	env := mapEnv(map[string]string{
+		"API_KEY":      "secret",
+		"DEBUG":        "true",
+		"ORIGINS":      "https://a.example, https://b.example",
+		"DB_HOST":      "db.internal",
+		"REPLICA_HOST": "replica.internal",
+		"REPLICA_PORT": "6432",
+	})
+	cfg := testConfig{Region: "eu"}
+	if err := loadConfig(env, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig{
+		APIKey:  "secret",
+		Timeout: 30 * time.Second,
+		Debug:   true,
+		Origins: []string{"https://a.example", "https://b.example"},
+		Region:  "eu",
+		DB:      testDBConfig{Host: "db.internal", Port: 5432},
+		Replica: &testDBConfig{Host: "replica.internal", Port: 6432},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v\nwant %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigEnvOverridesDefault(t *testing.T) {
+	env := mapEnv(map[string]string{"API_KEY": "k", "TIMEOUT": "1m30s", "DB_HOST": "h"})
+	var cfg testConfig
+	if err := loadConfig(env, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout != 90*time.Second {
+		t.Errorf("Timeout = %v, want 1m30s", cfg.Timeout)
+	}
+	if cfg.Replica != nil {
+		t.Errorf("Replica = %+v, want nil with no REPLICA_ variables set", cfg.Replica)
+	}
+}
+
+func TestLoadConfigReportsEveryField(t *testing.T) {
+	env := mapEnv(map[string]string{
+		"TIMEOUT":      "soon",
+		"DEBUG":        "maybe",
+		"DB_PORT":      "70000",
+		"REPLICA_HOST": "r",
+		"REPLICA_PORT": "x",
+	})
+	var cfg testConfig
+	err := loadConfig(env, &cfg)
+	var cerr ConfigError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("err = %v, want a ConfigError", err)
+	}
+
+	got := make([]string, len(cerr))
+	for i, fe := range cerr {
+		got[i] = fe.Env + " " + fe.Field
+	}
+	want := []string{
+		"TIMEOUT Timeout",
+		"DEBUG Debug",
+		"REPLICA_PORT Replica.Port",
+		"API_KEY APIKey",
+		"DB_HOST DB.Host",
+		"DB_PORT DB.Port",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields = %q\nwant %q", got, want)
+	}
+
+	if msg := cerr[0].Error(); msg != "TIMEOUT (Timeout): want a duration" {
+		t.Errorf("parse error = %q", msg)
+	}
+	var fe FieldError
+	if !errors.As(cerr[3], &fe) || fe.Rule != "required" {
+		t.Errorf("API_KEY error = %v, want the required rule", cerr[3])
+	}
+	if !strings.HasPrefix(err.Error(), "functions: invalid configuration: TIMEOUT") {
+		t.Errorf("Error() = %q", err)
+	}
+}
+
+func TestLoadConfigBadDefault(t *testing.T) {
+	var cfg struct {
+		Retries int `env:"RETRIES" default:"three"`
+	}
+	err := loadConfig(mapEnv(nil), &cfg)
+	var cerr ConfigError
+	if !errors.As(err, &cerr) || len(cerr) != 1 || cerr[0].Env != "RETRIES" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadConfigDestination(t *testing.T) {
+	var cfg testConfig
+	for _, v := range []any{cfg, (*testConfig)(nil), new(int)} {
+		if err := loadConfig(mapEnv(nil), v); err == nil || !strings.Contains(err.Error(), "pointer to a struct") {
+			t.Errorf("loadConfig(%T): %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package functions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinCookieSecretBytes is the shortest secret Cookies accepts.
+const MinCookieSecretBytes = 32
+
+// maxCookieBytes is the largest cookie, name and value, that browsers are
+// required to store.
+const maxCookieBytes = 4096
+
+// ErrInvalidCookie is returned by CookieJar.GetSigned and GetEncrypted for
+// a cookie that is present but was not set by the jar: its signature does
+// not verify, it does not decrypt, or it has expired. A missing cookie is
+// reported as http.ErrNoCookie instead.
+var ErrInvalidCookie = errors.New("functions: invalid cookie")
+
+// CookieOptions sets the attributes of a cookie written by a CookieJar.
+// The zero value, like a nil *CookieOptions, gives a Secure, HttpOnly,
+// SameSite=Lax cookie; weakening any of those takes an explicit field.
+type CookieOptions struct {
+	// MaxAge is how long the cookie lasts. The expiry is also sealed into
+	// the value, so a client cannot extend it. If zero, the cookie lasts
+	// until the browser session ends and never expires server-side.
+	MaxAge time.Duration
+
+	// Insecure lets the cookie be sent over plain HTTP. Otherwise it is
+	// Secure, restricted to HTTPS.
+	Insecure bool
+
+	// AllowJS lets JavaScript read the cookie. Otherwise it is HttpOnly.
+	AllowJS bool
+
+	// SameSite controls whether the cookie is sent with cross-site
+	// requests. If zero, http.SameSiteLaxMode is used. http.SameSiteNoneMode
+	// cannot be combined with Insecure.
+	SameSite http.SameSite
+
+	// Path is the URL path the cookie is sent for. If empty, "/" is used.
+	Path string
+
+	// Domain, if set, lets the cookie be sent to subdomains of it too.
+	Domain string
+}
+
+// DefaultCookieOptions returns the options used when none are given: a
+// Secure, HttpOnly, SameSite=Lax cookie for every path of the host that
+// set it, lasting the browser session. Setting only the fields that differ
+// keeps the rest of these:
+//
+//	err := jar.SetSigned(w, "session", id, &functions.CookieOptions{MaxAge: 24 * time.Hour})
+func DefaultCookieOptions() *CookieOptions {
+	return &CookieOptions{SameSite: http.SameSiteLaxMode, Path: "/"}
+}
+
+// CookieJar writes and reads cookies whose values are signed, so the
+// client cannot change them, or encrypted, so the client cannot read them
+// either. It is safe for concurrent use.
+type CookieJar struct {
+	keys []cookieKeys // current first
+	now  func() time.Time
+}
+
+type cookieKeys struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// Cookies returns a CookieJar keyed by secret, which must be at least
+// MinCookieSecretBytes long and should come from a Worker secret:
+//
+//	jar := functions.Cookies([]byte(functions.Env.MustString("COOKIE_SECRET")))
+//
+// To rotate the secret, pass the new one as secret and the old ones after
+// it. Cookies are then written with the new secret, while cookies written
+// with an old one still verify until it is removed, which should be once
+// the longest MaxAge has passed. Cookies panics if a secret is too short.
+func Cookies(secret []byte, previous ...[]byte) *CookieJar {
+	j := &CookieJar{now: time.Now}
+	for _, s := range append([][]byte{secret}, previous...) {
+		if len(s) < MinCookieSecretBytes {
+			panic("functions: cookie secret must be at least " + strconv.Itoa(MinCookieSecretBytes) + " bytes")
+		}
+		j.keys = append(j.keys, deriveCookieKeys(s))
+	}
+	return j
+}
+
+// deriveCookieKeys derives separate signing and encryption keys from
+// secret, so that neither use weakens the other.
+func deriveCookieKeys(secret []byte) cookieKeys {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("functions cookie encryption"))
+	if err != nil {
+		panic(err) // unreachable: the key is 32 bytes
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return cookieKeys{sign: derive("functions cookie signing"), aead: aead}
+}
+
+// SetSigned sets the cookie name to value, signed with HMAC-SHA256. The
+// value stays readable by the client, so it must not be secret; use
+// SetEncrypted for that. The signature covers the name, so a value signed
+// for one cookie is not accepted as another.
+//
+// It returns an error if the cookie would be longer than browsers store,
+// or opts asks for SameSite=None without Secure.
+func (j *CookieJar) SetSigned(w http.ResponseWriter, name, value string, opts *CookieOptions) error {
+	expires := j.expires(opts)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + strconv.FormatInt(expires, 10)
+	sig := j.sign(j.keys[0], name, payload)
+	return j.set(w, name, payload+"."+base64.RawURLEncoding.EncodeToString(sig), opts)
+}
+
+// GetSigned returns the value of the cookie name written by SetSigned. It
+// returns an error wrapping http.ErrNoCookie if the request has no such
+// cookie, and one wrapping ErrInvalidCookie if the cookie was tampered
+// with, signed by an unknown secret, or has expired.
+func (j *CookieJar) GetSigned(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", fmt.Errorf("functions: cookie %q: %w", name, err)
+	}
+	payload, sig64, ok := cutLastDot(c.Value)
+	if !ok {
+		return "", fmt.Errorf("%w: %q is not signed", ErrInvalidCookie, name)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sig64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q has a malformed signature", ErrInvalidCookie, name)
+	}
+	verified := false
+	for _, k := range j.keys {
+		if hmac.Equal(sig, j.sign(k, name, payload)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", fmt.Errorf("%w: %q has a bad signature", ErrInvalidCookie, name)
+	}
+
+	value64, expiresStr, _ := strings.Cut(payload, ".")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is malformed", ErrInvalidCookie, name)
+	}
+	if err := j.checkExpiry(name, expires); err != nil {
+		return "", err
+	}
+	value, err := base64.RawURLEncoding.DecodeString(value64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is malformed", ErrInvalidCookie, name)
+	}
+	return string(value), nil
+}
+
+// SetEncrypted sets the cookie name to value, encrypted and authenticated
+// with AES-256-GCM, so the client can neither read nor change it. Like
+// SetSigned, the cookie is bound to its name.
+func (j *CookieJar) SetEncrypted(w http.ResponseWriter, name, value string, opts *CookieOptions) error {
+	plain := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plain, uint64(j.expires(opts)))
+	plain = append(plain, value...)
+
+	aead := j.keys[0].aead
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("functions: cookie %q: %w", name, err)
+	}
+	sealed := aead.Seal(nonce, nonce, plain, []byte(name))
+	return j.set(w, name, base64.RawURLEncoding.EncodeToString(sealed), opts)
+}
+
+// GetEncrypted returns the value of the cookie name written by
+// SetEncrypted. Its errors are those of GetSigned.
+func (j *CookieJar) GetEncrypted(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", fmt.Errorf("functions: cookie %q: %w", name, err)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is malformed", ErrInvalidCookie, name)
+	}
+	for _, k := range j.keys {
+		n := k.aead.NonceSize()
+		if len(sealed) < n {
+			break
+		}
+		plain, err := k.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+		if err != nil || len(plain) < 8 {
+			continue
+		}
+		if err := j.checkExpiry(name, int64(binary.BigEndian.Uint64(plain))); err != nil {
+			return "", err
+		}
+		return string(plain[8:]), nil
+	}
+	return "", fmt.Errorf("%w: %q does not decrypt", ErrInvalidCookie, name)
+}
+
+// Delete tells the client to remove the cookie name. opts must have the
+// Path and Domain the cookie was set with.
+func (j *CookieJar) Delete(w http.ResponseWriter, name string, opts *CookieOptions) {
+	c := cookieFor(name, "", opts)
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+}
+
+func (j *CookieJar) set(w http.ResponseWriter, name, value string, opts *CookieOptions) error {
+	c := cookieFor(name, value, opts)
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		return fmt.Errorf("functions: cookie %q: SameSite=None cannot be Insecure", name)
+	}
+	if err := c.Valid(); err != nil {
+		return fmt.Errorf("functions: cookie %q: %w", name, err)
+	}
+	if len(name)+len(value) > maxCookieBytes {
+		return fmt.Errorf("functions: cookie %q is %d bytes, over the %d browsers store", name, len(name)+len(value), maxCookieBytes)
+	}
+	http.SetCookie(w, c)
+	return nil
+}
+
+func cookieFor(name, value string, opts *CookieOptions) *http.Cookie {
+	if opts == nil {
+		opts = DefaultCookieOptions()
+	}
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Secure:   !opts.Insecure,
+		HttpOnly: !opts.AllowJS,
+		SameSite: opts.SameSite,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	if opts.MaxAge > 0 {
+		c.MaxAge = int(opts.MaxAge / time.Second)
+	}
+	return c
+}
+
+// expires returns the Unix time a cookie set with opts expires at, or 0
+// if it does not.
+func (j *CookieJar) expires(opts *CookieOptions) int64 {
+	if opts == nil || opts.MaxAge <= 0 {
+		return 0
+	}
+	return j.now().Add(opts.MaxAge).Unix()
+}
+
+func (j *CookieJar) checkExpiry(name string, expires int64) error {
+	if expires != 0 && j.now().Unix() >= expires {
+		return fmt.Errorf("%w: %q has expired", ErrInvalidCookie, name)
+	}
+	return nil
+}
+
+func (j *CookieJar) sign(k cookieKeys, name, payload string) []byte {
+	mac := hmac.New(sha256.New, k.sign)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func cutLastDot(s string) (before, after string, ok bool) {
+	i := strings.LastIndexByte(s, '.')
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
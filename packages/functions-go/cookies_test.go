@@ -0,0 +1,187 @@
+package functions
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	cookieSecret    = bytes.Repeat([]byte("k"), 32)
+	oldCookieSecret = bytes.Repeat([]byte("o"), 32)
+)
+
+// replay returns a request carrying the cookies set on rec.
+func replay(rec *httptest.ResponseRecorder) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestSignedCookieRoundTrip(t *testing.T) {
+	jar := Cookies(cookieSecret)
+	rec := httptest.NewRecorder()
+	if err := jar.SetSigned(rec, "session", "user=42; admin=false", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rec.Result().Cookies()[0]
+	if !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+		t.Errorf("cookie %v is not hardened by default", c)
+	}
+	got, err := jar.GetSigned(replay(rec), "session")
+	if err != nil || got != "user=42; admin=false" {
+		t.Fatalf("GetSigned = %q, %v", got, err)
+	}
+}
+
+func TestSignedCookieRejectsTampering(t *testing.T) {
+	jar := Cookies(cookieSecret)
+	rec := httptest.NewRecorder()
+	jar.SetSigned(rec, "role", "member", nil)
+	signed := rec.Result().Cookies()[0].Value
+
+	_, sig, _ := cutLastDot(signed)
+	forged := strings.Replace(signed, signed[:strings.IndexByte(signed, '.')], "YWRtaW4", 1) // "admin"
+	for name, value := range map[string]string{
+		"forged value":  forged,
+		"no signature":  "YWRtaW4.0",
+		"bad signature": "YWRtaW4.0." + sig,
+		"other secret":  signedWith(t, oldCookieSecret, "role", "admin"),
+		"other name":    signedWith(t, cookieSecret, "theme", "admin"),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "role", Value: value})
+		if _, err := jar.GetSigned(req, "role"); !errors.Is(err, ErrInvalidCookie) {
+			t.Errorf("%s: err = %v, want ErrInvalidCookie", name, err)
+		}
+	}
+
+	_, err := jar.GetSigned(httptest.NewRequest(http.MethodGet, "/", nil), "role")
+	if !errors.Is(err, http.ErrNoCookie) || errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("missing cookie: err = %v, want http.ErrNoCookie", err)
+	}
+}
+
+func signedWith(t *testing.T, secret []byte, name, value string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := Cookies(secret).SetSigned(rec, name, value, nil); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Result().Cookies()[0].Value
+}
+
+func TestEncryptedCookieRoundTrip(t *testing.T) {
+	jar := Cookies(cookieSecret)
+	rec := httptest.NewRecorder()
+	if err := jar.SetEncrypted(rec, "token", "refresh-abc123", nil); err != nil {
+		t.Fatal(err)
+	}
+	if v := rec.Result().Cookies()[0].Value; strings.Contains(v, "refresh") {
+		t.Errorf("encrypted cookie %q shows its value", v)
+	}
+	got, err := jar.GetEncrypted(replay(rec), "token")
+	if err != nil || got != "refresh-abc123" {
+		t.Fatalf("GetEncrypted = %q, %v", got, err)
+	}
+
+	// Flip one bit of the ciphertext.
+	tampered := []byte(rec.Result().Cookies()[0].Value)
+	tampered[len(tampered)-3] ^= 1
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: string(tampered)})
+	if _, err := jar.GetEncrypted(req, "token"); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("tampered: err = %v, want ErrInvalidCookie", err)
+	}
+	if _, err := jar.GetEncrypted(httptest.NewRequest(http.MethodGet, "/", nil), "token"); !errors.Is(err, http.ErrNoCookie) {
+		t.Errorf("missing: err = %v, want http.ErrNoCookie", err)
+	}
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	old := Cookies(oldCookieSecret)
+	signed, sealed := httptest.NewRecorder(), httptest.NewRecorder()
+	old.SetSigned(signed, "session", "42", nil)
+	old.SetEncrypted(sealed, "session", "42", nil)
+
+	rotated := Cookies(cookieSecret, oldCookieSecret)
+	if got, err := rotated.GetSigned(replay(signed), "session"); err != nil || got != "42" {
+		t.Errorf("GetSigned during grace period = %q, %v", got, err)
+	}
+	if got, err := rotated.GetEncrypted(replay(sealed), "session"); err != nil || got != "42" {
+		t.Errorf("GetEncrypted during grace period = %q, %v", got, err)
+	}
+
+	// New cookies use the new secret, so they outlive the old one.
+	rec := httptest.NewRecorder()
+	rotated.SetSigned(rec, "session", "43", nil)
+	if got, err := Cookies(cookieSecret).GetSigned(replay(rec), "session"); err != nil || got != "43" {
+		t.Errorf("cookie set after rotation = %q, %v", got, err)
+	}
+
+	// Once the old secret is dropped, its cookies are rejected.
+	if _, err := Cookies(cookieSecret).GetSigned(replay(signed), "session"); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("after grace period: err = %v, want ErrInvalidCookie", err)
+	}
+}
+
+func TestCookieMaxAgeIsSealed(t *testing.T) {
+	jar := Cookies(cookieSecret)
+	now := time.Unix(1_700_000_000, 0)
+	jar.now = func() time.Time { return now }
+
+	opts := &CookieOptions{MaxAge: time.Hour}
+	signed, sealed := httptest.NewRecorder(), httptest.NewRecorder()
+	jar.SetSigned(signed, "s", "v", opts)
+	jar.SetEncrypted(sealed, "e", "v", opts)
+	if c := signed.Result().Cookies()[0]; c.MaxAge != 3600 || !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie = %+v, want Max-Age 3600 with the hardened defaults", c)
+	}
+
+	now = now.Add(59 * time.Minute)
+	if _, err := jar.GetSigned(replay(signed), "s"); err != nil {
+		t.Errorf("before expiry: %v", err)
+	}
+	// A client that keeps the cookie past its Max-Age gains nothing.
+	now = now.Add(time.Minute)
+	if _, err := jar.GetSigned(replay(signed), "s"); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("signed after expiry: err = %v, want ErrInvalidCookie", err)
+	}
+	if _, err := jar.GetEncrypted(replay(sealed), "e"); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("encrypted after expiry: err = %v, want ErrInvalidCookie", err)
+	}
+}
+
+func TestCookieOptOuts(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := Cookies(cookieSecret).SetSigned(w, "s", "v", &CookieOptions{Insecure: true, AllowJS: true}); err != nil {
+		t.Fatal(err)
+	}
+	if c := w.Result().Cookies()[0]; c.Secure || c.HttpOnly {
+		t.Errorf("Secure = %v, HttpOnly = %v; want both off", c.Secure, c.HttpOnly)
+	}
+}
+
+func TestCookieOptionsErrors(t *testing.T) {
+	jar := Cookies(cookieSecret)
+	if err := jar.SetSigned(httptest.NewRecorder(), "s", "v", &CookieOptions{SameSite: http.SameSiteNoneMode, Insecure: true}); err == nil {
+		t.Error("SameSite=None without Secure was accepted")
+	}
+	if err := jar.SetSigned(httptest.NewRecorder(), "s", strings.Repeat("x", 4000), nil); err == nil {
+		t.Error("oversized cookie was accepted")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Cookies accepted a short secret")
+		}
+	}()
+	Cookies([]byte("short"))
+}
@@ -0,0 +1,203 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists origins permitted to make cross-origin requests,
+	// such as "https://app.example.com". "*" allows any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods lists methods permitted in preflight requests. If
+	// empty, GET, HEAD, POST, PUT, PATCH and DELETE are allowed.
+	AllowedMethods []string
+
+	// AllowedHeaders lists request headers permitted in preflight requests.
+	// "*" allows any header the client asks for.
+	AllowedHeaders []string
+
+	// AllowCredentials allows cookies and HTTP authentication on
+	// cross-origin requests. It cannot be combined with a "*" origin.
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// CORS returns middleware that applies opts to cross-origin requests. It
+// panics if opts is invalid; use NewCORS to handle the error instead.
+func CORS(opts CORSOptions) Middleware {
+	mw, err := NewCORS(opts)
+	if err != nil {
+		panic(err)
+	}
+	return mw
+}
+
+// NewCORS returns middleware that applies opts to cross-origin requests.
+//
+// Preflight requests (OPTIONS with Access-Control-Request-Method) are
+// answered with 204 and never reach the wrapped handler. Requests from
+// origins that are not allowed are passed through without any CORS headers,
+// which makes the browser block the response.
+//
+// NewCORS returns an error if opts allows any origin together with
+// credentials, which browsers reject.
+func NewCORS(opts CORSOptions) (Middleware, error) {
+	c := &cors{
+		allowCredentials: opts.AllowCredentials,
+		methods:          opts.AllowedMethods,
+	}
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		c.origins = append(c.origins, strings.ToLower(o))
+	}
+	if c.anyOrigin && c.allowCredentials {
+		return nil, errors.New("functions: CORS cannot allow credentials for any origin (\"*\"); list origins explicitly")
+	}
+	if len(c.methods) == 0 {
+		c.methods = defaultCORSMethods
+	}
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers = append(c.headers, http.CanonicalHeaderKey(h))
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge / time.Second))
+	}
+	return c.handler, nil
+}
+
+type cors struct {
+	origins          []string
+	anyOrigin        bool
+	methods          []string
+	headers          []string
+	anyHeader        bool
+	allowCredentials bool
+	maxAge           string
+}
+
+func (c *cors) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// Responses differ by origin unless every origin gets the same "*".
+		if !c.anyOrigin || c.allowCredentials {
+			w.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" || !c.allowOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !preflight {
+			c.setOrigin(w, origin)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		reqHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if !c.allowMethod(method) || !c.allowHeaders(reqHeaders) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		c.setOrigin(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+		if reqHeaders != "" {
+			if c.anyHeader {
+				w.Header().Set("Access-Control-Allow-Headers", reqHeaders)
+			} else {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.headers, ", "))
+			}
+		}
+		if c.maxAge != "" {
+			w.Header().Set("Access-Control-Max-Age", c.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c *cors) setOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, o := range c.origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) allowMethod(method string) bool {
+	for _, m := range c.methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) allowHeaders(requested string) bool {
+	if c.anyHeader || requested == "" {
+		return true
+	}
+	for _, h := range strings.Split(requested, ",") {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		allowed := false
+		for _, a := range c.headers {
+			if a == h {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package functions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func corsHandler(t *testing.T, opts CORSOptions) (http.Handler, *bool) {
+	t.Helper()
+	mw, err := NewCORS(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := new(bool)
+	return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*called = true
+		w.Write([]byte("ok"))
+	})), called
+}
+
+func preflight(origin, method, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/api", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h, called := corsHandler(t, CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, preflight("https://app.example.com", "POST", "content-type, authorization"))
+
+	if *called {
+		t.Fatal("preflight reached the handler")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if vary := strings.Join(w.Header().Values("Vary"), ","); !strings.Contains(vary, "Origin") {
+		t.Errorf("Vary = %q, want it to include Origin", vary)
+	}
+}
+
+func TestCORSPreflightRejectsMethodAndHeaders(t *testing.T) {
+	h, _ := corsHandler(t, CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET"},
+		AllowedHeaders: []string{"Content-Type"},
+	})
+
+	for name, r := range map[string]*http.Request{
+		"method": preflight("https://app.example.com", "DELETE", ""),
+		"header": preflight("https://app.example.com", "GET", "X-Secret"),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want none", name, got)
+		}
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	h, called := corsHandler(t, CORSOptions{AllowedOrigins: []string{"https://App.Example.com"}})
+
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if !*called {
+		t.Fatal("handler not called")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("Access-Control-Allow-Credentials = %q, want none", got)
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	h, called := corsHandler(t, CORSOptions{AllowedOrigins: []string{"https://app.example.com"}})
+
+	for _, r := range []*http.Request{
+		preflight("https://evil.example.com", "GET", ""),
+		func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/api", nil)
+			r.Header.Set("Origin", "https://evil.example.com")
+			return r
+		}(),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		for k := range w.Header() {
+			if strings.HasPrefix(k, "Access-Control-") {
+				t.Errorf("%s %s: unexpected header %s", r.Method, r.URL, k)
+			}
+		}
+	}
+	if !*called {
+		t.Fatal("non-preflight request from a disallowed origin should still reach the handler")
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	h, _ := corsHandler(t, CORSOptions{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, preflight("https://anywhere.example", "PUT", "X-Custom"))
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "X-Custom" {
+		t.Fatalf("Access-Control-Allow-Headers = %q", got)
+	}
+}
+
+func TestCORSWildcardWithCredentials(t *testing.T) {
+	_, err := NewCORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	if err == nil {
+		t.Fatal("expected an error for wildcard origin with credentials")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("CORS did not panic on invalid options")
+		}
+	}()
+	CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -0,0 +1,324 @@
+package functions
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ErrNoRows is returned by D1Statement.First when the query matches no rows.
+var ErrNoRows = errors.New("functions: no rows in result set")
+
+// maxSafeInteger is the largest integer a JavaScript number holds exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// D1 is a Cloudflare D1 database.
+//
+// The binding name is the `binding` of a `[[d1_databases]]` entry in
+// wrangler.toml:
+//
+//	[[d1_databases]]
+//	binding = "DB"
+//	database_name = "app"
+//	database_id = "..."
+//
+// which is opened with NewD1("DB").
+type D1 struct {
+	binding string
+	b       d1Backend
+}
+
+// D1Database is the interface implemented by *D1. Handlers that accept a
+// D1Database instead of *D1 can be unit-tested with functest.NewMockD1.
+type D1Database interface {
+	Prepare(query string) *D1Statement
+	Batch(ctx context.Context, stmts ...*D1Statement) ([]*D1Result, error)
+}
+
+var _ D1Database = (*D1)(nil)
+
+// D1Statement is a prepared query with its bound arguments. Statements are
+// immutable; Bind returns a new statement.
+type D1Statement struct {
+	db    *D1
+	query string
+	args  []any
+}
+
+// D1Result holds the rows and metadata of an executed statement.
+type D1Result struct {
+	// Results has one map per row, keyed by column name. Numbers are
+	// json.Number values so integers keep their precision.
+	Results []map[string]any
+	Meta    D1Meta
+}
+
+// D1Meta describes the effects of an executed statement.
+type D1Meta struct {
+	Duration    float64 `json:"duration"` // milliseconds
+	Changes     int64   `json:"changes"`
+	LastRowID   int64   `json:"last_row_id"`
+	ChangedDB   bool    `json:"changed_db"`
+	SizeAfter   int64   `json:"size_after"`
+	RowsRead    int64   `json:"rows_read"`
+	RowsWritten int64   `json:"rows_written"`
+}
+
+// d1Query is a statement ready to send to the backend, with its arguments
+// already normalized by normalizeD1Args.
+type d1Query struct {
+	query string
+	args  []any
+}
+
+// d1Backend is the platform-specific half of D1.
+type d1Backend interface {
+	all(ctx context.Context, q d1Query) (*D1Result, error)
+	first(ctx context.Context, q d1Query) (row map[string]any, found bool, err error)
+	run(ctx context.Context, q d1Query) (*D1Meta, error)
+	batch(ctx context.Context, qs []d1Query) ([]*D1Result, error)
+}
+
+// NewD1 opens the D1 database bound to the Worker under binding. It returns
+// ErrBindingNotFound if no such binding is configured.
+func NewD1(binding string) (*D1, error) {
+	b, err := openD1(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &D1{binding: binding, b: b}, nil
+}
+
+// D1Executor runs the statements of a D1 created with NewD1WithExecutor.
+// Arguments arrive normalized to nil, bool, int64, float64, string or
+// []byte, as they would be sent to the Workers binding.
+type D1Executor interface {
+	// Query runs a statement and returns its rows. It serves All and First.
+	Query(ctx context.Context, query string, args []any) (*D1Result, error)
+	// Exec runs a statement for its effects. It serves Run.
+	Exec(ctx context.Context, query string, args []any) (*D1Meta, error)
+}
+
+// NewD1WithExecutor returns a D1 whose statements are run by ex instead of
+// a Workers binding, for tests and local tooling. name takes the place of
+// the binding name in errors. Batch runs the statements through Query one
+// by one, so it is only as atomic as ex makes it.
+func NewD1WithExecutor(name string, ex D1Executor) *D1 {
+	return &D1{binding: name, b: executorD1{ex}}
+}
+
+// executorD1 adapts a D1Executor to d1Backend.
+type executorD1 struct {
+	ex D1Executor
+}
+
+func (e executorD1) all(ctx context.Context, q d1Query) (*D1Result, error) {
+	return e.ex.Query(ctx, q.query, q.args)
+}
+
+func (e executorD1) first(ctx context.Context, q d1Query) (map[string]any, bool, error) {
+	res, err := e.ex.Query(ctx, q.query, q.args)
+	if err != nil || len(res.Results) == 0 {
+		return nil, false, err
+	}
+	return res.Results[0], true, nil
+}
+
+func (e executorD1) run(ctx context.Context, q d1Query) (*D1Meta, error) {
+	return e.ex.Exec(ctx, q.query, q.args)
+}
+
+func (e executorD1) batch(ctx context.Context, qs []d1Query) ([]*D1Result, error) {
+	out := make([]*D1Result, len(qs))
+	for i, q := range qs {
+		res, err := e.ex.Query(ctx, q.query, q.args)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		out[i] = res
+	}
+	return out, nil
+}
+
+// Prepare returns a statement for query, which may contain positional `?`
+// or numbered `?NNN` placeholders.
+func (db *D1) Prepare(query string) *D1Statement {
+	return &D1Statement{db: db, query: query}
+}
+
+// Batch executes stmts in a single transaction: if any statement fails, none
+// of them take effect. Results are returned in statement order.
+func (db *D1) Batch(ctx context.Context, stmts ...*D1Statement) ([]*D1Result, error) {
+	qs := make([]d1Query, len(stmts))
+	for i, s := range stmts {
+		if s.db != db {
+			return nil, fmt.Errorf("functions: D1 batch statement %d was prepared on a different database", i)
+		}
+		q, err := s.compile()
+		if err != nil {
+			return nil, err
+		}
+		qs[i] = q
+	}
+	res, err := db.b.batch(ctx, qs)
+	if err != nil {
+		return nil, db.wrap("batch", err)
+	}
+	return res, nil
+}
+
+// Bind returns a copy of the statement with args bound to its placeholders
+// in order. A nil argument, including a nil pointer, binds SQL NULL.
+func (s *D1Statement) Bind(args ...any) *D1Statement {
+	return &D1Statement{db: s.db, query: s.query, args: append([]any(nil), args...)}
+}
+
+// All executes the statement and returns every row.
+func (s *D1Statement) All(ctx context.Context) (*D1Result, error) {
+	q, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.b.all(ctx, q)
+	if err != nil {
+		return nil, s.db.wrap("query", err)
+	}
+	return res, nil
+}
+
+// First executes the statement and scans the first row into dest, which
+// must be a pointer to a struct or a *map[string]any. Struct fields are
+// matched to columns by their `db` tag, or by case-insensitive field name.
+// It returns ErrNoRows if there are no rows.
+func (s *D1Statement) First(ctx context.Context, dest any) error {
+	q, err := s.compile()
+	if err != nil {
+		return err
+	}
+	row, found, err := s.db.b.first(ctx, q)
+	if err != nil {
+		return s.db.wrap("query", err)
+	}
+	if !found {
+		return ErrNoRows
+	}
+	return scanRow(row, dest)
+}
+
+// Run executes the statement, discarding any rows, and returns its metadata.
+func (s *D1Statement) Run(ctx context.Context) (*D1Meta, error) {
+	q, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+	meta, err := s.db.b.run(ctx, q)
+	if err != nil {
+		return nil, s.db.wrap("exec", err)
+	}
+	return meta, nil
+}
+
+func (s *D1Statement) compile() (d1Query, error) {
+	args, err := normalizeD1Args(s.args)
+	if err != nil {
+		return d1Query{}, err
+	}
+	return d1Query{query: s.query, args: args}, nil
+}
+
+// ScanAll scans every row into dest, which must be a pointer to a slice of
+// structs, struct pointers, or maps.
+func (r *D1Result) ScanAll(dest any) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("functions: ScanAll destination must be a pointer to a slice, got %T", dest)
+	}
+	slice := dv.Elem()
+	elemType := slice.Type().Elem()
+	out := reflect.MakeSlice(slice.Type(), len(r.Results), len(r.Results))
+	for i, row := range r.Results {
+		target := out.Index(i)
+		if elemType.Kind() == reflect.Pointer {
+			target.Set(reflect.New(elemType.Elem()))
+		} else {
+			target = target.Addr()
+		}
+		if err := scanRow(row, target.Interface()); err != nil {
+			return fmt.Errorf("functions: row %d: %w", i, err)
+		}
+	}
+	slice.Set(out)
+	return nil
+}
+
+func (db *D1) wrap(op string, err error) error {
+	return fmt.Errorf("functions: D1 %s %s: %w", db.binding, op, err)
+}
+
+// normalizeD1Args converts Go values into the types D1 accepts: nil, bool,
+// int64, float64, string and []byte.
+func normalizeD1Args(args []any) ([]any, error) {
+	out := make([]any, len(args))
+	for i, arg := range args {
+		v, err := normalizeD1Arg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("functions: D1 argument %d: %w", i+1, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func normalizeD1Arg(arg any) (any, error) {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		arg = v
+	}
+	switch v := arg.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		if v == nil {
+			return nil, nil
+		}
+		return v, nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	}
+
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return normalizeD1Arg(rv.Elem().Interface())
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := rv.Int()
+		if n > maxSafeInteger || n < -maxSafeInteger {
+			return nil, fmt.Errorf("integer %d cannot be represented exactly", n)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := rv.Uint()
+		if n > maxSafeInteger {
+			return nil, fmt.Errorf("integer %d cannot be represented exactly", n)
+		}
+		return int64(n), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", arg)
+}
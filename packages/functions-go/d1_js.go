@@ -0,0 +1,105 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"syscall/js"
+)
+
+type jsD1 struct {
+	db js.Value
+}
+
+func openD1(binding string) (d1Backend, error) {
+	db, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsD1{db: db}, nil
+}
+
+func (d *jsD1) statement(q d1Query) js.Value {
+	stmt := d.db.Call("prepare", q.query)
+	if len(q.args) == 0 {
+		return stmt
+	}
+	args := make([]any, len(q.args))
+	for i, a := range q.args {
+		switch v := a.(type) {
+		case nil:
+			args[i] = js.Null()
+		case []byte:
+			args[i] = bytesToJS(v)
+		default:
+			args[i] = js.ValueOf(v)
+		}
+	}
+	return stmt.Call("bind", args...)
+}
+
+func (d *jsD1) all(ctx context.Context, q d1Query) (*D1Result, error) {
+	v, err := awaitPromise(ctx, d.statement(q).Call("all"))
+	if err != nil {
+		return nil, err
+	}
+	var res D1Result
+	if err := decodeD1JSON(v, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *jsD1) first(ctx context.Context, q d1Query) (map[string]any, bool, error) {
+	v, err := awaitPromise(ctx, d.statement(q).Call("first"))
+	if err != nil || isNullish(v) {
+		return nil, false, err
+	}
+	var row map[string]any
+	if err := decodeD1JSON(v, &row); err != nil {
+		return nil, false, err
+	}
+	return row, true, nil
+}
+
+func (d *jsD1) run(ctx context.Context, q d1Query) (*D1Meta, error) {
+	v, err := awaitPromise(ctx, d.statement(q).Call("run"))
+	if err != nil {
+		return nil, err
+	}
+	var meta D1Meta
+	if err := decodeD1JSON(v.Get("meta"), &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (d *jsD1) batch(ctx context.Context, qs []d1Query) ([]*D1Result, error) {
+	stmts := make([]any, len(qs))
+	for i, q := range qs {
+		stmts[i] = d.statement(q)
+	}
+	v, err := awaitPromise(ctx, d.db.Call("batch", js.ValueOf(stmts)))
+	if err != nil {
+		return nil, err
+	}
+	var res []*D1Result
+	if err := decodeD1JSON(v, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// decodeD1JSON round-trips a D1 result through JSON, keeping numbers as
+// json.Number so integer columns are not rounded.
+func decodeD1JSON(v js.Value, dst any) error {
+	if isNullish(v) {
+		return nil
+	}
+	raw := js.Global().Get("JSON").Call("stringify", v).String()
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.UseNumber()
+	return dec.Decode(dst)
+}
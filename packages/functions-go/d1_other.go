@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openD1(binding string) (d1Backend, error) {
+	return nil, fmt.Errorf("%w: %q (D1 has no local fallback; use `wrangler dev` to run against a local database)", ErrBindingNotFound, binding)
+}
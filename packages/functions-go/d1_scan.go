@@ -0,0 +1,226 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scanner matches sql.Scanner without importing database/sql, so fields
+// such as sql.NullString scan as they would with database/sql.
+type scanner interface {
+	Scan(src any) error
+}
+
+var (
+	scannerType = reflect.TypeOf((*scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scanRow copies the columns of row into dest, a pointer to a struct or a
+// *map[string]any.
+func scanRow(row map[string]any, dest any) error {
+	if m, ok := dest.(*map[string]any); ok {
+		*m = row
+		return nil
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("functions: scan destination must be a pointer to a struct, got %T", dest)
+	}
+
+	sv := dv.Elem()
+	columns := make(map[string]any, len(row))
+	for k, v := range row {
+		columns[strings.ToLower(k)] = v
+	}
+	for i := 0; i < sv.NumField(); i++ {
+		field := sv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("db")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		value, ok := columns[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		if err := assignColumn(sv.Field(i), value); err != nil {
+			return fmt.Errorf("functions: column %q into field %s: %w", name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+// assignColumn stores a decoded column value into dst.
+func assignColumn(dst reflect.Value, value any) error {
+	if dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		return dst.Addr().Interface().(scanner).Scan(scannerValue(value))
+	}
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Pointer {
+		elem := reflect.New(dst.Type().Elem())
+		if err := assignColumn(elem.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	if dst.Type() == timeType {
+		t, err := columnTime(value)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			dst.SetString(v)
+		case json.Number:
+			dst.SetString(v.String())
+		default:
+			return fmt.Errorf("cannot convert %T to string", value)
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			dst.SetBool(v)
+		case json.Number:
+			// SQLite stores booleans as integers.
+			dst.SetBool(v.String() != "0")
+		default:
+			return fmt.Errorf("cannot convert %T to bool", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := columnInt(value)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := columnInt(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		num, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("cannot convert %T to %s", value, dst.Type())
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(f)
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported field type %s", dst.Type())
+		}
+		b, err := columnBytes(value)
+		if err != nil {
+			return err
+		}
+		dst.SetBytes(b)
+	case reflect.Interface:
+		dst.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("unsupported field type %s", dst.Type())
+	}
+	return nil
+}
+
+func columnInt(value any) (int64, error) {
+	num, ok := value.(json.Number)
+	if !ok {
+		if s, isString := value.(string); isString {
+			num = json.Number(s)
+		} else {
+			return 0, fmt.Errorf("cannot convert %T to integer", value)
+		}
+	}
+	if n, err := num.Int64(); err == nil {
+		return n, nil
+	}
+	f, err := num.Float64()
+	if err != nil || f != math.Trunc(f) {
+		return 0, fmt.Errorf("cannot convert %q to integer", num)
+	}
+	return int64(f), nil
+}
+
+func columnBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case []any:
+		// D1 returns BLOB columns as arrays of byte values.
+		b := make([]byte, len(v))
+		for i, e := range v {
+			n, err := columnInt(e)
+			if err != nil || n < 0 || n > 255 {
+				return nil, fmt.Errorf("invalid blob byte at index %d", i)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to []byte", value)
+}
+
+func columnTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a time", v)
+	case json.Number:
+		n, err := columnInt(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %T to time.Time", value)
+}
+
+// scannerValue converts a decoded column value into one of the types
+// sql.Scanner implementations expect.
+func scannerValue(value any) any {
+	num, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if n, err := strconv.ParseInt(num.String(), 10, 64); err == nil {
+		return n
+	}
+	f, _ := num.Float64()
+	return f
+}
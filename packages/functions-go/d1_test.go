@@ -0,0 +1,194 @@
+package functions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeD1 is a d1Backend that records every query and returns canned rows.
+type fakeD1 struct {
+	queries []d1Query
+	rows    []map[string]any
+	err     error
+}
+
+func (f *fakeD1) all(ctx context.Context, q d1Query) (*D1Result, error) {
+	f.queries = append(f.queries, q)
+	return &D1Result{Results: f.rows}, f.err
+}
+
+func (f *fakeD1) first(ctx context.Context, q d1Query) (map[string]any, bool, error) {
+	f.queries = append(f.queries, q)
+	if len(f.rows) == 0 {
+		return nil, false, f.err
+	}
+	return f.rows[0], true, f.err
+}
+
+func (f *fakeD1) run(ctx context.Context, q d1Query) (*D1Meta, error) {
+	f.queries = append(f.queries, q)
+	return &D1Meta{Changes: 1, LastRowID: 7}, f.err
+}
+
+func (f *fakeD1) batch(ctx context.Context, qs []d1Query) ([]*D1Result, error) {
+	f.queries = append(f.queries, qs...)
+	out := make([]*D1Result, len(qs))
+	for i := range out {
+		out[i] = &D1Result{}
+	}
+	return out, f.err
+}
+
+type d1User struct {
+	ID        int64          `db:"id"`
+	Name      string         `db:"name"`
+	Admin     bool           `db:"is_admin"`
+	Nickname  *string        `db:"nickname"`
+	Bio       sql.NullString `db:"bio"`
+	CreatedAt time.Time      `db:"created_at"`
+	Score     float64
+	Ignored   string `db:"-"`
+}
+
+func TestD1BindPreservesOrderAndNulls(t *testing.T) {
+	fake := &fakeD1{}
+	db := &D1{binding: "DB", b: fake}
+	var nilName *string
+	name := "ada"
+
+	_, err := db.Prepare("INSERT INTO users (name, nickname, bio, age, avatar) VALUES (?, ?, ?, ?, ?)").
+		Bind(&name, nilName, nil, uint8(36), []byte(nil)).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []any{"ada", nil, nil, int64(36), nil}
+	if got := fake.queries[0].args; !reflect.DeepEqual(got, want) {
+		t.Fatalf("bound args = %#v, want %#v", got, want)
+	}
+}
+
+func TestD1BindIsImmutable(t *testing.T) {
+	fake := &fakeD1{}
+	db := &D1{binding: "DB", b: fake}
+	stmt := db.Prepare("SELECT * FROM users WHERE id = ?")
+
+	stmt.Bind(1).All(context.Background())
+	stmt.Bind(2).All(context.Background())
+	if fake.queries[0].args[0] != int64(1) || fake.queries[1].args[0] != int64(2) {
+		t.Fatalf("queries = %+v", fake.queries)
+	}
+}
+
+func TestD1BindRejectsUnsupportedValues(t *testing.T) {
+	db := &D1{binding: "DB", b: &fakeD1{}}
+	ctx := context.Background()
+
+	if _, err := db.Prepare("SELECT ?").Bind(struct{}{}).All(ctx); err == nil {
+		t.Error("struct argument accepted")
+	}
+	if _, err := db.Prepare("SELECT ?").Bind(int64(1) << 60).All(ctx); err == nil {
+		t.Error("integer beyond 2^53 accepted")
+	}
+}
+
+func TestD1First(t *testing.T) {
+	fake := &fakeD1{rows: []map[string]any{{
+		"id":         json.Number("9007199254740991"),
+		"name":       "ada",
+		"is_admin":   json.Number("1"),
+		"nickname":   "countess",
+		"bio":        nil,
+		"created_at": "2024-05-01T12:00:00Z",
+		"Score":      json.Number("9.5"),
+		"ignored":    "x",
+	}}}
+	db := &D1{binding: "DB", b: fake}
+
+	var u d1User
+	if err := db.Prepare("SELECT * FROM users WHERE id = ?").Bind(1).First(context.Background(), &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 9007199254740991 || u.Name != "ada" || !u.Admin || u.Score != 9.5 {
+		t.Fatalf("scanned %+v", u)
+	}
+	if u.Nickname == nil || *u.Nickname != "countess" {
+		t.Fatalf("nickname = %v", u.Nickname)
+	}
+	if u.Bio.Valid {
+		t.Fatalf("bio = %+v, want NULL", u.Bio)
+	}
+	if !u.CreatedAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("created_at = %v", u.CreatedAt)
+	}
+	if u.Ignored != "" {
+		t.Fatal("db:\"-\" field was scanned")
+	}
+}
+
+func TestD1FirstNoRows(t *testing.T) {
+	db := &D1{binding: "DB", b: &fakeD1{}}
+	var u d1User
+	if err := db.Prepare("SELECT * FROM users WHERE id = ?").Bind(1).First(context.Background(), &u); !errors.Is(err, ErrNoRows) {
+		t.Fatalf("err = %v, want ErrNoRows", err)
+	}
+}
+
+func TestD1FirstTypeMismatch(t *testing.T) {
+	db := &D1{binding: "DB", b: &fakeD1{rows: []map[string]any{{"id": "not a number"}}}}
+	var u d1User
+	if err := db.Prepare("SELECT id FROM users").First(context.Background(), &u); err == nil {
+		t.Fatal("expected a conversion error")
+	}
+}
+
+func TestD1ScanAll(t *testing.T) {
+	fake := &fakeD1{rows: []map[string]any{
+		{"id": json.Number("1"), "name": "ada"},
+		{"id": json.Number("2"), "name": "grace"},
+	}}
+	db := &D1{binding: "DB", b: fake}
+
+	res, err := db.Prepare("SELECT id, name FROM users").All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []*d1User
+	if err := res.ScanAll(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1].ID != 2 || users[1].Name != "grace" {
+		t.Fatalf("users = %+v", users)
+	}
+}
+
+func TestD1Batch(t *testing.T) {
+	fake := &fakeD1{}
+	db := &D1{binding: "DB", b: fake}
+	insert := db.Prepare("INSERT INTO log (msg) VALUES (?)")
+
+	res, err := db.Batch(context.Background(), insert.Bind("a"), insert.Bind("b"), db.Prepare("DELETE FROM queue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || len(fake.queries) != 3 || fake.queries[1].args[0] != "b" {
+		t.Fatalf("results = %d, queries = %+v", len(res), fake.queries)
+	}
+
+	other := &D1{binding: "OTHER", b: &fakeD1{}}
+	if _, err := db.Batch(context.Background(), other.Prepare("SELECT 1")); err == nil {
+		t.Fatal("batch accepted a statement from another database")
+	}
+}
+
+func TestNewD1UnboundBinding(t *testing.T) {
+	if _, err := NewD1("MISSING"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
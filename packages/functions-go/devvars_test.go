@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDevVars(t *testing.T) {
+	input := `
+# secrets for local development
+API_KEY=abc123
+export REGION = eu
+QUOTED="line one\nline \"two\""
+SINGLE='raw \n # not a comment'
+TRAILING=value # comment
+EMPTY=
+`
+	got, err := parseDevVars(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"API_KEY":  "abc123",
+		"REGION":   "eu",
+		"QUOTED":   "line one\nline \"two\"",
+		"SINGLE":   `raw \n # not a comment`,
+		"TRAILING": "value",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParseDevVarsInvalidLine(t *testing.T) {
+	if _, err := parseDevVars(strings.NewReader("OK=1\nnot a pair\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("err = %v, want a line 2 error", err)
+	}
+}
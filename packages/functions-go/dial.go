@@ -0,0 +1,82 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Values for DialOptions.SecureTransport.
+const (
+	SecureTransportOff      = "off"
+	SecureTransportOn       = "on"
+	SecureTransportStartTLS = "starttls"
+)
+
+// DialOptions configures Dial.
+type DialOptions struct {
+	// SecureTransport is SecureTransportOff (the default) for plain TCP,
+	// SecureTransportOn to speak TLS from the start, or
+	// SecureTransportStartTLS for a plain connection that can be upgraded
+	// later with StartTLS, as SMTP and Postgres do.
+	SecureTransport string
+
+	// AllowHalfOpen keeps the writing side open after the peer has closed
+	// its side. Without it, the Workers runtime closes the connection once
+	// the peer's data ends. Natively TCP connections are always half-open
+	// capable and the option has no effect.
+	AllowHalfOpen bool
+}
+
+// A TLSUpgrader is a connection dialed with SecureTransportStartTLS. Once
+// the protocol has agreed to switch, StartTLS performs the TLS handshake and
+// returns the secured connection, which replaces the original; the original
+// must not be used afterwards.
+type TLSUpgrader interface {
+	net.Conn
+	StartTLS() (net.Conn, error)
+}
+
+// Dial opens an outbound TCP connection to address, a "host:port" pair:
+//
+//	conn, err := functions.Dial(ctx, "db.example.com:5432", &functions.DialOptions{
+//		SecureTransport: functions.SecureTransportStartTLS,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//
+// In the Workers runtime it uses the connect() API of cloudflare:sockets;
+// natively it uses net.Dial, so code that speaks a protocol over the
+// returned net.Conn runs under go test unchanged. Canceling ctx aborts a
+// connect still in progress, but does not affect the connection once it is
+// open; use its deadlines for that. A nil opts means plain TCP.
+//
+// Close releases the socket. Workers limit how many connections a request
+// may have open at once, so every connection should be closed when done.
+func Dial(ctx context.Context, address string, opts *DialOptions) (net.Conn, error) {
+	if opts == nil {
+		opts = &DialOptions{}
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("functions: Dial %s: %w", address, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return nil, fmt.Errorf("functions: Dial %s: invalid port %q", address, port)
+	}
+	switch opts.SecureTransport {
+	case "", SecureTransportOff, SecureTransportOn, SecureTransportStartTLS:
+	default:
+		return nil, fmt.Errorf("functions: Dial %s: unknown SecureTransport %q", address, opts.SecureTransport)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	return dial(ctx, host, port, opts)
+}
@@ -0,0 +1,303 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// socketConnect returns the connect() function of cloudflare:sockets. Only
+// the entry module can import it, so it is looked up where the entry module
+// publishes it: on the runtime context, or failing that on globalThis.
+func socketConnect() (js.Value, error) {
+	if rc := js.Global().Get("context"); !isNullish(rc) {
+		if fn := rc.Get("connect"); fn.Type() == js.TypeFunction {
+			return fn, nil
+		}
+	}
+	if fn := js.Global().Get("connect"); fn.Type() == js.TypeFunction {
+		return fn, nil
+	}
+	return js.Value{}, errors.New("functions: Dial: connect() from cloudflare:sockets is not available; the entry module must import it and set it on globalThis.context")
+}
+
+func dial(ctx context.Context, host, port string, opts *DialOptions) (conn net.Conn, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			conn, err = nil, jsPanicError(r)
+		}
+	}()
+	connect, err := socketConnect()
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(port)
+	addr := js.Global().Get("Object").New()
+	addr.Set("hostname", host)
+	addr.Set("port", n)
+	jsOpts := js.Global().Get("Object").New()
+	secure := opts.SecureTransport
+	if secure == "" {
+		secure = SecureTransportOff
+	}
+	jsOpts.Set("secureTransport", secure)
+	jsOpts.Set("allowHalfOpen", opts.AllowHalfOpen)
+
+	address := net.JoinHostPort(host, port)
+	c, err := openJSSocket(ctx, connect.Invoke(addr, jsOpts), address)
+	if err != nil {
+		return nil, err
+	}
+	if secure == SecureTransportStartTLS {
+		return &jsStartTLSConn{c}, nil
+	}
+	return c, nil
+}
+
+// openJSSocket waits for socket to connect, closing it if ctx is done
+// first.
+func openJSSocket(ctx context.Context, socket js.Value, address string) (*jsSocketConn, error) {
+	info, err := awaitPromise(ctx, socket.Get("opened"))
+	if err != nil {
+		socket.Call("close")
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("functions: Dial %s: %w", address, err)
+	}
+	c := &jsSocketConn{
+		socket: socket,
+		reader: socket.Get("readable").Call("getReader"),
+		writer: socket.Get("writable").Call("getWriter"),
+		local:  dialAddr(""),
+		remote: dialAddr(address),
+		closed: make(chan struct{}),
+	}
+	if !isNullish(info) {
+		if a := info.Get("localAddress"); a.Type() == js.TypeString {
+			c.local = dialAddr(a.String())
+		}
+		if a := info.Get("remoteAddress"); a.Type() == js.TypeString {
+			c.remote = dialAddr(a.String())
+		}
+	}
+	return c, nil
+}
+
+// dialAddr is the net.Addr of a socket, which the runtime reports only as a
+// string.
+type dialAddr string
+
+func (dialAddr) Network() string  { return "tcp" }
+func (a dialAddr) String() string { return string(a) }
+
+// jsSocketConn is a net.Conn over a Workers Socket.
+type jsSocketConn struct {
+	socket js.Value
+	reader js.Value
+	writer js.Value
+	local  dialAddr
+	remote dialAddr
+
+	rmu     sync.Mutex // serializes reads
+	pending []byte
+	// inflight is a read the stream has not answered yet. A Read that hits
+	// its deadline leaves it for the next one, so no data is lost.
+	inflight chan socketRead
+	eof      bool
+
+	wmu sync.Mutex // serializes writes
+
+	dmu           sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	closeErr  error
+}
+
+type socketRead struct {
+	v   js.Value
+	err error
+}
+
+func (c *jsSocketConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	if len(c.pending) == 0 {
+		if c.eof {
+			return 0, io.EOF
+		}
+		if c.inflight == nil {
+			ch := make(chan socketRead, 1)
+			go func() {
+				v, err := awaitPromise(context.Background(), c.reader.Call("read"))
+				ch <- socketRead{v, err}
+			}()
+			c.inflight = ch
+		}
+		c.dmu.Lock()
+		deadline := c.readDeadline
+		c.dmu.Unlock()
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			t := time.NewTimer(time.Until(deadline))
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case res := <-c.inflight:
+			c.inflight = nil
+			if res.err != nil {
+				if c.isClosed() {
+					return 0, net.ErrClosed
+				}
+				return 0, res.err
+			}
+			if res.v.Get("done").Bool() {
+				c.eof = true
+				return 0, io.EOF
+			}
+			c.pending = bytesFromJS(res.v.Get("value"))
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		case <-c.closed:
+			return 0, net.ErrClosed
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *jsSocketConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	c.dmu.Lock()
+	deadline := c.writeDeadline
+	c.dmu.Unlock()
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	chunk := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(chunk, p)
+	if _, err := awaitPromise(ctx, c.writer.Call("write", chunk)); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		if c.isClosed() {
+			return 0, net.ErrClosed
+		}
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CloseWrite closes the writing side, like *net.TCPConn's. Reading goes on
+// until the peer closes its side, if the socket was dialed with
+// AllowHalfOpen.
+func (c *jsSocketConn) CloseWrite() error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.isClosed() {
+		return net.ErrClosed
+	}
+	_, err := awaitPromise(context.Background(), c.writer.Call("close"))
+	return err
+}
+
+func (c *jsSocketConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		_, c.closeErr = awaitPromise(context.Background(), c.socket.Call("close"))
+	})
+	return c.closeErr
+}
+
+func (c *jsSocketConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *jsSocketConn) LocalAddr() net.Addr  { return c.local }
+func (c *jsSocketConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *jsSocketConn) SetDeadline(t time.Time) error {
+	c.dmu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.dmu.Unlock()
+	return nil
+}
+
+func (c *jsSocketConn) SetReadDeadline(t time.Time) error {
+	c.dmu.Lock()
+	c.readDeadline = t
+	c.dmu.Unlock()
+	return nil
+}
+
+func (c *jsSocketConn) SetWriteDeadline(t time.Time) error {
+	c.dmu.Lock()
+	c.writeDeadline = t
+	c.dmu.Unlock()
+	return nil
+}
+
+// jsStartTLSConn is a socket dialed with SecureTransportStartTLS.
+type jsStartTLSConn struct {
+	*jsSocketConn
+}
+
+func (c *jsStartTLSConn) StartTLS() (conn net.Conn, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			conn, err = nil, jsPanicError(r)
+		}
+	}()
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.isClosed() {
+		return nil, net.ErrClosed
+	}
+	if c.inflight != nil || len(c.pending) > 0 {
+		return nil, errors.New("functions: StartTLS with unread plaintext data")
+	}
+	// startTls needs the streams unlocked; the old socket is unusable
+	// afterwards, so mark it closed without closing the connection.
+	c.reader.Call("releaseLock")
+	c.writer.Call("releaseLock")
+	c.closeOnce.Do(func() { close(c.closed) })
+	tc, err := openJSSocket(context.Background(), c.socket.Call("startTls"), string(c.remote))
+	if err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
@@ -0,0 +1,53 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// dialTLSConfig is the TLS configuration of native connections; tests
+// replace it to trust their own certificates.
+var dialTLSConfig = func(host string) *tls.Config { return &tls.Config{ServerName: host} }
+
+func dial(ctx context.Context, host, port string, opts *DialOptions) (net.Conn, error) {
+	var d net.Dialer
+	address := net.JoinHostPort(host, port)
+	switch opts.SecureTransport {
+	case SecureTransportOn:
+		td := &tls.Dialer{NetDialer: &d, Config: dialTLSConfig(host)}
+		return td.DialContext(ctx, "tcp", address)
+	case SecureTransportStartTLS:
+		c, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return &startTLSConn{Conn: c, host: host}, nil
+	}
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// startTLSConn is a plain connection that can be upgraded to TLS.
+type startTLSConn struct {
+	net.Conn
+	host string
+}
+
+func (c *startTLSConn) StartTLS() (net.Conn, error) {
+	tc := tls.Client(c.Conn, dialTLSConfig(c.host))
+	if err := tc.Handshake(); err != nil {
+		tc.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// CloseWrite shuts down the writing side, like *net.TCPConn's.
+func (c *startTLSConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
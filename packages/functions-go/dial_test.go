@@ -0,0 +1,233 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoServer answers each connection with everything it reads, once the
+// client has closed its writing side.
+func echoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				b, _ := io.ReadAll(c)
+				c.Write(b)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// trustServerCert makes native TLS connections trust the certificate of an
+// httptest TLS server for the rest of the test.
+func trustServerCert(t *testing.T) *tls.Config {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	orig := dialTLSConfig
+	dialTLSConfig = func(host string) *tls.Config { return &tls.Config{ServerName: host, RootCAs: pool} }
+	t.Cleanup(func() { dialTLSConfig = orig })
+	return srv.TLS
+}
+
+func TestDialHalfClose(t *testing.T) {
+	addr := echoServer(t)
+	conn, err := Dial(context.Background(), addr, &DialOptions{AllowHalfOpen: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	cw, ok := conn.(interface{ CloseWrite() error })
+	if !ok {
+		t.Fatalf("%T has no CloseWrite", conn)
+	}
+	if err := cw.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ping" {
+		t.Errorf("read %q, want ping", b)
+	}
+}
+
+func TestDialClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peerEOF := make(chan error, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			peerEOF <- err
+			return
+		}
+		defer c.Close()
+		_, err = c.Read(make([]byte, 1))
+		peerEOF <- err
+	}()
+
+	conn, err := Dial(context.Background(), ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-peerEOF; err != io.EOF {
+		t.Errorf("peer read: %v, want EOF", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read after Close: %v, want net.ErrClosed", err)
+	}
+}
+
+func TestDialCanceled(t *testing.T) {
+	addr := echoServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Dial(ctx, addr, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestDialInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		opts *DialOptions
+		want string
+	}{
+		{"example.com", nil, "missing port"},
+		{"example.com:http", nil, "invalid port"},
+		{"example.com:70000", nil, "invalid port"},
+		{"example.com:443", &DialOptions{SecureTransport: "tls"}, "unknown SecureTransport"},
+	} {
+		if _, err := Dial(context.Background(), tt.addr, tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Dial(%q): %v, want error containing %q", tt.addr, err, tt.want)
+		}
+	}
+}
+
+func TestDialSecureTransportOn(t *testing.T) {
+	srvTLS := trustServerCert(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srvTLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.WriteString(c, "hello\n")
+	}()
+
+	conn, err := Dial(context.Background(), ln.Addr().String(), &DialOptions{SecureTransport: SecureTransportOn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "hello\n" {
+		t.Errorf("read %q", line)
+	}
+}
+
+func TestDialStartTLS(t *testing.T) {
+	srvTLS := trustServerCert(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		cmd, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil || cmd != "STARTTLS\n" {
+			return
+		}
+		io.WriteString(c, "OK\n")
+		tc := tls.Server(c, &tls.Config{Certificates: srvTLS.Certificates})
+		io.WriteString(tc, "secret\n")
+		tc.Close()
+	}()
+
+	conn, err := Dial(context.Background(), ln.Addr().String(), &DialOptions{SecureTransport: SecureTransportStartTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(TLSUpgrader); !ok {
+		t.Fatalf("%T is not a TLSUpgrader", conn)
+	}
+	io.WriteString(conn, "STARTTLS\n")
+	ok := make([]byte, 3)
+	if _, err := io.ReadFull(conn, ok); err != nil || string(ok) != "OK\n" {
+		t.Fatalf("read %q, %v", ok, err)
+	}
+	tc, err := conn.(TLSUpgrader).StartTLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Close()
+	line, err := bufio.NewReader(tc).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "secret\n" {
+		t.Errorf("read %q", line)
+	}
+}
+
+func TestDialPlainIsNotTLSUpgrader(t *testing.T) {
+	conn, err := Dial(context.Background(), echoServer(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(TLSUpgrader); ok {
+		t.Error("plain connection implements TLSUpgrader")
+	}
+}
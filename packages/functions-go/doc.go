@@ -0,0 +1,6 @@
+// Package functions provides helpers for writing Functions.do handlers in Go.
+//
+// Handlers are plain net/http handlers, so everything in this package works
+// both inside the Workers runtime (via github.com/syumai/workers) and in a
+// regular `go test` run on the host machine.
+package functions
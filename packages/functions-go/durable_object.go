@@ -0,0 +1,199 @@
+package functions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DurableObjectNamespace is a Cloudflare Durable Object namespace: every
+// instance of one Durable Object class. Each instance has an ID, and all
+// requests for an ID reach the same instance, one at a time, wherever they
+// come from; that makes an instance the place to coordinate state across
+// requests.
+//
+// The binding name is the `name` of a `[[durable_objects.bindings]]` entry
+// in wrangler.toml:
+//
+//	[[durable_objects.bindings]]
+//	name = "ROOMS"
+//	class_name = "ChatRoom"
+//
+// which is opened with NewDurableObject("ROOMS"). A class defined by
+// another Worker also needs `script_name`, and a class defined by this
+// Worker needs a `[[migrations]]` entry listing it.
+type DurableObjectNamespace struct {
+	binding string
+	ns      durableObjectBackend
+}
+
+// DurableObjectID identifies one instance in a namespace.
+type DurableObjectID struct {
+	id   string
+	name string
+}
+
+// String returns the ID as 64 hexadecimal digits, the form accepted by
+// DurableObjectNamespace.IDFromString.
+func (id DurableObjectID) String() string { return id.id }
+
+// Name returns the name the ID was derived from by IDFromName, or "" for
+// IDs from NewUniqueID and IDFromString.
+func (id DurableObjectID) Name() string { return id.name }
+
+// DurableObjectStub sends requests to one Durable Object instance.
+type DurableObjectStub struct {
+	ns *DurableObjectNamespace
+	id DurableObjectID
+	s  serviceBackend
+}
+
+// durableObjectBackend is the platform-specific half of
+// DurableObjectNamespace.
+type durableObjectBackend interface {
+	idFromName(name string) string
+	idFromString(id string) (string, error)
+	newUniqueID() string
+	get(id string) serviceBackend
+}
+
+// NewDurableObject opens the Durable Object namespace bound to the Worker
+// under binding. It returns ErrBindingNotFound if no such binding is
+// configured.
+func NewDurableObject(binding string) (*DurableObjectNamespace, error) {
+	ns, err := openDurableObject(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &DurableObjectNamespace{binding: binding, ns: ns}, nil
+}
+
+// NewDurableObjectWithHandlers returns a namespace whose instances are
+// in-process handlers, for tests and local development. newObject is
+// called once per ID, the first time a stub for it is used, and the
+// returned handler serves every later request for that ID. As in the
+// runtime, requests to one instance are handled one at a time. name takes
+// the place of the binding name in errors.
+//
+// IDFromName hashes the name, so it is deterministic but does not produce
+// the IDs the runtime would.
+func NewDurableObjectWithHandlers(name string, newObject func(id DurableObjectID) http.Handler) *DurableObjectNamespace {
+	return &DurableObjectNamespace{binding: name, ns: &handlerDurableObjects{
+		newObject: newObject,
+		objects:   make(map[string]*handlerDurableObject),
+		names:     make(map[string]string),
+	}}
+}
+
+// IDFromName returns the ID of the instance for name. The same name always
+// yields the same ID, so it routes every request about, say, one chat room
+// to the same instance.
+func (ns *DurableObjectNamespace) IDFromName(name string) DurableObjectID {
+	return DurableObjectID{id: ns.ns.idFromName(name), name: name}
+}
+
+// IDFromString parses an ID previously returned by DurableObjectID.String.
+func (ns *DurableObjectNamespace) IDFromString(id string) (DurableObjectID, error) {
+	s, err := ns.ns.idFromString(id)
+	if err != nil {
+		return DurableObjectID{}, fmt.Errorf("functions: Durable Object %s: invalid ID %q: %w", ns.binding, id, err)
+	}
+	return DurableObjectID{id: s}, nil
+}
+
+// NewUniqueID returns the ID of a new instance. Store its String form to
+// reach the instance again.
+func (ns *DurableObjectNamespace) NewUniqueID() DurableObjectID {
+	return DurableObjectID{id: ns.ns.newUniqueID()}
+}
+
+// Get returns a stub for the instance with the given ID. The instance is
+// created, if need be, when the stub is first used.
+func (ns *DurableObjectNamespace) Get(id DurableObjectID) *DurableObjectStub {
+	return &DurableObjectStub{ns: ns, id: id, s: ns.ns.get(id.id)}
+}
+
+// ID returns the ID of the stub's instance.
+func (s *DurableObjectStub) ID() DurableObjectID { return s.id }
+
+// Fetch sends req to the instance's fetch handler and returns its
+// response. As with Service.Fetch, headers and body are forwarded intact,
+// a relative URL is accepted, a non-2xx status is not an error, and the
+// caller must close the response body.
+func (s *DurableObjectStub) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := s.s.fetch(ctx, outboundRequest(ctx, req, s.ns.binding))
+	if err != nil {
+		return nil, fmt.Errorf("functions: Durable Object %s %s: %w", s.ns.binding, req.Method, err)
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// handlerDurableObjects backs NewDurableObjectWithHandlers.
+type handlerDurableObjects struct {
+	newObject func(id DurableObjectID) http.Handler
+
+	mu      sync.Mutex
+	objects map[string]*handlerDurableObject
+	names   map[string]string // ID to the name it was derived from
+}
+
+// handlerDurableObject serializes the requests to one instance.
+type handlerDurableObject struct {
+	mu sync.Mutex
+	h  http.Handler
+}
+
+func (d *handlerDurableObjects) idFromName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+	d.mu.Lock()
+	d.names[id] = name
+	d.mu.Unlock()
+	return id
+}
+
+func (d *handlerDurableObjects) idFromString(id string) (string, error) {
+	if b, err := hex.DecodeString(id); err != nil || len(b) != 32 {
+		return "", errors.New("want 64 hexadecimal digits")
+	}
+	return id, nil
+}
+
+func (d *handlerDurableObjects) newUniqueID() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func (d *handlerDurableObjects) get(id string) serviceBackend {
+	return durableObjectFetcher(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		d.mu.Lock()
+		obj := d.objects[id]
+		if obj == nil {
+			obj = &handlerDurableObject{h: d.newObject(DurableObjectID{id: id, name: d.names[id]})}
+			d.objects[id] = obj
+		}
+		d.mu.Unlock()
+
+		obj.mu.Lock()
+		defer obj.mu.Unlock()
+		return handlerService{obj.h}.fetch(ctx, req)
+	})
+}
+
+type durableObjectFetcher func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+func (f durableObjectFetcher) fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return f(ctx, req)
+}
@@ -0,0 +1,39 @@
+//go:build js && wasm
+
+package functions
+
+import "syscall/js"
+
+type jsDurableObject struct {
+	ns js.Value
+}
+
+func openDurableObject(binding string) (durableObjectBackend, error) {
+	ns, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsDurableObject{ns: ns}, nil
+}
+
+func (d *jsDurableObject) idFromName(name string) string {
+	return d.ns.Call("idFromName", name).Call("toString").String()
+}
+
+func (d *jsDurableObject) idFromString(id string) (s string, err error) {
+	// idFromString throws on malformed input.
+	defer func() {
+		if r := recover(); r != nil {
+			err = jsPanicError(r)
+		}
+	}()
+	return d.ns.Call("idFromString", id).Call("toString").String(), nil
+}
+
+func (d *jsDurableObject) newUniqueID() string {
+	return d.ns.Call("newUniqueId").Call("toString").String()
+}
+
+func (d *jsDurableObject) get(id string) serviceBackend {
+	return &jsService{svc: d.ns.Call("get", d.ns.Call("idFromString", id))}
+}
@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openDurableObject(binding string) (durableObjectBackend, error) {
+	return nil, fmt.Errorf("%w: %q (Durable Objects are only available in the Workers runtime; use NewDurableObjectWithHandlers to run objects in-process)", ErrBindingNotFound, binding)
+}
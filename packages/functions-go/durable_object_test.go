@@ -0,0 +1,148 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// counterObject is a Durable Object that counts the requests it serves.
+type counterObject struct {
+	id DurableObjectID
+	n  int
+}
+
+func (c *counterObject) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.n++
+	fmt.Fprintf(w, "%s %d", c.id.Name(), c.n)
+}
+
+func newCounterNamespace() *DurableObjectNamespace {
+	return NewDurableObjectWithHandlers("COUNTERS", func(id DurableObjectID) http.Handler {
+		return &counterObject{id: id}
+	})
+}
+
+func fetchString(t *testing.T, stub *DurableObjectStub, req *http.Request) string {
+	t.Helper()
+	resp, err := stub.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestDurableObjectIDFromNameDeterministic(t *testing.T) {
+	ns := newCounterNamespace()
+	a1, a2, b := ns.IDFromName("room-a"), ns.IDFromName("room-a"), ns.IDFromName("room-b")
+	if a1.String() != a2.String() {
+		t.Fatalf("IDFromName(room-a) gave %s then %s", a1, a2)
+	}
+	if a1.String() == b.String() {
+		t.Fatal("different names share an ID")
+	}
+	if len(a1.String()) != 64 || a1.Name() != "room-a" {
+		t.Fatalf("id = %q name %q", a1, a1.Name())
+	}
+
+	// Stubs for the same name reach the same instance.
+	get := func(name string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		return fetchString(t, ns.Get(ns.IDFromName(name)), req)
+	}
+	get("room-a")
+	get("room-b")
+	if got := get("room-a"); got != "room-a 2" {
+		t.Fatalf("second request to room-a = %q, want its count to be 2", got)
+	}
+}
+
+func TestDurableObjectUniqueIDs(t *testing.T) {
+	ns := newCounterNamespace()
+	a, b := ns.NewUniqueID(), ns.NewUniqueID()
+	if a.String() == b.String() {
+		t.Fatal("NewUniqueID returned the same ID twice")
+	}
+	parsed, err := ns.IDFromString(a.String())
+	if err != nil || parsed.String() != a.String() {
+		t.Fatalf("IDFromString(%s) = %s, %v", a, parsed, err)
+	}
+	if _, err := ns.IDFromString("not-an-id"); err == nil {
+		t.Fatal("IDFromString accepted a malformed ID")
+	}
+}
+
+func TestDurableObjectStubForwardsRequest(t *testing.T) {
+	var got *http.Request
+	var body string
+	ns := NewDurableObjectWithHandlers("ROOMS", func(DurableObjectID) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			got, body = r, string(b)
+			w.Header().Set("X-Room", "lobby")
+			w.WriteHeader(http.StatusAccepted)
+		})
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/messages?since=5", strings.NewReader(`{"text":"hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-Trace", "a")
+	req.Header.Add("X-Trace", "b")
+	resp, err := ns.Get(ns.IDFromName("lobby")).Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Method != http.MethodPost || got.URL.Path != "/messages" || got.URL.Query().Get("since") != "5" {
+		t.Errorf("inbound %s %s", got.Method, got.URL)
+	}
+	if got.Header.Get("Content-Type") != "application/json" || len(got.Header.Values("X-Trace")) != 2 {
+		t.Errorf("inbound headers = %v", got.Header)
+	}
+	if body != `{"text":"hi"}` {
+		t.Errorf("inbound body = %q", body)
+	}
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("X-Room") != "lobby" {
+		t.Errorf("response %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestDurableObjectSerializesRequests(t *testing.T) {
+	ns := newCounterNamespace()
+	stub := ns.Get(ns.IDFromName("hot"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			resp, err := stub.Fetch(context.Background(), req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if got := fetchString(t, stub, req); got != "hot 51" {
+		t.Fatalf("after 50 concurrent requests: %q, want hot 51", got)
+	}
+}
+
+func TestNewDurableObjectNative(t *testing.T) {
+	if _, err := NewDurableObject("ROOMS"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
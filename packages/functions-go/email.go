@@ -0,0 +1,157 @@
+package functions
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// ErrNoTextBody is returned by EmailMessage.Body for a multipart message
+// without a text/plain part.
+var ErrNoTextBody = errors.New("functions: email has no text/plain part")
+
+// EmailHandler handles an email delivered by Cloudflare Email Routing. A
+// returned error fails the delivery; call Reject instead to refuse a
+// message with a reason the sender sees.
+type EmailHandler func(ctx context.Context, msg *EmailMessage) error
+
+// EmailMessage is an incoming email.
+//
+// The header is parsed when the message arrives, reading no further than
+// the blank line that ends it, so handlers that route on headers alone
+// never read the body. Raw and Body both read the rest of the message as
+// it streams in, so a handler uses only one of them, once.
+type EmailMessage struct {
+	// From and To are the envelope sender and recipient, which may differ
+	// from the From and To headers.
+	From string
+	To   string
+
+	Headers mail.Header
+
+	// Size is the length of the raw message in bytes, or -1 if unknown.
+	Size int64
+
+	head    []byte    // bytes read from the raw message while parsing the header
+	bodyOff int       // where the body starts in head
+	rest    io.Reader // the unread remainder of the raw message
+
+	forward func(to string) error
+	reject  func(reason string)
+}
+
+// NewEmailMessage returns a message read from raw, an RFC 5322 message,
+// whose Forward and Reject call forward and reject. It is used by runtime
+// adapters and to build messages in tests; functest.NewMockEmail is simpler
+// for the latter. It returns an error if the header is malformed.
+func NewEmailMessage(from, to string, raw io.Reader, size int64, forward func(to string) error, reject func(reason string)) (*EmailMessage, error) {
+	var head bytes.Buffer
+	br := bufio.NewReader(io.TeeReader(raw, &head))
+	m, err := mail.ReadMessage(br)
+	if err != nil {
+		return nil, fmt.Errorf("functions: parsing email header: %w", err)
+	}
+	return &EmailMessage{
+		From:    from,
+		To:      to,
+		Headers: m.Header,
+		Size:    size,
+		head:    head.Bytes(),
+		bodyOff: head.Len() - br.Buffered(),
+		rest:    raw,
+		forward: forward,
+		reject:  reject,
+	}, nil
+}
+
+// Subject returns the Subject header, with RFC 2047 encoded words such as
+// "=?UTF-8?B?...?=" decoded.
+func (m *EmailMessage) Subject() string {
+	s := m.Headers.Get("Subject")
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+// Raw returns the whole message as received, header included, for handlers
+// that store or parse it themselves.
+func (m *EmailMessage) Raw() io.Reader {
+	return io.MultiReader(bytes.NewReader(m.head), m.rest)
+}
+
+// Body returns the text of the message with its Content-Transfer-Encoding
+// removed: the body itself if the message is single-part, or the first
+// text/plain part, however deeply nested, if it is multipart. The text is
+// in the charset named by its Content-Type, which is not converted.
+func (m *EmailMessage) Body() (io.Reader, error) {
+	body := io.MultiReader(bytes.NewReader(m.head[m.bodyOff:]), m.rest)
+	return textBody(m.Headers.Get("Content-Type"), m.Headers.Get("Content-Transfer-Encoding"), body)
+}
+
+func textBody(contentType, encoding string, body io.Reader) (io.Reader, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return decodeTransfer(encoding, body), nil
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, ErrNoTextBody
+		}
+		if err != nil {
+			return nil, fmt.Errorf("functions: reading email body: %w", err)
+		}
+		ct := part.Header.Get("Content-Type")
+		partType, _, _ := mime.ParseMediaType(ct)
+		switch {
+		case strings.HasPrefix(partType, "multipart/"):
+			if r, err := textBody(ct, "", part); err != ErrNoTextBody {
+				return r, err
+			}
+		case partType == "text/plain" || ct == "":
+			// NextPart has already removed quoted-printable encoding.
+			return decodeTransfer(part.Header.Get("Content-Transfer-Encoding"), part), nil
+		}
+	}
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// Forward sends the message on to to, which must be a verified destination
+// address of the Email Routing zone.
+func (m *EmailMessage) Forward(to string) error {
+	if m.forward == nil {
+		return fmt.Errorf("functions: email forward to %s: not supported by this message", to)
+	}
+	if err := m.forward(to); err != nil {
+		return fmt.Errorf("functions: email forward to %s: %w", to, err)
+	}
+	return nil
+}
+
+// Reject refuses the message permanently, returning reason to the sending
+// server.
+func (m *EmailMessage) Reject(reason string) {
+	if m.reject != nil {
+		m.reject(reason)
+	}
+}
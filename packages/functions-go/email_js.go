@@ -0,0 +1,54 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"syscall/js"
+)
+
+// HandleEmail registers h as the Worker's email handler, as the function
+// handleEmail(message) on the binding object of the runtime context, which
+// returns a Promise. The Worker's entry module starts a Go instance for each
+// email event, as github.com/syumai/workers does for fetch events, and calls
+// it; create-function --trigger email generates one.
+func HandleEmail(h EmailHandler) {
+	binding := js.Global().Get("context").Get("binding")
+	binding.Set("handleEmail", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		message := args[0]
+		return js.Global().Get("Promise").New(js.FuncOf(func(_ js.Value, settle []js.Value) any {
+			resolve, reject := settle[0], settle[1]
+			go func() {
+				if err := serveEmail(h, message); err != nil {
+					reject.Invoke(js.Global().Get("Error").New(err.Error()))
+					return
+				}
+				resolve.Invoke()
+			}()
+			return nil
+		}))
+	}))
+}
+
+func serveEmail(h EmailHandler, message js.Value) error {
+	ctx := context.Background()
+	size := int64(-1)
+	if v := message.Get("rawSize"); v.Type() == js.TypeNumber {
+		size = int64(v.Int())
+	}
+	msg, err := NewEmailMessage(
+		message.Get("from").String(),
+		message.Get("to").String(),
+		newJSStreamReader(ctx, message.Get("raw")),
+		size,
+		func(to string) error {
+			_, err := awaitPromise(ctx, message.Call("forward", to))
+			return err
+		},
+		func(reason string) { message.Call("setReject", reason) },
+	)
+	if err != nil {
+		return err
+	}
+	return h(ctx, msg)
+}
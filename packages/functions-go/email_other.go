@@ -0,0 +1,8 @@
+//go:build !js || !wasm
+
+package functions
+
+// HandleEmail registers h as the Worker's email handler. Email is only
+// delivered in the Workers runtime, so outside it HandleEmail does nothing;
+// test handlers with NewEmailMessage or functest.NewMockEmail instead.
+func HandleEmail(h EmailHandler) {}
@@ -0,0 +1,164 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const sampleEmail = "Return-Path: <alice@example.com>\r\n" +
+	"From: Alice Example <alice@example.com>\r\n" +
+	"To: support@example.com, Bob <bob@example.com>\r\n" +
+	"Subject: =?UTF-8?B?UmU6IENhZsOpIG9yZGVy?=\r\n" +
+	"Date: Tue, 14 May 2024 09:30:00 +0000\r\n" +
+	"Message-ID: <1234@mail.example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Where is my order?</p>\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Where is my caf=C3=A9 order?\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"receipt.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--outer--\r\n"
+
+func newSampleEmail(t *testing.T, raw io.Reader) *EmailMessage {
+	t.Helper()
+	msg, err := NewEmailMessage("alice@example.com", "support@example.com", raw, int64(len(sampleEmail)), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestEmailMessageHeaders(t *testing.T) {
+	msg := newSampleEmail(t, strings.NewReader(sampleEmail))
+	if msg.From != "alice@example.com" || msg.To != "support@example.com" {
+		t.Errorf("envelope %s -> %s", msg.From, msg.To)
+	}
+	if got := msg.Subject(); got != "Re: Café order" {
+		t.Errorf("Subject() = %q", got)
+	}
+	if got := msg.Headers.Get("Message-ID"); got != "<1234@mail.example.com>" {
+		t.Errorf("Message-ID = %q", got)
+	}
+	to, err := msg.Headers.AddressList("To")
+	if err != nil || len(to) != 2 || to[1].Name != "Bob" || to[1].Address != "bob@example.com" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if date, err := msg.Headers.Date(); err != nil || date.Day() != 14 {
+		t.Errorf("Date = %v, %v", date, err)
+	}
+}
+
+func TestEmailMessageDoesNotReadBodyForHeaders(t *testing.T) {
+	header, _, _ := strings.Cut(sampleEmail, "\r\n\r\n")
+	// A body far bigger than any read-ahead, which must not be consumed.
+	counted := &countingReader{r: strings.NewReader(header + "\r\n\r\n" + strings.Repeat("x", 1<<20))}
+	msg := newSampleEmail(t, counted)
+	if msg.Subject() == "" {
+		t.Fatal("no subject")
+	}
+	if counted.n > int64(len(header))+8192 {
+		t.Errorf("parsing the header read %d bytes", counted.n)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestEmailMessageBody(t *testing.T) {
+	body, err := newSampleEmail(t, strings.NewReader(sampleEmail)).Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil || string(b) != "Where is my café order?" {
+		t.Errorf("Body = %q, %v", b, err)
+	}
+
+	single := "Subject: hi\nContent-Transfer-Encoding: base64\n\naGVsbG8g\nd29ybGQ=\n"
+	msg, err := NewEmailMessage("a@example.com", "b@example.com", strings.NewReader(single), -1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = msg.Body()
+	if b, _ := io.ReadAll(body); string(b) != "hello world" {
+		t.Errorf("single-part base64 Body = %q", b)
+	}
+
+	htmlOnly := "Content-Type: multipart/alternative; boundary=b\n\n--b\nContent-Type: text/html\n\n<p>hi</p>\n--b--\n"
+	msg, _ = NewEmailMessage("a@example.com", "b@example.com", strings.NewReader(htmlOnly), -1, nil, nil)
+	if _, err := msg.Body(); !errors.Is(err, ErrNoTextBody) {
+		t.Errorf("html-only Body err = %v, want ErrNoTextBody", err)
+	}
+}
+
+func TestEmailMessageRaw(t *testing.T) {
+	b, err := io.ReadAll(newSampleEmail(t, strings.NewReader(sampleEmail)).Raw())
+	if err != nil || string(b) != sampleEmail {
+		t.Errorf("Raw() returned %d bytes, %v; want the message unchanged", len(b), err)
+	}
+}
+
+func TestEmailMessageForwardAndReject(t *testing.T) {
+	var forwarded []string
+	var rejected string
+	msg, err := NewEmailMessage("alice@example.com", "support@example.com", strings.NewReader(sampleEmail), -1,
+		func(to string) error {
+			if to == "unverified@example.com" {
+				return errors.New("destination address not verified")
+			}
+			forwarded = append(forwarded, to)
+			return nil
+		},
+		func(reason string) { rejected = reason },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h EmailHandler = func(ctx context.Context, m *EmailMessage) error {
+		if err := m.Forward("unverified@example.com"); err == nil || !strings.Contains(err.Error(), "not verified") {
+			t.Errorf("Forward to unverified address: err = %v", err)
+		}
+		if err := m.Forward("team@example.com"); err != nil {
+			return err
+		}
+		m.Reject("mailbox is read-only")
+		return nil
+	}
+	if err := h(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(forwarded) != 1 || forwarded[0] != "team@example.com" || rejected != "mailbox is read-only" {
+		t.Errorf("forwarded %v, rejected %q", forwarded, rejected)
+	}
+
+	if _, err := NewEmailMessage("a", "b", strings.NewReader("not a header line\n"), -1, nil, nil); err == nil {
+		t.Error("malformed header was accepted")
+	}
+}
@@ -0,0 +1,89 @@
+package functions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Environment reads configuration values such as Worker vars and secrets.
+type Environment struct {
+	// Lookup returns the value for key and whether it is set.
+	Lookup func(key string) (string, bool)
+}
+
+// Env reads the Worker's environment: vars and secrets bound in the Workers
+// runtime, or process environment variables elsewhere.
+//
+// Under workers.Serve the runtime env exists by the time main runs, so
+// Env.Require can validate configuration at startup.
+var Env = Environment{Lookup: lookupEnv}
+
+// MissingEnvError reports required environment values that are not set.
+type MissingEnvError struct {
+	Keys []string
+}
+
+func (e *MissingEnvError) Error() string {
+	return "functions: missing required environment variables: " + strings.Join(e.Keys, ", ")
+}
+
+// Require returns a *MissingEnvError listing every key that is unset or
+// empty, or nil if all are present.
+func (e Environment) Require(keys ...string) error {
+	var missing []string
+	for _, key := range keys {
+		if v, ok := e.Lookup(key); !ok || v == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingEnvError{Keys: missing}
+	}
+	return nil
+}
+
+// String returns the value of key, or def if it is unset or empty.
+func (e Environment) String(key, def string) string {
+	if v, ok := e.Lookup(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// MustString returns the value of key, panicking if it is unset or empty.
+func (e Environment) MustString(key string) string {
+	v, ok := e.Lookup(key)
+	if !ok || v == "" {
+		panic(&MissingEnvError{Keys: []string{key}})
+	}
+	return v
+}
+
+// Int returns the value of key parsed as an integer, or def if it is unset
+// or empty. A value that is set but not an integer is an error.
+func (e Environment) Int(key string, def int) (int, error) {
+	v, ok := e.Lookup(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("functions: environment variable %s: invalid integer %q", key, v)
+	}
+	return n, nil
+}
+
+// Bool returns the value of key parsed with strconv.ParseBool, or def if it
+// is unset or empty. A value that is set but not a boolean is an error.
+func (e Environment) Bool(key string, def bool) (bool, error) {
+	v, ok := e.Lookup(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("functions: environment variable %s: invalid boolean %q", key, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,19 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"os"
+	"syscall/js"
+)
+
+// lookupEnv reads string vars and secrets from the runtime env, falling back
+// to the process environment.
+func lookupEnv(key string) (string, bool) {
+	if env := runtimeEnv(); !isNullish(env) {
+		if v := env.Get(key); v.Type() == js.TypeString {
+			return v.String(), true
+		}
+	}
+	return os.LookupEnv(key)
+}
@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "os"
+
+func lookupEnv(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
@@ -0,0 +1,82 @@
+package functions
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func mapEnv(m map[string]string) Environment {
+	return Environment{Lookup: func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}}
+}
+
+func TestEnvRequireReportsAllMissing(t *testing.T) {
+	env := mapEnv(map[string]string{"API_URL": "https://api.example.com", "EMPTY": ""})
+
+	err := env.Require("API_KEY", "API_URL", "EMPTY", "DB_NAME")
+	var missing *MissingEnvError
+	if !errors.As(err, &missing) {
+		t.Fatalf("err = %v, want *MissingEnvError", err)
+	}
+	if want := []string{"API_KEY", "EMPTY", "DB_NAME"}; !reflect.DeepEqual(missing.Keys, want) {
+		t.Fatalf("missing = %v, want %v", missing.Keys, want)
+	}
+	if got := err.Error(); got != "functions: missing required environment variables: API_KEY, EMPTY, DB_NAME" {
+		t.Fatalf("message = %q", got)
+	}
+
+	if err := env.Require("API_URL"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEnvAccessors(t *testing.T) {
+	env := mapEnv(map[string]string{
+		"NAME":    "functions",
+		"WORKERS": " 4 ",
+		"DEBUG":   "true",
+		"BAD_INT": "four",
+		"BAD_OK":  "maybe",
+	})
+
+	if got := env.String("NAME", "x"); got != "functions" {
+		t.Errorf("String = %q", got)
+	}
+	if got := env.String("UNSET", "x"); got != "x" {
+		t.Errorf("String default = %q", got)
+	}
+	if n, err := env.Int("WORKERS", 1); err != nil || n != 4 {
+		t.Errorf("Int = %d, %v", n, err)
+	}
+	if n, err := env.Int("UNSET", 7); err != nil || n != 7 {
+		t.Errorf("Int default = %d, %v", n, err)
+	}
+	if _, err := env.Int("BAD_INT", 1); err == nil {
+		t.Error("Int accepted a non-integer")
+	}
+	if b, err := env.Bool("DEBUG", false); err != nil || !b {
+		t.Errorf("Bool = %v, %v", b, err)
+	}
+	if _, err := env.Bool("BAD_OK", false); err == nil {
+		t.Error("Bool accepted a non-boolean")
+	}
+}
+
+func TestEnvMustStringPanics(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*MissingEnvError); !ok {
+			t.Fatal("MustString did not panic with *MissingEnvError")
+		}
+	}()
+	mapEnv(nil).MustString("SECRET")
+}
+
+func TestEnvDefaultReadsProcessEnv(t *testing.T) {
+	t.Setenv("FUNCTIONS_TEST_VALUE", "from-os")
+	if got := Env.String("FUNCTIONS_TEST_VALUE", ""); got != "from-os" {
+		t.Fatalf("Env.String = %q", got)
+	}
+}
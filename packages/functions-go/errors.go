@@ -0,0 +1,171 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType is the media type of the responses written by
+// WriteError, defined in RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Error is an error meant for the client, written by WriteError as a
+// problem+json response. Handlers return one to stop with a specific
+// status:
+//
+//	if u == nil {
+//		return functions.NotFound("no such user")
+//	}
+type Error struct {
+	// Status is the HTTP status code; zero means 500.
+	Status int
+	// Code is a short, stable identifier of the kind of error, such as
+	// "not_found", for clients to match on.
+	Code string
+	// Message describes this occurrence of the error. It is shown to the
+	// client, so it must not contain anything internal.
+	Message string
+	// Details, if non-nil, is marshaled as JSON alongside the message, for
+	// example to list invalid fields.
+	Details any
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.status())
+	}
+	if e.Code != "" {
+		return e.Code + ": " + msg
+	}
+	return msg
+}
+
+func (e *Error) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+// NotFound returns a 404 Error with code "not_found".
+func NotFound(msg string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: "not_found", Message: msg}
+}
+
+// BadRequest returns a 400 Error with code "bad_request".
+func BadRequest(msg string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: "bad_request", Message: msg}
+}
+
+// Unauthorized returns a 401 Error with code "unauthorized".
+func Unauthorized(msg string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: "unauthorized", Message: msg}
+}
+
+// problem is the body of an RFC 7807 problem+json response. Code and
+// Details are extension members.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Details  any    `json:"details,omitempty"`
+}
+
+// WriteError writes err as an RFC 7807 application/problem+json response:
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Not Found",
+//	  "status": 404,
+//	  "detail": "no such user",
+//	  "instance": "/users/42",
+//	  "code": "not_found"
+//	}
+//
+// An *Error anywhere in err's chain supplies the status, code, detail and
+// details. The client errors of this package are recognised too:
+// ValidationErrors are a 400 listing the invalid fields in details, and
+// the errors of DecodeJSON, ParseMultipart, BindQuery and BindForm get the
+// 400, 413 or 415 they stand for, and ErrSubrequestLimit is a 429.
+//
+// Any other error is a 500 whose body says only "Internal Server Error",
+// so internal messages never reach the client; err itself is logged with
+// slog.Default. Every error answered with a 5xx is also sent to the
+// Reporter installed by WithReporter, if any, once the response is written.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFor(err)
+	if p == nil {
+		slog.Default().LogAttrs(r.Context(), slog.LevelError, "unhandled error",
+			slog.String("request_id", RequestID(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
+		p = &problem{Status: http.StatusInternalServerError}
+	}
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+	writeJSON(w, p.Status, ProblemContentType, p)
+	if p.Status >= 500 {
+		tags := map[string]string{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"status": strconv.Itoa(p.Status),
+		}
+		reportAsync(r.Context(), func(ctx context.Context, rep Reporter) {
+			rep.CaptureError(ctx, err, tags)
+		})
+	}
+}
+
+// problemFor describes err to the client, or returns nil if it is not a
+// client error.
+func problemFor(err error) *problem {
+	var (
+		e       *Error
+		verrs   ValidationErrors
+		missing *MissingParamsError
+		param   *ParamError
+		jsonErr *jsonInputError
+	)
+	switch {
+	case errors.As(err, &e):
+		return &problem{Status: e.status(), Detail: e.Message, Code: e.Code, Details: e.Details}
+	case errors.As(err, &verrs):
+		return &problem{Status: http.StatusBadRequest, Detail: "validation failed", Code: "validation_failed", Details: []FieldError(verrs)}
+	case errors.Is(err, ErrBodyTooLarge):
+		return &problem{Status: http.StatusRequestEntityTooLarge, Detail: clientMessage(ErrBodyTooLarge)}
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return &problem{Status: http.StatusUnsupportedMediaType, Detail: clientMessage(ErrUnsupportedMediaType)}
+	case errors.Is(err, ErrEmptyBody):
+		return &problem{Status: http.StatusBadRequest, Detail: clientMessage(ErrEmptyBody)}
+	case errors.Is(err, ErrSubrequestLimit):
+		return &problem{Status: http.StatusTooManyRequests, Detail: clientMessage(ErrSubrequestLimit)}
+	case errors.Is(err, ErrMalformedMultipart):
+		return &problem{Status: http.StatusBadRequest, Detail: clientMessage(ErrMalformedMultipart)}
+	case errors.As(err, &jsonErr):
+		return &problem{Status: http.StatusBadRequest, Detail: clientMessage(jsonErr)}
+	case errors.As(err, &missing):
+		return &problem{Status: http.StatusBadRequest, Detail: clientMessage(missing)}
+	case errors.As(err, &param):
+		return &problem{Status: http.StatusBadRequest, Detail: clientMessage(param)}
+	}
+	return nil
+}
+
+// clientMessage returns the message of one of this package's errors
+// without its "functions: " prefix. It is given the matched error rather
+// than the whole chain, whose wrapping may say more than the client should
+// see.
+func clientMessage(err error) string {
+	return strings.TrimPrefix(err.Error(), "functions: ")
+}
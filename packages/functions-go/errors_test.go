@@ -0,0 +1,109 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func writeErrorRecorder(err error) (*httptest.ResponseRecorder, map[string]any) {
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest(http.MethodGet, "/users/42", nil), err)
+	var body map[string]any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestWriteErrorProblemJSON(t *testing.T) {
+	w, body := writeErrorRecorder(fmt.Errorf("load user: %w", NotFound("no such user")))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	want := map[string]any{
+		"type":     "about:blank",
+		"title":    "Not Found",
+		"status":   float64(404),
+		"detail":   "no such user",
+		"instance": "/users/42",
+		"code":     "not_found",
+	}
+	if fmt.Sprint(body) != fmt.Sprint(want) {
+		t.Fatalf("body = %v\nwant %v", body, want)
+	}
+}
+
+func TestWriteErrorDetails(t *testing.T) {
+	err := &Error{Status: http.StatusConflict, Code: "version_conflict", Message: "stale version", Details: map[string]int{"current": 7}}
+	w, body := writeErrorRecorder(err)
+	if w.Code != http.StatusConflict || body["title"] != "Conflict" || body["code"] != "version_conflict" {
+		t.Fatalf("%d %v", w.Code, body)
+	}
+	if d, _ := body["details"].(map[string]any); d["current"] != float64(7) {
+		t.Fatalf("details = %v", body["details"])
+	}
+
+	for _, e := range []*Error{BadRequest("x"), Unauthorized("x")} {
+		if w, _ := writeErrorRecorder(e); w.Code != e.Status {
+			t.Errorf("%s: status = %d", e.Code, w.Code)
+		}
+	}
+}
+
+func TestWriteErrorHidesUnknownErrors(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	w, body := writeErrorRecorder(errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+	if w.Code != http.StatusInternalServerError || body["title"] != "Internal Server Error" {
+		t.Fatalf("%d %v", w.Code, body)
+	}
+	if strings.Contains(w.Body.String(), "10.0.0.5") {
+		t.Fatalf("internal message leaked: %s", w.Body)
+	}
+	if _, ok := body["detail"]; ok {
+		t.Errorf("detail = %v, want none", body["detail"])
+	}
+	if !strings.Contains(logs.String(), "connection refused") {
+		t.Errorf("error not logged: %q", logs.String())
+	}
+}
+
+func TestWriteErrorPackageErrors(t *testing.T) {
+	decode := func(body string) error {
+		var v struct{ N int }
+		return DecodeJSON(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &v)
+	}
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{decode(`{"N":`), http.StatusBadRequest},
+		{decode(`{"N":"x"}`), http.StatusBadRequest},
+		{decode(`{} {}`), http.StatusBadRequest},
+		{ErrEmptyBody, http.StatusBadRequest},
+		{fmt.Errorf("%w: %q", ErrUnsupportedMediaType, "text/plain"), http.StatusUnsupportedMediaType},
+		{ErrBodyTooLarge, http.StatusRequestEntityTooLarge},
+		{&MissingParamsError{Params: []string{"cursor"}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w, _ := writeErrorRecorder(tt.err); w.Code != tt.status {
+			t.Errorf("%v: status = %d, want %d", tt.err, w.Code, tt.status)
+		}
+	}
+
+	w, body := writeErrorRecorder(ValidationErrors{{Field: "email", Rule: "email", Message: "must be a valid email address"}})
+	fields, _ := body["details"].([]any)
+	if w.Code != http.StatusBadRequest || body["code"] != "validation_failed" || len(fields) != 1 {
+		t.Fatalf("validation: %d %v", w.Code, body)
+	}
+}
@@ -0,0 +1,171 @@
+package functions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxETagBodyBytes is the largest response body ETag buffers to hash.
+// Larger responses are streamed as is, without an ETag.
+const MaxETagBodyBytes = 1 << 20
+
+// ETag returns middleware that answers conditional GET and HEAD requests,
+// so that polling clients only download a response when it has changed.
+//
+// The response is buffered and given a strong ETag, a hash of its body,
+// unless the handler set one itself. If the request's If-None-Match matches
+// it, the body is dropped and the response becomes a 304 Not Modified.
+// Requests without If-None-Match are compared on If-Modified-Since instead,
+// if the handler set Last-Modified.
+//
+// Only successful responses get an ETag; errors, 206 partial content and
+// empty bodies pass through unchanged. So do streamed responses: those that
+// flush, are text/event-stream, or grow past MaxETagBodyBytes. Other
+// methods are not buffered at all.
+func ETag() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagWriter{ResponseRecorder: NewResponseRecorder(w)}
+			next.ServeHTTP(ew, r)
+			ew.finish(r)
+		})
+	}
+}
+
+// etagWriter buffers a response until it is complete, or passes it through
+// once it turns out not to be one ETag handles. The embedded recorder is
+// the response as sent on, committed only when the buffer is let go.
+type etagWriter struct {
+	*ResponseRecorder
+	status int // held back while buffering
+	buf    bytes.Buffer
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if w.Written() || status < 200 {
+		w.ResponseRecorder.WriteHeader(status)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status > 299 || status == http.StatusPartialContent || isEventStream(w.Header().Get("Content-Type")) {
+		w.passThrough()
+	}
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.Written() {
+		return w.ResponseRecorder.Write(p)
+	}
+	if w.buf.Len()+len(p) > MaxETagBodyBytes {
+		if err := w.passThrough(); err != nil {
+			return 0, err
+		}
+		return w.ResponseRecorder.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *etagWriter) Flush() {
+	if !w.Written() {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.passThrough()
+	}
+	w.ResponseRecorder.Flush()
+}
+
+// passThrough sends the header and whatever has been buffered, and stops
+// buffering.
+func (w *etagWriter) passThrough() error {
+	w.ResponseRecorder.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseRecorder.Write(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// finish sends a buffered response, or a 304 in its place.
+func (w *etagWriter) finish(r *http.Request) {
+	if w.Written() {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	etag := h.Get("ETag")
+	if etag == "" && w.buf.Len() > 0 {
+		sum := sha256.Sum256(w.buf.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+	}
+	if notModified(r, h, etag) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		h.Del("Content-Encoding")
+		w.ResponseRecorder.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method != http.MethodHead && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" {
+		h.Set("Content-Length", strconv.Itoa(w.buf.Len()))
+	}
+	w.ResponseRecorder.WriteHeader(w.status)
+	w.ResponseRecorder.Write(w.buf.Bytes())
+}
+
+// notModified evaluates the request's validators against a response with
+// header h: If-None-Match if present, and otherwise If-Modified-Since.
+func notModified(r *http.Request, h http.Header, etag string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagMatch(inm, etag)
+	}
+	ims, lm := r.Header.Get("If-Modified-Since"), h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatch reports whether an If-None-Match list matches etag, using the
+// weak comparison RFC 9110 prescribes for it.
+func etagMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
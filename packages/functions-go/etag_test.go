@@ -0,0 +1,141 @@
+package functions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func etagDo(h http.Handler, method string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	ETag()(h).ServeHTTP(w, req)
+	return w
+}
+
+func jsonItem(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]int{"count": 3})
+}
+
+func TestETagFullResponse(t *testing.T) {
+	h := http.HandlerFunc(jsonItem)
+	w := etagDo(h, http.MethodGet, map[string]string{"If-None-Match": `"stale"`})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	etag := w.Header().Get("ETag")
+	if len(etag) < 3 || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("ETag = %q, want a strong ETag", etag)
+	}
+	if got := w.Body.String(); got != `{"count":3}`+"\n" {
+		t.Errorf("body = %q", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != "12" {
+		t.Errorf("Content-Length = %q, want 12", got)
+	}
+
+	if again := etagDo(h, http.MethodGet, nil).Header().Get("ETag"); again != etag {
+		t.Errorf("ETag changed between identical responses: %q, then %q", etag, again)
+	}
+}
+
+func TestETagNotModified(t *testing.T) {
+	h := http.HandlerFunc(jsonItem)
+	etag := etagDo(h, http.MethodGet, nil).Header().Get("ETag")
+
+	for _, inm := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		w := etagDo(h, http.MethodGet, map[string]string{"If-None-Match": inm})
+		if w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status = %d, want 304", inm, w.Code)
+			continue
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: body = %q, want empty", inm, w.Body)
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match %s: ETag = %q", inm, got)
+		}
+		if got := w.Header().Get("Content-Type"); got != "" {
+			t.Errorf("If-None-Match %s: Content-Type = %q on a 304", inm, got)
+		}
+	}
+}
+
+func TestETagKeepsHandlerETag(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v7"`)
+		w.Write([]byte("seven"))
+	})
+	if got := etagDo(h, http.MethodGet, nil).Header().Get("ETag"); got != `"v7"` {
+		t.Errorf("ETag = %q, want the handler's", got)
+	}
+	if w := etagDo(h, http.MethodGet, map[string]string{"If-None-Match": `"v7"`}); w.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want 304", w.Code)
+	}
+}
+
+func TestETagIfModifiedSince(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte("report"))
+	})
+
+	w := etagDo(h, http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("unchanged: status = %d, want 304", w.Code)
+	}
+	w = etagDo(h, http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)})
+	if w.Code != http.StatusOK || w.Body.String() != "report" {
+		t.Errorf("changed: status = %d, body = %q", w.Code, w.Body)
+	}
+	// If-None-Match takes precedence over If-Modified-Since.
+	w = etagDo(h, http.MethodGet, map[string]string{
+		"If-None-Match":     `"stale"`,
+		"If-Modified-Since": modified.Format(http.TimeFormat),
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("stale ETag: status = %d, want 200", w.Code)
+	}
+}
+
+func TestETagSkips(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		h      http.HandlerFunc
+	}{
+		{"error", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, NotFound("no such item"))
+		}},
+		{"post", http.MethodPost, jsonItem},
+		{"event stream", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: 1\n\n"))
+		}},
+		{"flushed", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("part"))
+			w.(http.Flusher).Flush()
+		}},
+		{"too large", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(make([]byte, MaxETagBodyBytes+1))
+		}},
+	}
+	for _, tt := range tests {
+		w := etagDo(tt.h, tt.method, map[string]string{"If-None-Match": "*"})
+		if w.Code == http.StatusNotModified {
+			t.Errorf("%s: got 304", tt.name)
+		}
+		if got := w.Header().Get("ETag"); got != "" {
+			t.Errorf("%s: ETag = %q, want none", tt.name, got)
+		}
+		if w.Body.Len() == 0 {
+			t.Errorf("%s: body missing", tt.name)
+		}
+	}
+}
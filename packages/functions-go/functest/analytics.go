@@ -0,0 +1,40 @@
+package functest
+
+import (
+	"sync"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// MockAnalyticsEngine is an in-memory Analytics Engine dataset that records
+// the data points written to it. Data points over the platform limits are
+// rejected before they are recorded, as they would be in production.
+//
+// MockAnalyticsEngine embeds the *functions.AnalyticsEngine it backs, so it
+// can be passed wherever one is expected. It is safe for concurrent use.
+type MockAnalyticsEngine struct {
+	*functions.AnalyticsEngine
+
+	mu     sync.Mutex
+	points []functions.AnalyticsDataPoint
+}
+
+// NewMockAnalyticsEngine returns a MockAnalyticsEngine with nothing
+// written.
+func NewMockAnalyticsEngine() *MockAnalyticsEngine {
+	m := &MockAnalyticsEngine{}
+	m.AnalyticsEngine = functions.NewAnalyticsEngineWithWriter("mock", func(dp functions.AnalyticsDataPoint) error {
+		m.mu.Lock()
+		m.points = append(m.points, dp)
+		m.mu.Unlock()
+		return nil
+	})
+	return m
+}
+
+// DataPoints returns the data points written so far, in order.
+func (m *MockAnalyticsEngine) DataPoints() []functions.AnalyticsDataPoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]functions.AnalyticsDataPoint(nil), m.points...)
+}
@@ -0,0 +1,45 @@
+package functest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockAnalyticsEngine(t *testing.T) {
+	metrics := NewMockAnalyticsEngine()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w.WriteHeader(http.StatusNoContent)
+		err := metrics.WriteDataPoint(functions.AnalyticsDataPoint{
+			Indexes: []string{r.Header.Get("X-Customer")},
+			Blobs:   []string{r.URL.Path},
+			Doubles: []float64{float64(time.Since(start).Milliseconds())},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	req := NewRequest(http.MethodGet, "/checkout", nil)
+	req.Header.Set("X-Customer", "c-42")
+	Do(h, req)
+	Do(h, NewRequest(http.MethodGet, "/cart", nil))
+
+	points := metrics.DataPoints()
+	if len(points) != 2 {
+		t.Fatalf("%d data points, want 2", len(points))
+	}
+	if points[0].Indexes[0] != "c-42" || points[0].Blobs[0] != "/checkout" || points[1].Blobs[0] != "/cart" {
+		t.Fatalf("data points = %+v", points)
+	}
+
+	if err := metrics.WriteDataPoint(functions.AnalyticsDataPoint{Blobs: make([]string, 21)}); err == nil {
+		t.Fatal("over-limit data point accepted")
+	}
+	if len(metrics.DataPoints()) != 2 {
+		t.Fatal("over-limit data point was recorded")
+	}
+}
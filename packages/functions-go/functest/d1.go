@@ -0,0 +1,113 @@
+package functest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// MockD1 is a scripted functions.D1Database. There is no SQL engine behind
+// it: each query the code under test runs must be registered with Handle,
+// Rows or Exec, and running an unregistered query is an error. Queries are
+// matched after collapsing runs of whitespace, so formatting differences
+// between the test and the code don't matter.
+//
+// MockD1 embeds the *functions.D1 it scripts, so it can be passed wherever
+// a functions.D1Database is expected. It is safe for concurrent use.
+type MockD1 struct {
+	*functions.D1
+
+	mu       sync.Mutex
+	handlers map[string]D1Handler
+	calls    []D1Call
+}
+
+// D1Handler answers a registered query. args are the bound arguments,
+// normalized to nil, bool, int64, float64, string or []byte.
+type D1Handler func(args []any) (*functions.D1Result, error)
+
+// D1Call records one query run against a MockD1.
+type D1Call struct {
+	Query string
+	Args  []any
+}
+
+var _ functions.D1Database = (*MockD1)(nil)
+
+// NewMockD1 returns a MockD1 with no registered queries.
+func NewMockD1() *MockD1 {
+	m := &MockD1{handlers: make(map[string]D1Handler)}
+	m.D1 = functions.NewD1WithExecutor("mock", mockD1Executor{m})
+	return m
+}
+
+// Handle registers fn to answer query.
+func (m *MockD1) Handle(query string, fn D1Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[normalizeQuery(query)] = fn
+}
+
+// Rows registers query to return rows, whatever its arguments.
+func (m *MockD1) Rows(query string, rows ...map[string]any) {
+	m.Handle(query, func([]any) (*functions.D1Result, error) {
+		return &functions.D1Result{Results: rows}, nil
+	})
+}
+
+// Exec registers query to succeed with meta, whatever its arguments.
+func (m *MockD1) Exec(query string, meta functions.D1Meta) {
+	m.Handle(query, func([]any) (*functions.D1Result, error) {
+		return &functions.D1Result{Meta: meta}, nil
+	})
+}
+
+// Calls returns the queries run so far, in order.
+func (m *MockD1) Calls() []D1Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]D1Call(nil), m.calls...)
+}
+
+func (m *MockD1) run(query string, args []any) (*functions.D1Result, error) {
+	key := normalizeQuery(query)
+	m.mu.Lock()
+	m.calls = append(m.calls, D1Call{Query: key, Args: args})
+	fn := m.handlers[key]
+	m.mu.Unlock()
+	if fn == nil {
+		return nil, fmt.Errorf("functest: unexpected D1 query %q", key)
+	}
+	res, err := fn(args)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = &functions.D1Result{}
+	}
+	return res, nil
+}
+
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// mockD1Executor is the functions.D1Executor behind MockD1.
+type mockD1Executor struct {
+	m *MockD1
+}
+
+func (e mockD1Executor) Query(ctx context.Context, query string, args []any) (*functions.D1Result, error) {
+	return e.m.run(query, args)
+}
+
+func (e mockD1Executor) Exec(ctx context.Context, query string, args []any) (*functions.D1Meta, error) {
+	res, err := e.m.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &res.Meta, nil
+}
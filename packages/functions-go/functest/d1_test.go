@@ -0,0 +1,74 @@
+package functest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockD1(t *testing.T) {
+	db := NewMockD1()
+	ctx := context.Background()
+
+	db.Handle("SELECT id, name FROM users WHERE id = ?", func(args []any) (*functions.D1Result, error) {
+		if args[0] != int64(1) {
+			return &functions.D1Result{}, nil
+		}
+		return &functions.D1Result{Results: []map[string]any{{"id": json.Number("1"), "name": "ada"}}}, nil
+	})
+	db.Exec("DELETE FROM users WHERE id = ?", functions.D1Meta{Changes: 1})
+
+	var user struct {
+		ID   int64
+		Name string
+	}
+	err := db.Prepare(`SELECT id, name
+		FROM users WHERE id = ?`).Bind(1).First(ctx, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.Name != "ada" {
+		t.Fatalf("user = %+v", user)
+	}
+
+	if err := db.Prepare("SELECT id, name FROM users WHERE id = ?").Bind(2).First(ctx, &user); !errors.Is(err, functions.ErrNoRows) {
+		t.Fatalf("err = %v, want ErrNoRows", err)
+	}
+
+	meta, err := db.Prepare("DELETE FROM users WHERE id = ?").Bind(1).Run(ctx)
+	if err != nil || meta.Changes != 1 {
+		t.Fatalf("run = %+v, %v", meta, err)
+	}
+
+	if _, err := db.Prepare("DROP TABLE users").Run(ctx); err == nil {
+		t.Fatal("expected an error for an unregistered query")
+	}
+
+	want := []D1Call{
+		{Query: "SELECT id, name FROM users WHERE id = ?", Args: []any{int64(1)}},
+		{Query: "SELECT id, name FROM users WHERE id = ?", Args: []any{int64(2)}},
+		{Query: "DELETE FROM users WHERE id = ?", Args: []any{int64(1)}},
+		{Query: "DROP TABLE users", Args: []any{}},
+	}
+	if got := db.Calls(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %#v", got)
+	}
+}
+
+func TestMockD1Batch(t *testing.T) {
+	db := NewMockD1()
+	db.Rows("SELECT 1 AS n", map[string]any{"n": json.Number("1")})
+	db.Exec("UPDATE t SET x = 1", functions.D1Meta{Changes: 3})
+
+	res, err := db.Batch(context.Background(), db.Prepare("SELECT 1 AS n"), db.Prepare("UPDATE t SET x = 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || len(res[0].Results) != 1 || res[1].Meta.Changes != 3 {
+		t.Fatalf("batch = %+v %+v", res[0], res[1])
+	}
+}
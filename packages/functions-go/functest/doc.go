@@ -0,0 +1,12 @@
+// Package functest provides in-memory fakes for Functions.do bindings so
+// handlers can be unit-tested with plain `go test`, without a Workers
+// runtime.
+//
+// Handlers that take functions.KVNamespace, functions.R2Store or
+// functions.D1Database instead of the concrete binding types can be given
+// a MockKV, MockR2 or MockD1, then exercised with NewRequest and Do.
+// MockDurableObject runs Durable Object instances as in-process handlers,
+// MockAnalyticsEngine records the metrics a handler writes, MockEmail
+// records what an email handler does with a message, and MockVectorize
+// answers similarity queries by brute force.
+package functest
@@ -0,0 +1,45 @@
+package functest
+
+import (
+	"net/http"
+	"sync"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// MockDurableObject is an in-process Durable Object namespace. Each ID is
+// served by its own http.Handler, created on first use by the function
+// given to NewMockDurableObject, so state kept in the handler persists
+// across requests to that ID the way it does in a real instance. Requests
+// to one ID are handled one at a time.
+//
+// MockDurableObject embeds the *functions.DurableObjectNamespace it backs,
+// so its IDFromName, NewUniqueID and Get can be called directly, and it can
+// be passed wherever a *functions.DurableObjectNamespace is expected.
+type MockDurableObject struct {
+	*functions.DurableObjectNamespace
+
+	mu      sync.Mutex
+	objects []functions.DurableObjectID
+}
+
+// NewMockDurableObject returns a namespace whose instances are created by
+// newObject.
+func NewMockDurableObject(newObject func(id functions.DurableObjectID) http.Handler) *MockDurableObject {
+	m := &MockDurableObject{}
+	m.DurableObjectNamespace = functions.NewDurableObjectWithHandlers("mock", func(id functions.DurableObjectID) http.Handler {
+		m.mu.Lock()
+		m.objects = append(m.objects, id)
+		m.mu.Unlock()
+		return newObject(id)
+	})
+	return m
+}
+
+// Objects returns the IDs of the instances created so far, in order of
+// creation.
+func (m *MockDurableObject) Objects() []functions.DurableObjectID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]functions.DurableObjectID(nil), m.objects...)
+}
@@ -0,0 +1,48 @@
+package functest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockDurableObject(t *testing.T) {
+	rooms := NewMockDurableObject(func(id functions.DurableObjectID) http.Handler {
+		var messages []string
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				b, _ := io.ReadAll(r.Body)
+				messages = append(messages, string(b))
+			}
+			fmt.Fprintf(w, "%s:%v", id.Name(), messages)
+		})
+	})
+
+	post := func(room, text string) string {
+		resp, err := rooms.Get(rooms.IDFromName(room)).Fetch(context.Background(), NewRequest(http.MethodPost, "/", text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	post("lobby", "hi")
+	post("games", "gg")
+	if got := post("lobby", "again"); got != `lobby:["hi" "again"]` {
+		t.Fatalf("lobby = %s, want both messages", got)
+	}
+
+	objects := rooms.Objects()
+	if len(objects) != 2 || objects[0].Name() != "lobby" || objects[1].Name() != "games" {
+		t.Fatalf("objects = %v, want lobby then games", objects)
+	}
+	if objects[0].String() != rooms.IDFromName("lobby").String() {
+		t.Fatal("instance ID does not match IDFromName")
+	}
+}
@@ -0,0 +1,67 @@
+package functest
+
+import (
+	"strings"
+	"sync"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// MockEmail is an incoming email whose Forward and Reject are recorded
+// instead of acted on. It embeds the *functions.EmailMessage it backs,
+// which is what an email handler is given:
+//
+//	m := functest.NewMockEmail("alice@example.com", "support@example.com", raw)
+//	if err := handleEmail(ctx, m.EmailMessage); err != nil {
+//		t.Fatal(err)
+//	}
+//	if reason, ok := m.Rejected(); ok { ... }
+type MockEmail struct {
+	*functions.EmailMessage
+
+	mu        sync.Mutex
+	forwarded []string
+	rejected  *string
+}
+
+// NewMockEmail returns a MockEmail sent from from to to, whose raw RFC 5322
+// message is raw. Line endings may be "\n" or "\r\n". It panics if the
+// header of raw is malformed.
+func NewMockEmail(from, to, raw string) *MockEmail {
+	m := &MockEmail{}
+	msg, err := functions.NewEmailMessage(from, to, strings.NewReader(raw), int64(len(raw)),
+		func(to string) error {
+			m.mu.Lock()
+			m.forwarded = append(m.forwarded, to)
+			m.mu.Unlock()
+			return nil
+		},
+		func(reason string) {
+			m.mu.Lock()
+			m.rejected = &reason
+			m.mu.Unlock()
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	m.EmailMessage = msg
+	return m
+}
+
+// Forwarded returns the addresses the message was forwarded to, in order.
+func (m *MockEmail) Forwarded() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.forwarded...)
+}
+
+// Rejected returns the reason given to Reject, and whether it was called.
+func (m *MockEmail) Rejected() (reason string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rejected == nil {
+		return "", false
+	}
+	return *m.rejected, true
+}
@@ -0,0 +1,42 @@
+package functest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockEmailRecordsRejectAndForward(t *testing.T) {
+	raw := "From: Spammer <spam@example.net>\nTo: support@example.com\nSubject: WIN BIG\n\nClick here.\n"
+	h := func(ctx context.Context, msg *functions.EmailMessage) error {
+		if strings.HasPrefix(msg.Subject(), "WIN") {
+			msg.Reject("message looks like spam")
+			return nil
+		}
+		return msg.Forward("team@example.com")
+	}
+
+	spam := NewMockEmail("spam@example.net", "support@example.com", raw)
+	if err := h(context.Background(), spam.EmailMessage); err != nil {
+		t.Fatal(err)
+	}
+	if reason, ok := spam.Rejected(); !ok || reason != "message looks like spam" {
+		t.Errorf("Rejected() = %q, %v", reason, ok)
+	}
+	if got := spam.Forwarded(); len(got) != 0 {
+		t.Errorf("Forwarded() = %v", got)
+	}
+
+	ham := NewMockEmail("alice@example.com", "support@example.com", strings.Replace(raw, "WIN BIG", "Order 42", 1))
+	if err := h(context.Background(), ham.EmailMessage); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ham.Rejected(); ok {
+		t.Error("legitimate message was rejected")
+	}
+	if got := ham.Forwarded(); len(got) != 1 || got[0] != "team@example.com" {
+		t.Errorf("Forwarded() = %v", got)
+	}
+}
@@ -0,0 +1,68 @@
+package functest_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+	"github.com/dot-do/functions/packages/functions-go/functest"
+)
+
+// notes stores notes in KV. It depends on the KVNamespace interface rather
+// than *functions.KV so tests can substitute a MockKV.
+type notes struct {
+	kv functions.KVNamespace
+}
+
+type note struct {
+	Text string `json:"text"`
+}
+
+func (n *notes) routes() http.Handler {
+	rt := functions.NewRouter()
+	rt.Put("/notes/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in note
+		if err := functions.DecodeJSON(r, &in); err != nil {
+			functions.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := n.kv.Put(r.Context(), "note:"+functions.Param(r, "id"), []byte(in.Text), nil); err != nil {
+			functions.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rt.Get("/notes/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text, err := n.kv.Get(r.Context(), "note:"+functions.Param(r, "id"))
+		if errors.Is(err, functions.ErrKeyNotFound) {
+			functions.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "no such note"})
+			return
+		}
+		functions.WriteJSON(w, http.StatusOK, note{Text: string(text)})
+	}))
+	return rt
+}
+
+func Example() {
+	kv := functest.NewMockKV()
+	h := (&notes{kv: kv}).routes()
+
+	put := functest.Do(h, functest.NewRequest(http.MethodPut, "/notes/1", note{Text: "buy milk"}))
+	fmt.Println("PUT", put.Status(), kv.Keys())
+
+	get := functest.Do(h, functest.NewRequest(http.MethodGet, "/notes/1", nil))
+	var got note
+	if err := get.JSON(&got); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println("GET", get.Status(), get.Header().Get("Content-Type"), got.Text)
+
+	missing := functest.Do(h, functest.NewRequest(http.MethodGet, "/notes/2", nil))
+	fmt.Println("GET", missing.Status())
+
+	// Output:
+	// PUT 204 [note:1]
+	// GET 200 application/json buy milk
+	// GET 404
+}
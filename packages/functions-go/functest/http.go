@@ -0,0 +1,73 @@
+package functest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// NewRequest returns a request for an in-process handler test. A non-nil
+// body is JSON-encoded and sent with Content-Type application/json, unless
+// it is already an io.Reader, which is sent as is. NewRequest panics if the
+// body cannot be encoded, like httptest.NewRequest does for a bad target.
+func NewRequest(method, target string, body any) *http.Request {
+	var r io.Reader
+	isJSON := false
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			panic(fmt.Sprintf("functest: encoding request body: %v", err))
+		}
+		r = bytes.NewReader(data)
+		isJSON = true
+	}
+	req := httptest.NewRequest(method, target, r)
+	if isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// Response is the recorded result of Do.
+type Response struct {
+	rec *httptest.ResponseRecorder
+}
+
+// Do serves req with h and returns the recorded response.
+func Do(h http.Handler, req *http.Request) *Response {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return &Response{rec: rec}
+}
+
+// Status returns the response status code.
+func (r *Response) Status() int { return r.rec.Code }
+
+// Header returns the response headers.
+func (r *Response) Header() http.Header { return r.rec.Result().Header }
+
+// Body returns the response body.
+func (r *Response) Body() []byte { return r.rec.Body.Bytes() }
+
+// JSON decodes the response body into v. It fails if the response is not
+// application/json.
+func (r *Response) JSON(v any) error {
+	if ct := r.rec.Header().Get("Content-Type"); ct != "" && !isJSON(ct) {
+		return fmt.Errorf("functest: response Content-Type is %q, not JSON", ct)
+	}
+	return json.Unmarshal(r.rec.Body.Bytes(), v)
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
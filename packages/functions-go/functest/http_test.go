@@ -0,0 +1,54 @@
+package functest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        any
+		wantBody    string
+		contentType string
+	}{
+		{"nil", nil, "", ""},
+		{"struct", struct{ A int }{1}, `{"A":1}`, "application/json"},
+		{"string", "hi", `"hi"`, "application/json"},
+		{"reader", strings.NewReader("raw"), "raw", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRequest(http.MethodPost, "/", tt.body)
+			b, _ := io.ReadAll(r.Body)
+			if string(b) != tt.wantBody {
+				t.Fatalf("body = %q, want %q", b, tt.wantBody)
+			}
+			if ct := r.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Fatalf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestNewRequestPanicsOnUnencodableBody(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	NewRequest(http.MethodPost, "/", func() {})
+}
+
+func TestResponseJSONRejectsOtherTypes(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<p>hi</p>")
+	})
+	var v any
+	if err := Do(h, NewRequest(http.MethodGet, "/", nil)).JSON(&v); err == nil {
+		t.Fatal("expected an error decoding HTML as JSON")
+	}
+}
@@ -0,0 +1,97 @@
+package functest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+	"github.com/dot-do/functions/packages/functions-go/internal/memstore"
+)
+
+// MockKV is an in-memory functions.KVNamespace. It is safe for concurrent use.
+type MockKV struct {
+	kv *memstore.KV
+
+	// Now returns the current time and is used to expire keys. It defaults
+	// to time.Now.
+	Now func() time.Time
+}
+
+var _ functions.KVNamespace = (*MockKV)(nil)
+
+// NewMockKV returns an empty MockKV.
+func NewMockKV() *MockKV {
+	m := &MockKV{Now: time.Now}
+	m.kv = memstore.NewKV(func() time.Time { return m.Now() })
+	return m
+}
+
+// Get implements functions.KVNamespace.
+func (m *MockKV) Get(ctx context.Context, key string) ([]byte, error) {
+	v, _, err := m.GetWithMetadata(ctx, key)
+	return v, err
+}
+
+// GetWithMetadata implements functions.KVNamespace.
+func (m *MockKV) GetWithMetadata(ctx context.Context, key string) ([]byte, json.RawMessage, error) {
+	if err := memstore.CheckKVKey(key); err != nil {
+		return nil, nil, fmt.Errorf("functest: %w", err)
+	}
+	e, ok := m.kv.Get(key)
+	if !ok {
+		return nil, nil, functions.ErrKeyNotFound
+	}
+	return e.Value, e.Metadata, nil
+}
+
+// Put implements functions.KVNamespace. Like KV.Put, it rejects keys and
+// expiration TTLs that Workers KV would.
+func (m *MockKV) Put(ctx context.Context, key string, value []byte, opts *functions.KVPutOptions) error {
+	var md json.RawMessage
+	var ttl time.Duration
+	if opts != nil {
+		ttl = opts.ExpirationTTL
+	}
+	if err := memstore.CheckKVPut(key, ttl); err != nil {
+		return fmt.Errorf("functest: %w", err)
+	}
+	if opts != nil && opts.Metadata != nil {
+		var err error
+		if md, err = json.Marshal(opts.Metadata); err != nil {
+			return err
+		}
+	}
+	m.kv.Put(key, value, md, ttl)
+	return nil
+}
+
+// Delete implements functions.KVNamespace.
+func (m *MockKV) Delete(ctx context.Context, key string) error {
+	if err := memstore.CheckKVKey(key); err != nil {
+		return fmt.Errorf("functest: %w", err)
+	}
+	m.kv.Delete(key)
+	return nil
+}
+
+// List implements functions.KVNamespace. Keys are returned in lexicographic
+// order, and the cursor is the offset of the next page.
+func (m *MockKV) List(ctx context.Context, opts *functions.KVListOptions) (*functions.KVListResult, error) {
+	if opts == nil {
+		opts = &functions.KVListOptions{}
+	}
+	keys, next, err := m.kv.List(opts.Prefix, opts.Cursor, opts.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("functest: %w", err)
+	}
+	res := &functions.KVListResult{Cursor: next, ListComplete: next == ""}
+	for _, k := range keys {
+		res.Keys = append(res.Keys, functions.KVKey{Name: k.Name, Expiration: k.Expiration, Metadata: k.Metadata})
+	}
+	return res, nil
+}
+
+// Keys returns every live key, sorted.
+func (m *MockKV) Keys() []string { return m.kv.Keys() }
@@ -0,0 +1,102 @@
+package functest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockKVRoundTrip(t *testing.T) {
+	kv := NewMockKV()
+	ctx := context.Background()
+
+	if _, err := kv.Get(ctx, "user:1"); !errors.Is(err, functions.ErrKeyNotFound) {
+		t.Fatalf("err = %v, want ErrKeyNotFound", err)
+	}
+	if err := kv.Put(ctx, "user:1", []byte("ada"), &functions.KVPutOptions{Metadata: map[string]int{"v": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	v, md, err := kv.GetWithMetadata(ctx, "user:1")
+	if err != nil || string(v) != "ada" || string(md) != `{"v":2}` {
+		t.Fatalf("got %q %s %v", v, md, err)
+	}
+	if err := kv.Delete(ctx, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get(ctx, "user:1"); !errors.Is(err, functions.ErrKeyNotFound) {
+		t.Fatalf("after delete err = %v", err)
+	}
+}
+
+func TestMockKVExpiration(t *testing.T) {
+	now := time.Unix(1000, 0)
+	kv := NewMockKV()
+	kv.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	kv.Put(ctx, "session", []byte("x"), &functions.KVPutOptions{ExpirationTTL: time.Minute})
+	if _, err := kv.Get(ctx, "session"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := kv.Get(ctx, "session"); !errors.Is(err, functions.ErrKeyNotFound) {
+		t.Fatalf("expired key err = %v", err)
+	}
+}
+
+func TestMockKVRejectsWhatKVRejects(t *testing.T) {
+	kv := NewMockKV()
+	ctx := context.Background()
+
+	if err := kv.Put(ctx, "", []byte("v"), nil); err == nil {
+		t.Error("empty key accepted")
+	}
+	if err := kv.Put(ctx, strings.Repeat("k", 513), []byte("v"), nil); err == nil {
+		t.Error("key over 512 bytes accepted")
+	}
+	if err := kv.Put(ctx, "k", []byte("v"), &functions.KVPutOptions{ExpirationTTL: 30 * time.Second}); err == nil {
+		t.Error("TTL under 60s accepted")
+	}
+	if _, err := kv.Get(ctx, ""); err == nil || errors.Is(err, functions.ErrKeyNotFound) {
+		t.Errorf("Get of empty key: err = %v, want a validation error", err)
+	}
+	if len(kv.Keys()) != 0 {
+		t.Fatalf("keys = %v, want none stored", kv.Keys())
+	}
+}
+
+func TestMockKVListPagination(t *testing.T) {
+	kv := NewMockKV()
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		kv.Put(ctx, fmt.Sprintf("a:%d", i), nil, nil)
+	}
+	kv.Put(ctx, "b:0", nil, nil)
+
+	var got []string
+	opts := &functions.KVListOptions{Prefix: "a:", Limit: 2}
+	for {
+		page, err := kv.List(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range page.Keys {
+			got = append(got, k.Name)
+		}
+		if page.ListComplete {
+			break
+		}
+		opts.Cursor = page.Cursor
+	}
+
+	want := []string{"a:0", "a:1", "a:2", "a:3", "a:4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package functest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+	"github.com/dot-do/functions/packages/functions-go/internal/memstore"
+)
+
+// MockR2 is an in-memory functions.R2Store. It is safe for concurrent use.
+type MockR2 struct {
+	b *memstore.Bucket[r2Metadata]
+
+	// Now stamps uploaded objects. It defaults to time.Now.
+	Now func() time.Time
+}
+
+type r2Metadata struct {
+	http   functions.R2HTTPMetadata
+	custom map[string]string
+}
+
+var _ functions.R2Store = (*MockR2)(nil)
+
+// NewMockR2 returns an empty MockR2.
+func NewMockR2() *MockR2 {
+	m := &MockR2{Now: time.Now}
+	m.b = memstore.NewBucket[r2Metadata](func() time.Time { return m.Now() })
+	return m
+}
+
+// Get implements functions.R2Store.
+func (m *MockR2) Get(ctx context.Context, key string) (*functions.R2Object, error) {
+	if key == "" {
+		return nil, errors.New("functest: R2 key must not be empty")
+	}
+	o, ok := m.b.Get(key)
+	if !ok {
+		return nil, functions.ErrObjectNotFound
+	}
+	obj := r2Object(o)
+	obj.Body = io.NopCloser(bytes.NewReader(o.Data))
+	return obj, nil
+}
+
+// Put implements functions.R2Store. Unlike a real bucket it reads the whole
+// body, so R2PutOptions.Size is not required; if it is set it must match.
+func (m *MockR2) Put(ctx context.Context, key string, body io.Reader, opts *functions.R2PutOptions) (*functions.R2Object, error) {
+	if key == "" {
+		return nil, errors.New("functest: R2 key must not be empty")
+	}
+	if opts == nil {
+		opts = &functions.R2PutOptions{}
+	}
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Size > 0 && opts.Size != int64(len(data)) {
+		return nil, fmt.Errorf("functest: R2 put of %q declared %d bytes but the body had %d", key, opts.Size, len(data))
+	}
+	o := m.b.Put(key, data, r2Metadata{http: opts.HTTPMetadata, custom: opts.CustomMetadata})
+	return r2Object(o), nil
+}
+
+// Delete implements functions.R2Store.
+func (m *MockR2) Delete(ctx context.Context, key string) error {
+	m.b.Delete(key)
+	return nil
+}
+
+// List implements functions.R2Store. Objects are returned in lexicographic
+// order, keys under Delimiter are collapsed into DelimitedPrefixes, and the
+// cursor is the offset of the next page.
+func (m *MockR2) List(ctx context.Context, opts *functions.R2ListOptions) (*functions.R2ListResult, error) {
+	if opts == nil {
+		opts = &functions.R2ListOptions{}
+	}
+	objects, prefixes, next, err := m.b.List(opts.Prefix, opts.Delimiter, opts.Cursor, opts.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("functest: %w", err)
+	}
+	res := &functions.R2ListResult{Truncated: next != "", Cursor: next, DelimitedPrefixes: prefixes}
+	for _, o := range objects {
+		res.Objects = append(res.Objects, r2Object(o))
+	}
+	return res, nil
+}
+
+// Keys returns every stored key, sorted.
+func (m *MockR2) Keys() []string { return m.b.Keys() }
+
+// r2Object returns the functions.R2Object for o, without a Body.
+func r2Object(o memstore.Object[r2Metadata]) *functions.R2Object {
+	return &functions.R2Object{
+		Key:            o.Key,
+		Size:           int64(len(o.Data)),
+		ETag:           o.ETag,
+		Uploaded:       o.Uploaded,
+		HTTPMetadata:   o.Meta.http,
+		CustomMetadata: o.Meta.custom,
+	}
+}
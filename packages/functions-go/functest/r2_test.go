@@ -0,0 +1,83 @@
+package functest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockR2RoundTrip(t *testing.T) {
+	r2 := NewMockR2()
+	ctx := context.Background()
+
+	if _, err := r2.Get(ctx, "a.txt"); !errors.Is(err, functions.ErrObjectNotFound) {
+		t.Fatalf("err = %v, want ErrObjectNotFound", err)
+	}
+	obj, err := r2.Put(ctx, "a.txt", strings.NewReader("hello"), &functions.R2PutOptions{
+		HTTPMetadata: functions.R2HTTPMetadata{ContentType: "text/plain"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size != 5 || obj.ETag == "" {
+		t.Fatalf("put returned %+v", obj)
+	}
+
+	got, err := r2.Get(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Body.Close()
+	body, _ := io.ReadAll(got.Body)
+	if string(body) != "hello" || got.HTTPMetadata.ContentType != "text/plain" {
+		t.Fatalf("got %q %+v", body, got.HTTPMetadata)
+	}
+
+	if err := r2.Delete(ctx, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r2.Keys()) != 0 {
+		t.Fatalf("keys after delete = %v", r2.Keys())
+	}
+}
+
+func TestMockR2SizeMismatch(t *testing.T) {
+	_, err := NewMockR2().Put(context.Background(), "k", strings.NewReader("abc"), &functions.R2PutOptions{Size: 10})
+	if err == nil {
+		t.Fatal("expected an error for a short body")
+	}
+}
+
+func TestMockR2List(t *testing.T) {
+	r2 := NewMockR2()
+	ctx := context.Background()
+	for _, k := range []string{"img/a.png", "img/b.png", "img/thumbs/a.png", "doc.txt"} {
+		if _, err := r2.Put(ctx, k, strings.NewReader(k), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := r2.List(ctx, &functions.R2ListOptions{Prefix: "img/", Delimiter: "/", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 1 || res.Objects[0].Key != "img/a.png" || !res.Truncated {
+		t.Fatalf("first page = %+v", res)
+	}
+	if !reflect.DeepEqual(res.DelimitedPrefixes, []string{"img/thumbs/"}) {
+		t.Fatalf("prefixes = %v", res.DelimitedPrefixes)
+	}
+
+	res, err = r2.List(ctx, &functions.R2ListOptions{Prefix: "img/", Delimiter: "/", Cursor: res.Cursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 1 || res.Objects[0].Key != "img/b.png" || res.Truncated {
+		t.Fatalf("second page = %+v", res)
+	}
+}
@@ -0,0 +1,257 @@
+package functest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// MockVectorize is an in-memory Vectorize index using the cosine metric.
+// Queries compare the query vector with every stored vector, so results
+// are exact where Vectorize's are approximate, and upserts are visible to
+// the next query rather than eventually. Metadata filters support the
+// operators documented on functions.QueryOptions.
+//
+// MockVectorize embeds the *functions.VectorizeIndex it backs, so it can
+// be passed wherever one is expected. It is safe for concurrent use.
+type MockVectorize struct {
+	*functions.VectorizeIndex
+
+	dims    int
+	mu      sync.Mutex
+	vectors map[string]functions.Vector
+}
+
+// NewMockVectorize returns an empty MockVectorize for vectors of the given
+// number of dimensions. Upserting or querying with any other number is an
+// error, as it is for an index created with that many.
+func NewMockVectorize(dimensions int) *MockVectorize {
+	m := &MockVectorize{dims: dimensions, vectors: make(map[string]functions.Vector)}
+	m.VectorizeIndex = functions.NewVectorizeWithStore("mock", mockVectorStore{m})
+	return m
+}
+
+// Vectors returns the stored vectors, ordered by ID.
+func (m *MockVectorize) Vectors() []functions.Vector {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]functions.Vector, 0, len(m.vectors))
+	for _, v := range m.vectors {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// mockVectorStore implements functions.VectorStore for a MockVectorize.
+type mockVectorStore struct {
+	m *MockVectorize
+}
+
+func (s mockVectorStore) Upsert(ctx context.Context, vectors []functions.Vector) error {
+	if n := len(vectors[0].Values); n != s.m.dims {
+		return fmt.Errorf("vectors have %d dimensions, the index has %d", n, s.m.dims)
+	}
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, v := range vectors {
+		s.m.vectors[v.ID] = functions.Vector{
+			ID:       v.ID,
+			Values:   append([]float32(nil), v.Values...),
+			Metadata: copyMetadata(v.Metadata),
+		}
+	}
+	return nil
+}
+
+func (s mockVectorStore) Query(ctx context.Context, vector []float32, opts functions.QueryOptions) (*functions.QueryResult, error) {
+	if len(vector) != s.m.dims {
+		return nil, fmt.Errorf("query vector has %d dimensions, the index has %d", len(vector), s.m.dims)
+	}
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var matches []functions.VectorMatch
+	for _, v := range s.m.vectors {
+		ok, err := matchesFilter(v.Metadata, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		match := functions.VectorMatch{ID: v.ID, Score: cosine(vector, v.Values)}
+		if opts.ReturnValues {
+			match.Values = append([]float32(nil), v.Values...)
+		}
+		if opts.ReturnMetadata {
+			match.Metadata = copyMetadata(v.Metadata)
+		}
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > opts.TopK {
+		matches = matches[:opts.TopK]
+	}
+	return &functions.QueryResult{Matches: matches, Count: len(matches)}, nil
+}
+
+func (s mockVectorStore) DeleteByIDs(ctx context.Context, ids []string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, id := range ids {
+		delete(s.m.vectors, id)
+	}
+	return nil
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		na += x * x
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func copyMetadata(md map[string]any) map[string]any {
+	if md == nil {
+		return nil
+	}
+	out := make(map[string]any, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// matchesFilter reports whether metadata satisfies every condition of
+// filter.
+func matchesFilter(metadata, filter map[string]any) (bool, error) {
+	for field, cond := range filter {
+		ops, ok := cond.(map[string]any)
+		if !ok {
+			ops = map[string]any{"$eq": cond}
+		}
+		value, present := metadata[field]
+		for op, operand := range ops {
+			ok, err := matchOperator(op, value, present, operand)
+			if err != nil {
+				return false, fmt.Errorf("filter on %q: %w", field, err)
+			}
+			if !ok {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func matchOperator(op string, value any, present bool, operand any) (bool, error) {
+	switch op {
+	case "$eq":
+		return present && metadataEqual(value, operand), nil
+	case "$ne":
+		return !present || !metadataEqual(value, operand), nil
+	case "$in", "$nin":
+		list := reflect.ValueOf(operand)
+		if list.Kind() != reflect.Slice {
+			return false, fmt.Errorf("%s needs a list, got %T", op, operand)
+		}
+		in := false
+		for i := 0; i < list.Len() && present && !in; i++ {
+			in = metadataEqual(value, list.Index(i).Interface())
+		}
+		return in == (op == "$in"), nil
+	case "$lt", "$lte", "$gt", "$gte":
+		if !present {
+			return false, nil
+		}
+		c, ok := metadataCompare(value, operand)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "$lt":
+			return c < 0, nil
+		case "$lte":
+			return c <= 0, nil
+		case "$gt":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unsupported operator %s", op)
+}
+
+func metadataEqual(a, b any) bool {
+	if c, ok := metadataCompare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// metadataCompare orders two numbers or two strings, reporting false for
+// any other pair.
+func metadataCompare(a, b any) (int, bool) {
+	if x, ok := metadataNumber(a); ok {
+		y, ok := metadataNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := a.(string)
+	y, ok2 := b.(string)
+	if !ok || !ok2 {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+func metadataNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string, bool, nil:
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
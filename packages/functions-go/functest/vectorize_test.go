@@ -0,0 +1,120 @@
+package functest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+func TestMockVectorizeSearch(t *testing.T) {
+	docs := NewMockVectorize(3)
+	ctx := context.Background()
+	err := docs.Upsert(ctx, []functions.Vector{
+		{ID: "go", Values: []float32{1, 0, 0}, Metadata: map[string]any{"lang": "en", "year": 2021}},
+		{ID: "rust", Values: []float32{0.9, 0.1, 0}, Metadata: map[string]any{"lang": "en", "year": 2019}},
+		{ID: "wasm", Values: []float32{0, 1, 0}, Metadata: map[string]any{"lang": "de", "year": 2023}},
+		{ID: "none", Values: []float32{0, 0, 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := docs.Query(r.Context(), []float32{1, 0.05, 0}, functions.QueryOptions{TopK: 2, ReturnMetadata: true})
+		if err != nil {
+			functions.WriteError(w, r, err)
+			return
+		}
+		functions.WriteJSON(w, http.StatusOK, res)
+	})
+	resp := Do(h, NewRequest(http.MethodGet, "/search", nil))
+	var res functions.QueryResult
+	if err := resp.JSON(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Count != 2 || res.Matches[0].ID != "go" || res.Matches[1].ID != "rust" {
+		t.Fatalf("matches = %+v", res.Matches)
+	}
+	if res.Matches[0].Score <= res.Matches[1].Score || res.Matches[0].Score > 1 {
+		t.Errorf("scores = %v, %v", res.Matches[0].Score, res.Matches[1].Score)
+	}
+	if res.Matches[0].Metadata["lang"] != "en" || res.Matches[0].Values != nil {
+		t.Errorf("match = %+v, want metadata without values", res.Matches[0])
+	}
+
+	for _, tt := range []struct {
+		filter map[string]any
+		want   []string
+	}{
+		{map[string]any{"lang": "en"}, []string{"go", "rust"}},
+		{map[string]any{"year": map[string]any{"$gte": 2021}}, []string{"go", "wasm"}},
+		{map[string]any{"lang": map[string]any{"$in": []any{"de", "fr"}}}, []string{"wasm"}},
+		{map[string]any{"lang": map[string]any{"$ne": "en"}}, []string{"wasm", "none"}},
+		{map[string]any{"lang": "en", "year": map[string]any{"$lt": 2020}}, []string{"rust"}},
+	} {
+		res, err := docs.Query(ctx, []float32{1, 1, 1}, functions.QueryOptions{Filter: tt.filter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range res.Matches {
+			got = append(got, m.ID)
+		}
+		if len(got) != len(tt.want) || !sameIDs(got, tt.want) {
+			t.Errorf("filter %v: matches %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func sameIDs(got, want []string) bool {
+	seen := make(map[string]bool)
+	for _, id := range got {
+		seen[id] = true
+	}
+	for _, id := range want {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMockVectorizeUpsertAndDelete(t *testing.T) {
+	docs := NewMockVectorize(2)
+	ctx := context.Background()
+	if err := docs.Upsert(ctx, []functions.Vector{{ID: "a", Values: []float32{1, 0}}, {ID: "b", Values: []float32{1, 0, 0}}}); err == nil {
+		t.Error("vectors of mixed dimensions accepted")
+	}
+	if err := docs.Upsert(ctx, []functions.Vector{{ID: "a", Values: []float32{1, 0, 0}}}); err == nil {
+		t.Error("vector of the wrong dimension accepted")
+	}
+	if err := docs.Upsert(ctx, []functions.Vector{{ID: "a", Values: []float32{1, 0}}, {ID: "b", Values: []float32{0, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := docs.Upsert(ctx, []functions.Vector{{ID: "a", Values: []float32{0, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := docs.DeleteByIDs(ctx, []string{"b", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	vs := docs.Vectors()
+	if len(vs) != 1 || vs[0].ID != "a" || vs[0].Values[1] != 1 {
+		t.Fatalf("vectors = %+v", vs)
+	}
+
+	res, err := docs.Query(ctx, []float32{0, 2}, functions.QueryOptions{ReturnValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Matches) != 1 || res.Matches[0].Score < 0.999 || len(res.Matches[0].Values) != 2 {
+		t.Errorf("matches = %+v", res.Matches)
+	}
+	if _, err := docs.Query(ctx, []float32{1}, functions.QueryOptions{}); err == nil {
+		t.Error("query of the wrong dimension accepted")
+	}
+	if _, err := docs.Query(ctx, []float32{1, 0}, functions.QueryOptions{Filter: map[string]any{"x": map[string]any{"$regex": "."}}}); err == nil {
+		t.Error("unsupported filter operator accepted")
+	}
+}
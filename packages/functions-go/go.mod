@@ -0,0 +1,5 @@
+module github.com/dot-do/functions/packages/functions-go
+
+go 1.21.3
+
+require github.com/syumai/workers v0.31.0
@@ -0,0 +1,165 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GraphQLRequest is an operation sent by a GraphQL client.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+// GraphQLResponse is the result of an operation, in the shape the GraphQL
+// specification gives responses.
+type GraphQLResponse struct {
+	Data       any            `json:"data,omitempty"`
+	Errors     []GraphQLError `json:"errors,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLError is an entry in the errors of a GraphQLResponse.
+type GraphQLError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLSchema executes GraphQL operations. This package has no GraphQL
+// engine of its own, so the schema of one is wrapped to satisfy it; for
+// graphql-go, for example:
+//
+//	type schema struct{ graphql.Schema }
+//
+//	func (s schema) Execute(ctx context.Context, req functions.GraphQLRequest) *functions.GraphQLResponse {
+//		res := graphql.Do(graphql.Params{
+//			Schema:         s.Schema,
+//			RequestString:  req.Query,
+//			VariableValues: req.Variables,
+//			OperationName:  req.OperationName,
+//			Context:        ctx,
+//		})
+//		out := &functions.GraphQLResponse{Data: res.Data}
+//		for _, e := range res.Errors {
+//			out.Errors = append(out.Errors, functions.GraphQLError{Message: e.Message, Path: e.Path})
+//		}
+//		return out
+//	}
+//
+// Execute is given the request's context, so resolvers can read what
+// middleware put there, such as Claims, and the request itself with
+// GraphQLHTTPRequest.
+type GraphQLSchema interface {
+	Execute(ctx context.Context, req GraphQLRequest) *GraphQLResponse
+}
+
+// GraphQLOptions configures GraphQL.
+type GraphQLOptions struct {
+	// MaxDepth, if positive, rejects operations whose fields nest deeper
+	// than this; { user { posts { title } } } is 3 deep.
+	MaxDepth int
+
+	// MaxComplexity, if positive, rejects operations that select more
+	// fields than this in all, counting every use of a fragment.
+	MaxComplexity int
+}
+
+type graphQLRequestKey struct{}
+
+// GraphQLHTTPRequest returns the HTTP request of the operation a resolver is
+// running for, or nil outside GraphQL.
+func GraphQLHTTPRequest(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(graphQLRequestKey{}).(*http.Request)
+	return r
+}
+
+// GraphQL returns a handler serving schema over HTTP. It accepts POST
+// requests with a JSON body holding query, variables and operationName, and
+// GET requests with those as URL parameters, variables JSON-encoded. GET
+// requests may not run mutations, so that they stay safe to cache and
+// prefetch.
+//
+// Responses are JSON with data and errors, and a 200 status once the
+// operation has been executed, even if it reports errors. A request that
+// cannot be executed at all gets a 400 with only errors, as does one that
+// goes over MaxDepth or MaxComplexity. Those limits are checked before the
+// operation is executed, on the document's selection sets; everything else
+// about the query is left for the schema to validate.
+func GraphQL(schema GraphQLSchema, opts GraphQLOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GraphQLRequest
+		switch r.Method {
+		case http.MethodPost:
+			if err := DecodeJSON(r, &req); err != nil {
+				writeGraphQLError(w, graphQLStatus(err), clientMessage(err))
+				return
+			}
+		case http.MethodGet:
+			q := r.URL.Query()
+			req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+			if v := q.Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					writeGraphQLError(w, http.StatusBadRequest, "variables must be a JSON object")
+					return
+				}
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeGraphQLError(w, http.StatusMethodNotAllowed, "GraphQL requests must be GET or POST")
+			return
+		}
+		if req.Query == "" {
+			writeGraphQLError(w, http.StatusBadRequest, "query is required")
+			return
+		}
+
+		if opts.MaxDepth > 0 || opts.MaxComplexity > 0 || r.Method == http.MethodGet {
+			doc, err := parseGraphQL(req.Query)
+			if err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			op := doc.operation(req.OperationName)
+			if op == nil {
+				writeGraphQLError(w, http.StatusBadRequest, "operationName does not name exactly one operation of the query")
+				return
+			}
+			if r.Method == http.MethodGet && op.kind != "query" {
+				w.Header().Set("Allow", http.MethodPost)
+				writeGraphQLError(w, http.StatusMethodNotAllowed, op.kind+" operations must be POSTed")
+				return
+			}
+			if d := doc.depth(op.set); opts.MaxDepth > 0 && d > opts.MaxDepth {
+				writeGraphQLError(w, http.StatusBadRequest, fmt.Sprintf("query is %d levels deep, more than the maximum of %d", d, opts.MaxDepth))
+				return
+			}
+			if c := doc.complexity(op.set); opts.MaxComplexity > 0 && c > opts.MaxComplexity {
+				writeGraphQLError(w, http.StatusBadRequest, fmt.Sprintf("query selects %d fields, more than the maximum of %d", c, opts.MaxComplexity))
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), graphQLRequestKey{}, r)
+		resp := schema.Execute(ctx, req)
+		if resp == nil {
+			resp = &GraphQLResponse{}
+		}
+		WriteJSON(w, http.StatusOK, resp)
+	})
+}
+
+// graphQLStatus is the status of a request body DecodeJSON rejected.
+func graphQLStatus(err error) int {
+	if p := problemFor(err); p != nil {
+		return p.Status
+	}
+	return http.StatusBadRequest
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, msg string) {
+	WriteJSON(w, status, GraphQLResponse{Errors: []GraphQLError{{Message: msg}}})
+}
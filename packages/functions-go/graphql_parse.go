@@ -0,0 +1,405 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This file holds just enough of a GraphQL parser to measure queries before
+// they are executed: it reads the selection sets of a document, skipping
+// arguments, variables and directives, and leaves validation to the engine.
+
+// gqlSelectionSet is the shape of a selection set: its fields, the named
+// fragments it spreads and its inline fragments.
+type gqlSelectionSet struct {
+	fields  []*gqlSelectionSet // each field's own selection set, or nil
+	spreads []string
+	inlines []*gqlSelectionSet
+}
+
+type gqlOperation struct {
+	kind string // "query", "mutation" or "subscription"
+	name string
+	set  *gqlSelectionSet
+}
+
+type gqlDocument struct {
+	operations []gqlOperation
+	fragments  map[string]*gqlSelectionSet
+	measured   map[string]gqlMeasure
+}
+
+// operation returns the operation a request with the given operationName
+// runs, or nil if there is no such operation or the name is needed to
+// choose one.
+func (d *gqlDocument) operation(name string) *gqlOperation {
+	for i, op := range d.operations {
+		if name == "" && len(d.operations) == 1 || name != "" && op.name == name {
+			return &d.operations[i]
+		}
+	}
+	return nil
+}
+
+// depth is the deepest nesting of fields in s; a field without a selection
+// set is 1 deep.
+func (d *gqlDocument) depth(s *gqlSelectionSet) int {
+	deepest := 0
+	for _, f := range s.fields {
+		n := 1
+		if f != nil {
+			n += d.depth(f)
+		}
+		deepest = max(deepest, n)
+	}
+	for _, in := range s.inlines {
+		deepest = max(deepest, d.depth(in))
+	}
+	for _, name := range s.spreads {
+		deepest = max(deepest, d.fragment(name).depth)
+	}
+	return deepest
+}
+
+// complexity is the number of fields s selects, counting each use of a
+// fragment.
+func (d *gqlDocument) complexity(s *gqlSelectionSet) int {
+	n := 0
+	for _, f := range s.fields {
+		n++
+		if f != nil {
+			n += d.complexity(f)
+		}
+	}
+	for _, in := range s.inlines {
+		n += d.complexity(in)
+	}
+	for _, name := range s.spreads {
+		n += d.fragment(name).complexity
+	}
+	return n
+}
+
+// gqlMeasure is the depth and complexity of a fragment.
+type gqlMeasure struct {
+	depth, complexity int
+}
+
+// fragment measures the named fragment once, however often it is spread,
+// so nested spreads cannot make measuring expensive. A fragment that spreads
+// itself, which validation rejects anyway, counts as empty where it does.
+func (d *gqlDocument) fragment(name string) gqlMeasure {
+	if m, ok := d.measured[name]; ok {
+		return m
+	}
+	frag := d.fragments[name]
+	if frag == nil {
+		return gqlMeasure{}
+	}
+	if d.measured == nil {
+		d.measured = make(map[string]gqlMeasure)
+	}
+	d.measured[name] = gqlMeasure{}
+	m := gqlMeasure{depth: d.depth(frag), complexity: d.complexity(frag)}
+	d.measured[name] = m
+	return m
+}
+
+type gqlParser struct {
+	src string
+	pos int
+	tok string // current token; "" at the end
+}
+
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{src: src}
+	doc := &gqlDocument{fragments: make(map[string]*gqlSelectionSet)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	for p.tok != "" {
+		switch p.tok {
+		case "{":
+			set, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, gqlOperation{kind: "query", set: set})
+		case "query", "mutation", "subscription":
+			op := gqlOperation{kind: p.tok}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if isGQLName(p.tok) {
+				op.name = p.tok
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.skipUntilSelectionSet(); err != nil {
+				return nil, err
+			}
+			set, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			op.set = set
+			doc.operations = append(doc.operations, op)
+		case "fragment":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name := p.tok
+			if !isGQLName(name) {
+				return nil, p.errorf("expected fragment name")
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if err := p.skipUntilSelectionSet(); err != nil {
+				return nil, err
+			}
+			set, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = set
+		default:
+			return nil, p.errorf("unexpected %q", p.tok)
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("no operations in document")
+	}
+	return doc, nil
+}
+
+// selectionSet parses the selection set starting at the current "{".
+func (p *gqlParser) selectionSet() (*gqlSelectionSet, error) {
+	if p.tok != "{" {
+		return nil, p.errorf("expected {")
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	set := &gqlSelectionSet{}
+	for p.tok != "}" {
+		switch {
+		case p.tok == "":
+			return nil, p.errorf("unterminated selection set")
+		case p.tok == "...":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if isGQLName(p.tok) && p.tok != "on" {
+				set.spreads = append(set.spreads, p.tok)
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				if err := p.skipDirectives(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := p.skipUntilSelectionSet(); err != nil {
+				return nil, err
+			}
+			in, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			set.inlines = append(set.inlines, in)
+		case isGQLName(p.tok):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok == ":" { // the name was an alias
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				if !isGQLName(p.tok) {
+					return nil, p.errorf("expected field name after alias")
+				}
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+			if p.tok == "(" {
+				if err := p.skipParens(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.skipDirectives(); err != nil {
+				return nil, err
+			}
+			var sub *gqlSelectionSet
+			if p.tok == "{" {
+				var err error
+				if sub, err = p.selectionSet(); err != nil {
+					return nil, err
+				}
+			}
+			set.fields = append(set.fields, sub)
+		default:
+			return nil, p.errorf("unexpected %q in selection set", p.tok)
+		}
+	}
+	return set, p.next()
+}
+
+// skipUntilSelectionSet skips variable definitions, type conditions and
+// directives up to the next "{".
+func (p *gqlParser) skipUntilSelectionSet() error {
+	for p.tok != "{" {
+		switch p.tok {
+		case "":
+			return p.errorf("expected {")
+		case "(":
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		default:
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *gqlParser) skipDirectives() error {
+	for p.tok == "@" {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if !isGQLName(p.tok) {
+			return p.errorf("expected directive name")
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.tok == "(" {
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipParens skips from the current "(" past its matching ")". Braces
+// inside are input objects, not selection sets.
+func (p *gqlParser) skipParens() error {
+	depth := 0
+	for {
+		switch p.tok {
+		case "":
+			return p.errorf("unterminated (")
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *gqlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token into p.tok. Strings and numbers come out as a
+// placeholder, since only the structure of the document matters here.
+func (p *gqlParser) next() error {
+	src := p.src
+	for p.pos < len(src) {
+		c := src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(src) && src[p.pos] != '\n' && src[p.pos] != '\r' {
+				p.pos++
+			}
+		case strings.HasPrefix(src[p.pos:], "\uFEFF"):
+			p.pos += len("\uFEFF")
+		default:
+			return p.token()
+		}
+	}
+	p.tok = ""
+	return nil
+}
+
+func (p *gqlParser) token() error {
+	src, start := p.src, p.pos
+	c := src[start]
+	switch {
+	case strings.HasPrefix(src[start:], "..."):
+		p.pos += 3
+		p.tok = "..."
+	case strings.ContainsRune("!$&():=@[]{|}", rune(c)):
+		p.pos++
+		p.tok = src[start:p.pos]
+	case strings.HasPrefix(src[start:], `"""`):
+		end := strings.Index(src[start+3:], `"""`)
+		for end >= 0 && src[start+3+end-1] == '\\' {
+			next := strings.Index(src[start+3+end+3:], `"""`)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += 3 + next
+		}
+		if end < 0 {
+			return p.errorf("unterminated block string")
+		}
+		p.pos = start + 3 + end + 3
+		p.tok = `""`
+	case c == '"':
+		p.pos++
+		for {
+			if p.pos >= len(src) || src[p.pos] == '\n' {
+				return p.errorf("unterminated string")
+			}
+			if src[p.pos] == '\\' {
+				p.pos += 2
+				continue
+			}
+			p.pos++
+			if src[p.pos-1] == '"' {
+				break
+			}
+		}
+		p.tok = `""`
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		for p.pos < len(src) && strings.IndexByte("0123456789.eE+-", src[p.pos]) >= 0 {
+			p.pos++
+		}
+		p.tok = "0"
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		p.pos++
+		for p.pos < len(src) && isGQLNameByte(src[p.pos]) {
+			p.pos++
+		}
+		p.tok = src[start:p.pos]
+	default:
+		return p.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+func isGQLName(tok string) bool {
+	return tok != "" && (tok[0] == '_' || tok[0] >= 'A' && tok[0] <= 'Z' || tok[0] >= 'a' && tok[0] <= 'z')
+}
+
+func isGQLNameByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
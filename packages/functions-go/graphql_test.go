@@ -0,0 +1,179 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// greetSchema answers { greeting } with a greeting for $name, or for the
+// subject of the request's JWT claims.
+type greetSchema struct{}
+
+func (greetSchema) Execute(ctx context.Context, req GraphQLRequest) *GraphQLResponse {
+	if !strings.Contains(req.Query, "greeting") {
+		return &GraphQLResponse{Errors: []GraphQLError{{Message: "Cannot query field", Path: []any{"unknown"}}}}
+	}
+	name, _ := req.Variables["name"].(string)
+	if name == "" {
+		name = Claims(ctx).Subject()
+	}
+	method := ""
+	if r := GraphQLHTTPRequest(ctx); r != nil {
+		method = r.Method
+	}
+	return &GraphQLResponse{Data: map[string]any{"greeting": "Hello, " + name, "method": method}}
+}
+
+func graphQLDo(t *testing.T, h http.Handler, r *http.Request) (int, GraphQLResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var resp GraphQLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	return w.Code, resp
+}
+
+func graphQLPost(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	h := GraphQL(greetSchema{}, GraphQLOptions{})
+	r := graphQLPost(`{"query": "{ greeting }"}`)
+	r = r.WithContext(context.WithValue(r.Context(), claimsKey{}, JWTClaims{"sub": "ada"}))
+
+	status, resp := graphQLDo(t, h, r)
+	if status != http.StatusOK || len(resp.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %v", status, resp.Errors)
+	}
+	data := resp.Data.(map[string]any)
+	if data["greeting"] != "Hello, ada" || data["method"] != http.MethodPost {
+		t.Errorf("data = %v", data)
+	}
+}
+
+func TestGraphQLVariables(t *testing.T) {
+	h := GraphQL(greetSchema{}, GraphQLOptions{MaxDepth: 5})
+	status, resp := graphQLDo(t, h, graphQLPost(`{
+		"query": "query Greet($name: String!) { greeting(name: $name) }",
+		"variables": {"name": "grace"},
+		"operationName": "Greet"
+	}`))
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, errors = %v", status, resp.Errors)
+	}
+	if got := resp.Data.(map[string]any)["greeting"]; got != "Hello, grace" {
+		t.Errorf("greeting = %v", got)
+	}
+
+	q := url.Values{
+		"query":     {"query Greet($name: String!) { greeting(name: $name) }"},
+		"variables": {`{"name": "linus"}`},
+	}
+	status, resp = graphQLDo(t, h, httptest.NewRequest(http.MethodGet, "/graphql?"+q.Encode(), nil))
+	if status != http.StatusOK {
+		t.Fatalf("GET status = %d, errors = %v", status, resp.Errors)
+	}
+	data := resp.Data.(map[string]any)
+	if data["greeting"] != "Hello, linus" || data["method"] != http.MethodGet {
+		t.Errorf("GET data = %v", data)
+	}
+}
+
+func TestGraphQLExecutionErrors(t *testing.T) {
+	status, resp := graphQLDo(t, GraphQL(greetSchema{}, GraphQLOptions{}), graphQLPost(`{"query": "{ unknown }"}`))
+	if status != http.StatusOK || len(resp.Errors) != 1 || resp.Data != nil {
+		t.Errorf("status = %d, response = %+v", status, resp)
+	}
+}
+
+func TestGraphQLMaxDepth(t *testing.T) {
+	h := GraphQL(greetSchema{}, GraphQLOptions{MaxDepth: 3})
+	status, resp := graphQLDo(t, h, graphQLPost(`{"query": "{ greeting user { friends { friends { name } } } }"}`))
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "4 levels deep") {
+		t.Errorf("errors = %v", resp.Errors)
+	}
+
+	status, _ = graphQLDo(t, h, graphQLPost(`{"query": "{ greeting user { friends { name } } }"}`))
+	if status != http.StatusOK {
+		t.Errorf("3 levels: status = %d, want 200", status)
+	}
+}
+
+func TestGraphQLMaxComplexity(t *testing.T) {
+	h := GraphQL(greetSchema{}, GraphQLOptions{MaxComplexity: 4})
+	status, resp := graphQLDo(t, h, graphQLPost(`{"query": "{ greeting ...F ...F } fragment F on Query { a b }"}`))
+	if status != http.StatusBadRequest || !strings.Contains(resp.Errors[0].Message, "selects 5 fields") {
+		t.Errorf("status = %d, errors = %v", status, resp.Errors)
+	}
+}
+
+func TestGraphQLRejects(t *testing.T) {
+	h := GraphQL(greetSchema{}, GraphQLOptions{})
+	mutation := url.Values{"query": {"mutation { greeting }"}}
+	for _, tt := range []struct {
+		name   string
+		r      *http.Request
+		status int
+	}{
+		{"no query", graphQLPost(`{}`), http.StatusBadRequest},
+		{"malformed body", graphQLPost(`{"query":`), http.StatusBadRequest},
+		{"bad variables", httptest.NewRequest(http.MethodGet, "/graphql?query=%7Bgreeting%7D&variables=nope", nil), http.StatusBadRequest},
+		{"mutation over GET", httptest.NewRequest(http.MethodGet, "/graphql?"+mutation.Encode(), nil), http.StatusMethodNotAllowed},
+		{"PUT", httptest.NewRequest(http.MethodPut, "/graphql", nil), http.StatusMethodNotAllowed},
+	} {
+		status, resp := graphQLDo(t, h, tt.r)
+		if status != tt.status || len(resp.Errors) != 1 {
+			t.Errorf("%s: status = %d, errors = %v; want %d", tt.name, status, resp.Errors, tt.status)
+		}
+	}
+}
+
+func TestGraphQLMeasure(t *testing.T) {
+	for _, tt := range []struct {
+		query             string
+		op                string
+		depth, complexity int
+	}{
+		{"{ a }", "", 1, 1},
+		{"{ a { b c } d }", "", 2, 4},
+		{`query Q($v: In = {x: {y: 1}}) { me: user(filter: {name: "}{"}) @include(if: true) { id } }`, "", 2, 2},
+		{"# comment {\n{ a { ... on User { b { c } } } }", "", 3, 3},
+		{"{ a ...F } fragment F on Q { b { ...G } } fragment G on Q { c }", "", 2, 3},
+		{"query A { a } query B { b { c } }", "B", 2, 2},
+		{`{ a(s: """block } { \""" still""") }`, "", 1, 1},
+		{"{ ...F } fragment F on Q { a { ...F } }", "", 1, 1},
+	} {
+		doc, err := parseGraphQL(tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		op := doc.operation(tt.op)
+		if op == nil {
+			t.Errorf("%q: no operation %q", tt.query, tt.op)
+			continue
+		}
+		if d, c := doc.depth(op.set), doc.complexity(op.set); d != tt.depth || c != tt.complexity {
+			t.Errorf("%q: depth %d, complexity %d; want %d, %d", tt.query, d, c, tt.depth, tt.complexity)
+		}
+	}
+
+	for _, bad := range []string{"", "{ a", "{ a(b: 1 }", `{ a(s: "open) }`, "type Query { a: Int }"} {
+		if _, err := parseGraphQL(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package functions
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// HandlerFunc is a handler that reports failure by returning an error
+// instead of writing the error response itself. Use H to serve it.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// H adapts fn to an http.Handler that writes any error fn returns with
+// WriteError, so a handler can end with a single return:
+//
+//	rt.Get("/users/:id", functions.H(func(w http.ResponseWriter, r *http.Request) error {
+//		u, err := users.Find(r.Context(), functions.Param(r, "id"))
+//		if err != nil {
+//			return err
+//		}
+//		if u == nil {
+//			return functions.NotFound("no such user")
+//		}
+//		functions.WriteJSON(w, http.StatusOK, u)
+//		return nil
+//	}))
+//
+// If fn has already started its response when it returns an error, the
+// status can no longer be changed, so the error is only logged with
+// slog.Default.
+func H(fn HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := NewResponseRecorder(w)
+		err := fn(hw, r)
+		if err == nil {
+			return
+		}
+		if hw.Written() {
+			slog.Default().LogAttrs(r.Context(), slog.LevelWarn, "handler error after response committed",
+				slog.String("request_id", RequestID(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		WriteError(w, r, err)
+	})
+}
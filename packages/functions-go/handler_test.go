@@ -0,0 +1,69 @@
+package functions
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveH(fn HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	H(fn).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	return w
+}
+
+func TestHHappyPath(t *testing.T) {
+	w := serveH(func(w http.ResponseWriter, r *http.Request) error {
+		WriteJSON(w, http.StatusOK, map[string]string{"id": "42"})
+		return nil
+	})
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"42"}`+"\n" {
+		t.Fatalf("%d %q", w.Code, w.Body)
+	}
+}
+
+func TestHReturnedError(t *testing.T) {
+	w := serveH(func(w http.ResponseWriter, r *http.Request) error {
+		return NotFound("no such user")
+	})
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("%d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"detail":"no such user"`) {
+		t.Fatalf("body = %s", w.Body)
+	}
+}
+
+func TestHRawError(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+
+	w := serveH(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("query users: connection reset")
+	})
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "connection reset") {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+}
+
+func TestHErrorAfterCommit(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	w := serveH(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		return errors.New("stream broke")
+	})
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("response rewritten: %d %q", w.Code, w.Body)
+	}
+	if !strings.Contains(logs.String(), "stream broke") {
+		t.Errorf("error not logged: %q", logs.String())
+	}
+}
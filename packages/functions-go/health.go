@@ -0,0 +1,121 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+)
+
+// healthCheckTimeout bounds each readiness check.
+var healthCheckTimeout = 2 * time.Second
+
+// HealthCheck is a named dependency probe run by Health, such as a D1 query
+// or a KV read. Check should return promptly once ctx is done.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthStatus is the JSON body written by Health.
+type HealthStatus struct {
+	Status string              `json:"status"` // "ok" or "unavailable"
+	Checks []HealthCheckStatus `json:"checks,omitempty"`
+}
+
+// HealthCheckStatus reports the outcome of one HealthCheck.
+type HealthCheckStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+// Health returns a handler for liveness and readiness probes.
+//
+// Requests whose path ends in /healthz are liveness probes and always get
+// 200 without running any checks. Every other path is a readiness probe:
+// the checks run concurrently, each bounded by a short timeout, and the
+// response is 200 if all of them pass or 503 naming the failures. A check
+// that ignores its context is abandoned when the timeout expires, so it
+// cannot hold the response. Mount the handler on both paths:
+//
+//	health := functions.Health(functions.HealthCheck{Name: "db", Check: pingDB})
+//	rt.Get("/healthz", health)
+//	rt.Get("/readyz", health)
+//
+// Only GET and HEAD are allowed.
+func Health(checks ...HealthCheck) http.Handler {
+	checks = append([]HealthCheck(nil), checks...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": http.StatusText(http.StatusMethodNotAllowed)})
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if path.Base(r.URL.Path) == "healthz" {
+			WriteJSON(w, http.StatusOK, HealthStatus{Status: "ok"})
+			return
+		}
+
+		res := runHealthChecks(r.Context(), checks)
+		status := http.StatusOK
+		if res.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		WriteJSON(w, status, res)
+	})
+}
+
+func runHealthChecks(ctx context.Context, checks []HealthCheck) HealthStatus {
+	res := HealthStatus{Status: "ok", Checks: make([]HealthCheckStatus, len(checks))}
+	done := make(chan struct{}, len(checks))
+	for i, c := range checks {
+		go func(i int, c HealthCheck) {
+			defer func() { done <- struct{}{} }()
+			res.Checks[i] = HealthCheckStatus{Name: c.Name, Status: "ok"}
+			if err := runHealthCheck(ctx, c); err != nil {
+				res.Checks[i].Status = "fail"
+				res.Checks[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+	for range checks {
+		<-done
+	}
+	for _, c := range res.Checks {
+		if c.Status != "ok" {
+			res.Status = "unavailable"
+		}
+	}
+	return res
+}
+
+// runHealthCheck runs c with a timeout and gives up waiting when it expires,
+// even if c does not.
+func runHealthCheck(ctx context.Context, c HealthCheck) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				result <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		result <- c.Check(ctx)
+	}()
+
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", healthCheckTimeout)
+	}
+	return err
+}
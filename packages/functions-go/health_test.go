@@ -0,0 +1,128 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveHealth(t *testing.T, h http.Handler, target string) (int, HealthStatus) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	var body HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func okCheck(name string) HealthCheck {
+	return HealthCheck{Name: name, Check: func(context.Context) error { return nil }}
+}
+
+func TestHealthAllPass(t *testing.T) {
+	h := Health(okCheck("db"), okCheck("kv"))
+
+	code, body := serveHealth(t, h, "/readyz")
+	if code != http.StatusOK || body.Status != "ok" {
+		t.Fatalf("got %d %q, want 200 ok", code, body.Status)
+	}
+	if len(body.Checks) != 2 || body.Checks[0].Name != "db" || body.Checks[1].Name != "kv" {
+		t.Fatalf("checks = %+v, want db and kv in order", body.Checks)
+	}
+	for _, c := range body.Checks {
+		if c.Status != "ok" {
+			t.Errorf("check %s = %q, want ok", c.Name, c.Status)
+		}
+	}
+}
+
+func TestHealthOneFails(t *testing.T) {
+	h := Health(okCheck("db"), HealthCheck{Name: "kv", Check: func(context.Context) error {
+		return errors.New("binding missing")
+	}})
+
+	code, body := serveHealth(t, h, "/readyz")
+	if code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Fatalf("got %d %q, want 503 unavailable", code, body.Status)
+	}
+	if body.Checks[0].Status != "ok" {
+		t.Errorf("db = %+v, want ok", body.Checks[0])
+	}
+	if kv := body.Checks[1]; kv.Status != "fail" || kv.Error != "binding missing" {
+		t.Errorf("kv = %+v, want fail with the check's error", kv)
+	}
+}
+
+func TestHealthHangingCheck(t *testing.T) {
+	defer func(d time.Duration) { healthCheckTimeout = d }(healthCheckTimeout)
+	healthCheckTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	h := Health(
+		// Ignores its context entirely.
+		HealthCheck{Name: "stuck", Check: func(context.Context) error { <-release; return nil }},
+		HealthCheck{Name: "slow", Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		okCheck("db"),
+	)
+
+	start := time.Now()
+	code, body := serveHealth(t, h, "/readyz")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("readiness took %v; checks should run concurrently and be bounded", elapsed)
+	}
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", code)
+	}
+	for _, c := range body.Checks[:2] {
+		if c.Status != "fail" || !strings.Contains(c.Error, "timed out") {
+			t.Errorf("%s = %+v, want a timeout failure", c.Name, c)
+		}
+	}
+	if body.Checks[2].Status != "ok" {
+		t.Errorf("db = %+v, want ok", body.Checks[2])
+	}
+}
+
+func TestHealthLiveness(t *testing.T) {
+	ran := false
+	h := Health(HealthCheck{Name: "db", Check: func(context.Context) error {
+		ran = true
+		return errors.New("down")
+	}})
+
+	code, body := serveHealth(t, h, "/healthz")
+	if code != http.StatusOK || body.Status != "ok" || len(body.Checks) != 0 {
+		t.Fatalf("got %d %+v, want a bare 200 ok", code, body)
+	}
+	if ran {
+		t.Fatal("liveness probe ran the readiness checks")
+	}
+}
+
+func TestHealthPanickingCheck(t *testing.T) {
+	h := Health(HealthCheck{Name: "bad", Check: func(context.Context) error { panic("boom") }})
+
+	code, body := serveHealth(t, h, "/readyz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body.Checks[0].Error, "boom") {
+		t.Fatalf("got %d %+v, want 503 reporting the panic", code, body)
+	}
+}
+
+func TestHealthMethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	Health().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("got %d Allow=%q, want 405 with Allow", w.Code, w.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,205 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryOptions configures HTTPClient retries.
+type RetryOptions struct {
+	// MaxRetries is the number of retries after the first attempt. If zero,
+	// 3 retries are made; a negative value disables retrying.
+	MaxRetries int
+
+	// BaseDelay is the backoff before the first retry, doubled for each
+	// later one. If zero, 100ms is used.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff between attempts. A Retry-After longer than
+	// MaxDelay ends retrying and returns the response as is. If zero, 5s is
+	// used.
+	MaxDelay time.Duration
+
+	// RetryOn reports whether an attempt should be retried. If nil,
+	// transport errors and 429, 502, 503 and 504 responses are retried.
+	RetryOn func(*http.Response, error) bool
+}
+
+// HTTPClient sends outbound requests, retrying transient failures of
+// idempotent requests with exponential backoff and jitter.
+//
+// GET, HEAD, OPTIONS, TRACE, PUT and DELETE requests are retried, as are
+// requests carrying an Idempotency-Key header. Other requests are sent once.
+// Retrying stops as soon as the request context is done, and every attempt
+// counts against the budget of SubrequestLimit.
+type HTTPClient struct {
+	// Client sends each attempt. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	Retry RetryOptions
+}
+
+// NewHTTPClient returns an HTTPClient using http.DefaultClient and opts.
+func NewHTTPClient(opts RetryOptions) *HTTPClient {
+	return &HTTPClient{Retry: opts}
+}
+
+// Do sends req, retrying as configured. After the last attempt the final
+// response or error is returned as is, so callers see the upstream's
+// status. A request whose body cannot be sent again is sent once, like a
+// non-idempotent one; set Request.GetBody, as http.NewRequest does for
+// in-memory bodies, to make it retryable.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	maxRetries, base, maxDelay := c.Retry.MaxRetries, c.Retry.BaseDelay, c.Retry.MaxDelay
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 5 * time.Second
+	}
+	retryOn := c.Retry.RetryOn
+	if retryOn == nil {
+		retryOn = defaultRetryOn
+	}
+	if !isIdempotent(req) || !replayable(req) {
+		maxRetries = 0
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := takeSubrequest(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if attempt >= maxRetries || ctx.Err() != nil || !retryOn(resp, err) {
+			return resp, err
+		}
+
+		delay := backoff(base, maxDelay, attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if d > maxDelay {
+					return resp, err
+				}
+				delay = d
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		next, ok := rewindRequest(req)
+		if !ok {
+			return resp, err
+		}
+		drain(resp)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		req = next
+	}
+}
+
+func defaultRetryOn(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// backoff returns a random delay in [0, min(maxDelay, base<<attempt)).
+func backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	d := maxDelay
+	if attempt < 32 && base<<attempt > 0 && base<<attempt < maxDelay {
+		d = base << attempt
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// retryAfter parses a Retry-After value in seconds or as an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// replayable reports whether req's body can be sent more than once.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of req with a fresh body for the next attempt.
+// It reports false if the body cannot be produced again.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, true
+}
+
+// drain discards a response that is going to be retried, so the connection
+// can be reused.
+func drain(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
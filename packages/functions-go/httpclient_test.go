@@ -0,0 +1,182 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first n requests with status, then answers 200 with
+// the request body echoed back.
+func flakyServer(t *testing.T, n int32, status int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func fastRetries(max int) *HTTPClient {
+	return NewHTTPClient(RetryOptions{MaxRetries: max, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+}
+
+func TestHTTPClientRetriesUntilSuccess(t *testing.T) {
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	resp, err := fastRetries(3).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("calls = %d, want 3", n)
+	}
+}
+
+func TestHTTPClientGivesUp(t *testing.T) {
+	srv, calls := flakyServer(t, 10, http.StatusTooManyRequests)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := fastRetries(2).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("calls = %d, want 3", n)
+	}
+}
+
+func TestHTTPClientDoesNotRetryPost(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("x"))
+	resp, err := fastRetries(3).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("got %d after %d calls", resp.StatusCode, calls.Load())
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("x"))
+	req.Header.Set("Idempotency-Key", "abc")
+	resp, err = fastRetries(3).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("with Idempotency-Key: status = %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPClientNonReplayableBody(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable)
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("stream")))
+	req.GetBody = nil
+	resp, err := fastRetries(3).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want the 503 that was not retried", resp.StatusCode)
+	}
+	resp.Body.Close()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("calls = %d, want 1", n)
+	}
+}
+
+func TestHTTPClientStopsOnContextCancel(t *testing.T) {
+	srv, calls := flakyServer(t, 100, http.StatusServiceUnavailable)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewHTTPClient(RetryOptions{
+		MaxRetries: 100,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+		RetryOn: func(resp *http.Response, err error) bool {
+			if calls.Load() == 2 {
+				cancel()
+			}
+			return true
+		},
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("calls = %d, want 2", n)
+	}
+}
+
+func TestHTTPClientLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := fastRetries(3).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d; a Retry-After beyond MaxDelay should not be waited for", calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v    string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.v, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v; want %s, %v", tt.v, got, ok, tt.want, tt.ok)
+		}
+	}
+}
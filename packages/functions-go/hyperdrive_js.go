@@ -0,0 +1,17 @@
+//go:build js && wasm
+
+package functions
+
+import "fmt"
+
+func openHyperdrive(binding string) (string, error) {
+	hd, err := lookupBinding(binding)
+	if err != nil {
+		return "", err
+	}
+	s := hd.Get("connectionString")
+	if isNullish(s) {
+		return "", fmt.Errorf("functions: Hyperdrive %s: binding has no connectionString; is it a [[hyperdrive]] binding?", binding)
+	}
+	return s.String(), nil
+}
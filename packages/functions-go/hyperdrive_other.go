@@ -0,0 +1,12 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openHyperdrive(binding string) (string, error) {
+	if s, ok := lookupEnv(hyperdriveLocalEnv + binding); ok && s != "" {
+		return s, nil
+	}
+	return "", fmt.Errorf("%w: %q (Hyperdrive is only available in the Workers runtime; set %s%s to a local database's connection string)", ErrBindingNotFound, binding, hyperdriveLocalEnv, binding)
+}
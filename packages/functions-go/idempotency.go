@@ -0,0 +1,496 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// idempotencyTTL is how long a completed response is replayed.
+	idempotencyTTL = 24 * time.Hour
+
+	// idempotencyLockTTL bounds how long a request holds its key. A request
+	// that runs longer, or a Worker that dies mid-request, frees the key for
+	// a retry once it passes.
+	idempotencyLockTTL = time.Minute
+
+	maxIdempotencyKeyLen       = 255
+	maxIdempotentResponseBytes = 1 << 20
+)
+
+// IdempotencyStore records which idempotency keys are in flight and the
+// responses of those that have completed.
+type IdempotencyStore interface {
+	// Lock claims key for a request that is about to run. If a request
+	// with key has completed, Lock returns its response; if one is still
+	// running, it returns a nil response and acquired false. Otherwise key
+	// is held for the caller for up to lockTTL and acquired is true.
+	Lock(ctx context.Context, key string, lockTTL time.Duration) (done *CachedResponse, acquired bool, err error)
+
+	// Complete stores resp under the held key for ttl, ending the lock.
+	Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+
+	// Release ends the lock on key without storing a response, so that a
+	// retry runs the handler again.
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency returns middleware that runs each mutating request at most
+// once per Idempotency-Key header, for endpoints such as payments that a
+// client must be able to retry safely:
+//
+//	rt.Handle(http.MethodPost, "/charges", functions.Idempotency(store)(createCharge))
+//
+// POST, PUT, PATCH and DELETE requests with the header are handled in one
+// of three ways:
+//
+//   - if a request with the same key has completed, its status, headers
+//     and body are replayed with an Idempotent-Replayed: true header, and
+//     the handler does not run;
+//   - if one is still running, the request gets a 409 Conflict with
+//     Retry-After, and the handler does not run;
+//   - otherwise the handler runs and its response is kept for replay for
+//     up to 24 hours.
+//
+// Keys are scoped to the method and path, so reusing a key on another
+// endpoint runs that endpoint independently. Responses with a 5xx status,
+// panics, flushed (streamed) responses and bodies over 1 MiB are not
+// kept: the key is released so that a retry runs the handler again. If the
+// store fails, the request is refused with a 503 rather than risk running
+// it twice. Requests without the header, or with other methods, pass
+// straight through.
+//
+// How reliably concurrent duplicates are caught, and whether a response
+// is kept for the full 24 hours, depends on the store; see
+// NewKVIdempotencyStore and NewDurableObjectIdempotencyStore.
+func Idempotency(store IdempotencyStore) Middleware {
+	logger := slog.Default()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idemKey := r.Header.Get("Idempotency-Key")
+			if idemKey == "" || !isMutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(idemKey) > maxIdempotencyKeyLen {
+				WriteError(w, r, BadRequest(fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen)))
+				return
+			}
+			key := r.Method + " " + r.URL.Path + " " + idemKey
+
+			done, acquired, err := store.Lock(r.Context(), key, idempotencyLockTTL)
+			if err != nil {
+				logger.Error("idempotency store failed; request refused", "key", key, "error", err)
+				WriteError(w, r, &Error{Status: http.StatusServiceUnavailable, Code: "idempotency_unavailable", Message: "the request cannot be deduplicated right now; retry it"})
+				return
+			}
+			if done != nil {
+				h := w.Header()
+				for k, vs := range done.Header {
+					h[k] = append([]string(nil), vs...)
+				}
+				h.Set("Idempotent-Replayed", "true")
+				w.WriteHeader(done.Status)
+				w.Write(done.Body)
+				return
+			}
+			if !acquired {
+				w.Header().Set("Retry-After", "1")
+				WriteError(w, r, &Error{Status: http.StatusConflict, Code: "idempotency_conflict", Message: "a request with this Idempotency-Key is still in progress"})
+				return
+			}
+
+			// The lock is released on every path that does not complete,
+			// including a panic, which continues to unwind afterwards.
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(context.WithoutCancel(r.Context()), key); err != nil {
+					logger.Warn("idempotency key release failed", "key", key, "error", err)
+				}
+			}()
+
+			cw := newCopyWriter(w, maxIdempotentResponseBytes)
+			next.ServeHTTP(cw, r)
+
+			if cw.Status() >= 500 {
+				return
+			}
+			if !cw.complete() {
+				logger.Warn("response not kept for idempotent replay; it was streamed or too large", "key", key)
+				return
+			}
+			resp := &CachedResponse{Status: cw.Status(), Header: cw.header(), Body: cw.body.bytes()}
+			if err := store.Complete(context.WithoutCancel(r.Context()), key, resp, idempotencyTTL); err != nil {
+				logger.Error("idempotency store failed to keep response", "key", key, "error", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore held in process memory.
+// It is exact within a Worker isolate, but each isolate has its own, so a
+// retry that lands on another isolate runs again. It is meant for tests and
+// local development.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+	swept   time.Time
+	now     func() time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	resp    *CachedResponse // nil while in flight
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]memoryIdempotencyEntry), now: time.Now}
+}
+
+// Lock implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Lock(ctx context.Context, key string, lockTTL time.Duration) (*CachedResponse, bool, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.swept) >= time.Minute {
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		if e.resp == nil {
+			return nil, false, nil
+		}
+		return &CachedResponse{Status: e.resp.Status, Header: e.resp.Header.Clone(), Body: e.resp.Body}, false, nil
+	}
+	s.entries[key] = memoryIdempotencyEntry{expires: now.Add(lockTTL)}
+	return nil, true, nil
+}
+
+// Complete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryIdempotencyEntry{
+		resp: &CachedResponse{
+			Status: resp.Status,
+			Header: resp.Header.Clone(),
+			Body:   append([]byte(nil), resp.Body...),
+		},
+		expires: now.Add(ttl),
+	}
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.resp == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// idempotencyRecord is an idempotency key's state as stored in KV and
+// exchanged with an idempotency Durable Object.
+type idempotencyRecord struct {
+	State  string      `json:"state"` // "pending" or "done"
+	Token  string      `json:"token,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+func (rec *idempotencyRecord) response() *CachedResponse {
+	return &CachedResponse{Status: rec.Status, Header: rec.Header, Body: rec.Body}
+}
+
+// kvIdempotencyStore keeps idempotency records in a KV namespace.
+type kvIdempotencyStore struct {
+	kv KVNamespace
+}
+
+// NewKVIdempotencyStore returns an IdempotencyStore that keeps its records
+// in kv, so that a retry is recognised whichever isolate it reaches.
+//
+// Replay is reliable once a response has been stored for a minute, the
+// time KV takes to reach every location. The in-flight lock is not: KV has
+// no compare-and-set, so Lock writes a claim, reads it back and gives way
+// if another request's claim replaced it. That catches duplicates that
+// arrive in the same location a moment apart, which is the common case of
+// a client retrying on a timeout, but two requests that claim the key at
+// the same instant, or in different locations within that minute, can
+// both run. Use NewDurableObjectIdempotencyStore where a duplicate must
+// never run, such as when moving money.
+func NewKVIdempotencyStore(kv KVNamespace) IdempotencyStore {
+	return &kvIdempotencyStore{kv: kv}
+}
+
+func (s *kvIdempotencyStore) Lock(ctx context.Context, key string, lockTTL time.Duration) (*CachedResponse, bool, error) {
+	k := "idempotency:" + key
+	rec, err := s.get(ctx, k)
+	if err != nil {
+		return nil, false, err
+	}
+	if rec != nil {
+		if rec.State == "done" {
+			return rec.response(), false, nil
+		}
+		return nil, false, nil
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(b[:])
+	claim, _ := json.Marshal(idempotencyRecord{State: "pending", Token: token})
+	if err := s.kv.Put(ctx, k, claim, &KVPutOptions{ExpirationTTL: max(lockTTL, kvMinTTL)}); err != nil {
+		return nil, false, err
+	}
+	rec, err = s.get(ctx, k)
+	if err != nil {
+		return nil, false, err
+	}
+	if rec == nil || rec.Token != token {
+		return nil, false, nil
+	}
+	return nil, true, nil
+}
+
+func (s *kvIdempotencyStore) Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	b, err := json.Marshal(idempotencyRecord{State: "done", Status: resp.Status, Header: resp.Header, Body: resp.Body})
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(ctx, "idempotency:"+key, b, &KVPutOptions{ExpirationTTL: max(ttl, kvMinTTL)})
+}
+
+func (s *kvIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.kv.Delete(ctx, "idempotency:"+key)
+}
+
+func (s *kvIdempotencyStore) get(ctx context.Context, k string) (*idempotencyRecord, error) {
+	v, err := s.kv.Get(ctx, k)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec idempotencyRecord
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return nil, fmt.Errorf("functions: corrupt idempotency record %q: %w", k, err)
+	}
+	return &rec, nil
+}
+
+// durableObjectIdempotencyStore keeps each key's record in its own Durable
+// Object.
+type durableObjectIdempotencyStore struct {
+	ns *DurableObjectNamespace
+}
+
+// NewDurableObjectIdempotencyStore returns an IdempotencyStore that keeps
+// each key's record in its own Durable Object from the namespace bound
+// under binding:
+//
+//	[[durable_objects.bindings]]
+//	name = "IDEMPOTENCY"
+//	class_name = "IdempotencyKey"
+//
+// The lock is exact. Every request for a key is routed to the single
+// object named after it, which handles one request at a time, so of any
+// number of concurrent duplicates exactly one runs and the others get a
+// 409, wherever at the edge they land.
+//
+// The object class must answer the protocol implemented by
+// IdempotencyObject: a POST of {"op": "lock", "ttl_ms": n} returns the
+// record's state as {"state": "acquired" | "pending" | "done", ...}, with
+// the stored status, header and base64 body when it is done; "complete"
+// stores {"status", "header", "body"} for ttl_ms, and "release" drops an
+// unfinished lock. The runtime evicts idle objects, so a class that keeps
+// the record in memory forgets completed responses within minutes; one
+// that keeps it in Durable Object storage, with an alarm to delete it when
+// it expires, replays them for as long as ttl_ms asks:
+//
+//	export class IdempotencyKey {
+//	  constructor(state) {
+//	    this.storage = state.storage
+//	  }
+//	  async fetch(req) {
+//	    const { op, ttl_ms, status, header, body } = await req.json()
+//	    const now = Date.now()
+//	    let rec = await this.storage.get("record")
+//	    if (rec && rec.expires <= now) rec = undefined
+//	    switch (op) {
+//	      case "lock":
+//	        if (rec) return Response.json(rec.state === "done" ? rec : { state: "pending" })
+//	        await this.save({ state: "pending", expires: now + ttl_ms })
+//	        return Response.json({ state: "acquired" })
+//	      case "complete":
+//	        await this.save({ state: "done", status, header, body, expires: now + ttl_ms })
+//	        return Response.json({ state: "done" })
+//	      case "release":
+//	        await this.storage.delete("record")
+//	        return Response.json({ state: "released" })
+//	    }
+//	    return Response.json({ error: "op must be lock, complete or release" }, { status: 400 })
+//	  }
+//	  async save(rec) {
+//	    await this.storage.put("record", rec)
+//	    await this.storage.setAlarm(rec.expires)
+//	  }
+//	  alarm() {
+//	    return this.storage.deleteAll()
+//	  }
+//	}
+func NewDurableObjectIdempotencyStore(binding string) (IdempotencyStore, error) {
+	ns, err := NewDurableObject(binding)
+	if err != nil {
+		return nil, err
+	}
+	return NewDurableObjectIdempotencyStoreFrom(ns), nil
+}
+
+// NewDurableObjectIdempotencyStoreFrom is like
+// NewDurableObjectIdempotencyStore but uses an already opened namespace,
+// such as one from NewDurableObjectWithHandlers serving IdempotencyObject.
+func NewDurableObjectIdempotencyStoreFrom(ns *DurableObjectNamespace) IdempotencyStore {
+	return &durableObjectIdempotencyStore{ns: ns}
+}
+
+type idempotencyRequest struct {
+	Op     string      `json:"op"` // "lock", "complete" or "release"
+	TTLMS  int64       `json:"ttl_ms,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+func (s *durableObjectIdempotencyStore) Lock(ctx context.Context, key string, lockTTL time.Duration) (*CachedResponse, bool, error) {
+	rec, err := s.call(ctx, key, idempotencyRequest{Op: "lock", TTLMS: lockTTL.Milliseconds()})
+	if err != nil {
+		return nil, false, err
+	}
+	switch rec.State {
+	case "done":
+		return rec.response(), false, nil
+	case "acquired":
+		return nil, true, nil
+	}
+	return nil, false, nil
+}
+
+func (s *durableObjectIdempotencyStore) Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	_, err := s.call(ctx, key, idempotencyRequest{Op: "complete", TTLMS: ttl.Milliseconds(), Status: resp.Status, Header: resp.Header, Body: resp.Body})
+	return err
+}
+
+func (s *durableObjectIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.call(ctx, key, idempotencyRequest{Op: "release"})
+	return err
+}
+
+func (s *durableObjectIdempotencyStore) call(ctx context.Context, key string, in idempotencyRequest) (*idempotencyRecord, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://idempotency/"+in.Op, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.ns.Get(s.ns.IDFromName(key)).Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("functions: Durable Object %s: idempotency object answered %s", s.ns.binding, resp.Status)
+	}
+	var rec idempotencyRecord
+	if err := json.NewDecoder(resp.Body).Decode(&rec); err != nil {
+		return nil, fmt.Errorf("functions: Durable Object %s: decode idempotency response: %w", s.ns.binding, err)
+	}
+	return &rec, nil
+}
+
+// IdempotencyObject returns the handler of an idempotency Durable Object
+// written in Go, for use with NewDurableObjectIdempotencyStore. Each object
+// holds one key's record; the handler must be created once per object
+// instance so that the record lives as long as the instance does.
+//
+// The record is kept in instance memory, not in Durable Object storage, so
+// it is lost when the runtime evicts the idle object, usually within
+// minutes. Duplicates that arrive while the original runs still get a 409,
+// but a retry after eviction runs the handler again. Where completed
+// responses must be replayed for their full ttl, serve the namespace with
+// a class backed by storage, such as the one shown for
+// NewDurableObjectIdempotencyStore.
+func IdempotencyObject() http.Handler {
+	store := NewMemoryIdempotencyStore()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": http.StatusText(http.StatusMethodNotAllowed)})
+			return
+		}
+		var in idempotencyRequest
+		if err := DecodeJSON(r, &in); err != nil {
+			WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid idempotency request"})
+			return
+		}
+		ttl := time.Duration(in.TTLMS) * time.Millisecond
+		switch in.Op {
+		case "lock":
+			done, acquired, _ := store.Lock(r.Context(), "", ttl)
+			switch {
+			case done != nil:
+				WriteJSON(w, http.StatusOK, idempotencyRecord{State: "done", Status: done.Status, Header: done.Header, Body: done.Body})
+			case acquired:
+				WriteJSON(w, http.StatusOK, idempotencyRecord{State: "acquired"})
+			default:
+				WriteJSON(w, http.StatusOK, idempotencyRecord{State: "pending"})
+			}
+		case "complete":
+			store.Complete(r.Context(), "", &CachedResponse{Status: in.Status, Header: in.Header, Body: in.Body}, ttl)
+			WriteJSON(w, http.StatusOK, idempotencyRecord{State: "done"})
+		case "release":
+			store.Release(r.Context(), "")
+			WriteJSON(w, http.StatusOK, idempotencyRecord{State: "released"})
+		default:
+			WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "op must be lock, complete or release"})
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var idempotencyStores = map[string]func() IdempotencyStore{
+	"memory": func() IdempotencyStore { return NewMemoryIdempotencyStore() },
+	"kv":     func() IdempotencyStore { return NewKVIdempotencyStore(&mapKV{}) },
+	"durable object": func() IdempotencyStore {
+		return NewDurableObjectIdempotencyStoreFrom(NewDurableObjectWithHandlers("IDEMPOTENCY", func(DurableObjectID) http.Handler {
+			return IdempotencyObject()
+		}))
+	},
+}
+
+func idempotentPost(h http.Handler, path, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"amount":100}`))
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysCompletedResponse(t *testing.T) {
+	for name, newStore := range idempotencyStores {
+		t.Run(name, func(t *testing.T) {
+			var runs atomic.Int32
+			h := Idempotency(newStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := runs.Add(1)
+				w.Header().Set("Location", fmt.Sprintf("/charges/%d", n))
+				WriteJSON(w, http.StatusCreated, map[string]int32{"charge": n})
+			}))
+
+			first := idempotentPost(h, "/charges", "key-1")
+			second := idempotentPost(h, "/charges", "key-1")
+			if runs.Load() != 1 {
+				t.Fatalf("handler ran %d times, want 1", runs.Load())
+			}
+			if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+				t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+			}
+			if second.Header().Get("Location") != "/charges/1" || second.Header().Get("Idempotent-Replayed") != "true" {
+				t.Errorf("replay headers = %v", second.Header())
+			}
+			if first.Header().Get("Idempotent-Replayed") != "" {
+				t.Error("original response marked as replayed")
+			}
+		})
+	}
+}
+
+func TestIdempotencyInFlightConflict(t *testing.T) {
+	for name, newStore := range idempotencyStores {
+		t.Run(name, func(t *testing.T) {
+			entered, release := make(chan struct{}), make(chan struct{})
+			h := Idempotency(newStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(entered)
+				<-release
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			done := make(chan *httptest.ResponseRecorder)
+			go func() { done <- idempotentPost(h, "/charges", "key-1") }()
+			<-entered
+
+			dup := idempotentPost(h, "/charges", "key-1")
+			if dup.Code != http.StatusConflict || dup.Header().Get("Retry-After") == "" {
+				t.Errorf("duplicate while in flight = %d %v, want 409 with Retry-After", dup.Code, dup.Header())
+			}
+			if !strings.Contains(dup.Body.String(), "idempotency_conflict") {
+				t.Errorf("body = %s", dup.Body)
+			}
+			close(release)
+			if w := <-done; w.Code != http.StatusNoContent {
+				t.Errorf("original = %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeysAreIndependent(t *testing.T) {
+	for name, newStore := range idempotencyStores {
+		t.Run(name, func(t *testing.T) {
+			var runs atomic.Int32
+			h := Idempotency(newStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runs.Add(1)
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			idempotentPost(h, "/charges", "key-1")
+			idempotentPost(h, "/charges", "key-2")
+			idempotentPost(h, "/refunds", "key-1") // same key, other endpoint
+			if runs.Load() != 3 {
+				t.Fatalf("handler ran %d times, want 3", runs.Load())
+			}
+		})
+	}
+}
+
+func TestIdempotencyExactUnderConcurrency(t *testing.T) {
+	store := idempotencyStores["durable object"]()
+	var runs atomic.Int32
+	h := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idempotentPost(h, "/charges", "key-1")
+		}()
+	}
+	wg.Wait()
+	if runs.Load() != 1 {
+		t.Fatalf("handler ran %d times for 20 concurrent duplicates, want 1", runs.Load())
+	}
+}
+
+func TestIdempotencyRetriesFailures(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+
+	var runs atomic.Int32
+	h := Idempotency(NewMemoryIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch runs.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			panic("boom")
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+
+	if w := idempotentPost(h, "/charges", "key-1"); w.Code != http.StatusBadGateway {
+		t.Fatalf("first attempt = %d", w.Code)
+	}
+	func() {
+		defer func() { recover() }()
+		idempotentPost(h, "/charges", "key-1")
+	}()
+	if w := idempotentPost(h, "/charges", "key-1"); w.Code != http.StatusCreated {
+		t.Fatalf("attempt after a 5xx and a panic = %d, want the handler to run again", w.Code)
+	}
+	if w := idempotentPost(h, "/charges", "key-1"); w.Header().Get("Idempotent-Replayed") != "true" || runs.Load() != 3 {
+		t.Fatalf("success was not replayed; handler ran %d times", runs.Load())
+	}
+}
+
+func TestIdempotencyPassThrough(t *testing.T) {
+	var runs atomic.Int32
+	h := Idempotency(NewMemoryIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs.Add(1)
+	}))
+
+	idempotentPost(h, "/charges", "")
+	idempotentPost(h, "/charges", "")
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/charges", nil)
+		r.Header.Set("Idempotency-Key", "key-1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if runs.Load() != 4 {
+		t.Fatalf("handler ran %d times, want every request without a key or with GET to run", runs.Load())
+	}
+
+	if w := idempotentPost(h, "/charges", strings.Repeat("k", 256)); w.Code != http.StatusBadRequest {
+		t.Errorf("oversized key = %d, want 400", w.Code)
+	}
+}
+
+type failingIdempotencyStore struct{ MemoryIdempotencyStore }
+
+func (*failingIdempotencyStore) Lock(context.Context, string, time.Duration) (*CachedResponse, bool, error) {
+	return nil, false, errors.New("store down")
+}
+
+func TestIdempotencyFailsClosed(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+
+	h := Idempotency(&failingIdempotencyStore{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran while the store was down")
+	}))
+	if w := idempotentPost(h, "/charges", "key-1"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package memstore
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket is an in-memory R2 bucket whose objects carry metadata of type M.
+// It is safe for concurrent use.
+type Bucket[M any] struct {
+	mu      sync.Mutex
+	objects map[string]Object[M]
+	now     func() time.Time
+}
+
+// Object is an object stored in a Bucket.
+type Object[M any] struct {
+	Key      string
+	ETag     string
+	Uploaded time.Time
+	Meta     M
+	Data     []byte
+}
+
+// NewBucket returns an empty Bucket that stamps uploads with the time now
+// returns.
+func NewBucket[M any](now func() time.Time) *Bucket[M] {
+	return &Bucket[M]{objects: make(map[string]Object[M]), now: now}
+}
+
+// Get returns the object stored under key. Its Data must not be modified.
+func (b *Bucket[M]) Get(key string) (Object[M], bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.objects[key]
+	return o, ok
+}
+
+// Put stores data under key, which the Bucket then owns, and returns the
+// object with the quoted MD5 of data as its ETag, as R2 gives single-part
+// uploads.
+func (b *Bucket[M]) Put(key string, data []byte, meta M) Object[M] {
+	sum := md5.Sum(data)
+	o := Object[M]{
+		Key:      key,
+		ETag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+		Uploaded: b.now(),
+		Meta:     meta,
+		Data:     data,
+	}
+	b.mu.Lock()
+	b.objects[key] = o
+	b.mu.Unlock()
+	return o
+}
+
+// Delete removes key.
+func (b *Bucket[M]) Delete(key string) {
+	b.mu.Lock()
+	delete(b.objects, key)
+	b.mu.Unlock()
+}
+
+// List returns up to limit objects with prefix in lexicographic order,
+// starting at cursor, and the cursor of the next page, which is empty once
+// the listing is complete. If delimiter is set, keys that contain it after
+// the prefix are collapsed into the sorted prefixes, up to and including
+// the delimiter, as R2 does.
+func (b *Bucket[M]) List(prefix, delimiter, cursor string, limit int) (objects []Object[M], prefixes []string, next string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var keys []string
+	seen := make(map[string]bool)
+	for key := range b.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			rest := key[len(prefix):]
+			if i := strings.Index(rest, delimiter); i >= 0 {
+				seen[prefix+rest[:i+len(delimiter)]] = true
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	start, end, next, err := page(len(keys), cursor, limit)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	for _, key := range keys[start:end] {
+		objects = append(objects, b.objects[key])
+	}
+	for p := range seen {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	return objects, prefixes, next, nil
+}
+
+// Keys returns every stored key, sorted.
+func (b *Bucket[M]) Keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,178 @@
+// Package memstore implements the in-memory KV namespace and R2 bucket
+// behind the local bindings of SetupLocal and the mocks in functest, so the
+// two expire, list and page keys the same way. It also holds the KV limits
+// that functions.KV and functest.MockKV both check.
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultListLimit is the page size of a listing that does not set one, as
+// in KV and R2.
+const DefaultListLimit = 1000
+
+// Limits of Workers KV, which functions.KV and functest.MockKV both enforce.
+const (
+	KVMaxKeyBytes = 512
+	KVMinTTL      = 60 * time.Second
+)
+
+// CheckKVKey reports whether key is one Workers KV accepts.
+func CheckKVKey(key string) error {
+	if key == "" {
+		return errors.New("KV key must not be empty")
+	}
+	if len(key) > KVMaxKeyBytes {
+		return fmt.Errorf("KV key exceeds %d bytes", KVMaxKeyBytes)
+	}
+	return nil
+}
+
+// CheckKVPut reports whether Workers KV accepts a write of key that expires
+// after ttl, where zero means never.
+func CheckKVPut(key string, ttl time.Duration) error {
+	if err := CheckKVKey(key); err != nil {
+		return err
+	}
+	if ttl != 0 && ttl < KVMinTTL {
+		return fmt.Errorf("KV expiration TTL must be at least %s, got %s", KVMinTTL, ttl)
+	}
+	return nil
+}
+
+// KV is an in-memory KV namespace. It is safe for concurrent use.
+type KV struct {
+	mu      sync.Mutex
+	entries map[string]KVEntry
+	now     func() time.Time
+}
+
+// KVEntry is a value stored in a KV, with its metadata and the time it
+// expires, zero if it does not.
+type KVEntry struct {
+	Value      []byte
+	Metadata   json.RawMessage
+	Expiration time.Time
+}
+
+// KVKey is a key returned by KV.List.
+type KVKey struct {
+	Name       string
+	Expiration time.Time
+	Metadata   json.RawMessage
+}
+
+// NewKV returns an empty KV that expires keys by the time now returns.
+func NewKV(now func() time.Time) *KV {
+	return &KV{entries: make(map[string]KVEntry), now: now}
+}
+
+// Get returns a copy of the live entry stored under key.
+func (m *KV) Get(key string) (KVEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.live(key)
+	if !ok {
+		return KVEntry{}, false
+	}
+	e.Value = append([]byte(nil), e.Value...)
+	return e, true
+}
+
+// Put stores a copy of value under key. A positive ttl expires it that long
+// from now.
+func (m *KV) Put(key string, value []byte, metadata json.RawMessage, ttl time.Duration) {
+	e := KVEntry{Value: append([]byte(nil), value...), Metadata: metadata}
+	if ttl > 0 {
+		e.Expiration = m.now().Add(ttl)
+	}
+	m.mu.Lock()
+	m.entries[key] = e
+	m.mu.Unlock()
+}
+
+// Delete removes key.
+func (m *KV) Delete(key string) {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+}
+
+// List returns up to limit live keys with prefix in lexicographic order,
+// starting at cursor, and the cursor of the next page, which is empty once
+// the listing is complete. The cursor is the offset of the page.
+func (m *KV) List(prefix, cursor string, limit int) ([]KVKey, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.entries {
+		if _, ok := m.live(name); ok && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, end, next, err := page(len(names), cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	var keys []KVKey
+	for _, name := range names[start:end] {
+		e := m.entries[name]
+		keys = append(keys, KVKey{Name: name, Expiration: e.Expiration, Metadata: e.Metadata})
+	}
+	return keys, next, nil
+}
+
+// Keys returns every live key, sorted.
+func (m *KV) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.entries {
+		if _, ok := m.live(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// live returns the entry for key, dropping it if it has expired. The caller
+// must hold m.mu.
+func (m *KV) live(key string) (KVEntry, bool) {
+	e, ok := m.entries[key]
+	if ok && !e.Expiration.IsZero() && !m.now().Before(e.Expiration) {
+		delete(m.entries, key)
+		return KVEntry{}, false
+	}
+	return e, ok
+}
+
+// page returns the bounds of the page of n sorted items that starts at
+// cursor, and the cursor of the page after it.
+func page(n int, cursor string, limit int) (start, end int, next string, err error) {
+	if cursor != "" {
+		start, err = strconv.Atoi(cursor)
+		if err != nil || start < 0 {
+			return 0, 0, "", fmt.Errorf("invalid list cursor %q", cursor)
+		}
+	}
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	start = min(start, n)
+	end = min(start+limit, n)
+	if end < n {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next, nil
+}
@@ -0,0 +1,55 @@
+package memstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKVListPages(t *testing.T) {
+	now := time.Unix(1000, 0)
+	kv := NewKV(func() time.Time { return now })
+	for _, k := range []string{"a/3", "a/1", "b/1", "a/2"} {
+		kv.Put(k, []byte(k), nil, 0)
+	}
+	kv.Put("a/0", nil, nil, time.Second)
+	now = now.Add(time.Second)
+
+	var got []string
+	cursor := ""
+	for {
+		keys, next, err := kv.List("a/", cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range keys {
+			got = append(got, k.Name)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(got) != 3 || got[0] != "a/1" || got[2] != "a/3" {
+		t.Errorf("listed %v, want [a/1 a/2 a/3]", got)
+	}
+	if _, _, err := kv.List("", "-1", 0); err == nil {
+		t.Error("negative cursor accepted")
+	}
+}
+
+func TestBucketListDelimiter(t *testing.T) {
+	b := NewBucket[string](time.Now)
+	for _, k := range []string{"img/a.png", "img/2024/b.png", "img/2025/c.png", "doc.txt"} {
+		b.Put(k, []byte(k), "meta")
+	}
+	objects, prefixes, next, err := b.List("img/", "/", "", 0)
+	if err != nil || next != "" {
+		t.Fatalf("List = %v, next %q", err, next)
+	}
+	if len(objects) != 1 || objects[0].Key != "img/a.png" || objects[0].Meta != "meta" {
+		t.Errorf("objects = %+v", objects)
+	}
+	if len(prefixes) != 2 || prefixes[0] != "img/2024/" || prefixes[1] != "img/2025/" {
+		t.Errorf("prefixes = %v", prefixes)
+	}
+}
@@ -0,0 +1,128 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxJSONBytes is the body size limit applied by DecodeJSON.
+const DefaultMaxJSONBytes int64 = 1 << 20
+
+var (
+	// ErrEmptyBody is returned when a JSON body was expected but none was sent.
+	ErrEmptyBody = errors.New("functions: request body is empty")
+
+	// ErrBodyTooLarge is returned when a request body exceeds the configured limit.
+	ErrBodyTooLarge = errors.New("functions: request body too large")
+
+	// ErrUnsupportedMediaType is returned when the request Content-Type is not JSON.
+	ErrUnsupportedMediaType = errors.New("functions: unsupported media type")
+)
+
+// DecodeJSON decodes the JSON request body into v, rejecting bodies larger
+// than DefaultMaxJSONBytes.
+func DecodeJSON(r *http.Request, v any) error {
+	return DecodeJSONLimit(r, v, DefaultMaxJSONBytes)
+}
+
+// DecodeJSONLimit is like DecodeJSON but rejects bodies larger than maxBytes.
+//
+// A missing Content-Type is accepted; any other value must be
+// application/json or a +json media type.
+func DecodeJSONLimit(r *http.Request, v any, maxBytes int64) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !isJSONMediaType(ct) {
+		return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, ct)
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return ErrEmptyBody
+	}
+	if r.ContentLength > maxBytes {
+		return ErrBodyTooLarge
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBytes))
+	if err := dec.Decode(v); err != nil {
+		return jsonDecodeError(err)
+	}
+	// Anything after the first value is a malformed body, not a second request.
+	if _, err := dec.Token(); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return ErrBodyTooLarge
+		}
+		return &jsonInputError{errors.New("functions: malformed JSON: unexpected data after top-level value")}
+	}
+	return nil
+}
+
+func jsonDecodeError(err error) error {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return ErrEmptyBody
+	case errors.As(err, &maxErr):
+		return ErrBodyTooLarge
+	case errors.As(err, &syntaxErr):
+		return &jsonInputError{fmt.Errorf("functions: malformed JSON at offset %d: %w", syntaxErr.Offset, err)}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &jsonInputError{fmt.Errorf("functions: malformed JSON: %w", err)}
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return &jsonInputError{fmt.Errorf("functions: invalid JSON value for field %q: %w", typeErr.Field, err)}
+		}
+		return &jsonInputError{fmt.Errorf("functions: invalid JSON value: %w", err)}
+	default:
+		return err
+	}
+}
+
+// jsonInputError reports a request body that is not well-formed JSON or
+// does not fit the value it is decoded into.
+type jsonInputError struct {
+	err error
+}
+
+func (e *jsonInputError) Error() string { return e.err.Error() }
+func (e *jsonInputError) Unwrap() error { return e.err }
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// WriteJSON writes v as a JSON response with the given status code.
+//
+// The value is marshaled before anything is written, so a marshal failure
+// still produces a well-formed 500 response with a JSON error body.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	writeJSON(w, status, "application/json", v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, contentType string, v any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `{"error":"failed to encode response"}`+"\n")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
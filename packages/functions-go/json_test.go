@@ -0,0 +1,121 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		limit       int64
+		want        string
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		{name: "valid", contentType: "application/json", body: `{"name":"ada"}`, want: "ada"},
+		{name: "charset param", contentType: "application/json; charset=utf-8", body: `{"name":"ada"}`, want: "ada"},
+		{name: "json suffix", contentType: "application/vnd.api+json", body: `{"name":"ada"}`, want: "ada"},
+		{name: "no content type", body: `{"name":"ada"}`, want: "ada"},
+		{name: "empty body", contentType: "application/json", body: "", wantErr: ErrEmptyBody},
+		{name: "whitespace body", contentType: "application/json", body: "  \n", wantErr: ErrEmptyBody},
+		{name: "oversized body", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, limit: 32, wantErr: ErrBodyTooLarge},
+		{name: "text content type", contentType: "text/plain", body: `{"name":"ada"}`, wantErr: ErrUnsupportedMediaType},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded", body: "name=ada", wantErr: ErrUnsupportedMediaType},
+		{name: "unparseable content type", contentType: "application/json; =", body: `{}`, wantErr: ErrUnsupportedMediaType},
+		{name: "malformed", contentType: "application/json", body: `{"name":`, wantAnyErr: true},
+		{name: "syntax error", contentType: "application/json", body: `{"name" "ada"}`, wantAnyErr: true},
+		{name: "wrong type", contentType: "application/json", body: `{"name":1}`, wantAnyErr: true},
+		{name: "trailing data", contentType: "application/json", body: `{"name":"ada"} {}`, wantAnyErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			limit := tt.limit
+			if limit == 0 {
+				limit = DefaultMaxJSONBytes
+			}
+
+			var got payload
+			err := DecodeJSONLimit(r, &got, limit)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if errors.Is(err, ErrEmptyBody) || errors.Is(err, ErrBodyTooLarge) {
+					t.Fatalf("err = %v, want a malformed JSON error", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Name != tt.want {
+					t.Fatalf("name = %q, want %q", got.Name, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeJSONContentLengthShortcut(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	r.ContentLength = DefaultMaxJSONBytes + 1
+
+	var v map[string]any
+	if err := DecodeJSON(r, &v); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("err = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		value      any
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "object", status: http.StatusCreated, value: map[string]string{"id": "1"}, wantStatus: http.StatusCreated, wantBody: `{"id":"1"}`},
+		{name: "no html escaping", status: http.StatusOK, value: "<b>", wantStatus: http.StatusOK, wantBody: `"<b>"`},
+		{name: "marshal error", status: http.StatusOK, value: func() {}, wantStatus: http.StatusInternalServerError, wantBody: `{"error":"failed to encode response"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteJSON(w, tt.status, tt.value)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Content-Type = %q", ct)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("body = %s, want %s", got, tt.wantBody)
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Fatalf("body is not valid JSON: %s", w.Body.String())
+			}
+		})
+	}
+}
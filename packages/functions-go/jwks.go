@@ -0,0 +1,179 @@
+package functions
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSCacheTTL = time.Hour
+
+	// jwksMinRefresh bounds how often an unknown kid can trigger a refetch,
+	// so tokens with made-up kids can't turn every request into a JWKS call.
+	jwksMinRefresh = 30 * time.Second
+)
+
+// jwksCache holds the RSA keys of a JWKS endpoint by kid.
+//
+// The set is fetched without holding mu, so requests whose key is cached are
+// not held up by a slow endpoint, and by one request at a time: the others
+// that need it wait for that fetch rather than starting their own. Fetches
+// are at least minRefresh apart whether or not they succeed, so a failing
+// endpoint is not called on every request.
+type jwksCache struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]jwk
+	fetched   time.Time  // last successful fetch
+	attempted time.Time  // last fetch, successful or not
+	inflight  *jwksFetch // the fetch under way, if any
+}
+
+// jwksFetch is a fetch of the key set that other requests can wait on.
+type jwksFetch struct {
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+type jwk struct {
+	key *rsa.PublicKey
+	alg string
+}
+
+func newJWKSCache(url string, client *http.Client, ttl time.Duration) *jwksCache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if ttl <= 0 {
+		ttl = defaultJWKSCacheTTL
+	}
+	return &jwksCache{url: url, client: client, ttl: ttl, minRefresh: jwksMinRefresh, now: time.Now}
+}
+
+// key returns the key for kid, fetching the set if it is stale or doesn't
+// contain kid. An empty kid matches the only key in a single-key set.
+func (c *jwksCache) key(ctx context.Context, kid, alg string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	k, ok := c.lookup(kid)
+	now := c.now()
+	stale := c.keys == nil || !ok || now.Sub(c.fetched) >= c.ttl
+	f := c.inflight
+	if stale && f == nil && now.Sub(c.attempted) >= c.minRefresh {
+		f = &jwksFetch{done: make(chan struct{})}
+		c.inflight, c.attempted = f, now
+		c.mu.Unlock()
+
+		keys, err := c.fetch(ctx)
+		c.mu.Lock()
+		if err == nil {
+			c.keys, c.fetched = keys, c.now()
+		}
+		c.inflight, f.err = nil, err
+		close(f.done)
+		c.mu.Unlock()
+	} else {
+		c.mu.Unlock()
+		if !stale {
+			f = nil
+		}
+		if f != nil {
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	c.mu.Lock()
+	k, ok = c.lookup(kid)
+	unavailable := c.keys == nil
+	c.mu.Unlock()
+	if !ok {
+		// Known keys keep being served if the endpoint is briefly
+		// unavailable, but a key missing from a set that could not be
+		// refreshed may just not have been fetched yet.
+		if unavailable || (f != nil && f.err != nil) {
+			return nil, errors.New("signing keys unavailable")
+		}
+		return nil, errors.New("unknown signing key")
+	}
+	if k.alg != "" && k.alg != alg {
+		return nil, errors.New("algorithm does not match signing key")
+	}
+	return k.key, nil
+}
+
+func (c *jwksCache) lookup(kid string) (jwk, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+// fetch downloads the key set, keeping the usable RSA signing keys.
+func (c *jwksCache) fetch(ctx context.Context) (map[string]jwk, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("functions: fetching JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("functions: decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		keys[k.Kid] = jwk{key: pub, alg: k.Alg}
+	}
+	return keys, nil
+}
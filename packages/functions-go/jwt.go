@@ -0,0 +1,279 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers crypto.SHA256
+	_ "crypto/sha512" // registers crypto.SHA384 and crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTOptions configures the JWTAuth middleware. Exactly one of Secret and
+// JWKSURL must be set.
+type JWTOptions struct {
+	// Secret verifies HS256, HS384 and HS512 tokens.
+	Secret []byte
+
+	// JWKSURL is a JSON Web Key Set endpoint whose RSA keys verify RS256,
+	// RS384 and RS512 tokens. Keys are fetched on first use, cached, and
+	// refetched when a token names a kid the cache doesn't know.
+	JWKSURL string
+
+	// Issuer, if set, must equal the token's iss claim.
+	Issuer string
+
+	// Audience, if set, must appear in the token's aud claim.
+	Audience string
+
+	// Leeway is the clock skew tolerated when checking exp and nbf.
+	Leeway time.Duration
+
+	// Client fetches the JWKS. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// CacheTTL is how long fetched keys are trusted before the set is
+	// refetched. If zero, keys are cached for an hour.
+	CacheTTL time.Duration
+}
+
+// JWTClaims holds the claims of a verified token.
+type JWTClaims map[string]any
+
+// Subject returns the sub claim, or "" if it is missing.
+func (c JWTClaims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Claims returns the claims stored by JWTAuth, or nil if the request was not
+// authenticated by it.
+func Claims(ctx context.Context) JWTClaims {
+	c, _ := ctx.Value(claimsKey{}).(JWTClaims)
+	return c
+}
+
+type claimsKey struct{}
+
+// JWTAuth returns middleware that requires a valid bearer token. It panics
+// if opts is invalid; use NewJWTAuth to handle the error instead.
+func JWTAuth(opts JWTOptions) Middleware {
+	mw, err := NewJWTAuth(opts)
+	if err != nil {
+		panic(err)
+	}
+	return mw
+}
+
+// NewJWTAuth returns middleware that verifies the token in the
+// Authorization: Bearer header and stores its claims in the request context,
+// where handlers read them with Claims.
+//
+// Requests without a valid token get a 401 with a WWW-Authenticate header
+// and never reach the wrapped handler.
+func NewJWTAuth(opts JWTOptions) (Middleware, error) {
+	v, err := newJWTVerifier(opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing bearer token"})
+				return
+			}
+			claims, err := v.verify(r.Context(), token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"invalid_token\", error_description=%q", err.Error()))
+				WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+		})
+	}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+type jwtVerifier struct {
+	secret   []byte
+	jwks     *jwksCache
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+func newJWTVerifier(opts JWTOptions) (*jwtVerifier, error) {
+	if (len(opts.Secret) == 0) == (opts.JWKSURL == "") {
+		return nil, errors.New("functions: JWTOptions must set exactly one of Secret and JWKSURL")
+	}
+	v := &jwtVerifier{
+		secret:   opts.Secret,
+		issuer:   opts.Issuer,
+		audience: opts.Audience,
+		leeway:   opts.Leeway,
+		now:      time.Now,
+	}
+	if opts.JWKSURL != "" {
+		v.jwks = newJWKSCache(opts.JWKSURL, opts.Client, opts.CacheTTL)
+	}
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the token's signature and registered claims. The error
+// messages end up in WWW-Authenticate, so they never include token data.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var hdr jwtHeader
+	if err := decodeJWTPart(parts[0], &hdr); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	signed := token[:len(parts[0])+1+len(parts[1])]
+
+	hash, family, ok := jwtAlgorithm(hdr.Alg)
+	if !ok {
+		return nil, errors.New("unsupported algorithm")
+	}
+	switch {
+	case family == "HS" && v.secret != nil:
+		mac := hmac.New(hash.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, errors.New("invalid signature")
+		}
+	case family == "RS" && v.jwks != nil:
+		key, err := v.jwks.key(ctx, hdr.Kid, hdr.Alg)
+		if err != nil {
+			return nil, err
+		}
+		h := hash.New()
+		h.Write([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig); err != nil {
+			return nil, errors.New("invalid signature")
+		}
+	default:
+		return nil, errors.New("unexpected algorithm")
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *jwtVerifier) validate(c JWTClaims) error {
+	now := v.now()
+	if exp, ok, err := numericDate(c, "exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(v.leeway)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok, err := numericDate(c, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(v.leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	if v.issuer != "" {
+		if iss, _ := c["iss"].(string); iss != v.issuer {
+			return errors.New("unexpected issuer")
+		}
+	}
+	if v.audience != "" && !hasAudience(c["aud"], v.audience) {
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+func numericDate(c JWTClaims, name string) (time.Time, bool, error) {
+	raw, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := raw.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true, nil
+}
+
+// hasAudience reports whether aud, a string or array of strings, contains want.
+func hasAudience(aud any, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []any:
+		for _, a := range aud {
+			if s, _ := a.(string); s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jwtAlgorithm(alg string) (hash crypto.Hash, family string, ok bool) {
+	if len(alg) != 5 {
+		return 0, "", false
+	}
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return 0, "", false
+	}
+	family = alg[:2]
+	return hash, family, family == "HS" || family == "RS"
+}
+
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
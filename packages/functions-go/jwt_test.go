@@ -0,0 +1,324 @@
+package functions
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func signHS256(t *testing.T, secret []byte, claims map[string]any) string {
+	t.Helper()
+	signed := jwtSigningInput(t, map[string]any{"alg": "HS256", "typ": "JWT"}, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signed := jwtSigningInput(t, map[string]any{"alg": "RS256", "typ": "JWT", "kid": kid}, claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func jwtSigningInput(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+}
+
+// jwksServer serves the public halves of keys as a JWKS and counts fetches.
+type jwksServer struct {
+	*httptest.Server
+	keys    atomic.Value // map[string]*rsa.PrivateKey
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys map[string]*rsa.PrivateKey) *jwksServer {
+	s := &jwksServer{}
+	s.keys.Store(keys)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, k := range s.keys.Load().(map[string]*rsa.PrivateKey) {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		WriteJSON(w, http.StatusOK, set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func serveWithToken(mw Middleware, token string) *httptest.ResponseRecorder {
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Claims(r.Context()).Subject()))
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestJWTAuthHMAC(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now().Unix()
+	mw := JWTAuth(JWTOptions{Secret: secret, Issuer: "https://auth.example.com", Audience: "api", Leeway: time.Minute})
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": "api", "exp": now + 60}), 200},
+		{"audience list", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": []string{"web", "api"}}), 200},
+		{"expired", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": "api", "exp": now - 300}), 401},
+		{"expired within leeway", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": "api", "exp": now - 30}), 200},
+		{"not yet valid", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": "api", "nbf": now + 300}), 401},
+		{"wrong audience", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": "other"}), 401},
+		{"wrong issuer", signHS256(t, secret, map[string]any{"sub": "u1", "iss": "https://evil.example.com", "aud": "api"}), 401},
+		{"wrong secret", signHS256(t, []byte("nope"), map[string]any{"sub": "u1", "iss": "https://auth.example.com", "aud": "api"}), 401},
+		{"malformed", "not.a.jwt", 401},
+		{"missing", "", 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveWithToken(mw, tt.token)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Header().Get("WWW-Authenticate"))
+			}
+			if tt.status == 200 && w.Body.String() != "u1" {
+				t.Fatalf("subject = %q", w.Body.String())
+			}
+			if tt.status == 401 && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Fatalf("WWW-Authenticate = %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestJWTAuthErrorHeader(t *testing.T) {
+	secret := []byte("s3cret")
+	token := signHS256(t, secret, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})
+	w := serveWithToken(JWTAuth(JWTOptions{Secret: secret}), token)
+	want := `Bearer error="invalid_token", error_description="token is expired"`
+	if got := w.Header().Get("WWW-Authenticate"); got != want {
+		t.Fatalf("WWW-Authenticate = %q, want %q", got, want)
+	}
+}
+
+func TestJWTAuthRS256(t *testing.T) {
+	key := newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PrivateKey{"k1": key})
+	mw := JWTAuth(JWTOptions{JWKSURL: srv.URL, Audience: "api"})
+
+	claims := map[string]any{"sub": "u2", "aud": "api", "exp": time.Now().Add(time.Hour).Unix()}
+	for i := 0; i < 3; i++ {
+		w := serveWithToken(mw, signRS256(t, key, "k1", claims))
+		if w.Code != http.StatusOK || w.Body.String() != "u2" {
+			t.Fatalf("got %d %q (%s)", w.Code, w.Body.String(), w.Header().Get("WWW-Authenticate"))
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	// A token signed by a key the JWKS doesn't publish is rejected.
+	if w := serveWithToken(mw, signRS256(t, newRSAKey(t), "k1", claims)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("foreign key: status = %d", w.Code)
+	}
+
+	// An HMAC token must not be accepted using the RSA key material.
+	hs := signHS256(t, key.N.Bytes(), claims)
+	if w := serveWithToken(mw, hs); w.Code != http.StatusUnauthorized {
+		t.Fatalf("HS256 against JWKS: status = %d", w.Code)
+	}
+}
+
+func TestJWKSRefreshesOnUnknownKid(t *testing.T) {
+	k1, k2 := newRSAKey(t), newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PrivateKey{"k1": k1})
+	v, err := newJWTVerifier(JWTOptions{JWKSURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.jwks.now = func() time.Time { return now }
+
+	claims := map[string]any{"sub": "u3"}
+	if _, err := v.verify(context.Background(), signRS256(t, k1, "k1", claims)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The issuer rotates to k2.
+	srv.keys.Store(map[string]*rsa.PrivateKey{"k1": k1, "k2": k2})
+	rotated := signRS256(t, k2, "k2", claims)
+
+	// Within the minimum refresh interval the unknown kid is rejected
+	// without refetching.
+	if _, err := v.verify(context.Background(), rotated); err == nil {
+		t.Fatal("expected unknown kid to be rejected before the refresh interval")
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	now = now.Add(jwksMinRefresh)
+	if _, err := v.verify(context.Background(), rotated); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
+
+func TestJWKSBacksOffAfterFailure(t *testing.T) {
+	k1 := newRSAKey(t)
+	var fetches atomic.Int32
+	var failing atomic.Bool
+	failing.Store(true)
+	good := newJWKSServer(t, map[string]*rsa.PrivateKey{"k1": k1})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		good.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c := newJWKSCache(srv.URL, nil, 0)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := c.key(context.Background(), "k1", "RS256"); err == nil || err.Error() != "signing keys unavailable" {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("fetches after a failure = %d, want 1", n)
+	}
+
+	failing.Store(false)
+	now = now.Add(jwksMinRefresh)
+	if _, err := c.key(context.Background(), "k1", "RS256"); err != nil {
+		t.Fatalf("after the backoff: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
+
+func TestJWKSSingleFetch(t *testing.T) {
+	k1, k2 := newRSAKey(t), newRSAKey(t)
+	good := newJWKSServer(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2})
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		good.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c := newJWKSCache(srv.URL, nil, time.Hour)
+	if _, err := c.key(context.Background(), "k1", "RS256"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the set expires, every request needs it refetched, but only one
+	// fetch is made and the rest wait for it.
+	c.mu.Lock()
+	c.fetched, c.attempted = time.Time{}, time.Time{}
+	c.mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.key(context.Background(), "k2", "RS256"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The cache is not locked while the fetch is under way.
+	c.mu.Lock()
+	c.fetched = time.Now()
+	c.mu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.key(context.Background(), "k1", "RS256")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("cached key: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("a cached key waited for the fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
+
+func TestNewJWTAuthValidatesOptions(t *testing.T) {
+	if _, err := NewJWTAuth(JWTOptions{}); err == nil {
+		t.Fatal("expected an error with no key source")
+	}
+	if _, err := NewJWTAuth(JWTOptions{Secret: []byte("x"), JWKSURL: "https://example.com/jwks"}); err == nil {
+		t.Fatal("expected an error with two key sources")
+	}
+}
@@ -0,0 +1,200 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dot-do/functions/packages/functions-go/internal/memstore"
+)
+
+// ErrKeyNotFound is returned by KV reads for keys that do not exist.
+var ErrKeyNotFound = errors.New("functions: key not found")
+
+const (
+	kvMaxKeyBytes = memstore.KVMaxKeyBytes
+	kvMinTTL      = memstore.KVMinTTL
+)
+
+// KVNamespace is the interface implemented by *KV. Handlers that accept a
+// KVNamespace instead of *KV can be unit-tested with functest.NewMockKV.
+type KVNamespace interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	GetWithMetadata(ctx context.Context, key string) ([]byte, json.RawMessage, error)
+	Put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, opts *KVListOptions) (*KVListResult, error)
+}
+
+// KVPutOptions configures a KV write.
+type KVPutOptions struct {
+	// ExpirationTTL expires the key after the given duration. Cloudflare
+	// requires at least 60 seconds; zero means the key never expires.
+	ExpirationTTL time.Duration
+
+	// Metadata is stored alongside the value and must be JSON-serializable.
+	Metadata any
+}
+
+// KVListOptions configures a KV listing.
+type KVListOptions struct {
+	// Prefix restricts the listing to keys that start with it.
+	Prefix string
+
+	// Limit is the maximum number of keys to return, at most 1000. If
+	// zero, up to 1000 are returned.
+	Limit int
+
+	// Cursor continues a previous listing.
+	Cursor string
+}
+
+// KVListResult is one page of a KV listing.
+type KVListResult struct {
+	Keys []KVKey
+
+	// Cursor is passed to the next List call to fetch the following page.
+	// It is empty when ListComplete is true.
+	Cursor       string
+	ListComplete bool
+}
+
+// KVKey describes one key in a KV listing.
+type KVKey struct {
+	Name       string
+	Expiration time.Time // zero if the key does not expire
+	Metadata   json.RawMessage
+}
+
+// KV is a Cloudflare Workers KV namespace.
+//
+// The binding name is the `binding` of a `[[kv_namespaces]]` entry in
+// wrangler.toml:
+//
+//	[[kv_namespaces]]
+//	binding = "CACHE"
+//	id = "..."
+//
+// which is opened with NewKV("CACHE").
+type KV struct {
+	binding string
+	ns      kvBackend
+}
+
+var _ KVNamespace = (*KV)(nil)
+
+// kvBackend is the platform-specific half of KV. Reads report a missing key
+// with found == false rather than an error.
+type kvBackend interface {
+	get(ctx context.Context, key string, withMetadata bool) (value []byte, metadata json.RawMessage, found bool, err error)
+	put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error
+	delete(ctx context.Context, key string) error
+	list(ctx context.Context, opts *KVListOptions) (*KVListResult, error)
+}
+
+// NewKV opens the KV namespace bound to the Worker under binding. It
+// returns ErrBindingNotFound if no such binding is configured.
+func NewKV(binding string) (*KV, error) {
+	ns, err := openKV(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &KV{binding: binding, ns: ns}, nil
+}
+
+// Get returns the value stored under key, or ErrKeyNotFound.
+func (kv *KV) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := validateKVKey(key); err != nil {
+		return nil, err
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	value, _, found, err := kv.ns.get(ctx, key, false)
+	if err != nil {
+		return nil, kv.wrap("get", err)
+	}
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// GetWithMetadata returns the value and JSON metadata stored under key, or
+// ErrKeyNotFound. The metadata is nil if none was stored.
+func (kv *KV) GetWithMetadata(ctx context.Context, key string) ([]byte, json.RawMessage, error) {
+	if err := validateKVKey(key); err != nil {
+		return nil, nil, err
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, nil, err
+	}
+	value, metadata, found, err := kv.ns.get(ctx, key, true)
+	if err != nil {
+		return nil, nil, kv.wrap("get", err)
+	}
+	if !found {
+		return nil, nil, ErrKeyNotFound
+	}
+	return value, metadata, nil
+}
+
+// Put stores value under key. opts may be nil.
+func (kv *KV) Put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error {
+	var ttl time.Duration
+	if opts != nil {
+		ttl = opts.ExpirationTTL
+	}
+	if err := memstore.CheckKVPut(key, ttl); err != nil {
+		return fmt.Errorf("functions: %w", err)
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return err
+	}
+	return kv.wrap("put", kv.ns.put(ctx, key, value, opts))
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	if err := validateKVKey(key); err != nil {
+		return err
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return err
+	}
+	return kv.wrap("delete", kv.ns.delete(ctx, key))
+}
+
+// List returns one page of keys. opts may be nil.
+func (kv *KV) List(ctx context.Context, opts *KVListOptions) (*KVListResult, error) {
+	if opts == nil {
+		opts = &KVListOptions{}
+	}
+	if opts.Limit < 0 || opts.Limit > 1000 {
+		return nil, fmt.Errorf("functions: KV list limit must be between 0 (the default of 1000) and 1000, got %d", opts.Limit)
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	res, err := kv.ns.list(ctx, opts)
+	if err != nil {
+		return nil, kv.wrap("list", err)
+	}
+	return res, nil
+}
+
+func (kv *KV) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("functions: KV %s %s: %w", kv.binding, op, err)
+}
+
+func validateKVKey(key string) error {
+	if err := memstore.CheckKVKey(key); err != nil {
+		return fmt.Errorf("functions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+	"time"
+)
+
+type jsKV struct {
+	ns js.Value
+}
+
+func openKV(binding string) (kvBackend, error) {
+	ns, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsKV{ns: ns}, nil
+}
+
+func (kv *jsKV) get(ctx context.Context, key string, withMetadata bool) ([]byte, json.RawMessage, bool, error) {
+	opts := js.ValueOf(map[string]any{"type": "arrayBuffer"})
+	if !withMetadata {
+		v, err := awaitPromise(ctx, kv.ns.Call("get", key, opts))
+		if err != nil || isNullish(v) {
+			return nil, nil, false, err
+		}
+		return bytesFromJS(v), nil, true, nil
+	}
+
+	res, err := awaitPromise(ctx, kv.ns.Call("getWithMetadata", key, opts))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	v := res.Get("value")
+	if isNullish(v) {
+		return nil, nil, false, nil
+	}
+	return bytesFromJS(v), rawJSONFromJS(res.Get("metadata")), true, nil
+}
+
+func (kv *jsKV) put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error {
+	jsOpts := js.Global().Get("Object").New()
+	if opts != nil {
+		if opts.ExpirationTTL > 0 {
+			jsOpts.Set("expirationTtl", int(opts.ExpirationTTL/time.Second))
+		}
+		if opts.Metadata != nil {
+			md, err := valueToJS(opts.Metadata)
+			if err != nil {
+				return err
+			}
+			jsOpts.Set("metadata", md)
+		}
+	}
+	_, err := awaitPromise(ctx, kv.ns.Call("put", key, bytesToJS(value), jsOpts))
+	return err
+}
+
+func (kv *jsKV) delete(ctx context.Context, key string) error {
+	_, err := awaitPromise(ctx, kv.ns.Call("delete", key))
+	return err
+}
+
+func (kv *jsKV) list(ctx context.Context, opts *KVListOptions) (*KVListResult, error) {
+	jsOpts := js.Global().Get("Object").New()
+	if opts.Prefix != "" {
+		jsOpts.Set("prefix", opts.Prefix)
+	}
+	if opts.Limit > 0 {
+		jsOpts.Set("limit", opts.Limit)
+	}
+	if opts.Cursor != "" {
+		jsOpts.Set("cursor", opts.Cursor)
+	}
+	res, err := awaitPromise(ctx, kv.ns.Call("list", jsOpts))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := res.Get("keys")
+	out := &KVListResult{
+		Keys:         make([]KVKey, keys.Length()),
+		ListComplete: res.Get("list_complete").Truthy(),
+	}
+	if c := res.Get("cursor"); c.Type() == js.TypeString && !out.ListComplete {
+		out.Cursor = c.String()
+	}
+	for i := range out.Keys {
+		k := keys.Index(i)
+		out.Keys[i].Name = k.Get("name").String()
+		if exp := k.Get("expiration"); exp.Type() == js.TypeNumber {
+			out.Keys[i].Expiration = time.Unix(int64(exp.Float()), 0)
+		}
+		out.Keys[i].Metadata = rawJSONFromJS(k.Get("metadata"))
+	}
+	return out, nil
+}
@@ -0,0 +1,12 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openKV(binding string) (kvBackend, error) {
+	if b, ok := localBindings.openKV(binding); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %q (KV is only available in the Workers runtime; call SetupLocal for an in-memory fallback)", ErrBindingNotFound, binding)
+}
@@ -0,0 +1,102 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubKV is a kvBackend that records calls and serves a single stored value.
+type stubKV struct {
+	value    []byte
+	metadata json.RawMessage
+	found    bool
+	err      error
+	puts     []string
+}
+
+func (s *stubKV) get(ctx context.Context, key string, withMetadata bool) ([]byte, json.RawMessage, bool, error) {
+	if !withMetadata {
+		return s.value, nil, s.found, s.err
+	}
+	return s.value, s.metadata, s.found, s.err
+}
+
+func (s *stubKV) put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error {
+	s.puts = append(s.puts, key)
+	return s.err
+}
+
+func (s *stubKV) delete(ctx context.Context, key string) error { return s.err }
+
+func (s *stubKV) list(ctx context.Context, opts *KVListOptions) (*KVListResult, error) {
+	return &KVListResult{ListComplete: true}, s.err
+}
+
+func TestKVNotFound(t *testing.T) {
+	kv := &KV{binding: "CACHE", ns: &stubKV{}}
+	ctx := context.Background()
+
+	if _, err := kv.Get(ctx, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get err = %v, want ErrKeyNotFound", err)
+	}
+	if _, _, err := kv.GetWithMetadata(ctx, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetWithMetadata err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestKVGetWithMetadata(t *testing.T) {
+	kv := &KV{binding: "CACHE", ns: &stubKV{value: []byte("v"), metadata: json.RawMessage(`{"a":1}`), found: true}}
+
+	v, md, err := kv.GetWithMetadata(context.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "v" || string(md) != `{"a":1}` {
+		t.Fatalf("got %q %s", v, md)
+	}
+}
+
+func TestKVValidation(t *testing.T) {
+	backend := &stubKV{}
+	kv := &KV{binding: "CACHE", ns: backend}
+	ctx := context.Background()
+
+	if err := kv.Put(ctx, "", []byte("v"), nil); err == nil {
+		t.Error("empty key accepted")
+	}
+	if err := kv.Put(ctx, strings.Repeat("k", kvMaxKeyBytes+1), []byte("v"), nil); err == nil {
+		t.Error("oversized key accepted")
+	}
+	if err := kv.Put(ctx, "k", []byte("v"), &KVPutOptions{ExpirationTTL: time.Second}); err == nil {
+		t.Error("TTL below the platform minimum accepted")
+	}
+	if _, err := kv.List(ctx, &KVListOptions{Limit: 5000}); err == nil {
+		t.Error("list limit above 1000 accepted")
+	}
+	if len(backend.puts) != 0 {
+		t.Fatalf("invalid writes reached the backend: %v", backend.puts)
+	}
+	if err := kv.Put(ctx, "k", []byte("v"), &KVPutOptions{ExpirationTTL: time.Minute}); err != nil {
+		t.Fatalf("valid put: %v", err)
+	}
+}
+
+func TestKVWrapsBackendErrors(t *testing.T) {
+	boom := errors.New("boom")
+	kv := &KV{binding: "CACHE", ns: &stubKV{err: boom}}
+
+	err := kv.Delete(context.Background(), "k")
+	if !errors.Is(err, boom) || !strings.Contains(err.Error(), "CACHE") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestNewKVUnboundBinding(t *testing.T) {
+	if _, err := NewKV("MISSING"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
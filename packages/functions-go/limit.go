@@ -0,0 +1,26 @@
+package functions
+
+import "net/http"
+
+// LimitBody returns middleware that caps request bodies at maxBytes.
+//
+// Requests whose Content-Length already exceeds the limit get a 413 without
+// reaching the handler. Other bodies, including chunked ones with no
+// Content-Length, are wrapped with http.MaxBytesReader, so a handler that
+// reads past the limit gets an *http.MaxBytesError, which DecodeJSON reports
+// as ErrBodyTooLarge.
+func LimitBody(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				w.Header().Set("Connection", "close")
+				WriteJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package functions
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBodyContentLength(t *testing.T) {
+	called := false
+	h := LimitBody(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 11))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+	if called {
+		t.Fatal("handler ran for an oversized Content-Length")
+	}
+}
+
+func TestLimitBodyStreaming(t *testing.T) {
+	var readErr error
+	var n int64
+	h := LimitBody(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, readErr = io.Copy(io.Discard, r.Body)
+	}))
+
+	// A chunked body has no Content-Length to check up front.
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(strings.Repeat("x", 100))))
+	r.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var maxErr *http.MaxBytesError
+	if !errors.As(readErr, &maxErr) {
+		t.Fatalf("read err = %v, want *http.MaxBytesError", readErr)
+	}
+	if n > 10 {
+		t.Fatalf("read %d bytes past a 10 byte limit", n)
+	}
+}
+
+func TestLimitBodyWithDecodeJSON(t *testing.T) {
+	var decodeErr error
+	h := LimitBody(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]string
+		decodeErr = DecodeJSON(r, &v)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(`{"name":"`+strings.Repeat("a", 32)+`"}`)))
+	r.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if !errors.Is(decodeErr, ErrBodyTooLarge) {
+		t.Fatalf("err = %v, want ErrBodyTooLarge", decodeErr)
+	}
+}
+
+func TestLimitBodyWithinLimit(t *testing.T) {
+	h := LimitBody(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789")))
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+}
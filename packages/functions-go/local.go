@@ -0,0 +1,92 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultLocalAddr is the address ServeLocal listens on by default, the same
+// port `wrangler dev` uses.
+const DefaultLocalAddr = "localhost:8787"
+
+var setupLocalOnce = sync.OnceValue(func() error {
+	if err := loadDevVars(".dev.vars"); err != nil {
+		return err
+	}
+	localBindings.enable()
+	return nil
+})
+
+// SetupLocal prepares the process to run a handler natively instead of in
+// the Workers runtime. It is called by ServeLocal; call it directly first if
+// bindings or env vars are read while building the handler.
+//
+// SetupLocal loads `.dev.vars` from the working directory into the process
+// environment, without overriding variables that are already set, so Env
+// sees the same values `wrangler dev` would. It also switches bindings to
+// local fallbacks:
+//
+//   - KV (NewKV) and R2 (NewR2Bucket) are backed by in-memory stores, one
+//     per binding name, that last for the life of the process.
+//   - D1 (NewD1) has no local fallback and still returns
+//     ErrBindingNotFound; use `wrangler dev` to work against a real database.
+func SetupLocal() error {
+	return setupLocalOnce()
+}
+
+// ServeLocal serves h on a native net/http server at addr, or at
+// DefaultLocalAddr if addr is empty, after calling SetupLocal. It is only
+// available outside js/wasm builds, so it lives in a main file with a
+// `//go:build !js || !wasm` constraint next to the one that calls
+// workers.Serve.
+//
+// The handler, routing and middleware are exactly those deployed to the
+// Worker; only the bindings differ, as described on SetupLocal.
+func ServeLocal(addr string, h http.Handler) error {
+	if err := SetupLocal(); err != nil {
+		return err
+	}
+	if addr == "" {
+		addr = DefaultLocalAddr
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("functions: serving on http://%s", addr)
+	return srv.ListenAndServe()
+}
+
+func loadDevVars(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	vars, err := parseDevVars(f)
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		if _, set := os.LookupEnv(k); set {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("functions: setting %s from %s: %w", k, path, err)
+		}
+	}
+	return nil
+}
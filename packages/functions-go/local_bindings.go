@@ -0,0 +1,60 @@
+//go:build !js || !wasm
+
+package functions
+
+import "sync"
+
+// localBindings holds the in-memory bindings used after SetupLocal.
+var localBindings = &localRegistry{}
+
+type localRegistry struct {
+	mu      sync.Mutex
+	enabled bool
+	kv      map[string]*memoryKV
+	r2      map[string]*memoryR2
+}
+
+func (l *localRegistry) enable() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabled = true
+}
+
+// reset disables local bindings and drops their contents.
+func (l *localRegistry) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabled = false
+	l.kv = nil
+	l.r2 = nil
+}
+
+func (l *localRegistry) openKV(binding string) (kvBackend, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled {
+		return nil, false
+	}
+	if l.kv == nil {
+		l.kv = make(map[string]*memoryKV)
+	}
+	if l.kv[binding] == nil {
+		l.kv[binding] = newMemoryKV()
+	}
+	return l.kv[binding], true
+}
+
+func (l *localRegistry) openR2(binding string) (r2Backend, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled {
+		return nil, false
+	}
+	if l.r2 == nil {
+		l.r2 = make(map[string]*memoryR2)
+	}
+	if l.r2[binding] == nil {
+		l.r2[binding] = newMemoryR2()
+	}
+	return l.r2[binding], true
+}
@@ -0,0 +1,61 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dot-do/functions/packages/functions-go/internal/memstore"
+)
+
+// memoryKV is the in-memory kvBackend used by local bindings.
+type memoryKV struct {
+	kv *memstore.KV
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{kv: memstore.NewKV(time.Now)}
+}
+
+func (m *memoryKV) get(ctx context.Context, key string, withMetadata bool) ([]byte, json.RawMessage, bool, error) {
+	e, ok := m.kv.Get(key)
+	if !ok {
+		return nil, nil, false, nil
+	}
+	return e.Value, e.Metadata, true, nil
+}
+
+func (m *memoryKV) put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error {
+	var md json.RawMessage
+	var ttl time.Duration
+	if opts != nil {
+		ttl = opts.ExpirationTTL
+		if opts.Metadata != nil {
+			var err error
+			if md, err = json.Marshal(opts.Metadata); err != nil {
+				return err
+			}
+		}
+	}
+	m.kv.Put(key, value, md, ttl)
+	return nil
+}
+
+func (m *memoryKV) delete(ctx context.Context, key string) error {
+	m.kv.Delete(key)
+	return nil
+}
+
+func (m *memoryKV) list(ctx context.Context, opts *KVListOptions) (*KVListResult, error) {
+	keys, next, err := m.kv.List(opts.Prefix, opts.Cursor, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	res := &KVListResult{Cursor: next, ListComplete: next == ""}
+	for _, k := range keys {
+		res.Keys = append(res.Keys, KVKey{Name: k.Name, Expiration: k.Expiration, Metadata: k.Metadata})
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/dot-do/functions/packages/functions-go/internal/memstore"
+)
+
+// memoryR2 is the in-memory r2Backend used by local bindings.
+type memoryR2 struct {
+	b *memstore.Bucket[r2Metadata]
+}
+
+// r2Metadata is the metadata an object is stored with in a memstore.Bucket.
+type r2Metadata struct {
+	http   R2HTTPMetadata
+	custom map[string]string
+}
+
+func newMemoryR2() *memoryR2 {
+	return &memoryR2{b: memstore.NewBucket[r2Metadata](time.Now)}
+}
+
+func (m *memoryR2) get(ctx context.Context, key string) (*R2Object, bool, error) {
+	o, ok := m.b.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	obj := memoryR2Object(o)
+	obj.Body = io.NopCloser(bytes.NewReader(o.Data))
+	return obj, true, nil
+}
+
+func (m *memoryR2) put(ctx context.Context, key string, body io.Reader, size int64, opts *R2PutOptions) (*R2Object, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	o := m.b.Put(key, data, r2Metadata{http: opts.HTTPMetadata, custom: opts.CustomMetadata})
+	return memoryR2Object(o), nil
+}
+
+func (m *memoryR2) delete(ctx context.Context, key string) error {
+	m.b.Delete(key)
+	return nil
+}
+
+func (m *memoryR2) list(ctx context.Context, opts *R2ListOptions) (*R2ListResult, error) {
+	objects, prefixes, next, err := m.b.List(opts.Prefix, opts.Delimiter, opts.Cursor, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	res := &R2ListResult{Truncated: next != "", Cursor: next, DelimitedPrefixes: prefixes}
+	for _, o := range objects {
+		res.Objects = append(res.Objects, memoryR2Object(o))
+	}
+	return res, nil
+}
+
+// memoryR2Object returns the R2Object for o, without a Body.
+func memoryR2Object(o memstore.Object[r2Metadata]) *R2Object {
+	return &R2Object{
+		Key:            o.Key,
+		Size:           int64(len(o.Data)),
+		ETag:           o.ETag,
+		Uploaded:       o.Uploaded,
+		HTTPMetadata:   o.Meta.http,
+		CustomMetadata: o.Meta.custom,
+	}
+}
@@ -0,0 +1,89 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDevVarsKeepsExistingEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".dev.vars")
+	os.WriteFile(path, []byte("FUNCTIONS_LOCAL_A=from-file\nFUNCTIONS_LOCAL_B=from-file\n"), 0o600)
+	t.Setenv("FUNCTIONS_LOCAL_A", "from-env")
+	t.Setenv("FUNCTIONS_LOCAL_B", "")
+	os.Unsetenv("FUNCTIONS_LOCAL_B")
+
+	if err := loadDevVars(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := Env.String("FUNCTIONS_LOCAL_A", ""); got != "from-env" {
+		t.Errorf("A = %q, want the existing value", got)
+	}
+	if got := Env.String("FUNCTIONS_LOCAL_B", ""); got != "from-file" {
+		t.Errorf("B = %q, want the .dev.vars value", got)
+	}
+}
+
+func TestLoadDevVarsMissingFile(t *testing.T) {
+	if err := loadDevVars(filepath.Join(t.TempDir(), ".dev.vars")); err != nil {
+		t.Fatalf("missing .dev.vars should be ignored, got %v", err)
+	}
+}
+
+func TestLocalBindings(t *testing.T) {
+	localBindings.enable()
+	t.Cleanup(localBindings.reset)
+	ctx := context.Background()
+
+	kv, err := NewKV("CACHE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Put(ctx, "greeting", []byte("hi"), nil); err != nil {
+		t.Fatal(err)
+	}
+	// A second handle on the same binding sees the same data.
+	again, _ := NewKV("CACHE")
+	if v, err := again.Get(ctx, "greeting"); err != nil || string(v) != "hi" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	other, _ := NewKV("OTHER")
+	if _, err := other.Get(ctx, "greeting"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("separate binding shared data: %v", err)
+	}
+
+	bucket, err := NewR2Bucket("UPLOADS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"docs/a.txt", "docs/b.txt", "img/logo.png"} {
+		if _, err := bucket.Put(ctx, key, strings.NewReader(key), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	obj, err := bucket.Get(ctx, "docs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(obj.Body)
+	if string(body) != "docs/a.txt" || obj.ETag == "" {
+		t.Fatalf("object = %+v, body %q", obj, body)
+	}
+	list, err := bucket.List(ctx, &R2ListOptions{Delimiter: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(list.DelimitedPrefixes, ",") != "docs/,img/" || len(list.Objects) != 0 {
+		t.Fatalf("list = %+v", list)
+	}
+
+	if _, err := NewD1("DB"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("D1 err = %v, want ErrBindingNotFound", err)
+	}
+}
@@ -0,0 +1,94 @@
+package functions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RequestIDHeader is the header used to propagate request IDs.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds upstream request IDs that are propagated as is.
+const maxRequestIDLength = 128
+
+// LoggerOptions configures the Logger middleware.
+type LoggerOptions struct {
+	// Handler receives one record per request. If nil, records are written
+	// as JSON to standard output, which the Workers runtime forwards to the
+	// Worker's logs.
+	Handler slog.Handler
+
+	// SkipPaths lists request paths, such as "/health", that are not logged.
+	// Requests to them still get a request ID.
+	SkipPaths []string
+}
+
+// Logger returns middleware that assigns each request an ID and logs one
+// structured record per request with its method, path, status, bytes
+// written and duration.
+//
+// A request ID sent by the client in X-Request-Id is kept; otherwise a new
+// one is generated. Either way it is echoed on the response and available to
+// handlers through RequestID.
+func Logger(opts LoggerOptions) Middleware {
+	handler := opts.Handler
+	if handler == nil {
+		handler = slog.NewJSONHandler(os.Stdout, nil)
+	}
+	logger := slog.New(handler)
+	skip := make(map[string]bool, len(opts.SkipPaths))
+	for _, p := range opts.SkipPaths {
+		skip[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > maxRequestIDLength {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := NewResponseRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+				slog.String("request_id", id),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			)
+		})
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the request ID assigned by the Logger middleware, or ""
+// if ctx did not pass through it.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
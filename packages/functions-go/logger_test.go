@@ -0,0 +1,101 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerRecordsRequest(t *testing.T) {
+	var logs bytes.Buffer
+	h := Logger(LoggerOptions{Handler: slog.NewJSONHandler(&logs, nil)})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "hello")
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry is not JSON: %v\n%s", err, logs.String())
+	}
+	if entry["method"] != "POST" || entry["path"] != "/items" {
+		t.Errorf("method/path = %v %v", entry["method"], entry["path"])
+	}
+	if entry["status"] != float64(201) || entry["bytes"] != float64(5) {
+		t.Errorf("status/bytes = %v %v", entry["status"], entry["bytes"])
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("duration_ms missing: %v", entry)
+	}
+	id := w.Header().Get(RequestIDHeader)
+	if len(id) != 32 || entry["request_id"] != id {
+		t.Errorf("request id = %q, logged %v", id, entry["request_id"])
+	}
+}
+
+func TestLoggerDefaultStatus(t *testing.T) {
+	var logs bytes.Buffer
+	h := Logger(LoggerOptions{Handler: slog.NewJSONHandler(&logs, nil)})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var entry map[string]any
+	json.Unmarshal(logs.Bytes(), &entry)
+	if entry["status"] != float64(200) {
+		t.Fatalf("status = %v, want 200", entry["status"])
+	}
+}
+
+func TestLoggerPreservesUpstreamRequestID(t *testing.T) {
+	var seen string
+	h := Logger(LoggerOptions{Handler: slog.NewJSONHandler(io.Discard, nil)})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = RequestID(r.Context())
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "upstream-abc123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if seen != "upstream-abc123" {
+		t.Fatalf("RequestID in handler = %q", seen)
+	}
+	if got := w.Header().Get(RequestIDHeader); got != "upstream-abc123" {
+		t.Fatalf("response %s = %q", RequestIDHeader, got)
+	}
+}
+
+func TestLoggerSkipPaths(t *testing.T) {
+	var logs bytes.Buffer
+	var seen string
+	h := Logger(LoggerOptions{Handler: slog.NewJSONHandler(&logs, nil), SkipPaths: []string{"/health"}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = RequestID(r.Context())
+		}),
+	)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if logs.Len() != 0 {
+		t.Fatalf("skipped path was logged: %s", logs.String())
+	}
+	if seen == "" {
+		t.Fatal("skipped path lost its request ID")
+	}
+}
+
+func TestRequestIDWithoutMiddleware(t *testing.T) {
+	if id := RequestID(httptest.NewRequest(http.MethodGet, "/", nil).Context()); id != "" {
+		t.Fatalf("RequestID = %q, want empty", id)
+	}
+}
@@ -0,0 +1,25 @@
+package functions
+
+import "net/http"
+
+// Middleware wraps an http.Handler with additional behavior.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middleware in the order given: the first middleware is the
+// outermost and sees the request first.
+//
+// A middleware that writes a response without calling the next handler ends
+// the request; nothing further down the chain runs.
+func Chain(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// Use wraps h with mws, equivalent to Chain(mws...)(h).
+func Use(h http.Handler, mws ...Middleware) http.Handler {
+	return Chain(mws...)(h)
+}
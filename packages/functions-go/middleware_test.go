@@ -0,0 +1,80 @@
+package functions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// recordingMiddleware appends name to calls before and after calling next.
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name+":before")
+			next.ServeHTTP(w, r)
+			*calls = append(*calls, name+":after")
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+
+	chained := Chain(
+		recordingMiddleware("a", &calls),
+		recordingMiddleware("b", &calls),
+		recordingMiddleware("c", &calls),
+	)(h)
+	chained.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"a:before", "b:before", "c:before", "handler", "c:after", "b:after", "a:after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestChainShortCircuit(t *testing.T) {
+	var calls []string
+	handlerCalled := false
+	spy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+	})
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "deny")
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	}
+
+	h := Use(spy,
+		recordingMiddleware("outer", &calls),
+		deny,
+		recordingMiddleware("inner", &calls),
+	)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if handlerCalled {
+		t.Fatal("final handler ran after short-circuit")
+	}
+	want := []string{"outer:before", "deny", "outer:after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestChainEmpty(t *testing.T) {
+	called := false
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	Use(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Fatal("handler not called")
+	}
+}
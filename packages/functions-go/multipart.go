@@ -0,0 +1,245 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+)
+
+// DefaultMaxMultipartBytes is the total body size limit applied by
+// ParseMultipart.
+const DefaultMaxMultipartBytes int64 = 32 << 20
+
+// ErrMalformedMultipart is returned when a multipart body cannot be parsed,
+// for example because its Content-Type has no boundary.
+var ErrMalformedMultipart = errors.New("functions: malformed multipart form")
+
+// MultipartForm is a parsed multipart/form-data request body.
+type MultipartForm struct {
+	form *multipart.Form
+}
+
+// UploadedFile is a file sent in a multipart form.
+type UploadedFile struct {
+	// Filename is the base name the client gave the file. It is not
+	// safe to use as a path without further checks.
+	Filename string
+	// ContentType is the media type the client declared for the file,
+	// or "application/octet-stream" if it declared none.
+	ContentType string
+	// Size is the length of the file in bytes.
+	Size int64
+	// Header holds all the headers of the file's part.
+	Header textproto.MIMEHeader
+
+	fh *multipart.FileHeader
+}
+
+// Open returns the contents of the file. Call it as many times as needed;
+// each reader starts at the beginning and must be closed.
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	return f.fh.Open()
+}
+
+// ParseMultipart parses the multipart/form-data body of r, rejecting
+// bodies larger than DefaultMaxMultipartBytes:
+//
+//	form, err := functions.ParseMultipart(r, 8<<20)
+//	if err != nil {
+//		functions.WriteError(w, r, err)
+//		return
+//	}
+//	defer form.RemoveAll()
+//	avatar, err := form.File("avatar")
+//
+// The body is read part by part. Up to maxMemory bytes of file contents are
+// kept in memory, and larger files are streamed to temporary files, which
+// RemoveAll deletes. Workers have no disk to spill to, so in the Workers
+// runtime a form whose files exceed maxMemory is ErrBodyTooLarge; choose a
+// maxMemory that covers the largest upload expected, or read the body with
+// NewMultipartReader instead.
+//
+// A Content-Type other than multipart/form-data is ErrUnsupportedMediaType,
+// one without a boundary or a body that does not parse is
+// ErrMalformedMultipart, and a body over the limit is ErrBodyTooLarge.
+func ParseMultipart(r *http.Request, maxMemory int64) (*MultipartForm, error) {
+	return ParseMultipartLimit(r, maxMemory, DefaultMaxMultipartBytes)
+}
+
+// ParseMultipartLimit is like ParseMultipart but rejects bodies larger than
+// maxBytes.
+func ParseMultipartLimit(r *http.Request, maxMemory, maxBytes int64) (*MultipartForm, error) {
+	mr, err := openMultipart(r, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	form, err := mr.ReadForm(maxMemory)
+	if err != nil {
+		return nil, multipartError(err)
+	}
+	return &MultipartForm{form: form}, nil
+}
+
+// openMultipart checks that r has a multipart/form-data body of at most
+// maxBytes and returns a reader over its parts.
+func openMultipart(r *http.Request, maxBytes int64) (*multipart.Reader, error) {
+	ct := r.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, fmt.Errorf("%w: %q, want multipart/form-data", ErrUnsupportedMediaType, ct)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("%w: Content-Type has no boundary", ErrMalformedMultipart)
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, ErrEmptyBody
+	}
+	if r.ContentLength > maxBytes {
+		return nil, ErrBodyTooLarge
+	}
+	return multipart.NewReader(http.MaxBytesReader(nil, r.Body, maxBytes), boundary), nil
+}
+
+// multipartError maps an error from reading a multipart body to the errors
+// of this package. A file that could not be spilled to a temporary file is
+// too large for the memory allowed, as it always is in the Workers runtime.
+func multipartError(err error) error {
+	var (
+		maxErr  *http.MaxBytesError
+		pathErr *fs.PathError
+	)
+	switch {
+	case err == nil, err == io.EOF:
+		return err
+	case errors.As(err, &maxErr), errors.Is(err, multipart.ErrMessageTooLarge):
+		return ErrBodyTooLarge
+	case errors.As(err, &pathErr):
+		return fmt.Errorf("%w: a file exceeds the memory allowed and cannot be stored on disk: %v", ErrBodyTooLarge, err)
+	}
+	return fmt.Errorf("%w: %v", ErrMalformedMultipart, err)
+}
+
+// MultipartReader reads a multipart/form-data body one part at a time,
+// without buffering it; see NewMultipartReader.
+type MultipartReader struct {
+	mr *multipart.Reader
+}
+
+// MultipartPart is one field of a multipart form being read by
+// MultipartReader. It reads the field's contents, and is valid only until
+// the next call to NextPart.
+type MultipartPart struct {
+	// Name is the form field name.
+	Name string
+	// Filename is the base name the client gave a file, or "" for a text
+	// field. It is not safe to use as a path without further checks.
+	Filename string
+	// Header holds all the headers of the part.
+	Header textproto.MIMEHeader
+
+	p *multipart.Part
+}
+
+// NewMultipartReader returns a reader over the parts of r's
+// multipart/form-data body, rejecting bodies larger than maxBytes. Unlike
+// ParseMultipart it holds nothing in memory or on disk, so uploads of any
+// size up to maxBytes can be streamed on, for example to R2 or to another
+// service:
+//
+//	mr, err := functions.NewMultipartReader(r, 100<<20)
+//	if err != nil {
+//		return err
+//	}
+//	for {
+//		part, err := mr.NextPart()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		if part.Filename != "" {
+//			// Stream part to its destination.
+//		}
+//	}
+//
+// The errors are those of ParseMultipart, from NewMultipartReader, NextPart
+// and reads of a part alike.
+func NewMultipartReader(r *http.Request, maxBytes int64) (*MultipartReader, error) {
+	mr, err := openMultipart(r, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &MultipartReader{mr: mr}, nil
+}
+
+// NextPart returns the next part of the form, or io.EOF once there are no
+// more. Any unread contents of the previous part are skipped.
+func (m *MultipartReader) NextPart() (*MultipartPart, error) {
+	p, err := m.mr.NextPart()
+	if err != nil {
+		return nil, multipartError(err)
+	}
+	return &MultipartPart{Name: p.FormName(), Filename: p.FileName(), Header: p.Header, p: p}, nil
+}
+
+// Read reads the contents of the part.
+func (p *MultipartPart) Read(b []byte) (int, error) {
+	n, err := p.p.Read(b)
+	return n, multipartError(err)
+}
+
+// Value returns the first value of the text field name, or "" if there is
+// none. A file field is not a text field: Value returns "" for it.
+func (f *MultipartForm) Value(name string) string {
+	if vs := f.form.Value[name]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// Values returns every value of the text field name.
+func (f *MultipartForm) Values(name string) []string {
+	return f.form.Value[name]
+}
+
+// File returns the first file sent as name. If there is none it returns an
+// error wrapping http.ErrMissingFile, which says whether name was sent as a
+// text field instead, as browsers do for a file input left empty.
+func (f *MultipartForm) File(name string) (*UploadedFile, error) {
+	if files := f.Files(name); len(files) > 0 {
+		return files[0], nil
+	}
+	if _, ok := f.form.Value[name]; ok {
+		return nil, fmt.Errorf("%w: %q is a text field, not a file", http.ErrMissingFile, name)
+	}
+	return nil, fmt.Errorf("%w: %q", http.ErrMissingFile, name)
+}
+
+// Files returns every file sent as name, in the order they were sent.
+func (f *MultipartForm) Files(name string) []*UploadedFile {
+	fhs := f.form.File[name]
+	if len(fhs) == 0 {
+		return nil
+	}
+	files := make([]*UploadedFile, len(fhs))
+	for i, fh := range fhs {
+		ct := fh.Header.Get("Content-Type")
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		files[i] = &UploadedFile{Filename: fh.Filename, ContentType: ct, Size: fh.Size, Header: fh.Header, fh: fh}
+	}
+	return files
+}
+
+// RemoveAll deletes any temporary files holding the form's uploads.
+func (f *MultipartForm) RemoveAll() error {
+	return f.form.RemoveAll()
+}
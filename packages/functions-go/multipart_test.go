@@ -0,0 +1,204 @@
+package functions
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// multipartRequest builds a POST whose body has the given text fields and
+// files, each file given as filename, content type and contents.
+func multipartRequest(t *testing.T, fields map[string]string, files map[string][][3]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, v := range fields {
+		mw.WriteField(name, v)
+	}
+	for name, fs := range files {
+		for _, f := range fs {
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", `form-data; name="`+name+`"; filename="`+f[0]+`"`)
+			if f[1] != "" {
+				h.Set("Content-Type", f[1])
+			}
+			part, err := mw.CreatePart(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(part, f[2])
+		}
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func readUpload(t *testing.T, f *UploadedFile) string {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, _ := io.ReadAll(rc)
+	return string(b)
+}
+
+func TestParseMultipart(t *testing.T) {
+	r := multipartRequest(t,
+		map[string]string{"caption": "holiday"},
+		map[string][][3]string{"photos": {
+			{"beach.png", "image/png", "\x89PNG beach"},
+			{"../../etc/sunset.jpg", "", "sunset bytes"},
+		}},
+	)
+	form, err := ParseMultipart(r, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer form.RemoveAll()
+
+	if got := form.Value("caption"); got != "holiday" {
+		t.Errorf("caption = %q", got)
+	}
+	files := form.Files("photos")
+	if len(files) != 2 {
+		t.Fatalf("%d files, want 2", len(files))
+	}
+	if f := files[0]; f.Filename != "beach.png" || f.ContentType != "image/png" || f.Size != 10 || readUpload(t, f) != "\x89PNG beach" {
+		t.Errorf("first file = %+v", f)
+	}
+	if f := files[1]; f.Filename != "sunset.jpg" || f.ContentType != "application/octet-stream" || readUpload(t, f) != "sunset bytes" {
+		t.Errorf("second file = %+v", f)
+	}
+	if f, err := form.File("photos"); err != nil || f.Filename != "beach.png" {
+		t.Errorf("File(photos) = %+v, %v", f, err)
+	}
+}
+
+func TestParseMultipartFieldKinds(t *testing.T) {
+	r := multipartRequest(t,
+		map[string]string{"avatar": ""},
+		map[string][][3]string{"doc": {{"a.txt", "text/plain", "hello"}}},
+	)
+	form, err := ParseMultipart(r, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer form.RemoveAll()
+
+	// A text field asked for as a file, as sent for an empty file input.
+	_, err = form.File("avatar")
+	if !errors.Is(err, http.ErrMissingFile) || !strings.Contains(err.Error(), "text field") {
+		t.Errorf("File(avatar) err = %v", err)
+	}
+	if _, err := form.File("absent"); !errors.Is(err, http.ErrMissingFile) {
+		t.Errorf("File(absent) err = %v", err)
+	}
+	// A file asked for as a text field.
+	if got := form.Value("doc"); got != "" {
+		t.Errorf("Value(doc) = %q, want empty", got)
+	}
+}
+
+func TestParseMultipartSpillsLargeFiles(t *testing.T) {
+	big := strings.Repeat("x", 64<<10)
+	r := multipartRequest(t, nil, map[string][][3]string{"blob": {{"big.bin", "", big}}})
+	form, err := ParseMultipart(r, 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer form.RemoveAll()
+	f, err := form.File("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size != int64(len(big)) || readUpload(t, f) != big {
+		t.Fatalf("size %d, want %d", f.Size, len(big))
+	}
+}
+
+func TestParseMultipartErrors(t *testing.T) {
+	post := func(contentType, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+	if _, err := ParseMultipart(post("application/json", "{}"), 1<<20); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("JSON body: err = %v", err)
+	}
+	if _, err := ParseMultipart(post("multipart/form-data", "--x\r\n"), 1<<20); !errors.Is(err, ErrMalformedMultipart) || !strings.Contains(err.Error(), "boundary") {
+		t.Errorf("no boundary: err = %v", err)
+	}
+	if _, err := ParseMultipart(post("multipart/form-data; boundary=x", "not multipart"), 1<<20); !errors.Is(err, ErrMalformedMultipart) {
+		t.Errorf("garbage body: err = %v", err)
+	}
+
+	r := multipartRequest(t, nil, map[string][][3]string{"blob": {{"big.bin", "", strings.Repeat("x", 4<<10)}}})
+	if _, err := ParseMultipartLimit(r, 1<<20, 1<<10); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("over the limit: err = %v", err)
+	}
+	r = multipartRequest(t, nil, map[string][][3]string{"blob": {{"big.bin", "", strings.Repeat("x", 4<<10)}}})
+	r.ContentLength = -1 // chunked: the limit is only hit while reading
+	if _, err := ParseMultipartLimit(r, 1<<20, 1<<10); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("over the limit, chunked: err = %v", err)
+	}
+}
+
+func TestParseMultipartWithoutDisk(t *testing.T) {
+	// As in the Workers runtime, temporary files cannot be created.
+	t.Setenv("TMPDIR", t.TempDir()+"/missing")
+	r := multipartRequest(t, nil, map[string][][3]string{"blob": {{"big.bin", "", strings.Repeat("x", 64<<10)}}})
+	if _, err := ParseMultipart(r, 1<<10); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("err = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestMultipartReader(t *testing.T) {
+	big := strings.Repeat("x", 64<<10)
+	r := multipartRequest(t, map[string]string{"title": "holiday"}, map[string][][3]string{"photo": {{"beach.jpg", "image/jpeg", big}}})
+	mr, err := NewMultipartReader(r, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[part.Name+"|"+part.Filename] = string(b)
+	}
+	if len(got) != 2 || got["title|"] != "holiday" || got["photo|beach.jpg"] != big {
+		t.Fatalf("parts = %d, title %q, photo %d bytes", len(got), got["title|"], len(got["photo|beach.jpg"]))
+	}
+
+	r = multipartRequest(t, nil, map[string][][3]string{"blob": {{"big.bin", "", big}}})
+	r.ContentLength = -1
+	mr, err = NewMultipartReader(r, 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := mr.NextPart()
+	if err == nil {
+		_, err = io.ReadAll(part)
+	}
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("over the limit: err = %v, want ErrBodyTooLarge", err)
+	}
+}
@@ -0,0 +1,148 @@
+package functions
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate returns the offered media type the request's Accept header
+// prefers, or "" if the client accepts none of them.
+//
+// Each offer gets the quality of the most specific Accept range matching it,
+// so "text/html" beats "text/*", which beats "*/*". The offer with the
+// highest quality wins, and ties go to the offer listed first. A request
+// without an Accept header gets the first offer.
+func Negotiate(r *http.Request, offers ...string) string {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Respond writes data with the renderer whose media type the request
+// prefers, as chosen by Negotiate. It responds 406 Not Acceptable if the
+// client accepts none of them.
+//
+// Renderers are offered in sorted order, so sorting decides ties and the
+// response for clients without an Accept header. The response always has
+// the given status, whatever the renderer passes to WriteHeader, and a
+// Content-Type of the negotiated type unless the renderer sets its own:
+//
+//	functions.Respond(w, r, http.StatusOK, page, map[string]func(http.ResponseWriter, any){
+//		"application/json": func(w http.ResponseWriter, v any) { json.NewEncoder(w).Encode(v) },
+//		"text/html":        func(w http.ResponseWriter, v any) { pageTemplate.Execute(w, v) },
+//	})
+func Respond(w http.ResponseWriter, r *http.Request, status int, data any, renderers map[string]func(w http.ResponseWriter, data any)) {
+	offers := make([]string, 0, len(renderers))
+	for mt := range renderers {
+		offers = append(offers, mt)
+	}
+	sort.Strings(offers)
+
+	addVary(w.Header(), "Accept")
+	mt := Negotiate(r, offers...)
+	if mt == "" {
+		http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	}
+	rw := &respondWriter{ResponseWriter: w, status: status, contentType: mt}
+	renderers[mt](rw, data)
+	rw.WriteHeader(status)
+}
+
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, _ := strings.Cut(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mt)), "/")
+		if !ok || typ == "" || subtype == "" {
+			continue
+		}
+		ar := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(p, "=")
+			if strings.TrimSpace(k) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+				ar.q = q
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching
+// offer, or 0 if none does.
+func acceptQuality(ranges []acceptRange, offer string) float64 {
+	mt, _, _ := strings.Cut(offer, ";")
+	typ, subtype, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mt)), "/")
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			s = 2
+		case ar.typ == typ && ar.subtype == "*":
+			s = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+// respondWriter commits the status and Content-Type chosen by Respond on
+// the renderer's first write.
+type respondWriter struct {
+	http.ResponseWriter
+	status      int
+	contentType string
+	wroteHeader bool
+}
+
+func (w *respondWriter) WriteHeader(int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", w.contentType)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *respondWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(w.status)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *respondWriter) Flush() {
+	w.WriteHeader(w.status)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,106 @@
+package functions
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "text/html"}
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", offers, "application/json"},
+		{"", []string{"text/html", "application/json"}, "text/html"},
+		{"application/json;q=0.9, text/html", offers, "text/html"},
+		{"text/html;q=0.5, application/json;q=0.5", offers, "application/json"},
+		{"*/*", offers, "application/json"},
+		{"text/*", offers, "text/html"},
+		{"text/*;q=0.3, */*;q=0.2", offers, "text/html"},
+		{"text/*, text/html;q=0", offers, ""},
+		{"*/*;q=0.1, application/json;q=0", offers, "text/html"},
+		{"image/png", offers, ""},
+		{"application/xml, */*;q=0.8", offers, "application/json"},
+		{"TEXT/HTML", offers, "text/html"},
+		{"text/html;level=1;q=0.7, application/json;q=0.6", offers, "text/html"},
+		{"application/json;q=bogus, text/html;q=0.5", offers, "application/json"},
+		{"garbage, text/html", offers, "text/html"},
+		{"text/html", nil, ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := Negotiate(r, tt.offers...); got != tt.want {
+			t.Errorf("Negotiate(%q, %v) = %q, want %q", tt.accept, tt.offers, got, tt.want)
+		}
+	}
+}
+
+func TestRespond(t *testing.T) {
+	renderers := map[string]func(http.ResponseWriter, any){
+		"application/json": func(w http.ResponseWriter, v any) {
+			WriteJSON(w, http.StatusOK, v)
+		},
+		"text/html": func(w http.ResponseWriter, v any) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<p>%v</p>", v.(map[string]string)["name"])
+		},
+		"text/plain": func(w http.ResponseWriter, v any) {
+			io.WriteString(w, v.(map[string]string)["name"])
+		},
+	}
+	data := map[string]string{"name": "ada"}
+
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusCreated, "application/json", `{"name":"ada"}` + "\n"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", http.StatusCreated, "text/html; charset=utf-8", "<p>ada</p>"},
+		{"text/plain", http.StatusCreated, "text/plain", "ada"},
+		{"image/webp", http.StatusNotAcceptable, "text/plain; charset=utf-8", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			Respond(w, r, http.StatusCreated, data, renderers)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Fatalf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if v := w.Header().Get("Vary"); v != "Accept" {
+				t.Fatalf("Vary = %q", v)
+			}
+		})
+	}
+}
+
+func TestRespondEmptyRenderer(t *testing.T) {
+	w := httptest.NewRecorder()
+	Respond(w, httptest.NewRequest(http.MethodDelete, "/", nil), http.StatusAccepted, nil,
+		map[string]func(http.ResponseWriter, any){"application/json": func(http.ResponseWriter, any) {}})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
@@ -0,0 +1,401 @@
+package functions
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteDoc documents a route in the spec generated by Router.OpenAPI.
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// RequestBody is a value of the type the route accepts as its JSON
+	// body, such as CreateUser{}. Its schema is derived from the type;
+	// the value itself is ignored. Nil means the route takes no body.
+	RequestBody any
+
+	// Responses maps status codes to a value of the type returned with
+	// that status. A nil value documents a response without a body.
+	Responses map[int]any
+}
+
+// OpenAPIInfo is the info object of a generated OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// Doc attaches documentation to the route and returns it, for chaining
+// after registration:
+//
+//	rt.Get("/users/:id", showUser).Doc(functions.RouteDoc{
+//		Summary:   "Get a user",
+//		Responses: map[int]any{200: User{}, 404: nil},
+//	})
+func (r *Route) Doc(doc RouteDoc) *Route {
+	r.doc = &doc
+	return r
+}
+
+// OpenAPI returns an OpenAPI 3.1 JSON document describing every route
+// registered on rt, in registration order.
+//
+// Path parameters and catch-alls become required string parameters.
+// Schemas are derived from the Go types in each RouteDoc by reflection,
+// following encoding/json: json tags rename and omit fields, embedded
+// structs are flattened, and a field is required unless it is a pointer or
+// tagged omitempty. Named struct types are emitted once under
+// components/schemas and referenced from there. Types JSON cannot encode,
+// such as funcs and channels, get an empty schema.
+//
+// The title and version come from rt.OpenAPIInfo, defaulting to "API" and
+// "0.0.0".
+func (rt *Router) OpenAPI() []byte {
+	info := rt.OpenAPIInfo
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "0.0.0"
+	}
+	infoObj := map[string]any{"title": info.Title, "version": info.Version}
+	if info.Description != "" {
+		infoObj["description"] = info.Description
+	}
+
+	g := &schemaGen{names: make(map[reflect.Type]string), schemas: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+	for _, route := range rt.routes {
+		if route.hidden || !openAPIMethods[route.Method] {
+			continue
+		}
+		path, params := openAPIPath(route.Pattern)
+		item := paths[path]
+		if item == nil {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = g.operation(route, params)
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info":    infoObj,
+		"paths":   paths,
+	}
+	if len(g.schemas) > 0 {
+		doc["components"] = map[string]any{"schemas": g.schemas}
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		// Every value in doc is built from maps, slices and strings.
+		panic(err)
+	}
+	return append(b, '\n')
+}
+
+// ServeOpenAPI registers GET /openapi.json on rt, serving rt.OpenAPI. The
+// document is generated on each request, so it includes routes registered
+// later, and it leaves out its own route.
+func ServeOpenAPI(rt *Router) *Route {
+	route := rt.Get("/openapi.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(rt.OpenAPI())
+	}))
+	route.hidden = true
+	return route
+}
+
+var openAPIMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPut: true, http.MethodPost: true,
+	http.MethodDelete: true, http.MethodOptions: true, http.MethodHead: true,
+	http.MethodPatch: true, http.MethodTrace: true,
+}
+
+// openAPIPath converts a route pattern to an OpenAPI path template and
+// returns its parameter names.
+func openAPIPath(pattern string) (string, []string) {
+	segs, _ := compilePattern(pattern) // validated by Handle
+	var params []string
+	var b strings.Builder
+	for _, s := range segs {
+		b.WriteByte('/')
+		if s.kind == staticSegment {
+			b.WriteString(s.text)
+			continue
+		}
+		params = append(params, s.text)
+		b.WriteString("{" + s.text + "}")
+	}
+	return b.String(), params
+}
+
+func (g *schemaGen) operation(route *Route, params []string) map[string]any {
+	op := make(map[string]any)
+	if len(params) > 0 {
+		ps := make([]any, len(params))
+		for i, name := range params {
+			ps[i] = map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			}
+		}
+		op["parameters"] = ps
+	}
+
+	doc := route.doc
+	if doc == nil {
+		doc = &RouteDoc{}
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	if doc.Description != "" {
+		op["description"] = doc.Description
+	}
+	if len(doc.Tags) > 0 {
+		op["tags"] = doc.Tags
+	}
+	if doc.RequestBody != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(g.schema(reflect.TypeOf(doc.RequestBody))),
+		}
+	}
+
+	responses := make(map[string]any)
+	for status, v := range doc.Responses {
+		resp := map[string]any{"description": statusDescription(status)}
+		if v != nil {
+			resp["content"] = jsonContent(g.schema(reflect.TypeOf(v)))
+		}
+		responses[strconv.Itoa(status)] = resp
+	}
+	if len(responses) == 0 {
+		responses["default"] = map[string]any{"description": "Undocumented response"}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func statusDescription(status int) string {
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return "Status " + strconv.Itoa(status)
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaGen derives JSON Schemas from Go types, collecting named structs
+// into components.
+type schemaGen struct {
+	names   map[reflect.Type]string
+	schemas map[string]any
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	durationType      = reflect.TypeOf(time.Duration(0))
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]any{}
+	case jsonNumberType:
+		return map[string]any{"type": "number"}
+	case durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]any{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32", "minimum": 0}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return map[string]any{}
+}
+
+// structRef returns a $ref to the component for a named struct, building it
+// on first use, or the inline schema of an anonymous struct.
+func (g *schemaGen) structRef(t reflect.Type) map[string]any {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		g.schemas[name] = nil // reserve the name; the type may refer to itself
+		g.schemas[name] = g.structSchema(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName picks a unique component name for t, qualifying it with
+// its package if another type already took the bare name.
+func (g *schemaGen) componentName(t reflect.Type) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+				return r
+			}
+			return '_'
+		}, s)
+	}
+	name := clean(t.Name())
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	base := clean(pkg + "." + t.Name())
+	name = base
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[name]; !taken {
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	g.addFields(t, props, &required)
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+// addFields adds the JSON fields of struct t to props. Untagged embedded
+// structs are flattened after the struct's own fields, so that, as in
+// encoding/json, a field declared directly wins over a promoted one.
+func (g *schemaGen) addFields(t reflect.Type, props map[string]any, required *[]string) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			et := ft
+			for et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				embedded = append(embedded, et)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, dup := props[name]; dup {
+			continue
+		}
+
+		schema := g.schema(ft)
+		if hasTagOption(opts, "string") && isJSONStringable(ft) {
+			schema = map[string]any{"type": "string"}
+		}
+		props[name] = schema
+		if !hasTagOption(opts, "omitempty") && ft.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+	for _, et := range embedded {
+		g.addFields(et, props, required)
+	}
+}
+
+func hasTagOption(opts, want string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == want {
+			return true
+		}
+	}
+	return false
+}
+
+// isJSONStringable reports whether the ",string" tag option applies to t.
+func isJSONStringable(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+type apiTimestamps struct {
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type apiUser struct {
+	ID      int64             `json:"id"`
+	Name    string            `json:"name"`
+	Email   string            `json:"email,omitempty"`
+	Roles   []string          `json:"roles"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Manager *apiUser          `json:"manager"`
+	Score   int               `json:"score,string"`
+	secret  string
+	Ignored string `json:"-"`
+	apiTimestamps
+}
+
+type apiCreateUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Admin bool   `json:"admin,omitempty"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func openAPITestRouter() *Router {
+	rt := NewRouter()
+	rt.OpenAPIInfo = OpenAPIInfo{Title: "Users", Version: "1.2.0"}
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	rt.Get("/users", noop).Doc(RouteDoc{
+		Summary:   "List users",
+		Tags:      []string{"users"},
+		Responses: map[int]any{200: []apiUser{}},
+	})
+	rt.Post("/users", noop).Doc(RouteDoc{
+		Summary:     "Create a user",
+		Tags:        []string{"users"},
+		RequestBody: apiCreateUser{},
+		Responses:   map[int]any{201: apiUser{}, 400: apiError{}},
+	})
+	rt.Get("/users/:id", noop).Doc(RouteDoc{
+		Summary:   "Get a user",
+		Responses: map[int]any{200: &apiUser{}, 404: apiError{}},
+	})
+	rt.Delete("/users/:id", noop).Doc(RouteDoc{
+		Responses: map[int]any{204: nil},
+	})
+	rt.Get("/files/*path", noop)
+	ServeOpenAPI(rt)
+	return rt
+}
+
+func TestOpenAPIGolden(t *testing.T) {
+	got := openAPITestRouter().OpenAPI()
+
+	golden := filepath.Join("testdata", "openapi.golden.json")
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("OpenAPI() differs from %s; run go test -run OpenAPIGolden -update and review the diff\n%s", golden, got)
+	}
+}
+
+func TestOpenAPIIsDeterministic(t *testing.T) {
+	first := openAPITestRouter().OpenAPI()
+	for i := 0; i < 10; i++ {
+		if got := openAPITestRouter().OpenAPI(); !bytes.Equal(got, first) {
+			t.Fatal("OpenAPI output changed between runs")
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	rt := openAPITestRouter()
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q, want 200 JSON", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var doc struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/openapi.json"]; ok {
+		t.Error("the spec documents its own route")
+	}
+	if _, ok := doc.Paths["/users/{id}"]["delete"]; !ok {
+		t.Errorf("paths = %v, want DELETE /users/{id}", doc.Paths)
+	}
+}
@@ -0,0 +1,212 @@
+package functions
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hopHeaders are the hop-by-hop headers of RFC 9110, which describe a
+// single connection and are not forwarded by proxies.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ProxyOptions configures Proxy.
+type ProxyOptions struct {
+	// Director, if set, modifies each outbound request after Proxy has
+	// pointed it at the target, for example to add credentials:
+	//
+	//	Director: func(r *http.Request) {
+	//		r.Header.Set("Authorization", "Bearer "+token)
+	//	}
+	Director func(*http.Request)
+
+	// ModifyResponse, if set, modifies each upstream response before it is
+	// copied to the client. If it returns an error, the client gets a 502
+	// instead.
+	ModifyResponse func(*http.Response) error
+
+	// Transport sends the outbound requests. If nil, http.DefaultTransport
+	// is used, which in the Workers runtime is fetch. With it, redirects
+	// are passed to the client rather than followed; a custom Transport
+	// must not follow them either.
+	Transport http.RoundTripper
+
+	// Logger receives upstream failures. If nil, slog.Default is used.
+	Logger *slog.Logger
+}
+
+// Proxy returns a handler that forwards every request to target, like
+// httputil.ReverseProxy:
+//
+//	origin, _ := url.Parse("https://api.internal.example.com/v2")
+//	http.Handle("/", functions.Proxy(origin, functions.ProxyOptions{}))
+//
+// The request path is appended to the target's path and the queries are
+// merged, so GET /users?page=2 goes to /v2/users?page=2. The outbound Host is
+// the target's. Hop-by-hop headers, and any the Connection header names,
+// are dropped in both directions; the client's address is appended to
+// X-Forwarded-For, and X-Forwarded-Host and X-Forwarded-Proto describe the
+// original request.
+//
+// Bodies are streamed rather than buffered. A response of unknown length,
+// such as a chunked or event-stream one, is flushed to the client as each
+// piece arrives. Each proxied request counts against the SubrequestLimit
+// budget. If the upstream cannot be reached the client gets a 502.
+func Proxy(target *url.URL, opts ProxyOptions) http.Handler {
+	transport := opts.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := takeSubrequest(r.Context()); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		out := proxyRequest(r, target)
+		if transport == http.DefaultTransport {
+			noFollowRedirects(out)
+		}
+		if opts.Director != nil {
+			opts.Director(out)
+		}
+
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			if r.Context().Err() == nil {
+				logger.Error("proxy upstream failed", "method", r.Method, "path", r.URL.Path, "target", target.Redacted(), "error", err.Error())
+			}
+			WriteError(w, r, &Error{Status: http.StatusBadGateway, Code: "bad_gateway", Message: "the upstream server could not be reached"})
+			return
+		}
+		defer resp.Body.Close()
+		removeHopHeaders(resp.Header)
+		if opts.ModifyResponse != nil {
+			if err := opts.ModifyResponse(resp); err != nil {
+				logger.Error("proxy ModifyResponse failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+				WriteError(w, r, &Error{Status: http.StatusBadGateway, Code: "bad_gateway", Message: "the upstream response was rejected"})
+				return
+			}
+		}
+
+		h := w.Header()
+		for k, vs := range resp.Header {
+			h[k] = append(h[k][:0:0], vs...)
+		}
+		w.WriteHeader(resp.StatusCode)
+		if err := copyProxyBody(w, resp.Body, resp.ContentLength < 0); err != nil && r.Context().Err() == nil {
+			logger.Warn("proxy response copy failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+		}
+	})
+}
+
+// proxyRequest returns the request to send upstream for r.
+func proxyRequest(r *http.Request, target *url.URL) *http.Request {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Host = ""
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+
+	u := *target
+	u.Path, u.RawPath = joinProxyPath(target, r.URL)
+	switch {
+	case target.RawQuery == "":
+		u.RawQuery = r.URL.RawQuery
+	case r.URL.RawQuery != "":
+		u.RawQuery = target.RawQuery + "&" + r.URL.RawQuery
+	}
+	out.URL = &u
+
+	removeHopHeaders(out.Header)
+	if ip := ClientIP(r); ip != "" {
+		if prior := out.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			ip = strings.Join(prior, ", ") + ", " + ip
+		}
+		out.Header.Set("X-Forwarded-For", ip)
+	}
+	out.Header.Set("X-Forwarded-Host", r.Host)
+	proto := "http"
+	if r.TLS != nil || r.URL.Scheme == "https" {
+		proto = "https"
+	}
+	out.Header.Set("X-Forwarded-Proto", proto)
+	return out
+}
+
+// joinProxyPath appends the path of req to that of target, with exactly one
+// slash between them.
+func joinProxyPath(target, req *url.URL) (path, rawPath string) {
+	join := func(a, b string) string {
+		switch {
+		case strings.HasSuffix(a, "/") && strings.HasPrefix(b, "/"):
+			return a + b[1:]
+		case !strings.HasSuffix(a, "/") && !strings.HasPrefix(b, "/"):
+			return a + "/" + b
+		}
+		return a + b
+	}
+	if target.RawPath == "" && req.RawPath == "" {
+		return join(target.Path, req.Path), ""
+	}
+	return join(target.Path, req.Path), join(target.EscapedPath(), req.EscapedPath())
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// copyProxyBody copies an upstream body to w, flushing after every write if
+// flush is set.
+func copyProxyBody(w http.ResponseWriter, body io.Reader, flush bool) error {
+	if !flush {
+		_, err := io.Copy(w, body)
+		return err
+	}
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if err := rc.Flush(); errors.Is(err, http.ErrNotSupported) {
+				_, err := io.Copy(w, body)
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build js && wasm
+
+package functions
+
+import "net/http"
+
+// noFollowRedirects tells the fetch transport of syscall/js to hand
+// redirects back rather than follow them, which fetch does by default. The
+// transport reads the option from a js.fetch:redirect header and does not
+// send it.
+func noFollowRedirects(r *http.Request) {
+	r.Header.Set("js.fetch:redirect", "manual")
+}
@@ -0,0 +1,8 @@
+//go:build !js || !wasm
+
+package functions
+
+import "net/http"
+
+// noFollowRedirects does nothing: http.Transport never follows redirects.
+func noFollowRedirects(*http.Request) {}
@@ -0,0 +1,184 @@
+package functions
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func proxyTo(t *testing.T, origin http.Handler, opts ProxyOptions) (*httptest.Server, *url.URL) {
+	t.Helper()
+	up := httptest.NewServer(origin)
+	t.Cleanup(up.Close)
+	target, _ := url.Parse(up.URL + "/v2")
+	front := httptest.NewServer(Proxy(target, opts))
+	t.Cleanup(front.Close)
+	return front, target
+}
+
+func TestProxyRewritesRequest(t *testing.T) {
+	seen := make(chan *http.Request, 1)
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		seen <- r
+		w.Header().Set("Connection", "X-Internal")
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	})
+	front, target := proxyTo(t, origin, ProxyOptions{
+		Director: func(r *http.Request) { r.Header.Set("Authorization", "Bearer upstream") },
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, front.URL+"/users?page=2", strings.NewReader(`{"name":"ada"}`))
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set("Proxy-Authorization", "Basic eA==")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusCreated || string(body) != "created" {
+		t.Errorf("response = %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Upstream") != "yes" || resp.Header.Get("X-Internal") != "" {
+		t.Errorf("response headers = %v", resp.Header)
+	}
+
+	r := <-seen
+	if r.URL.Path != "/v2/users" || r.URL.RawQuery != "page=2" {
+		t.Errorf("upstream URL = %s", r.URL)
+	}
+	if r.Host != target.Host {
+		t.Errorf("upstream Host = %q, want %q", r.Host, target.Host)
+	}
+	if got := r.Header.Get("X-Forwarded-For"); got != "203.0.113.7, 127.0.0.1" {
+		t.Errorf("X-Forwarded-For = %q", got)
+	}
+	if got := r.Header.Get("X-Forwarded-Host"); got != strings.TrimPrefix(front.URL, "http://") {
+		t.Errorf("X-Forwarded-Host = %q", got)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer upstream" {
+		t.Errorf("Authorization = %q", got)
+	}
+	for _, h := range []string{"X-Hop", "Proxy-Authorization"} {
+		if r.Header.Get(h) != "" {
+			t.Errorf("hop-by-hop %s forwarded", h)
+		}
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != `{"name":"ada"}` {
+		t.Errorf("upstream body = %q", b)
+	}
+}
+
+func TestProxyStreamsResponse(t *testing.T) {
+	next := make(chan struct{})
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first\n")
+		w.(http.Flusher).Flush()
+		<-next
+		io.WriteString(w, "second\n")
+	})
+	front, _ := proxyTo(t, origin, ProxyOptions{})
+
+	resp, err := http.Get(front.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if te := resp.TransferEncoding; len(te) == 0 || te[0] != "chunked" {
+		t.Errorf("TransferEncoding = %v, want chunked", te)
+	}
+
+	br := bufio.NewReader(resp.Body)
+	got := make(chan string, 1)
+	go func() {
+		line, _ := br.ReadString('\n')
+		got <- line
+	}()
+	select {
+	case line := <-got:
+		if line != "first\n" {
+			t.Fatalf("first chunk = %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("first chunk not delivered before the upstream finished")
+	}
+	close(next)
+	if line, _ := br.ReadString('\n'); line != "second\n" {
+		t.Errorf("second chunk = %q", line)
+	}
+}
+
+func TestProxyModifyResponse(t *testing.T) {
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "origin/1.0")
+		io.WriteString(w, "ok")
+	})
+	front, _ := proxyTo(t, origin, ProxyOptions{
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Del("Server")
+			resp.Header.Set("X-Proxied", "1")
+			return nil
+		},
+	})
+	resp, err := http.Get(front.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Server") != "" || resp.Header.Get("X-Proxied") != "1" {
+		t.Errorf("headers = %v", resp.Header)
+	}
+
+	front, _ = proxyTo(t, origin, ProxyOptions{
+		ModifyResponse: func(*http.Response) error { return errors.New("rejected") },
+		Logger:         discardLogger(),
+	})
+	resp, err = http.Get(front.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", resp.StatusCode)
+	}
+}
+
+func TestProxyUnreachable(t *testing.T) {
+	up := httptest.NewServer(http.NotFoundHandler())
+	target, _ := url.Parse(up.URL)
+	up.Close()
+
+	w := httptest.NewRecorder()
+	Proxy(target, ProxyOptions{Logger: discardLogger()}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", w.Code)
+	}
+}
+
+func TestJoinProxyPath(t *testing.T) {
+	for _, tt := range []struct{ target, req, want string }{
+		{"", "/a", "/a"},
+		{"/v2", "/a", "/v2/a"},
+		{"/v2/", "/a", "/v2/a"},
+		{"/v2", "/", "/v2/"},
+	} {
+		path, _ := joinProxyPath(&url.URL{Path: tt.target}, &url.URL{Path: tt.req})
+		if path != tt.want {
+			t.Errorf("join(%q, %q) = %q, want %q", tt.target, tt.req, path, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	queueMaxMessageBytes = 128 << 10
+	queueMaxBatchBytes   = 256 << 10
+	queueMaxBatchLen     = 100
+)
+
+// Queue is a producer for a Cloudflare Queue.
+//
+// The binding name is the `binding` of a `[[queues.producers]]` entry in
+// wrangler.toml:
+//
+//	[[queues.producers]]
+//	binding = "JOBS"
+//	queue = "jobs"
+//
+// which is opened with NewQueue("JOBS").
+type Queue struct {
+	binding string
+	q       queueBackend
+}
+
+// queueBackend is the platform-specific half of Queue. Bodies are already
+// JSON-encoded.
+type queueBackend interface {
+	send(ctx context.Context, body json.RawMessage) error
+	sendBatch(ctx context.Context, bodies []json.RawMessage) error
+}
+
+// NewQueue opens the queue producer bound to the Worker under binding. It
+// returns ErrBindingNotFound if no such binding is configured.
+func NewQueue(binding string) (*Queue, error) {
+	q, err := openQueue(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{binding: binding, q: q}, nil
+}
+
+// Send JSON-encodes body and enqueues it as one message.
+func (q *Queue) Send(ctx context.Context, body any) error {
+	b, err := encodeQueueMessage(body)
+	if err != nil {
+		return err
+	}
+	return q.wrap("send", q.q.send(ctx, b))
+}
+
+// SendBatch JSON-encodes msgs and enqueues them in a single call. A batch
+// holds at most 100 messages and 256 KiB in total.
+func (q *Queue) SendBatch(ctx context.Context, msgs ...any) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if len(msgs) > queueMaxBatchLen {
+		return fmt.Errorf("functions: queue batch has %d messages, the limit is %d", len(msgs), queueMaxBatchLen)
+	}
+	bodies := make([]json.RawMessage, len(msgs))
+	total := 0
+	for i, m := range msgs {
+		b, err := encodeQueueMessage(m)
+		if err != nil {
+			return fmt.Errorf("%w (message %d)", err, i)
+		}
+		bodies[i] = b
+		total += len(b)
+	}
+	if total > queueMaxBatchBytes {
+		return fmt.Errorf("functions: queue batch is %d bytes, the limit is %d", total, queueMaxBatchBytes)
+	}
+	return q.wrap("sendBatch", q.q.sendBatch(ctx, bodies))
+}
+
+func (q *Queue) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("functions: queue %s %s: %w", q.binding, op, err)
+}
+
+func encodeQueueMessage(body any) (json.RawMessage, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("functions: encoding queue message: %w", err)
+	}
+	if len(b) > queueMaxMessageBytes {
+		return nil, fmt.Errorf("functions: queue message is %d bytes, the limit is %d", len(b), queueMaxMessageBytes)
+	}
+	return b, nil
+}
+
+// QueueHandler consumes a batch of queue messages.
+//
+// Messages acknowledged with Ack are not redelivered, and messages marked
+// with Retry are redelivered, whatever the handler returns. If the handler
+// returns an error, every other message in the batch is retried; if it
+// returns nil they are acknowledged. Acking messages one by one therefore
+// limits a partial failure to the messages that actually failed.
+type QueueHandler func(ctx context.Context, batch *QueueBatch) error
+
+// QueueBatch is a batch of messages delivered to a queue consumer.
+type QueueBatch struct {
+	// Queue is the name of the queue the messages came from.
+	Queue    string
+	Messages []*QueueMessage
+}
+
+// AckAll acknowledges every message in the batch.
+func (b *QueueBatch) AckAll() {
+	for _, m := range b.Messages {
+		m.Ack()
+	}
+}
+
+// RetryAll marks every message in the batch for redelivery.
+func (b *QueueBatch) RetryAll() {
+	for _, m := range b.Messages {
+		m.Retry()
+	}
+}
+
+// QueueMessage is one message in a QueueBatch.
+type QueueMessage struct {
+	ID        string
+	Timestamp time.Time
+	// Body is the message as JSON.
+	Body json.RawMessage
+	// Attempts counts deliveries of this message, starting at 1.
+	Attempts int
+
+	once  sync.Once
+	ack   func()
+	retry func()
+}
+
+// NewQueueMessage returns a message whose Ack and Retry call ack and retry.
+// It is used by runtime adapters and to build batches in tests.
+func NewQueueMessage(id string, timestamp time.Time, body json.RawMessage, attempts int, ack, retry func()) *QueueMessage {
+	return &QueueMessage{ID: id, Timestamp: timestamp, Body: body, Attempts: attempts, ack: ack, retry: retry}
+}
+
+// Decode unmarshals the message body into v.
+func (m *QueueMessage) Decode(v any) error {
+	return json.Unmarshal(m.Body, v)
+}
+
+// Ack marks the message as processed so it is not redelivered. Only the
+// first call to Ack or Retry on a message has an effect.
+func (m *QueueMessage) Ack() {
+	m.once.Do(func() {
+		if m.ack != nil {
+			m.ack()
+		}
+	})
+}
+
+// Retry marks the message for redelivery. Only the first call to Ack or
+// Retry on a message has an effect.
+func (m *QueueMessage) Retry() {
+	m.once.Do(func() {
+		if m.retry != nil {
+			m.retry()
+		}
+	})
+}
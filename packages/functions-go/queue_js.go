@@ -0,0 +1,43 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+)
+
+type jsQueue struct {
+	q js.Value
+}
+
+func openQueue(binding string) (queueBackend, error) {
+	q, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsQueue{q: q}, nil
+}
+
+func (q *jsQueue) send(ctx context.Context, body json.RawMessage) error {
+	opts := js.ValueOf(map[string]any{"contentType": "json"})
+	_, err := awaitPromise(ctx, q.q.Call("send", parseJSON(body), opts))
+	return err
+}
+
+func (q *jsQueue) sendBatch(ctx context.Context, bodies []json.RawMessage) error {
+	msgs := js.Global().Get("Array").New(len(bodies))
+	for i, b := range bodies {
+		m := js.Global().Get("Object").New()
+		m.Set("body", parseJSON(b))
+		m.Set("contentType", "json")
+		msgs.SetIndex(i, m)
+	}
+	_, err := awaitPromise(ctx, q.q.Call("sendBatch", msgs))
+	return err
+}
+
+func parseJSON(b json.RawMessage) js.Value {
+	return js.Global().Get("JSON").Call("parse", string(b))
+}
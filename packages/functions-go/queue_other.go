@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openQueue(binding string) (queueBackend, error) {
+	return nil, fmt.Errorf("%w: %q (Queues are only available in the Workers runtime; use `wrangler dev` to run with a local queue)", ErrBindingNotFound, binding)
+}
@@ -0,0 +1,114 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubQueue is a queueBackend that records the messages it is sent.
+type stubQueue struct {
+	sent    []json.RawMessage
+	batches int
+	err     error
+}
+
+func (s *stubQueue) send(ctx context.Context, body json.RawMessage) error {
+	s.sent = append(s.sent, body)
+	return s.err
+}
+
+func (s *stubQueue) sendBatch(ctx context.Context, bodies []json.RawMessage) error {
+	s.batches++
+	s.sent = append(s.sent, bodies...)
+	return s.err
+}
+
+func TestQueueSend(t *testing.T) {
+	stub := &stubQueue{}
+	q := &Queue{binding: "JOBS", q: stub}
+	ctx := context.Background()
+
+	if err := q.Send(ctx, map[string]string{"job": "resize"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SendBatch(ctx, 1, "two", []int{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SendBatch(ctx); err != nil || stub.batches != 1 {
+		t.Fatalf("empty batch: err = %v, batches = %d", err, stub.batches)
+	}
+
+	var got []string
+	for _, b := range stub.sent {
+		got = append(got, string(b))
+	}
+	if want := `{"job":"resize"}|1|"two"|[3]`; strings.Join(got, "|") != want {
+		t.Fatalf("sent %s, want %s", strings.Join(got, "|"), want)
+	}
+}
+
+func TestQueueSendLimits(t *testing.T) {
+	q := &Queue{binding: "JOBS", q: &stubQueue{}}
+	ctx := context.Background()
+
+	if err := q.Send(ctx, strings.Repeat("x", queueMaxMessageBytes)); err == nil {
+		t.Fatal("expected an error for an oversized message")
+	}
+	if err := q.Send(ctx, func() {}); err == nil {
+		t.Fatal("expected an error for an unencodable message")
+	}
+	if err := q.SendBatch(ctx, make([]any, queueMaxBatchLen+1)...); err == nil {
+		t.Fatal("expected an error for too many messages")
+	}
+	big := strings.Repeat("x", 100<<10)
+	if err := q.SendBatch(ctx, big, big, big); err == nil {
+		t.Fatal("expected an error for an oversized batch")
+	}
+}
+
+func TestQueueWrapsErrors(t *testing.T) {
+	q := &Queue{binding: "JOBS", q: &stubQueue{err: errors.New("boom")}}
+	err := q.Send(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "queue JOBS send: boom") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestQueueBatchAckRetry(t *testing.T) {
+	var acked, retried []string
+	msg := func(id, body string) *QueueMessage {
+		return NewQueueMessage(id, time.Now(), json.RawMessage(body), 1,
+			func() { acked = append(acked, id) },
+			func() { retried = append(retried, id) })
+	}
+	batch := &QueueBatch{Queue: "jobs", Messages: []*QueueMessage{
+		msg("a", `{"n":1}`), msg("b", `{"n":-1}`), msg("c", `{"n":2}`),
+	}}
+
+	// A consumer that fails on negative numbers acks the rest one by one.
+	handler := func(ctx context.Context, batch *QueueBatch) error {
+		for _, m := range batch.Messages {
+			var job struct{ N int }
+			if err := m.Decode(&job); err != nil || job.N < 0 {
+				m.Retry()
+				continue
+			}
+			m.Ack()
+		}
+		return nil
+	}
+	if err := handler(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	// Later calls don't change a message's outcome.
+	batch.AckAll()
+	batch.RetryAll()
+
+	if strings.Join(acked, ",") != "a,c" || strings.Join(retried, ",") != "b" {
+		t.Fatalf("acked %v, retried %v", acked, retried)
+	}
+}
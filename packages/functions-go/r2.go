@@ -0,0 +1,213 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrObjectNotFound is returned by R2 reads for keys that do not exist.
+var ErrObjectNotFound = errors.New("functions: object not found")
+
+// R2Object is an object stored in an R2 bucket.
+type R2Object struct {
+	Key            string
+	Size           int64
+	ETag           string
+	Uploaded       time.Time
+	HTTPMetadata   R2HTTPMetadata
+	CustomMetadata map[string]string
+
+	// Body streams the object's contents. It is set only for objects
+	// returned by Get, and must be closed by the caller. Nothing is read
+	// from the bucket until Body is read.
+	Body io.ReadCloser
+}
+
+// R2HTTPMetadata holds the HTTP headers stored with an object.
+type R2HTTPMetadata struct {
+	ContentType        string
+	ContentLanguage    string
+	ContentDisposition string
+	ContentEncoding    string
+	CacheControl       string
+	CacheExpiry        time.Time
+}
+
+// R2PutOptions configures an R2 write.
+type R2PutOptions struct {
+	// Size is the length of the body in bytes. It can be omitted when the
+	// body reports its own length, as *bytes.Reader, *bytes.Buffer and
+	// *strings.Reader do.
+	Size int64
+
+	HTTPMetadata   R2HTTPMetadata
+	CustomMetadata map[string]string
+}
+
+// R2ListOptions configures an R2 listing.
+type R2ListOptions struct {
+	// Prefix restricts the listing to keys that start with it.
+	Prefix string
+
+	// Delimiter groups keys that share a prefix up to the delimiter into
+	// R2ListResult.DelimitedPrefixes, like directories.
+	Delimiter string
+
+	// Limit is the maximum number of objects to return, at most 1000. If
+	// zero, up to 1000 are returned.
+	Limit int
+
+	// Cursor continues a previous listing.
+	Cursor string
+}
+
+// R2ListResult is one page of an R2 listing. Its objects have no Body.
+type R2ListResult struct {
+	Objects           []*R2Object
+	DelimitedPrefixes []string
+
+	// Cursor is passed to the next List call when Truncated is true.
+	Cursor    string
+	Truncated bool
+}
+
+// R2Bucket is a Cloudflare R2 bucket.
+//
+// The binding name is the `binding` of an `[[r2_buckets]]` entry in
+// wrangler.toml:
+//
+//	[[r2_buckets]]
+//	binding = "UPLOADS"
+//	bucket_name = "user-uploads"
+//
+// which is opened with NewR2Bucket("UPLOADS").
+type R2Bucket struct {
+	binding string
+	b       r2Backend
+}
+
+// R2Store is the interface implemented by *R2Bucket. Handlers that accept an
+// R2Store instead of *R2Bucket can be unit-tested with functest.NewMockR2.
+type R2Store interface {
+	Get(ctx context.Context, key string) (*R2Object, error)
+	Put(ctx context.Context, key string, body io.Reader, opts *R2PutOptions) (*R2Object, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, opts *R2ListOptions) (*R2ListResult, error)
+}
+
+var _ R2Store = (*R2Bucket)(nil)
+
+// r2Backend is the platform-specific half of R2Bucket. Reads report a
+// missing object with found == false rather than an error.
+type r2Backend interface {
+	get(ctx context.Context, key string) (obj *R2Object, found bool, err error)
+	put(ctx context.Context, key string, body io.Reader, size int64, opts *R2PutOptions) (*R2Object, error)
+	delete(ctx context.Context, key string) error
+	list(ctx context.Context, opts *R2ListOptions) (*R2ListResult, error)
+}
+
+// NewR2Bucket opens the R2 bucket bound to the Worker under binding. It
+// returns ErrBindingNotFound if no such binding is configured.
+func NewR2Bucket(binding string) (*R2Bucket, error) {
+	b, err := openR2(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &R2Bucket{binding: binding, b: b}, nil
+}
+
+// Get returns the object stored under key, or ErrObjectNotFound. The caller
+// must close the object's Body.
+func (b *R2Bucket) Get(ctx context.Context, key string) (*R2Object, error) {
+	if key == "" {
+		return nil, errors.New("functions: R2 key must not be empty")
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	obj, found, err := b.b.get(ctx, key)
+	if err != nil {
+		return nil, b.wrap("get", err)
+	}
+	if !found {
+		return nil, ErrObjectNotFound
+	}
+	return obj, nil
+}
+
+// Put streams body into the bucket under key and returns the stored
+// object's metadata. The body is never buffered in full, so its size must
+// be known up front; see R2PutOptions.Size. opts may be nil.
+func (b *R2Bucket) Put(ctx context.Context, key string, body io.Reader, opts *R2PutOptions) (*R2Object, error) {
+	if key == "" {
+		return nil, errors.New("functions: R2 key must not be empty")
+	}
+	if opts == nil {
+		opts = &R2PutOptions{}
+	}
+	size, ok := r2BodySize(body, opts.Size)
+	if !ok {
+		return nil, errors.New("functions: R2 put requires the body size; set R2PutOptions.Size")
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	obj, err := b.b.put(ctx, key, body, size, opts)
+	if err != nil {
+		return nil, b.wrap("put", err)
+	}
+	return obj, nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (b *R2Bucket) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return errors.New("functions: R2 key must not be empty")
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return err
+	}
+	return b.wrap("delete", b.b.delete(ctx, key))
+}
+
+// List returns one page of objects. opts may be nil.
+func (b *R2Bucket) List(ctx context.Context, opts *R2ListOptions) (*R2ListResult, error) {
+	if opts == nil {
+		opts = &R2ListOptions{}
+	}
+	if opts.Limit < 0 || opts.Limit > 1000 {
+		return nil, fmt.Errorf("functions: R2 list limit must be between 0 (the default of 1000) and 1000, got %d", opts.Limit)
+	}
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	res, err := b.b.list(ctx, opts)
+	if err != nil {
+		return nil, b.wrap("list", err)
+	}
+	return res, nil
+}
+
+func (b *R2Bucket) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("functions: R2 %s %s: %w", b.binding, op, err)
+}
+
+// r2BodySize determines the length of body, preferring an explicit size.
+func r2BodySize(body io.Reader, size int64) (int64, bool) {
+	switch {
+	case size > 0:
+		return size, true
+	case body == nil:
+		return 0, true
+	}
+	if l, ok := body.(interface{ Len() int }); ok {
+		return int64(l.Len()), true
+	}
+	return 0, false
+}
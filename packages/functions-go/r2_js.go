@@ -0,0 +1,250 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall/js"
+	"time"
+)
+
+// r2ChunkSize is the size of the chunks streamed into an R2 put.
+const r2ChunkSize = 64 << 10
+
+type jsR2 struct {
+	bucket js.Value
+}
+
+func openR2(binding string) (r2Backend, error) {
+	bucket, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsR2{bucket: bucket}, nil
+}
+
+func (b *jsR2) get(ctx context.Context, key string) (*R2Object, bool, error) {
+	v, err := awaitPromise(ctx, b.bucket.Call("get", key))
+	if err != nil || isNullish(v) {
+		return nil, false, err
+	}
+	obj := r2ObjectFromJS(v)
+	obj.Body = newJSStreamReader(ctx, v.Get("body"))
+	return obj, true, nil
+}
+
+func (b *jsR2) put(ctx context.Context, key string, body io.Reader, size int64, opts *R2PutOptions) (*R2Object, error) {
+	jsOpts := js.Global().Get("Object").New()
+	jsOpts.Set("httpMetadata", r2HTTPMetadataToJS(opts.HTTPMetadata))
+	if len(opts.CustomMetadata) > 0 {
+		md := js.Global().Get("Object").New()
+		for k, v := range opts.CustomMetadata {
+			md.Set(k, v)
+		}
+		jsOpts.Set("customMetadata", md)
+	}
+
+	// R2 only accepts streams of known length; FixedLengthStream lets us
+	// feed it chunk by chunk without holding the whole body.
+	var value js.Value
+	writeErr := make(chan error, 1)
+	if body == nil || size == 0 {
+		value = js.Null()
+		writeErr <- nil
+	} else {
+		stream := js.Global().Get("FixedLengthStream").New(size)
+		value = stream.Get("readable")
+		go func() { writeErr <- pipeToJSWriter(ctx, body, stream.Get("writable").Call("getWriter")) }()
+	}
+
+	v, err := awaitPromise(ctx, b.bucket.Call("put", key, value, jsOpts))
+	if werr := <-writeErr; werr != nil {
+		return nil, werr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r2ObjectFromJS(v), nil
+}
+
+func (b *jsR2) delete(ctx context.Context, key string) error {
+	_, err := awaitPromise(ctx, b.bucket.Call("delete", key))
+	return err
+}
+
+func (b *jsR2) list(ctx context.Context, opts *R2ListOptions) (*R2ListResult, error) {
+	jsOpts := js.Global().Get("Object").New()
+	jsOpts.Set("include", js.ValueOf([]any{"httpMetadata", "customMetadata"}))
+	if opts.Prefix != "" {
+		jsOpts.Set("prefix", opts.Prefix)
+	}
+	if opts.Delimiter != "" {
+		jsOpts.Set("delimiter", opts.Delimiter)
+	}
+	if opts.Limit > 0 {
+		jsOpts.Set("limit", opts.Limit)
+	}
+	if opts.Cursor != "" {
+		jsOpts.Set("cursor", opts.Cursor)
+	}
+	v, err := awaitPromise(ctx, b.bucket.Call("list", jsOpts))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := v.Get("objects")
+	res := &R2ListResult{
+		Objects:   make([]*R2Object, objects.Length()),
+		Truncated: v.Get("truncated").Truthy(),
+	}
+	for i := range res.Objects {
+		res.Objects[i] = r2ObjectFromJS(objects.Index(i))
+	}
+	if res.Truncated {
+		res.Cursor = v.Get("cursor").String()
+	}
+	if prefixes := v.Get("delimitedPrefixes"); !isNullish(prefixes) {
+		for i := 0; i < prefixes.Length(); i++ {
+			res.DelimitedPrefixes = append(res.DelimitedPrefixes, prefixes.Index(i).String())
+		}
+	}
+	return res, nil
+}
+
+func r2ObjectFromJS(v js.Value) *R2Object {
+	obj := &R2Object{
+		Key:      v.Get("key").String(),
+		Size:     int64(v.Get("size").Float()),
+		ETag:     v.Get("httpEtag").String(),
+		Uploaded: jsDate(v.Get("uploaded")),
+	}
+	if md := v.Get("httpMetadata"); !isNullish(md) {
+		obj.HTTPMetadata = R2HTTPMetadata{
+			ContentType:        jsString(md.Get("contentType")),
+			ContentLanguage:    jsString(md.Get("contentLanguage")),
+			ContentDisposition: jsString(md.Get("contentDisposition")),
+			ContentEncoding:    jsString(md.Get("contentEncoding")),
+			CacheControl:       jsString(md.Get("cacheControl")),
+			CacheExpiry:        jsDate(md.Get("cacheExpiry")),
+		}
+	}
+	if md := v.Get("customMetadata"); !isNullish(md) {
+		keys := js.Global().Get("Object").Call("keys", md)
+		if n := keys.Length(); n > 0 {
+			obj.CustomMetadata = make(map[string]string, n)
+			for i := 0; i < n; i++ {
+				k := keys.Index(i).String()
+				obj.CustomMetadata[k] = md.Get(k).String()
+			}
+		}
+	}
+	return obj
+}
+
+func r2HTTPMetadataToJS(md R2HTTPMetadata) js.Value {
+	v := js.Global().Get("Object").New()
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	set("contentType", md.ContentType)
+	set("contentLanguage", md.ContentLanguage)
+	set("contentDisposition", md.ContentDisposition)
+	set("contentEncoding", md.ContentEncoding)
+	set("cacheControl", md.CacheControl)
+	if !md.CacheExpiry.IsZero() {
+		v.Set("cacheExpiry", js.Global().Get("Date").New(md.CacheExpiry.UnixMilli()))
+	}
+	return v
+}
+
+func jsString(v js.Value) string {
+	if v.Type() != js.TypeString {
+		return ""
+	}
+	return v.String()
+}
+
+func jsDate(v js.Value) time.Time {
+	if isNullish(v) {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(v.Call("getTime").Float()))
+}
+
+// pipeToJSWriter copies r into a WritableStreamDefaultWriter, waiting for
+// each chunk to be accepted before reading the next.
+func pipeToJSWriter(ctx context.Context, r io.Reader, writer js.Value) error {
+	buf := make([]byte, r2ChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := js.Global().Get("Uint8Array").New(n)
+			js.CopyBytesToJS(chunk, buf[:n])
+			if _, werr := awaitPromise(ctx, writer.Call("write", chunk)); werr != nil {
+				writer.Call("abort", werr.Error())
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			_, cerr := awaitPromise(ctx, writer.Call("close"))
+			return cerr
+		}
+		if err != nil {
+			writer.Call("abort", err.Error())
+			return err
+		}
+	}
+}
+
+// jsStreamReader reads a JavaScript ReadableStream on demand.
+type jsStreamReader struct {
+	ctx     context.Context
+	stream  js.Value
+	reader  js.Value
+	pending []byte
+	done    bool
+}
+
+func newJSStreamReader(ctx context.Context, stream js.Value) io.ReadCloser {
+	return &jsStreamReader{ctx: ctx, stream: stream}
+}
+
+func (r *jsStreamReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if r.done || isNullish(r.stream) {
+			return 0, io.EOF
+		}
+		if r.reader.IsUndefined() {
+			r.reader = r.stream.Call("getReader")
+		}
+		res, err := awaitPromise(r.ctx, r.reader.Call("read"))
+		if err != nil {
+			return 0, err
+		}
+		if res.Get("done").Bool() {
+			r.done = true
+			return 0, io.EOF
+		}
+		r.pending = bytesFromJS(res.Get("value"))
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *jsStreamReader) Close() error {
+	if r.done || isNullish(r.stream) {
+		return nil
+	}
+	r.done = true
+	if r.reader.IsUndefined() {
+		r.stream.Call("cancel")
+	} else {
+		r.reader.Call("cancel")
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openR2(binding string) (r2Backend, error) {
+	if b, ok := localBindings.openR2(binding); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %q (R2 is only available in the Workers runtime; call SetupLocal for an in-memory fallback)", ErrBindingNotFound, binding)
+}
@@ -0,0 +1,118 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stubR2 is an r2Backend that serves one object and records puts.
+type stubR2 struct {
+	obj      *R2Object
+	putBody  io.Reader
+	putSize  int64
+	listOpts *R2ListOptions
+}
+
+func (s *stubR2) get(ctx context.Context, key string) (*R2Object, bool, error) {
+	if s.obj == nil || s.obj.Key != key {
+		return nil, false, nil
+	}
+	return s.obj, true, nil
+}
+
+func (s *stubR2) put(ctx context.Context, key string, body io.Reader, size int64, opts *R2PutOptions) (*R2Object, error) {
+	s.putBody, s.putSize = body, size
+	return &R2Object{Key: key, Size: size, HTTPMetadata: opts.HTTPMetadata}, nil
+}
+
+func (s *stubR2) delete(ctx context.Context, key string) error { return nil }
+
+func (s *stubR2) list(ctx context.Context, opts *R2ListOptions) (*R2ListResult, error) {
+	s.listOpts = opts
+	return &R2ListResult{}, nil
+}
+
+func TestR2GetMissingObject(t *testing.T) {
+	b := &R2Bucket{binding: "UPLOADS", b: &stubR2{}}
+	if _, err := b.Get(context.Background(), "avatars/1.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("err = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestR2GetReturnsBody(t *testing.T) {
+	b := &R2Bucket{binding: "UPLOADS", b: &stubR2{obj: &R2Object{
+		Key:  "a.txt",
+		Size: 5,
+		Body: io.NopCloser(strings.NewReader("hello")),
+	}}}
+
+	obj, err := b.Get(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Body.Close()
+	got, _ := io.ReadAll(obj.Body)
+	if string(got) != "hello" || obj.Size != 5 {
+		t.Fatalf("got %q (size %d)", got, obj.Size)
+	}
+}
+
+func TestR2PutStreamsBody(t *testing.T) {
+	stub := &stubR2{}
+	b := &R2Bucket{binding: "UPLOADS", b: stub}
+	body := strings.NewReader("streamed bytes")
+
+	obj, err := b.Put(context.Background(), "a.txt", body, &R2PutOptions{
+		HTTPMetadata: R2HTTPMetadata{ContentType: "text/plain"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.putBody != body {
+		t.Fatal("Put did not hand the caller's reader to the backend")
+	}
+	if stub.putSize != int64(len("streamed bytes")) || obj.HTTPMetadata.ContentType != "text/plain" {
+		t.Fatalf("size = %d, metadata = %+v", stub.putSize, obj.HTTPMetadata)
+	}
+}
+
+func TestR2PutRequiresSize(t *testing.T) {
+	stub := &stubR2{}
+	b := &R2Bucket{binding: "UPLOADS", b: stub}
+	unsized := io.MultiReader(bytes.NewReader([]byte("x")))
+
+	if _, err := b.Put(context.Background(), "a", unsized, nil); err == nil {
+		t.Fatal("expected an error for a body of unknown size")
+	}
+	if _, err := b.Put(context.Background(), "a", unsized, &R2PutOptions{Size: 1}); err != nil {
+		t.Fatalf("explicit size: %v", err)
+	}
+	if stub.putSize != 1 {
+		t.Fatalf("size = %d, want 1", stub.putSize)
+	}
+}
+
+func TestR2ListValidation(t *testing.T) {
+	stub := &stubR2{}
+	b := &R2Bucket{binding: "UPLOADS", b: stub}
+
+	if _, err := b.List(context.Background(), &R2ListOptions{Limit: 1001}); err == nil {
+		t.Fatal("list limit above 1000 accepted")
+	}
+	if _, err := b.List(context.Background(), &R2ListOptions{Prefix: "avatars/", Cursor: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if stub.listOpts.Prefix != "avatars/" || stub.listOpts.Cursor != "c1" {
+		t.Fatalf("options not forwarded: %+v", stub.listOpts)
+	}
+}
+
+func TestNewR2BucketUnboundBinding(t *testing.T) {
+	if _, err := NewR2Bucket("MISSING"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
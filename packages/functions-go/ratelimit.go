@@ -0,0 +1,334 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configures the RateLimit middleware.
+type RateLimitOptions struct {
+	// Limit is the number of requests a client may make per Window.
+	Limit int
+
+	// Window is the length of each fixed counting window.
+	Window time.Duration
+
+	// KeyFunc identifies the client a request counts against. If nil,
+	// ClientIP is used. Requests for which it returns "" are not limited.
+	KeyFunc func(r *http.Request) string
+
+	// Store holds the counters. If nil, an in-memory store is used, which
+	// counts per Worker isolate rather than globally; see
+	// NewMemoryRateLimitStore.
+	Store RateLimitStore
+
+	// Logger receives store errors. If nil, slog.Default is used.
+	Logger *slog.Logger
+}
+
+// RateLimitStore counts requests per key in fixed windows.
+type RateLimitStore interface {
+	// Increment adds one to the counter for key in the window that
+	// contains now and returns the new count and the time the window ends.
+	Increment(ctx context.Context, key string, window time.Duration) (count int64, reset time.Time, err error)
+}
+
+// RateLimit returns middleware that limits each client to opts.Limit
+// requests per opts.Window. It panics if opts is invalid; use
+// NewRateLimit to handle the error instead.
+func RateLimit(opts RateLimitOptions) Middleware {
+	mw, err := NewRateLimit(opts)
+	if err != nil {
+		panic(err)
+	}
+	return mw
+}
+
+// NewRateLimit returns middleware that limits each client to opts.Limit
+// requests per opts.Window, using fixed windows aligned to the clock.
+//
+// Every limited response carries X-RateLimit-Limit, X-RateLimit-Remaining
+// and X-RateLimit-Reset (the Unix time in seconds at which the window
+// ends). Requests over the limit get a 429 with Retry-After and never reach
+// the handler. If the store fails, the request is let through and the error
+// logged, so an outage of the store does not take the Worker down with it.
+//
+// How exact the limit is depends on the store; see
+// NewDurableObjectRateLimitStore and NewKVRateLimitStore.
+func NewRateLimit(opts RateLimitOptions) (Middleware, error) {
+	if opts.Limit <= 0 {
+		return nil, fmt.Errorf("functions: rate limit must be positive, got %d", opts.Limit)
+	}
+	if opts.Window <= 0 {
+		return nil, fmt.Errorf("functions: rate limit window must be positive, got %s", opts.Window)
+	}
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = ClientIP
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	limit := strconv.Itoa(opts.Limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			count, reset, err := store.Increment(r.Context(), key, opts.Window)
+			if err != nil {
+				logger.Warn("rate limit store failed; request allowed", "key", key, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", limit)
+			h.Set("X-RateLimit-Remaining", strconv.FormatInt(max(int64(opts.Limit)-count, 0), 10))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if count > int64(opts.Limit) {
+				wait := max((time.Until(reset)+time.Second-1)/time.Second, 1)
+				h.Set("Retry-After", strconv.FormatInt(int64(wait), 10))
+				WriteJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// ClientIP returns the address of the client that sent r: the
+// CF-Connecting-IP header set by Cloudflare, or the host of r.RemoteAddr
+// outside Workers.
+func ClientIP(r *http.Request) string {
+	if ip := r.Header.Get("CF-Connecting-IP"); ip != "" {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// rateLimitWindow returns the fixed window containing now.
+func rateLimitWindow(now time.Time, window time.Duration) (start, reset time.Time) {
+	start = now.Truncate(window)
+	return start, start.Add(window)
+}
+
+// MemoryRateLimitStore is a RateLimitStore held in process memory. It is
+// exact, but each Worker isolate has its own counters, so across the edge
+// a client may get up to Limit requests per isolate that serves it. It is
+// meant for tests, local development and coarse per-isolate limits.
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryRateCounter
+	swept    time.Time
+	now      func() time.Time
+}
+
+type memoryRateCounter struct {
+	count int64
+	reset time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counters: make(map[string]memoryRateCounter), now: time.Now}
+}
+
+// Increment implements RateLimitStore.
+func (s *MemoryRateLimitStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.swept) >= window {
+		for k, c := range s.counters {
+			if !now.Before(c.reset) {
+				delete(s.counters, k)
+			}
+		}
+		s.swept = now
+	}
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.reset) {
+		_, reset := rateLimitWindow(now, window)
+		c = memoryRateCounter{reset: reset}
+	}
+	c.count++
+	s.counters[key] = c
+	return c.count, c.reset, nil
+}
+
+// kvRateLimitStore counts in a KV namespace.
+type kvRateLimitStore struct {
+	kv  KVNamespace
+	now func() time.Time
+}
+
+// NewKVRateLimitStore returns a RateLimitStore that keeps its counters in
+// kv, so that they are shared by every isolate.
+//
+// The limit is best-effort. KV has no atomic increment, so each request
+// reads the counter and writes it back one higher; concurrent requests
+// that read the same value each write the same result, and all of them
+// are let through. KV is also eventually consistent: a write can take up
+// to a minute to reach other locations, during which they keep counting
+// from the old value. Expect it to stop sustained abuse, not to enforce an
+// exact quota; use NewDurableObjectRateLimitStore for that.
+func NewKVRateLimitStore(kv KVNamespace) RateLimitStore {
+	return &kvRateLimitStore{kv: kv, now: time.Now}
+}
+
+func (s *kvRateLimitStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	now := s.now()
+	start, reset := rateLimitWindow(now, window)
+	k := "ratelimit:" + key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+
+	var count int64
+	v, err := s.kv.Get(ctx, k)
+	switch {
+	case err == nil:
+		if count, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+			return 0, time.Time{}, fmt.Errorf("functions: corrupt rate limit counter %q: %w", k, err)
+		}
+	case !errors.Is(err, ErrKeyNotFound):
+		return 0, time.Time{}, err
+	}
+	count++
+
+	// Keys expire once their window is over, but no sooner than KV allows.
+	ttl := max(reset.Sub(now)+time.Second, kvMinTTL)
+	if err := s.kv.Put(ctx, k, []byte(strconv.FormatInt(count, 10)), &KVPutOptions{ExpirationTTL: ttl}); err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, reset, nil
+}
+
+// durableObjectRateLimitStore counts in one Durable Object per key.
+type durableObjectRateLimitStore struct {
+	ns *DurableObjectNamespace
+}
+
+// NewDurableObjectRateLimitStore returns a RateLimitStore that keeps each
+// key's counter in its own Durable Object from the namespace bound under
+// binding:
+//
+//	[[durable_objects.bindings]]
+//	name = "RATE_LIMITER"
+//	class_name = "RateLimiter"
+//
+// The limit is exact. Every request for a key is routed to the single
+// object named after it, and an object handles one request at a time, so
+// increments to the same counter are serialized no matter how many
+// requests arrive at once or where at the edge they land. The counter is
+// only as durable as the object, though: the class below and
+// RateLimitObject keep it in memory, and the runtime evicts an object that
+// has been idle for a while, so a key that pauses mid-window may start
+// again from zero. Limits whose windows outlast that idle time need a
+// class that keeps the count in Durable Object storage.
+//
+// The object class must answer the protocol implemented by
+// RateLimitObject: a POST of {"window_ms": n} returns {"count": n,
+// "reset": unixMillis}. A JavaScript class that does so:
+//
+//	export class RateLimiter {
+//	  fetch(req) {
+//	    return req.json().then(({ window_ms }) => {
+//	      const now = Date.now()
+//	      if (!(now < this.reset)) {
+//	        this.reset = now - (now % window_ms) + window_ms
+//	        this.count = 0
+//	      }
+//	      return Response.json({ count: ++this.count, reset: this.reset })
+//	    })
+//	  }
+//	}
+func NewDurableObjectRateLimitStore(binding string) (RateLimitStore, error) {
+	ns, err := NewDurableObject(binding)
+	if err != nil {
+		return nil, err
+	}
+	return NewDurableObjectRateLimitStoreFrom(ns), nil
+}
+
+// NewDurableObjectRateLimitStoreFrom is like NewDurableObjectRateLimitStore
+// but uses an already opened namespace, such as one from
+// NewDurableObjectWithHandlers serving RateLimitObject.
+func NewDurableObjectRateLimitStoreFrom(ns *DurableObjectNamespace) RateLimitStore {
+	return &durableObjectRateLimitStore{ns: ns}
+}
+
+type rateLimitRequest struct {
+	WindowMS int64 `json:"window_ms"`
+}
+
+type rateLimitResponse struct {
+	Count int64 `json:"count"`
+	Reset int64 `json:"reset"` // Unix milliseconds
+}
+
+func (s *durableObjectRateLimitStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	body, _ := json.Marshal(rateLimitRequest{WindowMS: window.Milliseconds()})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://rate-limit/increment", bytes.NewReader(body))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.ns.Get(s.ns.IDFromName(key)).Fetch(ctx, req)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, time.Time{}, fmt.Errorf("functions: Durable Object %s: rate limit object answered %s", s.ns.binding, resp.Status)
+	}
+	var out rateLimitResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, time.Time{}, fmt.Errorf("functions: Durable Object %s: decode rate limit response: %w", s.ns.binding, err)
+	}
+	return out.Count, time.UnixMilli(out.Reset), nil
+}
+
+// RateLimitObject returns the handler of a rate limit Durable Object
+// written in Go, for use with NewDurableObjectRateLimitStore. Each object
+// holds one counter; the handler must be created once per object instance
+// so that the counter lives as long as the instance does. The counter is
+// kept in instance memory, so it resets when the runtime evicts the idle
+// object, and a client that pauses long enough gets a fresh quota before
+// its window ends.
+func RateLimitObject() http.Handler {
+	store := NewMemoryRateLimitStore()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": http.StatusText(http.StatusMethodNotAllowed)})
+			return
+		}
+		var in rateLimitRequest
+		if err := DecodeJSON(r, &in); err != nil || in.WindowMS <= 0 {
+			WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "window_ms must be a positive integer"})
+			return
+		}
+		count, reset, _ := store.Increment(r.Context(), "", time.Duration(in.WindowMS)*time.Millisecond)
+		WriteJSON(w, http.StatusOK, rateLimitResponse{Count: count, Reset: reset.UnixMilli()})
+	})
+}
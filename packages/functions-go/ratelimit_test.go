@@ -0,0 +1,223 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for rate limit stores.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func rateLimitedHandler(store RateLimitStore, limit int) http.Handler {
+	return RateLimit(RateLimitOptions{Limit: limit, Window: time.Minute, Store: store})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+}
+
+func hit(h http.Handler, ip string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("CF-Connecting-IP", ip)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// exhaust checks that h allows exactly limit requests from ip and then
+// rejects the next one, returning the rejection.
+func exhaust(t *testing.T, h http.Handler, ip string, limit int) *httptest.ResponseRecorder {
+	t.Helper()
+	for i := 1; i <= limit; i++ {
+		w := hit(h, ip)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status = %d, want 204", i, w.Code)
+		}
+		if got, want := w.Header().Get("X-RateLimit-Remaining"), strconv.Itoa(limit-i); got != want {
+			t.Fatalf("request %d: remaining = %s, want %s", i, got, want)
+		}
+	}
+	w := hit(h, ip)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request %d: status = %d, want 429", limit+1, w.Code)
+	}
+	return w
+}
+
+func TestRateLimitExhaustAndReset(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 10, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.now
+	h := rateLimitedHandler(store, 3)
+
+	w := exhaust(t, h, "203.0.113.7", 3)
+	reset := time.Date(2024, 5, 1, 12, 1, 0, 0, time.UTC)
+	if got := w.Header().Get("X-RateLimit-Reset"); got != strconv.FormatInt(reset.Unix(), 10) {
+		t.Errorf("reset = %s, want %d", got, reset.Unix())
+	}
+	if w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("Retry-After") == "" {
+		t.Errorf("429 headers = %v", w.Header())
+	}
+
+	// Other clients have their own counters.
+	if w := hit(h, "198.51.100.1"); w.Code != http.StatusNoContent {
+		t.Fatalf("other client: status = %d, want 204", w.Code)
+	}
+
+	// Still limited just before the window ends, allowed again after.
+	clock.advance(49 * time.Second)
+	if w := hit(h, "203.0.113.7"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("before reset: status = %d, want 429", w.Code)
+	}
+	clock.advance(time.Second)
+	exhaust(t, h, "203.0.113.7", 3)
+}
+
+// mapKV is a minimal KVNamespace that ignores expiration.
+type mapKV struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func (kv *mapKV) Get(ctx context.Context, key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	v, ok := kv.m[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (kv *mapKV) GetWithMetadata(ctx context.Context, key string) ([]byte, json.RawMessage, error) {
+	v, err := kv.Get(ctx, key)
+	return v, nil, err
+}
+
+func (kv *mapKV) Put(ctx context.Context, key string, value []byte, opts *KVPutOptions) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.m == nil {
+		kv.m = make(map[string][]byte)
+	}
+	kv.m[key] = value
+	return nil
+}
+
+func (kv *mapKV) Delete(ctx context.Context, key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	delete(kv.m, key)
+	return nil
+}
+
+func (kv *mapKV) List(ctx context.Context, opts *KVListOptions) (*KVListResult, error) {
+	return &KVListResult{ListComplete: true}, nil
+}
+
+func TestRateLimitKVStore(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewKVRateLimitStore(&mapKV{}).(*kvRateLimitStore)
+	store.now = clock.now
+	h := rateLimitedHandler(store, 2)
+
+	exhaust(t, h, "203.0.113.7", 2)
+	clock.advance(time.Minute)
+	exhaust(t, h, "203.0.113.7", 2)
+}
+
+func TestRateLimitDurableObjectStore(t *testing.T) {
+	var mu sync.Mutex
+	var names []string
+	ns := NewDurableObjectWithHandlers("RATE_LIMITER", func(id DurableObjectID) http.Handler {
+		mu.Lock()
+		names = append(names, id.Name())
+		mu.Unlock()
+		return RateLimitObject()
+	})
+	store := NewDurableObjectRateLimitStoreFrom(ns)
+	h := rateLimitedHandler(store, 5)
+
+	// Concurrent requests for one key are serialized by its object, so
+	// exactly Limit of them get through.
+	var wg sync.WaitGroup
+	codes := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- hit(h, "203.0.113.7").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	allowed := 0
+	for c := range codes {
+		if c == http.StatusNoContent {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Fatalf("%d of 20 concurrent requests allowed, want 5", allowed)
+	}
+	if len(names) != 1 || names[0] != "203.0.113.7" {
+		t.Fatalf("objects = %v, want one named after the client", names)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Increment(context.Context, string, time.Duration) (int64, time.Time, error) {
+	return 0, time.Time{}, errors.New("store down")
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	h := rateLimitedHandler(failingRateLimitStore{}, 1)
+	for i := 0; i < 3; i++ {
+		if w := hit(h, "203.0.113.7"); w.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204 while the store is down", w.Code)
+		}
+	}
+}
+
+func TestNewRateLimitInvalid(t *testing.T) {
+	if _, err := NewRateLimit(RateLimitOptions{Window: time.Second}); err == nil {
+		t.Error("zero limit accepted")
+	}
+	if _, err := NewRateLimit(RateLimitOptions{Limit: 1}); err == nil {
+		t.Error("zero window accepted")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if got := ClientIP(r); got != "192.0.2.1" {
+		t.Errorf("ClientIP = %q, want the RemoteAddr host", got)
+	}
+	r.Header.Set("CF-Connecting-IP", "203.0.113.7")
+	if got := ClientIP(r); got != "203.0.113.7" {
+		t.Errorf("ClientIP = %q, want CF-Connecting-IP", got)
+	}
+}
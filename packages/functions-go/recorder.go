@@ -0,0 +1,192 @@
+package functions
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// ResponseRecorder wraps an http.ResponseWriter and records what the
+// handler did with it, for middleware that logs or reacts to responses:
+//
+//	rec := functions.NewResponseRecorder(w)
+//	next.ServeHTTP(rec, r)
+//	metrics.Observe(r.URL.Path, rec.Status(), rec.BytesWritten())
+//
+// The response is committed by the first WriteHeader with a final status,
+// or by the first Write or Flush, which commit a 200 as net/http does.
+// Later calls to WriteHeader are dropped rather than passed on, so the
+// status recorded is always the one the client got. Informational 1xx
+// statuses are passed on without committing.
+//
+// Flush and Hijack reach the writer underneath, through any Unwrap methods
+// it has; Hijack returns an error wrapping http.ErrNotSupported if it cannot
+// be hijacked. Unwrap lets http.ResponseController do the same.
+type ResponseRecorder struct {
+	w       http.ResponseWriter
+	status  int
+	bytes   int64
+	written bool
+}
+
+// NewResponseRecorder returns a ResponseRecorder writing to w.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{w: w}
+}
+
+// Status is the status the response was committed with, or 200, the status
+// net/http sends for a handler that writes nothing, if it has not been
+// committed yet. A hijacked response has status 101.
+func (w *ResponseRecorder) Status() int {
+	if !w.written {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// BytesWritten is the number of body bytes written so far.
+func (w *ResponseRecorder) BytesWritten() int64 { return w.bytes }
+
+// Written reports whether the response has been committed, after which its
+// status can no longer be changed.
+func (w *ResponseRecorder) Written() bool { return w.written }
+
+func (w *ResponseRecorder) Header() http.Header { return w.w.Header() }
+
+func (w *ResponseRecorder) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	if status >= 200 {
+		w.status, w.written = status, true
+	}
+	w.w.WriteHeader(status)
+}
+
+func (w *ResponseRecorder) Write(p []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.w.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush sends the response so far, committing it, if the underlying writer
+// supports flushing, and does nothing otherwise.
+func (w *ResponseRecorder) Flush() {
+	rc := http.NewResponseController(w.w)
+	if !w.written {
+		if !canFlush(w.w) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	rc.Flush()
+}
+
+// Hijack takes over the connection from net/http, as for a WebSocket.
+func (w *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, brw, err := http.NewResponseController(w.w).Hijack()
+	if err == nil && !w.written {
+		w.status, w.written = http.StatusSwitchingProtocols, true
+	}
+	return c, brw, err
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *ResponseRecorder) Unwrap() http.ResponseWriter { return w.w }
+
+// canFlush reports whether the writer at the bottom of w's Unwrap chain can
+// be flushed. Wrappers are looked through even if they have a Flush method
+// of their own, since theirs only reaches the writer underneath.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch u := w.(type) {
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = u.Unwrap()
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// copyWriter passes a response through while keeping the header it was sent
+// with and a copy of the start of its body, for middleware that stores or
+// logs responses.
+type copyWriter struct {
+	*ResponseRecorder
+	sent    http.Header // snapshot of the header at WriteHeader
+	body    limitedBuffer
+	flushed bool
+}
+
+func newCopyWriter(w http.ResponseWriter, max int64) *copyWriter {
+	return &copyWriter{ResponseRecorder: NewResponseRecorder(w), body: limitedBuffer{max: max}}
+}
+
+func (w *copyWriter) WriteHeader(status int) {
+	if !w.Written() && status >= 200 {
+		w.sent = w.Header().Clone()
+	}
+	w.ResponseRecorder.WriteHeader(status)
+}
+
+func (w *copyWriter) Write(p []byte) (int, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseRecorder.Write(p)
+	w.body.write(p[:n])
+	return n, err
+}
+
+// Flush snapshots the header if it commits the response, and records that
+// the response was streamed.
+func (w *copyWriter) Flush() {
+	w.flushed = true
+	if !w.Written() && canFlush(w.Unwrap()) {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseRecorder.Flush()
+}
+
+// complete reports whether the copy holds the whole response, which it
+// does not if the body outgrew it, or was streamed or hijacked.
+func (w *copyWriter) complete() bool {
+	return !w.body.truncated && !w.flushed && w.Status() != http.StatusSwitchingProtocols
+}
+
+// header returns the header the response was sent with, or a copy of the
+// header as it is now if nothing was sent.
+func (w *copyWriter) header() http.Header {
+	if w.sent == nil {
+		return w.Header().Clone()
+	}
+	return w.sent
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+}
+
+func (b *limitedBuffer) write(p []byte) {
+	if room := b.max - int64(b.buf.Len()); int64(len(p)) > room {
+		p = p[:max(room, 0)]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+}
+
+func (b *limitedBuffer) bytes() []byte {
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	return b.buf.Bytes()
+}
@@ -0,0 +1,135 @@
+package functions
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseRecorderDefaultStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewResponseRecorder(w)
+	if rec.Written() || rec.Status() != http.StatusOK {
+		t.Fatalf("before writing: Written = %v, Status = %d", rec.Written(), rec.Status())
+	}
+	io.WriteString(rec, "hello")
+	io.WriteString(rec, ", world")
+	if !rec.Written() || rec.Status() != http.StatusOK || rec.BytesWritten() != 12 {
+		t.Errorf("Written = %v, Status = %d, BytesWritten = %d", rec.Written(), rec.Status(), rec.BytesWritten())
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "hello, world" {
+		t.Errorf("underlying = %d %q", w.Code, w.Body)
+	}
+}
+
+// headerCounter counts the WriteHeader calls that reach it.
+type headerCounter struct {
+	http.ResponseWriter
+	calls []int
+}
+
+func (w *headerCounter) WriteHeader(status int) {
+	w.calls = append(w.calls, status)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func TestResponseRecorderWriteHeaderOnce(t *testing.T) {
+	under := &headerCounter{ResponseWriter: httptest.NewRecorder()}
+	rec := NewResponseRecorder(under)
+	rec.WriteHeader(http.StatusEarlyHints)
+	if rec.Written() {
+		t.Error("1xx status committed the response")
+	}
+	rec.WriteHeader(http.StatusCreated)
+	rec.WriteHeader(http.StatusInternalServerError)
+	rec.Write([]byte("x"))
+	if rec.Status() != http.StatusCreated {
+		t.Errorf("Status = %d, want 201", rec.Status())
+	}
+	if len(under.calls) != 2 || under.calls[0] != http.StatusEarlyHints || under.calls[1] != http.StatusCreated {
+		t.Errorf("WriteHeader calls passed on = %v, want [103 201]", under.calls)
+	}
+}
+
+func TestResponseRecorderFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewResponseRecorder(w)
+	rec.Flush()
+	if !w.Flushed || !rec.Written() {
+		t.Errorf("Flushed = %v, Written = %v", w.Flushed, rec.Written())
+	}
+	w = httptest.NewRecorder()
+	if err := http.NewResponseController(NewResponseRecorder(NewResponseRecorder(w))).Flush(); err != nil || !w.Flushed {
+		t.Errorf("nested Flush: err = %v, Flushed = %v", err, w.Flushed)
+	}
+
+	rec = NewResponseRecorder(struct{ http.ResponseWriter }{httptest.NewRecorder()})
+	rec.Flush()
+	if rec.Written() {
+		t.Error("Flush committed a response that cannot be flushed")
+	}
+}
+
+type hijackable struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackable) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseRecorderHijack(t *testing.T) {
+	under := &hijackable{ResponseRecorder: httptest.NewRecorder()}
+	rec := NewResponseRecorder(under)
+	if _, _, err := http.NewResponseController(rec).Hijack(); err != nil || !under.hijacked {
+		t.Fatalf("Hijack: err = %v, hijacked = %v", err, under.hijacked)
+	}
+	if !rec.Written() || rec.Status() != http.StatusSwitchingProtocols {
+		t.Errorf("after Hijack: Written = %v, Status = %d", rec.Written(), rec.Status())
+	}
+
+	rec = NewResponseRecorder(httptest.NewRecorder())
+	if _, _, err := rec.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijack of a plain writer = %v, want ErrNotSupported", err)
+	}
+	if rec.Written() {
+		t.Error("failed Hijack committed the response")
+	}
+}
+
+// TestBufferingMiddlewareHijack checks that the middleware which holds a
+// response back lets go of it once the connection is hijacked.
+func TestBufferingMiddlewareHijack(t *testing.T) {
+	for _, mw := range []struct {
+		name string
+		mw   Middleware
+	}{
+		{"ETag", ETag()},
+		{"Timeout", Timeout(time.Minute)},
+		{"Compress", Compress(CompressOptions{MinSize: 1})},
+	} {
+		h := mw.mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			if _, _, err := http.NewResponseController(w).Hijack(); err != nil {
+				t.Errorf("%s: Hijack: %v", mw.name, err)
+			}
+		}))
+		under := &hijackable{ResponseRecorder: httptest.NewRecorder()}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(under, r)
+		if !under.hijacked {
+			t.Errorf("%s: connection was not hijacked", mw.name)
+		}
+		if under.Body.Len() > 0 || under.Header().Get("Content-Encoding") != "" || under.Header().Get("Content-Length") != "" {
+			t.Errorf("%s: wrote %q with %v after the hijack", mw.name, under.Body, under.Header())
+		}
+	}
+}
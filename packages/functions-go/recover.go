@@ -0,0 +1,84 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// RecoverOptions configures the Recover middleware.
+type RecoverOptions struct {
+	// OnPanic, if set, is called with the recovered value and stack trace,
+	// for example to report to an error tracker. If it writes a response,
+	// Recover does not write its own.
+	OnPanic func(w http.ResponseWriter, r *http.Request, recovered any, stack []byte)
+
+	// Logger receives the panic log entry. If nil, slog.Default is used.
+	Logger *slog.Logger
+}
+
+// Recover returns middleware that turns a panicking handler into a
+// 500 Internal Server Error instead of a crashed Worker.
+//
+// The response body is JSON if the request accepts application/json and
+// plain text otherwise. If the handler had already started the response
+// when it panicked, the status can no longer change; Recover logs that the
+// response was committed and leaves it as is.
+//
+// Panics are also sent to the Reporter installed by WithReporter, if any,
+// once the response has been written.
+// Panics with http.ErrAbortHandler are re-raised, as net/http expects.
+func Recover(opts RecoverOptions) Middleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := NewResponseRecorder(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				stack := debug.Stack()
+				logger.Error("handler panic",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", fmt.Sprint(recovered),
+					"stack", string(stack),
+				)
+				defer reportAsync(r.Context(), func(ctx context.Context, rep Reporter) {
+					rep.CapturePanic(ctx, recovered, stack)
+				})
+				if opts.OnPanic != nil {
+					opts.OnPanic(rw, r, recovered, stack)
+				}
+				if rw.Written() {
+					logger.Warn("response already committed before panic; status not rewritten",
+						"method", r.Method,
+						"path", r.URL.Path,
+					)
+					return
+				}
+				writePanicResponse(rw, r)
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func writePanicResponse(w http.ResponseWriter, r *http.Request) {
+	const status = http.StatusInternalServerError
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		WriteJSON(w, status, map[string]string{"error": http.StatusText(status)})
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,114 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicHandler(v any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(v) })
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestRecoverPlainText(t *testing.T) {
+	var logs bytes.Buffer
+	h := Recover(RecoverOptions{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})(panicHandler("boom"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/explode", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry: %v", err)
+	}
+	if entry["panic"] != "boom" || !strings.Contains(entry["stack"].(string), "goroutine") {
+		t.Fatalf("log entry missing panic details: %v", entry)
+	}
+}
+
+func TestRecoverJSON(t *testing.T) {
+	h := Recover(RecoverOptions{Logger: discardLogger()})(panicHandler("boom"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+		t.Fatalf("body = %s", w.Body.String())
+	}
+}
+
+func TestRecoverOnPanicHook(t *testing.T) {
+	var got any
+	var stack []byte
+	h := Recover(RecoverOptions{
+		Logger: discardLogger(),
+		OnPanic: func(w http.ResponseWriter, r *http.Request, recovered any, s []byte) {
+			got, stack = recovered, s
+		},
+	})(panicHandler(42))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got != 42 || len(stack) == 0 {
+		t.Fatalf("hook got %v with %d-byte stack", got, len(stack))
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
+
+func TestRecoverAfterPartialResponse(t *testing.T) {
+	var logs bytes.Buffer
+	h := Recover(RecoverOptions{Logger: slog.New(slog.NewTextHandler(&logs, nil))})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, "partial")
+			panic("late")
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want the handler's 202", w.Code)
+	}
+	if w.Body.String() != "partial" {
+		t.Fatalf("body = %q, want only the partial response", w.Body.String())
+	}
+	if !strings.Contains(logs.String(), "already committed") {
+		t.Fatalf("expected a committed-response log, got:\n%s", logs.String())
+	}
+}
+
+func TestRecoverReraisesAbortHandler(t *testing.T) {
+	h := Recover(RecoverOptions{Logger: discardLogger()})(panicHandler(http.ErrAbortHandler))
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Fatal("http.ErrAbortHandler was swallowed")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,77 @@
+package functions
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Reporter sends errors and panics to an error tracker such as Sentry; see
+// the sentry subpackage. Implementations must be safe for concurrent use
+// and should not panic.
+type Reporter interface {
+	// CaptureError reports err, annotated with tags such as the request
+	// path.
+	CaptureError(ctx context.Context, err error, tags map[string]string)
+
+	// CapturePanic reports a recovered panic and the stack of the
+	// goroutine that panicked.
+	CapturePanic(ctx context.Context, recovered any, stack []byte)
+}
+
+type reporterKey struct{}
+
+// WithReporter returns middleware that makes r the Reporter of every
+// request, as returned by Report. Once it is installed, Recover reports
+// panics and WriteError reports the errors it answers with a 5xx, so it
+// belongs before both in the chain. They report after writing the
+// response, in the background (through waitUntil in the Workers runtime),
+// and the events do not count against SubrequestLimit:
+//
+//	h = functions.Use(h,
+//		functions.WithReporter(reporter),
+//		functions.Recover(functions.RecoverOptions{}),
+//	)
+func WithReporter(r Reporter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), reporterKey{}, r)))
+		})
+	}
+}
+
+// Report returns the Reporter installed by WithReporter, or one that
+// discards everything if there is none, so code can report without
+// checking whether a tracker is configured.
+func Report(ctx context.Context) Reporter {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok && r != nil {
+		return r
+	}
+	return nopReporter{}
+}
+
+// reportAsync hands the Reporter of ctx to send once the response no longer
+// waits on it. The send outlives the request, so it gets a context without
+// the request's cancellation, and one without its SubrequestLimit budget:
+// reporting is not the handler's traffic and must not use up its quota.
+func reportAsync(ctx context.Context, send func(ctx context.Context, r Reporter)) {
+	r := Report(ctx)
+	if _, ok := r.(nopReporter); ok {
+		return
+	}
+	ctx = context.WithValue(context.WithoutCancel(ctx), subrequestKey{}, (*subrequestBudget)(nil))
+	pendingReports.Add(1)
+	afterResponse(func() {
+		defer pendingReports.Done()
+		send(ctx, r)
+	})
+}
+
+// pendingReports tracks the sends started by reportAsync, so tests can wait
+// for them.
+var pendingReports sync.WaitGroup
+
+type nopReporter struct{}
+
+func (nopReporter) CaptureError(context.Context, error, map[string]string) {}
+func (nopReporter) CapturePanic(context.Context, any, []byte)              {}
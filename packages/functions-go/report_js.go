@@ -0,0 +1,32 @@
+//go:build js && wasm
+
+package functions
+
+import "syscall/js"
+
+// afterResponse runs task without holding up the response, keeping the
+// invocation alive until it is done through the waitUntil of the runtime
+// context's ExecutionContext. Without one, task runs in a goroutine, which
+// the runtime may cut short.
+func afterResponse(task func()) {
+	var ec js.Value
+	if rc := js.Global().Get("context"); !isNullish(rc) {
+		ec = rc.Get("ctx")
+	}
+	if isNullish(ec) || ec.Get("waitUntil").Type() != js.TypeFunction {
+		go task()
+		return
+	}
+	var executor js.Func
+	executor = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		resolve := args[0]
+		go func() {
+			defer resolve.Invoke()
+			task()
+		}()
+		return nil
+	})
+	p := js.Global().Get("Promise").New(executor)
+	executor.Release()
+	ec.Call("waitUntil", p)
+}
@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+// afterResponse runs task in a goroutine, so it does not hold up the
+// response.
+func afterResponse(task func()) {
+	go task()
+}
@@ -0,0 +1,111 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordingReporter records what it is sent.
+type recordingReporter struct {
+	mu     sync.Mutex
+	errs   []error
+	tags   []map[string]string
+	panics []any
+}
+
+func (r *recordingReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+	r.tags = append(r.tags, tags)
+}
+
+func (r *recordingReporter) CapturePanic(ctx context.Context, recovered any, stack []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panics = append(r.panics, recovered)
+}
+
+func TestReportDefaultIsNop(t *testing.T) {
+	r := Report(context.Background())
+	r.CaptureError(context.Background(), errors.New("x"), nil)
+	r.CapturePanic(context.Background(), "x", nil)
+}
+
+func TestWithReporterCapturesPanics(t *testing.T) {
+	rep := &recordingReporter{}
+	h := Use(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), WithReporter(rep), Recover(RecoverOptions{Logger: discardLogger()}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	pendingReports.Wait()
+	if len(rep.panics) != 1 || rep.panics[0] != "boom" {
+		t.Fatalf("panics = %v", rep.panics)
+	}
+}
+
+func TestWithReporterCapturesServerErrors(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+	rep := &recordingReporter{}
+	var toReturn error
+	h := WithReporter(rep)(H(func(w http.ResponseWriter, r *http.Request) error {
+		return toReturn
+	}))
+	serve := func(err error) {
+		toReturn = err
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+		pendingReports.Wait()
+	}
+
+	serve(NotFound("no such order"))
+	serve(errors.New("db down"))
+	serve(&Error{Status: http.StatusBadGateway, Message: "upstream failed"})
+
+	if len(rep.errs) != 2 {
+		t.Fatalf("reported %v, want the 500 and the 502 only", rep.errs)
+	}
+	if rep.errs[0].Error() != "db down" || rep.tags[0]["path"] != "/orders" || rep.tags[0]["status"] != "500" {
+		t.Errorf("first report = %v %v", rep.errs[0], rep.tags[0])
+	}
+	if rep.tags[1]["status"] != "502" {
+		t.Errorf("second report tags = %v", rep.tags[1])
+	}
+}
+
+// budgetReporter records whether it could have made a subrequest.
+type budgetReporter struct {
+	recordingReporter
+	budgetErr error
+}
+
+func (r *budgetReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	r.budgetErr = takeSubrequest(ctx)
+	r.recordingReporter.CaptureError(ctx, err, tags)
+}
+
+func TestReportsAfterResponseOutsideSubrequestBudget(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+	rep := &budgetReporter{}
+	h := Use(H(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("db down")
+	}), WithReporter(rep), SubrequestLimit(0))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	pendingReports.Wait()
+
+	if w.Code != http.StatusInternalServerError || len(rep.errs) != 1 {
+		t.Fatalf("status = %d, reported %v", w.Code, rep.errs)
+	}
+	if rep.budgetErr != nil {
+		t.Fatalf("reporter was charged to the request's budget: %v", rep.budgetErr)
+	}
+}
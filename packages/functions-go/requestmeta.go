@@ -0,0 +1,106 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// ErrNoRequestMeta is returned by RequestMeta when the request carries no
+// Cloudflare properties, as in local development outside `wrangler dev`.
+var ErrNoRequestMeta = errors.New("functions: Cloudflare request properties not available")
+
+// CF holds the properties Cloudflare attaches to an incoming request, the
+// `request.cf` object of the Workers runtime. Fields Cloudflare did not
+// supply are left zero; which ones are present depends on the plan and on
+// the zone's settings.
+type CF struct {
+	// Location of the client, by IP geolocation.
+	Country     string `json:"country"` // ISO 3166-1 alpha-2, or "T1" for Tor
+	IsEUCountry bool   `json:"-"`
+	Continent   string `json:"continent"`
+	Region      string `json:"region"`
+	RegionCode  string `json:"regionCode"`
+	City        string `json:"city"`
+	PostalCode  string `json:"postalCode"`
+	MetroCode   string `json:"metroCode"`
+	Timezone    string `json:"timezone"`
+	Latitude    string `json:"latitude"`
+	Longitude   string `json:"longitude"`
+
+	// Network and connection of the client.
+	ASN            int64  `json:"asn"`
+	ASOrganization string `json:"asOrganization"`
+	Colo           string `json:"colo"` // IATA code of the data center that received the request
+	HTTPProtocol   string `json:"httpProtocol"`
+	TLSVersion     string `json:"tlsVersion"`
+	TLSCipher      string `json:"tlsCipher"`
+	ClientTCPRTT   int64  `json:"clientTcpRtt"` // milliseconds
+
+	// BotManagement is set only on zones with Bot Management enabled.
+	BotManagement BotManagement `json:"botManagement"`
+}
+
+// BotManagement holds the bot detection results for a request.
+type BotManagement struct {
+	// Score ranges from 1 (almost certainly a bot) to 99 (almost certainly
+	// human). It is 0 when no score was computed.
+	Score          int    `json:"score"`
+	VerifiedBot    bool   `json:"verifiedBot"`
+	StaticResource bool   `json:"staticResource"`
+	JA3Hash        string `json:"ja3Hash"`
+	JA4            string `json:"ja4"`
+}
+
+// UnmarshalJSON decodes the runtime's cf object, whose isEUCountry is the
+// string "1" rather than a boolean.
+func (c *CF) UnmarshalJSON(b []byte) error {
+	type plain CF
+	var aux struct {
+		*plain
+		IsEUCountry any `json:"isEUCountry"`
+	}
+	aux.plain = (*plain)(c)
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	switch v := aux.IsEUCountry.(type) {
+	case string:
+		c.IsEUCountry, _ = strconv.ParseBool(v)
+	case bool:
+		c.IsEUCountry = v
+	}
+	return nil
+}
+
+type requestMetaKey struct{}
+
+// WithRequestMeta returns a copy of ctx whose requests report cf from
+// RequestMeta, for tests and local development.
+func WithRequestMeta(ctx context.Context, cf *CF) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, cf)
+}
+
+// RequestMeta returns the Cloudflare properties of r, such as the client's
+// country and the bot score, for geo-routing and bot filtering:
+//
+//	cf, err := functions.RequestMeta(r)
+//	if err == nil && cf.BotManagement.Score > 0 && cf.BotManagement.Score < 30 {
+//		http.Error(w, "forbidden", http.StatusForbidden)
+//		return
+//	}
+//
+// Properties set with WithRequestMeta take precedence. If none are
+// available, RequestMeta returns a zero CF and ErrNoRequestMeta, so code that
+// ignores the error sees empty fields rather than a nil pointer.
+func RequestMeta(r *http.Request) (*CF, error) {
+	if cf, ok := r.Context().Value(requestMetaKey{}).(*CF); ok && cf != nil {
+		return cf, nil
+	}
+	if cf := runtimeRequestCF(r.Context()); cf != nil {
+		return cf, nil
+	}
+	return &CF{}, ErrNoRequestMeta
+}
@@ -0,0 +1,53 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+
+	"github.com/syumai/workers/cloudflare/fetch"
+)
+
+// runtimeRequestCF reads the cf object of the request github.com/syumai/workers
+// is serving with ctx. It returns nil for requests syumai/workers did not
+// build, whose context carries no runtime request, and when the runtime sent
+// no cf object.
+//
+// syumai/workers decodes cf itself and exposes only the fields it models, so
+// IsEUCountry, MetroCode, ClientTCPRTT and the bot fingerprints stay zero
+// here; set them with WithRequestMeta if a handler needs them.
+func runtimeRequestCF(ctx context.Context) (cf *CF) {
+	defer func() {
+		// NewIncomingProperties panics when ctx has no runtime request.
+		if recover() != nil {
+			cf = nil
+		}
+	}()
+	p, err := fetch.NewIncomingProperties(ctx)
+	if err != nil || p == nil {
+		return nil
+	}
+	cf = &CF{
+		Country:        p.Country,
+		Continent:      p.Continent,
+		Region:         p.Region,
+		RegionCode:     p.RegionCode,
+		City:           p.City,
+		PostalCode:     p.PostalCode,
+		Timezone:       p.Timezone,
+		Latitude:       p.Latitude,
+		Longitude:      p.Longitude,
+		ASN:            int64(p.Asn),
+		ASOrganization: p.AsOrganization,
+		Colo:           p.Colo,
+		HTTPProtocol:   p.HttpProtocol,
+		TLSVersion:     p.TLSVersion,
+		TLSCipher:      p.TLSCipher,
+	}
+	if bm := p.BotManagement; bm != nil {
+		cf.BotManagement.Score = bm.Score
+		cf.BotManagement.VerifiedBot = bm.VerifiedBot
+		cf.BotManagement.StaticResource = bm.StaticResource
+	}
+	return cf
+}
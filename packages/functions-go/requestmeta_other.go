@@ -0,0 +1,11 @@
+//go:build !js || !wasm
+
+package functions
+
+import "context"
+
+// runtimeRequestCF returns nil: outside Workers no request properties are
+// attached.
+func runtimeRequestCF(context.Context) *CF {
+	return nil
+}
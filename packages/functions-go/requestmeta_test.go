@@ -0,0 +1,85 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A cf object as sent by the Workers runtime, trimmed of fields CF does not
+// model.
+const sampleCF = `{
+	"asn": 13335,
+	"asOrganization": "Cloudflare",
+	"botManagement": {
+		"score": 12,
+		"verifiedBot": false,
+		"staticResource": false,
+		"ja3Hash": "25b4882c2bcb50cd6b469ff28c596742",
+		"ja4": "t13d1516h2_8daaf6152771_b186095e22b6"
+	},
+	"city": "Lisbon",
+	"clientTcpRtt": 23,
+	"colo": "LIS",
+	"continent": "EU",
+	"country": "PT",
+	"httpProtocol": "HTTP/2",
+	"isEUCountry": "1",
+	"latitude": "38.71670",
+	"longitude": "-9.13330",
+	"postalCode": "1000-001",
+	"region": "Lisbon",
+	"regionCode": "11",
+	"timezone": "Europe/Lisbon",
+	"tlsCipher": "AEAD-AES128-GCM-SHA256",
+	"tlsClientAuth": {"certPresented": "0"},
+	"tlsVersion": "TLSv1.3"
+}`
+
+func TestCFUnmarshal(t *testing.T) {
+	var cf CF
+	if err := json.Unmarshal([]byte(sampleCF), &cf); err != nil {
+		t.Fatal(err)
+	}
+	want := CF{
+		Country: "PT", IsEUCountry: true, Continent: "EU", Region: "Lisbon", RegionCode: "11",
+		City: "Lisbon", PostalCode: "1000-001", Timezone: "Europe/Lisbon",
+		Latitude: "38.71670", Longitude: "-9.13330",
+		ASN: 13335, ASOrganization: "Cloudflare", Colo: "LIS", HTTPProtocol: "HTTP/2",
+		TLSVersion: "TLSv1.3", TLSCipher: "AEAD-AES128-GCM-SHA256", ClientTCPRTT: 23,
+		BotManagement: BotManagement{
+			Score:   12,
+			JA3Hash: "25b4882c2bcb50cd6b469ff28c596742",
+			JA4:     "t13d1516h2_8daaf6152771_b186095e22b6",
+		},
+	}
+	if cf != want {
+		t.Fatalf("got  %+v\nwant %+v", cf, want)
+	}
+
+	var us CF
+	if err := json.Unmarshal([]byte(`{"country":"US"}`), &us); err != nil || us.IsEUCountry {
+		t.Fatalf("US = %+v, %v; want IsEUCountry false", us, err)
+	}
+}
+
+func TestRequestMetaMissing(t *testing.T) {
+	cf, err := RequestMeta(httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(err, ErrNoRequestMeta) {
+		t.Fatalf("err = %v, want ErrNoRequestMeta", err)
+	}
+	if cf == nil || *cf != (CF{}) {
+		t.Fatalf("cf = %+v, want a zero CF", cf)
+	}
+}
+
+func TestRequestMetaFromContext(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(WithRequestMeta(r.Context(), &CF{Country: "DE", Colo: "FRA"}))
+	cf, err := RequestMeta(r)
+	if err != nil || cf.Country != "DE" || cf.Colo != "FRA" {
+		t.Fatalf("got %+v, %v", cf, err)
+	}
+}
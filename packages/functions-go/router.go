@@ -0,0 +1,407 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Router is an http.Handler that dispatches requests by method and path.
+//
+// Patterns are slash-separated segments. A segment starting with ':' names a
+// single path parameter, and a final segment starting with '*' captures the
+// rest of the path:
+//
+//	r.Get("/users/:id", showUser)
+//	r.Get("/assets/*path", serveAsset)
+//
+// Static segments take precedence over parameters, and parameters over
+// catch-alls, so the most specific registered route always wins. Parameter
+// values are read with Param.
+//
+// A GET route also answers HEAD requests, unless HEAD is registered for the
+// same pattern: the GET handler runs as usual, and its status and headers
+// are sent without the body, with the Content-Length the body would have
+// had.
+//
+// The zero value is ready to use. Router implements http.Handler, so it can
+// be passed directly to workers.Serve.
+type Router struct {
+	// NotFound handles requests that match no route. If nil, http.NotFound
+	// is used.
+	NotFound http.Handler
+
+	// OpenAPIInfo supplies the title and version of the document generated
+	// by OpenAPI.
+	OpenAPIInfo OpenAPIInfo
+
+	root   *node
+	routes []*Route
+}
+
+// Route is a registered method and pattern.
+type Route struct {
+	Method  string
+	Pattern string
+
+	handler http.Handler
+	doc     *RouteDoc
+	hidden  bool // left out of the OpenAPI document
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Get registers h for GET requests matching pattern.
+func (rt *Router) Get(pattern string, h http.Handler) *Route {
+	return rt.Handle(http.MethodGet, pattern, h)
+}
+
+// Post registers h for POST requests matching pattern.
+func (rt *Router) Post(pattern string, h http.Handler) *Route {
+	return rt.Handle(http.MethodPost, pattern, h)
+}
+
+// Put registers h for PUT requests matching pattern.
+func (rt *Router) Put(pattern string, h http.Handler) *Route {
+	return rt.Handle(http.MethodPut, pattern, h)
+}
+
+// Delete registers h for DELETE requests matching pattern.
+func (rt *Router) Delete(pattern string, h http.Handler) *Route {
+	return rt.Handle(http.MethodDelete, pattern, h)
+}
+
+// HandleFunc registers fn for requests with the given method matching pattern.
+func (rt *Router) HandleFunc(method, pattern string, fn func(http.ResponseWriter, *http.Request)) *Route {
+	return rt.Handle(method, pattern, http.HandlerFunc(fn))
+}
+
+// Handle registers h for requests with the given method matching pattern.
+//
+// Handle panics if the pattern is malformed, if it conflicts with the
+// parameter names of an existing route, or if the method and pattern are
+// already registered.
+func (rt *Router) Handle(method, pattern string, h http.Handler) *Route {
+	if method == "" {
+		panic("functions: route method must not be empty")
+	}
+	if h == nil {
+		panic("functions: nil handler for route " + method + " " + pattern)
+	}
+	segs, err := compilePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	if rt.root == nil {
+		rt.root = &node{}
+	}
+
+	leaf := rt.root.insert(pattern, segs)
+	if _, dup := leaf.handlers[method]; dup {
+		panic(fmt.Sprintf("functions: route %s %s is already registered", method, pattern))
+	}
+	route := &Route{Method: method, Pattern: pattern, handler: h}
+	leaf.addRoute(route, segs)
+	rt.routes = append(rt.routes, route)
+	return route
+}
+
+// ServeHTTP dispatches the request to the handler whose pattern matches the
+// request path. Requests whose path matches but whose method does not get a
+// 405 response listing the allowed methods. The OnStart initializers are
+// run before the instance's first request is dispatched.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !serveStarted(w, r) {
+		return
+	}
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	var leaf *node
+	if rt.root != nil && path[0] == '/' {
+		leaf = rt.root.match(path[1:])
+	}
+	if leaf == nil {
+		rt.notFound(w, r)
+		return
+	}
+
+	route, ok := leaf.handlers[r.Method]
+	head := false
+	if !ok && r.Method == http.MethodHead {
+		route, ok = leaf.handlers[http.MethodGet]
+		head = ok
+	}
+	if !ok {
+		w.Header().Set("Allow", leaf.allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if leaf.nparams > 0 {
+		ctx := context.WithValue(r.Context(), paramsKey{}, leaf.params(path))
+		r = r.WithContext(ctx)
+	}
+	if head {
+		hw := &headWriter{ResponseWriter: w}
+		route.handler.ServeHTTP(hw, r)
+		hw.finish()
+		return
+	}
+	route.handler.ServeHTTP(w, r)
+}
+
+func (rt *Router) notFound(w http.ResponseWriter, r *http.Request) {
+	if rt.NotFound != nil {
+		rt.NotFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// Param returns the value of the named path parameter for a request
+// dispatched by a Router, or "" if the route has no such parameter.
+func Param(r *http.Request, name string) string {
+	ps, _ := r.Context().Value(paramsKey{}).([]param)
+	for _, p := range ps {
+		if p.name == name {
+			return p.value
+		}
+	}
+	return ""
+}
+
+type paramsKey struct{}
+
+type param struct {
+	name  string
+	value string
+}
+
+type segmentKind uint8
+
+const (
+	staticSegment segmentKind = iota
+	paramSegment
+	catchAllSegment
+)
+
+type segment struct {
+	kind segmentKind
+	text string // literal text, or the parameter name
+}
+
+func compilePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("functions: invalid route pattern %q: must start with '/'", pattern)
+	}
+	parts := strings.Split(pattern[1:], "/")
+	segs := make([]segment, len(parts))
+	seen := make(map[string]bool)
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"), strings.HasPrefix(part, "*"):
+			name := part[1:]
+			if name == "" {
+				return nil, fmt.Errorf("functions: invalid route pattern %q: unnamed parameter", pattern)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("functions: invalid route pattern %q: duplicate parameter %q", pattern, name)
+			}
+			seen[name] = true
+			kind := paramSegment
+			if part[0] == '*' {
+				if i != len(parts)-1 {
+					return nil, fmt.Errorf("functions: invalid route pattern %q: catch-all must be the last segment", pattern)
+				}
+				kind = catchAllSegment
+			}
+			segs[i] = segment{kind: kind, text: name}
+		default:
+			segs[i] = segment{kind: staticSegment, text: part}
+		}
+	}
+	return segs, nil
+}
+
+// node is one segment position in the routing tree. Nodes with handlers are
+// leaves that terminate a pattern.
+type node struct {
+	static   map[string]*node
+	param    *node
+	catchAll *node
+	name     string // parameter name for param and catch-all nodes
+
+	handlers map[string]*Route
+	allow    string
+	segs     []segment
+	nparams  int
+}
+
+func (n *node) insert(pattern string, segs []segment) *node {
+	for _, s := range segs {
+		switch s.kind {
+		case staticSegment:
+			child := n.static[s.text]
+			if child == nil {
+				if n.static == nil {
+					n.static = make(map[string]*node)
+				}
+				child = &node{}
+				n.static[s.text] = child
+			}
+			n = child
+		case paramSegment:
+			if n.param == nil {
+				n.param = &node{name: s.text}
+			} else if n.param.name != s.text {
+				panic(fmt.Sprintf("functions: route pattern %q: parameter %q conflicts with existing parameter %q", pattern, s.text, n.param.name))
+			}
+			n = n.param
+		case catchAllSegment:
+			if n.catchAll == nil {
+				n.catchAll = &node{name: s.text}
+			} else if n.catchAll.name != s.text {
+				panic(fmt.Sprintf("functions: route pattern %q: catch-all %q conflicts with existing catch-all %q", pattern, s.text, n.catchAll.name))
+			}
+			n = n.catchAll
+		}
+	}
+	return n
+}
+
+func (n *node) addRoute(route *Route, segs []segment) {
+	if n.handlers == nil {
+		n.handlers = make(map[string]*Route)
+		n.segs = segs
+		for _, s := range segs {
+			if s.kind != staticSegment {
+				n.nparams++
+			}
+		}
+	}
+	n.handlers[route.Method] = route
+
+	methods := make([]string, 0, len(n.handlers)+1)
+	for m := range n.handlers {
+		methods = append(methods, m)
+	}
+	if _, get := n.handlers[http.MethodGet]; get {
+		if _, head := n.handlers[http.MethodHead]; !head {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	n.allow = strings.Join(methods, ", ")
+}
+
+// match returns the leaf matching path, which is the request path without
+// its leading slash. It does not allocate.
+func (n *node) match(path string) *node {
+	seg, rest, more := strings.Cut(path, "/")
+	if child := n.static[seg]; child != nil {
+		if leaf := child.next(rest, more); leaf != nil {
+			return leaf
+		}
+	}
+	if n.param != nil && seg != "" {
+		if leaf := n.param.next(rest, more); leaf != nil {
+			return leaf
+		}
+	}
+	if n.catchAll != nil {
+		return n.catchAll
+	}
+	return nil
+}
+
+func (n *node) next(rest string, more bool) *node {
+	if more {
+		return n.match(rest)
+	}
+	if n.handlers != nil {
+		return n
+	}
+	return nil
+}
+
+// params extracts parameter values from a path already matched to n.
+func (n *node) params(path string) []param {
+	ps := make([]param, 0, n.nparams)
+	path = path[1:]
+	for _, s := range n.segs {
+		if s.kind == catchAllSegment {
+			return append(ps, param{name: s.text, value: path})
+		}
+		seg, rest, _ := strings.Cut(path, "/")
+		if s.kind == paramSegment {
+			ps = append(ps, param{name: s.text, value: seg})
+		}
+		path = rest
+	}
+	return ps
+}
+
+// headWriter answers a HEAD request with a GET handler. It discards the body
+// and holds the header back until the handler returns, so that it can add
+// the Content-Length of what was discarded.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	sent   bool
+	n      int64
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// Flush sends the header at once, without a Content-Length unless the
+// handler set one, as the discarded body is not yet complete.
+func (w *headWriter) Flush() {
+	w.sendHeader(false)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *headWriter) finish() { w.sendHeader(true) }
+
+func (w *headWriter) sendHeader(complete bool) {
+	if w.sent {
+		return
+	}
+	w.sent = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if complete && w.n > 0 && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" {
+		h.Set("Content-Length", strconv.FormatInt(w.n, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *headWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,298 @@
+package functions
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoRoute responds with the route label followed by the given parameters.
+func echoRoute(label string, params ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, label)
+		for _, p := range params {
+			fmt.Fprintf(w, " %s=%s", p, Param(r, p))
+		}
+	})
+}
+
+func TestRouterMatching(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/", echoRoute("root"))
+	rt.Get("/users", echoRoute("users"))
+	rt.Get("/users/me", echoRoute("me"))
+	rt.Get("/users/:id", echoRoute("user", "id"))
+	rt.Get("/users/:id/posts/:post", echoRoute("post", "id", "post"))
+	rt.Get("/assets/*path", echoRoute("assets", "path"))
+	rt.Get("/assets/css/*path", echoRoute("css", "path"))
+	rt.Get("/assets/logo.png", echoRoute("logo"))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", 200, "root"},
+		{"/users", 200, "users"},
+		{"/users/me", 200, "me"},
+		{"/users/42", 200, "user id=42"},
+		{"/users/42/posts/7", 200, "post id=42 post=7"},
+		{"/users/42/posts", 404, ""},
+		{"/users/", 404, ""},
+		{"/assets/logo.png", 200, "logo"},
+		{"/assets/js/app.js", 200, "assets path=js/app.js"},
+		{"/assets/css/site.css", 200, "css path=site.css"},
+		{"/assets/", 200, "assets path="},
+		{"/missing", 404, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == 200 && w.Body.String() != tt.body {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestRouterBacktracksToParam(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/me/settings", echoRoute("settings"))
+	rt.Get("/users/:id/profile", echoRoute("profile", "id"))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/me/profile", nil))
+	if got := w.Body.String(); got != "profile id=me" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/items/:id", echoRoute("get"))
+	rt.Put("/items/:id", echoRoute("put"))
+	rt.Delete("/items/:id", echoRoute("delete"))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items/1", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD, PUT" {
+		t.Fatalf("Allow = %q", allow)
+	}
+}
+
+func TestRouterHeadFromGet(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/items/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Item", Param(r, "id"))
+		WriteJSON(w, http.StatusAccepted, map[string]string{"id": Param(r, "id")})
+	}))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/items/7", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	if got := w.Header().Get("X-Item"); got != "7" {
+		t.Errorf("X-Item = %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != "11" {
+		t.Errorf("Content-Length = %q, want 11", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", w.Body.String())
+	}
+}
+
+func TestRouterHeadKeepsContentLength(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/f", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "partial")
+	}))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/f", nil))
+	if got := w.Header().Get("Content-Length"); got != "1000" {
+		t.Errorf("Content-Length = %q, want 1000", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", w.Body.String())
+	}
+}
+
+func TestRouterExplicitHead(t *testing.T) {
+	for _, headFirst := range []bool{false, true} {
+		rt := NewRouter()
+		get := func() { rt.Get("/r", echoRoute("get")) }
+		head := func() {
+			rt.Handle(http.MethodHead, "/r", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Handler", "head")
+			}))
+		}
+		if headFirst {
+			head()
+			get()
+		} else {
+			get()
+			head()
+		}
+
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/r", nil))
+		if got := w.Header().Get("X-Handler"); got != "head" {
+			t.Errorf("headFirst=%v: X-Handler = %q, want the HEAD handler", headFirst, got)
+		}
+
+		w = httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/r", nil))
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+			t.Errorf("headFirst=%v: Allow = %q", headFirst, allow)
+		}
+	}
+}
+
+func TestRouterHeadWithoutGet(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/r", echoRoute("post"))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/r", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "POST" {
+		t.Errorf("Allow = %q", allow)
+	}
+}
+
+func TestRouterMethods(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/r", echoRoute("get"))
+	rt.Post("/r", echoRoute("post"))
+	rt.Put("/r", echoRoute("put"))
+	rt.Delete("/r", echoRoute("delete"))
+	rt.HandleFunc(http.MethodPatch, "/r", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "patch")
+	})
+
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "PATCH"} {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(method, "/r", nil))
+		if got := w.Body.String(); got != strings.ToLower(method) {
+			t.Errorf("%s: body = %q", method, got)
+		}
+	}
+}
+
+func TestRouterCustomNotFound(t *testing.T) {
+	rt := &Router{NotFound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	})}
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRouterRegistrationPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(rt *Router)
+	}{
+		{"no leading slash", func(rt *Router) { rt.Get("users", echoRoute("")) }},
+		{"unnamed param", func(rt *Router) { rt.Get("/users/:", echoRoute("")) }},
+		{"catch-all not last", func(rt *Router) { rt.Get("/a/*rest/b", echoRoute("")) }},
+		{"duplicate param", func(rt *Router) { rt.Get("/a/:id/:id", echoRoute("")) }},
+		{"duplicate route", func(rt *Router) {
+			rt.Get("/a", echoRoute(""))
+			rt.Get("/a", echoRoute(""))
+		}},
+		{"conflicting param names", func(rt *Router) {
+			rt.Get("/a/:id", echoRoute(""))
+			rt.Put("/a/:key", echoRoute(""))
+		}},
+		{"nil handler", func(rt *Router) { rt.Get("/a", nil) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			tt.register(NewRouter())
+		})
+	}
+}
+
+func TestParamWithoutRouter(t *testing.T) {
+	if got := Param(httptest.NewRequest(http.MethodGet, "/", nil), "id"); got != "" {
+		t.Fatalf("Param = %q, want empty", got)
+	}
+}
+
+// benchRouter registers a few hundred routes resembling a typical REST API.
+func benchRouter() *Router {
+	rt := NewRouter()
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for i := 0; i < 100; i++ {
+		resource := fmt.Sprintf("/v1/resource%d", i)
+		rt.Get(resource, noop)
+		rt.Post(resource, noop)
+		rt.Get(resource+"/:id", noop)
+		rt.Put(resource+"/:id", noop)
+		rt.Get(resource+"/:id/children/:child", noop)
+	}
+	return rt
+}
+
+type discardWriter struct{ h http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.h }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkRoute(b *testing.B, method, path string) {
+	rt := benchRouter()
+	r := httptest.NewRequest(method, path, nil)
+	w := &discardWriter{h: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkRouterStatic(b *testing.B) {
+	benchmarkRoute(b, http.MethodGet, "/v1/resource99")
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	benchmarkRoute(b, http.MethodGet, "/v1/resource99/123")
+}
+
+func BenchmarkRouterTwoParams(b *testing.B) {
+	benchmarkRoute(b, http.MethodGet, "/v1/resource50/123/children/456")
+}
+
+func BenchmarkRouterNotFound(b *testing.B) {
+	benchmarkRoute(b, http.MethodGet, "/v2/unknown")
+}
@@ -0,0 +1,33 @@
+package functions
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduledEvent describes one invocation of a cron trigger.
+type ScheduledEvent struct {
+	// Cron is the schedule expression that fired, as written in the
+	// `[triggers] crons` list of wrangler.toml.
+	Cron string
+
+	// ScheduledTime is when the trigger was scheduled to run.
+	ScheduledTime time.Time
+}
+
+// ScheduledHandler handles a cron trigger. A returned error marks the
+// invocation as failed in the Workers dashboard.
+type ScheduledHandler func(ctx context.Context, event ScheduledEvent) error
+
+// ScheduledMiddleware wraps a ScheduledHandler, the cron counterpart of
+// Middleware.
+type ScheduledMiddleware func(ScheduledHandler) ScheduledHandler
+
+// UseScheduled wraps h with mws. As with Use, the first middleware is the
+// outermost.
+func UseScheduled(h ScheduledHandler, mws ...ScheduledMiddleware) ScheduledHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
@@ -0,0 +1,35 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUseScheduled(t *testing.T) {
+	var calls []string
+	trace := func(name string) ScheduledMiddleware {
+		return func(next ScheduledHandler) ScheduledHandler {
+			return func(ctx context.Context, event ScheduledEvent) error {
+				calls = append(calls, name)
+				return next(ctx, event)
+			}
+		}
+	}
+	boom := errors.New("boom")
+	h := UseScheduled(func(ctx context.Context, event ScheduledEvent) error {
+		calls = append(calls, "handler:"+event.Cron)
+		return boom
+	}, trace("outer"), trace("inner"))
+
+	err := h(context.Background(), ScheduledEvent{Cron: "*/5 * * * *", ScheduledTime: time.Now()})
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	want := []string{"outer", "inner", "handler:*/5 * * * *"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,330 @@
+// Package sentry reports errors and panics to Sentry. Its Reporter
+// implements functions.Reporter:
+//
+//	reporter, err := sentry.New(sentry.Options{
+//		DSN:         functions.Env.MustString("SENTRY_DSN"),
+//		Environment: "production",
+//	})
+//	if err != nil {
+//		panic(err)
+//	}
+//	h = functions.Use(h,
+//		functions.WithReporter(reporter),
+//		functions.Recover(functions.RecoverOptions{}),
+//	)
+//
+// Events are posted to the project's store endpoint with the outbound
+// fetch client, so no Sentry SDK is needed in the Worker.
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	functions "github.com/dot-do/functions/packages/functions-go"
+)
+
+// DefaultTimeout bounds the sending of one event, if Options.Timeout is
+// zero.
+const DefaultTimeout = 2 * time.Second
+
+const clientName = "functions-go/sentry"
+
+// Options configures a Reporter.
+type Options struct {
+	// DSN is the Sentry project's client key, as shown in its settings,
+	// such as "https://abc123@o1.ingest.sentry.io/42". It is required.
+	DSN string
+
+	// Environment and Release, if set, are sent with every event so Sentry
+	// can filter on them, for example "production" and a git SHA.
+	Environment string
+	Release     string
+
+	// Tags are added to every event. Tags given to CaptureError take
+	// precedence.
+	Tags map[string]string
+
+	// Client sends the events. If nil, a functions.HTTPClient with default
+	// options is used.
+	Client *functions.HTTPClient
+
+	// Timeout bounds the sending of one event. If zero, DefaultTimeout is
+	// used.
+	Timeout time.Duration
+
+	// Logger receives events that could not be sent. If nil, slog.Default
+	// is used.
+	Logger *slog.Logger
+}
+
+// Reporter sends events to Sentry. It is safe for concurrent use.
+type Reporter struct {
+	opts     Options
+	storeURL string
+	key      string
+	client   *functions.HTTPClient
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// New returns a Reporter for opts, or an error if opts.DSN is not a valid
+// Sentry DSN.
+func New(opts Options) (*Reporter, error) {
+	storeURL, key, err := parseDSN(opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reporter{opts: opts, storeURL: storeURL, key: key, client: opts.Client, logger: opts.Logger, now: time.Now}
+	if r.client == nil {
+		r.client = functions.NewHTTPClient(functions.RetryOptions{})
+	}
+	if r.logger == nil {
+		r.logger = slog.Default()
+	}
+	if r.opts.Timeout <= 0 {
+		r.opts.Timeout = DefaultTimeout
+	}
+	return r, nil
+}
+
+// parseDSN returns the store endpoint and public key of a DSN of the form
+// scheme://key@host[/path]/project.
+func parseDSN(dsn string) (storeURL, key string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("sentry: invalid DSN: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return "", "", errors.New("sentry: invalid DSN: want an http or https URL")
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", "", errors.New("sentry: invalid DSN: missing public key")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	prefix, project := path[:max(i, 0)], path[i+1:]
+	if project == "" {
+		return "", "", errors.New("sentry: invalid DSN: missing project ID")
+	}
+	return u.Scheme + "://" + u.Host + prefix + "/api/" + project + "/store/", u.User.Username(), nil
+}
+
+// CaptureError implements functions.Reporter, sending err as an event of
+// level "error". It returns once the event is sent or has failed.
+func (r *Reporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	r.send(ctx, r.event(ctx, "error", exception{Type: fmt.Sprintf("%T", err), Value: err.Error()}, tags))
+}
+
+// CapturePanic implements functions.Reporter, sending the panic as an
+// event of level "fatal" whose stack trace is parsed from stack.
+func (r *Reporter) CapturePanic(ctx context.Context, recovered any, stack []byte) {
+	exc := exception{Type: "panic", Value: fmt.Sprint(recovered)}
+	if err, ok := recovered.(error); ok {
+		exc.Type = fmt.Sprintf("%T", err)
+	}
+	if frames := parseStack(stack); len(frames) > 0 {
+		exc.Stacktrace = &stacktrace{Frames: frames}
+	}
+	r.send(ctx, r.event(ctx, "fatal", exc, nil))
+}
+
+// event is the JSON body of a store request; see
+// https://develop.sentry.dev/sdk/event-payloads/.
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   struct {
+		Values []exception `json:"values"`
+	} `json:"exception"`
+	SDK struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"sdk"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+func (r *Reporter) event(ctx context.Context, level string, exc exception, tags map[string]string) *event {
+	e := &event{
+		EventID:     newEventID(),
+		Timestamp:   r.now().UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       level,
+		Logger:      "functions",
+		Environment: r.opts.Environment,
+		Release:     r.opts.Release,
+		Tags:        make(map[string]string, len(r.opts.Tags)+len(tags)+1),
+	}
+	for k, v := range r.opts.Tags {
+		e.Tags[k] = v
+	}
+	if id := functions.RequestID(ctx); id != "" {
+		e.Tags["request_id"] = id
+	}
+	for k, v := range tags {
+		e.Tags[k] = v
+	}
+	e.Exception.Values = []exception{exc}
+	e.SDK.Name, e.SDK.Version = clientName, "1.0.0"
+	return e
+}
+
+func (r *Reporter) send(ctx context.Context, e *event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		r.logger.Warn("sentry: encoding event failed", "error", err)
+		return
+	}
+	// The request may be over by the time an error is reported, so its
+	// cancellation does not stop the event.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.storeURL, bytes.NewReader(body))
+	if err != nil {
+		r.logger.Warn("sentry: sending event failed", "event_id", e.EventID, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s/%s, sentry_timestamp=%d, sentry_key=%s",
+		clientName, e.SDK.Version, r.now().Unix(), r.key))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Warn("sentry: sending event failed", "event_id", e.EventID, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		r.logger.Warn("sentry: event rejected", "event_id", e.EventID, "status", resp.StatusCode,
+			"reason", resp.Header.Get("X-Sentry-Error"))
+	}
+}
+
+func newEventID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// parseStack turns the output of runtime/debug.Stack into Sentry frames,
+// outermost call first as Sentry expects. If the stack was taken while
+// recovering from a panic, the frames of the deferred recovery are dropped
+// so that the innermost frame is where the panic happened.
+func parseStack(stack []byte) []frame {
+	var frames []frame
+	panicked := -1
+	sc := bufio.NewScanner(bytes.NewReader(stack))
+	var fn string
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "\t") {
+			// A function line such as "main.handler(0x1, 0x2)", or the
+			// "goroutine 1 [running]:" header.
+			fn = ""
+			if i := strings.LastIndex(line, "("); i > 0 && strings.HasSuffix(line, ")") {
+				fn = line[:i]
+			}
+			continue
+		}
+		if fn == "" {
+			continue
+		}
+		loc := strings.TrimSpace(line)
+		if i := strings.LastIndex(loc, " +0x"); i >= 0 {
+			loc = loc[:i]
+		}
+		file, lineStr, ok := cutLast(loc, ":")
+		if !ok {
+			continue
+		}
+		lineno, _ := strconv.Atoi(lineStr)
+		module, function := splitFunction(fn)
+		if fn == "panic" || module == "runtime" && function == "gopanic" {
+			panicked = len(frames)
+			fn = ""
+			continue
+		}
+		if module == "runtime/debug" {
+			fn = ""
+			continue
+		}
+		frames = append(frames, frame{
+			Function: function,
+			Module:   module,
+			Filename: file[strings.LastIndex(file, "/")+1:],
+			AbsPath:  file,
+			Lineno:   lineno,
+			InApp:    !isStdlib(module),
+		})
+		fn = ""
+	}
+	if panicked >= 0 {
+		frames = frames[panicked:]
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// splitFunction splits a qualified function name such as
+// "github.com/a/b.(*T).M" into its package path and the rest.
+func splitFunction(fn string) (module, function string) {
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot], fn[slash+2+dot:]
+	}
+	return "", fn
+}
+
+// isStdlib reports whether module is a standard library package, whose
+// import path has no dot in its first element.
+func isStdlib(module string) bool {
+	first, _, _ := strings.Cut(module, "/")
+	return module != "main" && !strings.Contains(first, ".")
+}
+
+func cutLast(s, sep string) (before, after string, ok bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
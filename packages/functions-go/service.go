@@ -0,0 +1,171 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Service is a Cloudflare service binding: another Worker that this one
+// calls directly, without going over the public internet.
+//
+// The binding name is the `binding` of a `[[services]]` entry in
+// wrangler.toml:
+//
+//	[[services]]
+//	binding = "USERS"
+//	service = "users-function"
+//
+// which is opened with NewService("USERS"). Requests are delivered to the
+// bound Worker's fetch handler; the host in the request URL is passed along
+// but does not pick the destination, so a relative URL such as "/users/42"
+// is enough.
+type Service struct {
+	binding string
+	s       serviceBackend
+}
+
+// serviceBackend is the platform-specific half of Service. req always has
+// an absolute URL.
+type serviceBackend interface {
+	fetch(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// NewService opens the service bound to the Worker under binding. It
+// returns ErrBindingNotFound if no such binding is configured.
+func NewService(binding string) (*Service, error) {
+	s, err := openService(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{binding: binding, s: s}, nil
+}
+
+// NewServiceWithHandler returns a Service that delivers requests to h in
+// the same process instead of a bound Worker, for tests and local
+// development. name takes the place of the binding name in errors and
+// in the host of relative request URLs.
+func NewServiceWithHandler(name string, h http.Handler) *Service {
+	return &Service{binding: name, s: handlerService{h}}
+}
+
+// Fetch sends req to the bound Worker and returns its response, as
+// http.Client.Do would: headers and body are forwarded, a non-2xx status
+// is not an error, and the caller must close the response body. The
+// request is sent with ctx rather than req's own context.
+func (s *Service) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if err := takeSubrequest(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := s.s.fetch(ctx, outboundRequest(ctx, req, s.binding))
+	if err != nil {
+		return nil, s.wrap(req.Method, err)
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// Get sends a GET request for target, which may be relative.
+func (s *Service) Get(ctx context.Context, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.Fetch(ctx, req)
+}
+
+// Post sends a POST request for target with the given body and
+// Content-Type.
+func (s *Service) Post(ctx context.Context, target, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return s.Fetch(ctx, req)
+}
+
+func (s *Service) wrap(op string, err error) error {
+	return fmt.Errorf("functions: Service %s %s: %w", s.binding, op, err)
+}
+
+// outboundRequest clones req for sending with ctx, making a relative URL
+// absolute with the given host.
+func outboundRequest(ctx context.Context, req *http.Request, host string) *http.Request {
+	out := req.Clone(ctx)
+	if !out.URL.IsAbs() {
+		u := *out.URL
+		u.Scheme, u.Host = "https", host
+		out.URL = &u
+	}
+	if out.Host == "" {
+		out.Host = out.URL.Host
+	}
+	return out
+}
+
+// handlerService serves requests with an in-process http.Handler.
+type handlerService struct {
+	h http.Handler
+}
+
+func (s handlerService) fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	// Present the request as a server would have received it.
+	in := req.Clone(ctx)
+	in.RequestURI = req.URL.RequestURI()
+	if in.Body == nil {
+		in.Body = http.NoBody
+	}
+
+	rec := &serviceRecorder{header: make(http.Header)}
+	s.h.ServeHTTP(rec, in)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rec.WriteHeader(http.StatusOK)
+
+	resp := &http.Response{
+		Status:        strconv.Itoa(rec.status) + " " + http.StatusText(rec.status),
+		StatusCode:    rec.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.sent,
+		Body:          io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		ContentLength: int64(rec.body.Len()),
+	}
+	if req.Method == http.MethodHead {
+		resp.Body, resp.ContentLength = http.NoBody, -1
+	}
+	return resp, nil
+}
+
+// serviceRecorder captures the response of an in-process service.
+type serviceRecorder struct {
+	header http.Header
+	sent   http.Header // snapshot of header at WriteHeader
+	status int
+	body   bytes.Buffer
+}
+
+func (r *serviceRecorder) Header() http.Header { return r.header }
+
+func (r *serviceRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.sent = r.header.Clone()
+	}
+}
+
+func (r *serviceRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		if r.header.Get("Content-Type") == "" {
+			r.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.body.Write(p)
+}
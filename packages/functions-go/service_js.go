@@ -0,0 +1,87 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"syscall/js"
+)
+
+type jsService struct {
+	svc js.Value
+}
+
+func openService(binding string) (serviceBackend, error) {
+	svc, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsService{svc: svc}, nil
+}
+
+func (s *jsService) fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	init := js.Global().Get("Object").New()
+	init.Set("method", req.Method)
+	headers := js.Global().Get("Headers").New()
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			headers.Call("append", k, v)
+		}
+	}
+	init.Set("headers", headers)
+
+	// Request bodies are buffered: a streamed body would leave the pipe
+	// blocked if the callee answers without reading it.
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			u8 := js.Global().Get("Uint8Array").New(len(b))
+			js.CopyBytesToJS(u8, b)
+			init.Set("body", u8)
+		}
+	}
+
+	jsReq := js.Global().Get("Request").New(req.URL.String(), init)
+	v, err := awaitPromise(ctx, s.svc.Call("fetch", jsReq))
+	if err != nil {
+		return nil, err
+	}
+	return responseFromJS(ctx, v), nil
+}
+
+// responseFromJS converts a JavaScript Response, streaming its body.
+func responseFromJS(ctx context.Context, v js.Value) *http.Response {
+	status := v.Get("status").Int()
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		ContentLength: -1,
+	}
+	each := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		resp.Header.Add(args[1].String(), args[0].String())
+		return nil
+	})
+	v.Get("headers").Call("forEach", each)
+	each.Release()
+
+	if n, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = n
+	}
+	if body := v.Get("body"); isNullish(body) {
+		resp.Body = http.NoBody
+	} else {
+		resp.Body = newJSStreamReader(ctx, body)
+	}
+	return resp
+}
@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openService(binding string) (serviceBackend, error) {
+	return nil, fmt.Errorf("%w: %q (service bindings are only available in the Workers runtime; use NewServiceWithHandler to call a handler in-process)", ErrBindingNotFound, binding)
+}
@@ -0,0 +1,107 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordingService is a mock Worker that records the request it receives.
+type recordingService struct {
+	req  *http.Request
+	body string
+}
+
+func (s *recordingService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	s.req, s.body = r, string(b)
+	w.Header().Set("X-Served-By", "users")
+	WriteJSON(w, http.StatusCreated, map[string]string{"id": "42"})
+}
+
+func TestServiceFetchForwardsRequest(t *testing.T) {
+	mock := &recordingService{}
+	svc := NewServiceWithHandler("USERS", mock)
+
+	req, _ := http.NewRequest(http.MethodPut, "https://users.internal/users/42?force=1", strings.NewReader(`{"name":"ada"}`))
+	req.Header.Set("Authorization", "Bearer t")
+	req.Header.Add("X-Trace", "a")
+	req.Header.Add("X-Trace", "b")
+	resp, err := svc.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	in := mock.req
+	if in.Method != http.MethodPut || in.URL.Path != "/users/42" || in.URL.Query().Get("force") != "1" {
+		t.Errorf("inbound %s %s, want PUT /users/42?force=1", in.Method, in.URL)
+	}
+	if in.Host != "users.internal" || in.RequestURI != "/users/42?force=1" {
+		t.Errorf("Host = %q, RequestURI = %q", in.Host, in.RequestURI)
+	}
+	if in.Header.Get("Authorization") != "Bearer t" || len(in.Header.Values("X-Trace")) != 2 {
+		t.Errorf("inbound headers = %v", in.Header)
+	}
+	if mock.body != `{"name":"ada"}` {
+		t.Errorf("inbound body = %q", mock.body)
+	}
+
+	if resp.StatusCode != http.StatusCreated || resp.Status != "201 Created" {
+		t.Errorf("status = %d %q", resp.StatusCode, resp.Status)
+	}
+	if resp.Header.Get("X-Served-By") != "users" || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("response headers = %v", resp.Header)
+	}
+	if resp.Request != req {
+		t.Error("resp.Request is not the caller's request")
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if strings.TrimSpace(string(b)) != `{"id":"42"}` || resp.ContentLength != int64(len(b)) {
+		t.Errorf("body = %q, ContentLength = %d", b, resp.ContentLength)
+	}
+}
+
+func TestServiceGetPost(t *testing.T) {
+	mock := &recordingService{}
+	svc := NewServiceWithHandler("USERS", mock)
+	ctx := context.Background()
+
+	resp, err := svc.Get(ctx, "/users/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if mock.req.Method != http.MethodGet || mock.req.URL.Path != "/users/42" || mock.req.Host != "USERS" {
+		t.Errorf("Get: inbound %s %s host %q", mock.req.Method, mock.req.URL, mock.req.Host)
+	}
+
+	resp, err = svc.Post(ctx, "/users", "text/plain", strings.NewReader("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if mock.req.Method != http.MethodPost || mock.req.Header.Get("Content-Type") != "text/plain" || mock.body != "hi" {
+		t.Errorf("Post: inbound %s %v %q", mock.req.Method, mock.req.Header, mock.body)
+	}
+}
+
+func TestServiceCanceledContext(t *testing.T) {
+	svc := NewServiceWithHandler("USERS", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := svc.Get(ctx, "/"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestNewServiceNative(t *testing.T) {
+	if _, err := NewService("USERS"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
@@ -0,0 +1,99 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrFlushNotSupported is returned by NewSSE when the ResponseWriter cannot
+// flush, which would leave events buffered until the handler returns.
+var ErrFlushNotSupported = errors.New("functions: response writer does not support flushing")
+
+// SSEEvent is one Server-Sent Event. Empty fields are omitted.
+type SSEEvent struct {
+	// ID sets the client's last event ID, sent back as Last-Event-ID when
+	// the browser reconnects.
+	ID string
+	// Event names the event type; clients listen for it with
+	// addEventListener. Without it the client fires "message".
+	Event string
+	// Data is the payload. Multi-line data is split into one data: line
+	// per line and reassembled by the client.
+	Data string
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// SSEWriter streams Server-Sent Events to a client. It is not safe for
+// concurrent use.
+type SSEWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// NewSSE prepares w for an event stream and sends the response headers.
+//
+// It returns ErrFlushNotSupported, without writing anything, if w cannot be
+// flushed. Middleware wrappers are looked through with their Unwrap method,
+// so a stream behind Logger or Recover fails here rather than buffering.
+func NewSSE(w http.ResponseWriter) (*SSEWriter, error) {
+	if !canFlush(w) {
+		return nil, ErrFlushNotSupported
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return nil, err
+	}
+	return &SSEWriter{w: w, rc: rc}, nil
+}
+
+// Send writes ev and flushes it to the client.
+//
+// ID and Event must not contain line breaks, since they would end the field
+// early and corrupt the stream.
+func (s *SSEWriter) Send(ev SSEEvent) error {
+	if strings.ContainsAny(ev.ID, "\r\n") || strings.ContainsAny(ev.Event, "\r\n") {
+		return errors.New("functions: SSE id and event must not contain line breaks")
+	}
+
+	var b strings.Builder
+	if ev.ID != "" {
+		b.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	if ev.Data != "" || (ev.ID == "" && ev.Event == "" && ev.Retry <= 0) {
+		data := strings.ReplaceAll(ev.Data, "\r\n", "\n")
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Ping writes a comment line, which clients ignore, to keep idle
+// connections from being closed by intermediaries.
+func (s *SSEWriter) Ping() error {
+	return s.write(": ping\n\n")
+}
+
+func (s *SSEWriter) write(msg string) error {
+	if _, err := s.w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
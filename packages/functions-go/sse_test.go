@@ -0,0 +1,147 @@
+package functions
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSESend(t *testing.T) {
+	tests := []struct {
+		name string
+		ev   SSEEvent
+		want string
+	}{
+		{"data only", SSEEvent{Data: "hello"}, "data: hello\n\n"},
+		{"all fields", SSEEvent{ID: "7", Event: "token", Data: "hi", Retry: 3 * time.Second}, "id: 7\nevent: token\nretry: 3000\ndata: hi\n\n"},
+		{"multi-line", SSEEvent{Data: "a\nb\r\nc"}, "data: a\ndata: b\ndata: c\n\n"},
+		{"trailing newline", SSEEvent{Data: "a\n"}, "data: a\ndata: \n\n"},
+		{"empty", SSEEvent{}, "data: \n\n"},
+		{"retry only", SSEEvent{Retry: 500 * time.Millisecond}, "retry: 500\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sse, err := NewSSE(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sse.Send(tt.ev); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSEHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	if _, err := NewSSE(w); err != nil {
+		t.Fatal(err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("Cache-Control = %q", cc)
+	}
+	if !w.Flushed {
+		t.Fatal("headers were not flushed")
+	}
+}
+
+func TestSSERejectsLineBreaksInFields(t *testing.T) {
+	sse, _ := NewSSE(httptest.NewRecorder())
+	if err := sse.Send(SSEEvent{Event: "a\nb"}); err == nil {
+		t.Fatal("expected an error for a multi-line event name")
+	}
+}
+
+func TestSSEPing(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse, _ := NewSSE(w)
+	if err := sse.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Body.String(); got != ": ping\n\n" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+type noFlushWriter struct{ http.ResponseWriter }
+
+func TestSSERequiresFlusher(t *testing.T) {
+	w := httptest.NewRecorder()
+	if _, err := NewSSE(noFlushWriter{w}); !errors.Is(err, ErrFlushNotSupported) {
+		t.Fatalf("err = %v, want ErrFlushNotSupported", err)
+	}
+	if w.Header().Get("Content-Type") != "" {
+		t.Fatal("headers were modified before the error")
+	}
+}
+
+func TestSSEBehindMiddleware(t *testing.T) {
+	logger := Logger(LoggerOptions{Handler: slog.NewTextHandler(io.Discard, nil)})
+	for _, tt := range []struct {
+		name string
+		w    http.ResponseWriter
+		want error
+	}{
+		{"flusher", httptest.NewRecorder(), nil},
+		{"no flusher", noFlushWriter{httptest.NewRecorder()}, ErrFlushNotSupported},
+	} {
+		var err error
+		h := Use(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err = NewSSE(w)
+		}), Recover(RecoverOptions{}), logger)
+		h.ServeHTTP(tt.w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestSSEIncremental checks that each event reaches the client before the
+// handler returns.
+func TestSSEIncremental(t *testing.T) {
+	next := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse, err := NewSSE(w)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, tok := range []string{"one", "two"} {
+			sse.Send(SSEEvent{Data: tok})
+			<-next
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	br := bufio.NewReader(resp.Body)
+	for _, want := range []string{"data: one\n", "data: two\n"} {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != want {
+			t.Fatalf("line = %q, want %q", line, want)
+		}
+		br.ReadString('\n') // blank separator
+		next <- struct{}{}
+	}
+}
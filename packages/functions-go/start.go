@@ -0,0 +1,129 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// startup holds the initializers registered with OnStart and the outcome of
+// running them.
+type startup struct {
+	mu      sync.Mutex // guards fns and started
+	fns     []func(context.Context) error
+	started bool
+
+	run  sync.Mutex // held while the initializers run
+	done atomic.Bool
+	err  error // set before done
+}
+
+var starts = &startup{}
+
+// OnStart registers fn to run once per Go instance, before the instance
+// serves its first request. Initializers run in the order they were
+// registered, one at a time, and stop at the first that fails.
+//
+// Under workers.Serve an instance lasts one event: the worker.mjs that
+// syumai/workers generates creates a new WebAssembly instance and runs main
+// again for every fetch, scheduled and queue event, so the initializers run
+// for every request and nothing they build outlives it. Keep them cheap,
+// such as opening binding handles, and keep anything expensive to compute
+// in KV, R2 or the Cache API rather than in Go memory. Under ServeLocal the
+// process serves every request and the initializers run once:
+//
+//	func main() {
+//		functions.OnStart(func(ctx context.Context) error {
+//			var err error
+//			db, err = functions.NewD1("DB")
+//			return err
+//		})
+//		workers.Serve(rt)
+//	}
+//
+// Requests that arrive while the initializers run are held until every one
+// has returned. If an initializer fails, the error is logged with
+// slog.Default and the instance answers 503 to the request that started it
+// and every later one; under workers.Serve that is the one event, and under
+// ServeLocal it lasts until the process restarts. The initializers are run
+// by Router, and by Started for handlers that are not routers, with the
+// first request's context values but without its cancellation, so a client
+// that goes away does not fail the start.
+//
+// OnStart must be called before the first request, typically from main or an
+// init function; it panics once the initializers have started.
+func OnStart(fn func(ctx context.Context) error) {
+	starts.add(fn)
+}
+
+// Started wraps h so that the initializers registered with OnStart have run
+// before it serves a request. Router does this itself; Started is for a
+// handler that is passed to workers.Serve or ServeLocal without one.
+func Started(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !serveStarted(w, r) {
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveStarted runs the OnStart initializers if they have not been run, and
+// reports whether r can be served. If not, it has written a 503 to w.
+func serveStarted(w http.ResponseWriter, r *http.Request) bool {
+	if err := starts.start(r.Context()); err != nil {
+		WriteError(w, r, &Error{Status: http.StatusServiceUnavailable, Code: "unavailable", Message: "the function failed to start"})
+		return false
+	}
+	return true
+}
+
+func (s *startup) add(fn func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		panic("functions: OnStart called after the first request")
+	}
+	s.fns = append(s.fns, fn)
+}
+
+// start runs the initializers the first time it is called and returns their
+// error, the same one every time.
+func (s *startup) start(ctx context.Context) error {
+	if s.done.Load() {
+		return s.err
+	}
+	s.run.Lock()
+	defer s.run.Unlock()
+	if s.done.Load() {
+		return s.err
+	}
+
+	s.mu.Lock()
+	fns := s.fns
+	s.started = true
+	s.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for i, fn := range fns {
+		if err := runInitializer(ctx, fn); err != nil {
+			s.err = fmt.Errorf("functions: OnStart initializer %d: %w", i+1, err)
+			slog.Default().Error("startup failed", "error", s.err.Error())
+			break
+		}
+	}
+	s.done.Store(true)
+	return s.err
+}
+
+func runInitializer(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return fn(ctx)
+}
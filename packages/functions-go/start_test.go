@@ -0,0 +1,106 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// freshStart gives the test its own set of OnStart initializers.
+func freshStart(t *testing.T) {
+	old := starts
+	starts = &startup{}
+	t.Cleanup(func() { starts = old })
+}
+
+func TestOnStartRunsOnce(t *testing.T) {
+	freshStart(t)
+	var order []int
+	var runs atomic.Int32
+	OnStart(func(context.Context) error { order = append(order, 1); runs.Add(1); return nil })
+	OnStart(func(context.Context) error { order = append(order, 2); return nil })
+
+	rt := NewRouter()
+	rt.HandleFunc(http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) {
+		if runs.Load() != 1 {
+			t.Error("handler ran before the initializers")
+		}
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if runs.Load() != 1 {
+		t.Errorf("initializer ran %d times, want 1", runs.Load())
+	}
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("order = %v, want [1 2]", order)
+	}
+}
+
+func TestOnStartFailure(t *testing.T) {
+	freshStart(t)
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+
+	var runs, later atomic.Int32
+	OnStart(func(context.Context) error { runs.Add(1); return errors.New("no database") })
+	OnStart(func(context.Context) error { later.Add(1); return nil })
+
+	served := false
+	h := Started(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { served = true }))
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("request %d: status = %d, want 503", i, w.Code)
+		}
+	}
+	if served {
+		t.Error("handler ran after a failed start")
+	}
+	if runs.Load() != 1 || later.Load() != 0 {
+		t.Errorf("runs = %d, later = %d; want 1, 0", runs.Load(), later.Load())
+	}
+}
+
+func TestOnStartPanicAndCancel(t *testing.T) {
+	freshStart(t)
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(discardLogger())
+
+	OnStart(func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		panic("boom")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := starts.start(ctx)
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("start = %v, want the panic", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("OnStart after start did not panic")
+		}
+	}()
+	OnStart(func(context.Context) error { return nil })
+}
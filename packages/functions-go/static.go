@@ -0,0 +1,161 @@
+package functions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStaticCacheControl is the Cache-Control header StaticFS sets when
+// StaticOptions.CacheControl is empty. It lets browsers cache files but
+// makes them revalidate with If-None-Match, which costs a 304 at most.
+const DefaultStaticCacheControl = "public, max-age=0, must-revalidate"
+
+// StaticOptions configures StaticFS.
+type StaticOptions struct {
+	// Index is the file served for requests naming a directory. If empty,
+	// "index.html" is used.
+	Index string
+
+	// SPAFallback serves the root Index file, with status 200, for paths
+	// that match no file, so that a single-page app's client-side routes
+	// load the app instead of a 404.
+	SPAFallback bool
+
+	// CacheControl is set on every file response. If empty,
+	// DefaultStaticCacheControl is used.
+	CacheControl string
+}
+
+// StaticFS returns a handler that serves the files in fsys, typically an
+// embed.FS:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	assets, _ := fs.Sub(dist, "dist")
+//	rt.NotFound = functions.StaticFS(assets, functions.StaticOptions{SPAFallback: true})
+//
+// The request path is resolved against the root of fsys after cleaning, so
+// "../" segments cannot escape it. Content-Type comes from the file
+// extension, or from sniffing the content if the extension is unknown.
+// Every file gets a strong ETag derived from its content, and a request
+// whose If-None-Match matches is answered with 304. Range requests are
+// supported. Only GET and HEAD are allowed.
+//
+// ETags are cached per file and recomputed when its size or modification
+// time changes.
+func StaticFS(fsys fs.FS, opts StaticOptions) http.Handler {
+	s := &staticFS{
+		fsys:         fsys,
+		index:        opts.Index,
+		spa:          opts.SPAFallback,
+		cacheControl: opts.CacheControl,
+	}
+	if s.index == "" {
+		s.index = "index.html"
+	}
+	if s.cacheControl == "" {
+		s.cacheControl = DefaultStaticCacheControl
+	}
+	return s
+}
+
+type staticFS struct {
+	fsys         fs.FS
+	index        string
+	spa          bool
+	cacheControl string
+
+	mu    sync.Mutex
+	etags map[string]staticETag
+}
+
+type staticETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+func (s *staticFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Cleaning a rooted path drops any ".." that would climb above "/".
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	err := s.serveFile(w, r, name)
+	if errors.Is(err, fs.ErrNotExist) && s.spa {
+		err = s.serveFile(w, r, s.index)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		http.NotFound(w, r)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serveFile writes the file called name, or the Index file inside it if it
+// is a directory. It writes nothing if it returns an error.
+func (s *staticFS) serveFile(w http.ResponseWriter, r *http.Request, name string) error {
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		name = path.Join(name, s.index)
+		if info, err = fs.Stat(s.fsys, name); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fs.ErrNotExist
+		}
+	}
+
+	data, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("ETag", s.etag(name, info, data))
+	h.Set("Cache-Control", s.cacheControl)
+	// ServeContent picks the Content-Type from the extension of the name it
+	// is given, answers If-None-Match with 304, and handles Range and HEAD.
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(data))
+	return nil
+}
+
+func (s *staticFS) etag(name string, info fs.FileInfo, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.etags[name]; ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.etag
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if s.etags == nil {
+		s.etags = make(map[string]staticETag)
+	}
+	s.etags[name] = staticETag{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	return etag
+}
@@ -0,0 +1,146 @@
+package functions
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var staticTestFS = fstest.MapFS{
+	"index.html":      {Data: []byte("<!doctype html><title>app</title>")},
+	"assets/app.js":   {Data: []byte("console.log('app')")},
+	"assets/app.css":  {Data: []byte("body{margin:0}")},
+	"docs/index.html": {Data: []byte("<h1>docs</h1>")},
+}
+
+func serveStatic(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticFSContentType(t *testing.T) {
+	h := StaticFS(staticTestFS, StaticOptions{})
+	tests := []struct {
+		target, wantType, wantBody string
+	}{
+		{"/assets/app.js", "text/javascript; charset=utf-8", "console.log('app')"},
+		{"/assets/app.css", "text/css; charset=utf-8", "body{margin:0}"},
+		{"/", "text/html; charset=utf-8", "<!doctype html><title>app</title>"},
+		{"/docs/", "text/html; charset=utf-8", "<h1>docs</h1>"},
+		{"/docs", "text/html; charset=utf-8", "<h1>docs</h1>"},
+	}
+	for _, tt := range tests {
+		w := serveStatic(h, http.MethodGet, tt.target, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.target, w.Code)
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.wantType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.target, got, tt.wantType)
+		}
+		if got := w.Header().Get("Cache-Control"); got != DefaultStaticCacheControl {
+			t.Errorf("%s: Cache-Control = %q", tt.target, got)
+		}
+		if w.Body.String() != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.target, w.Body.String(), tt.wantBody)
+		}
+	}
+}
+
+func TestStaticFSETagRevalidation(t *testing.T) {
+	h := StaticFS(staticTestFS, StaticOptions{CacheControl: "public, max-age=60"})
+
+	first := serveStatic(h, http.MethodGet, "/assets/app.js", nil)
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || len(etag) < 3 {
+		t.Fatalf("ETag = %q, want a strong quoted tag", etag)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Fatalf("Cache-Control = %q, want the configured value", got)
+	}
+
+	w := serveStatic(h, http.MethodGet, "/assets/app.js", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("matching If-None-Match: got %d with %d bytes, want an empty 304", w.Code, w.Body.Len())
+	}
+
+	w = serveStatic(h, http.MethodGet, "/assets/app.js", http.Header{"If-None-Match": {`"stale"`}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale If-None-Match: status = %d, want 200", w.Code)
+	}
+
+	if other := serveStatic(h, http.MethodGet, "/assets/app.css", nil).Header().Get("ETag"); other == etag {
+		t.Fatal("different files share an ETag")
+	}
+}
+
+func TestStaticFSSPAFallback(t *testing.T) {
+	spa := StaticFS(staticTestFS, StaticOptions{SPAFallback: true})
+	w := serveStatic(spa, http.MethodGet, "/users/42/settings", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<title>app</title>") {
+		t.Fatalf("got %d %q, want the root index", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Fatalf("Content-Type = %q, want html", got)
+	}
+
+	// Existing files are still served as themselves.
+	if w := serveStatic(spa, http.MethodGet, "/assets/app.js", nil); w.Body.String() != "console.log('app')" {
+		t.Fatalf("asset body = %q", w.Body.String())
+	}
+
+	plain := StaticFS(staticTestFS, StaticOptions{})
+	if w := serveStatic(plain, http.MethodGet, "/users/42/settings", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("without fallback: status = %d, want 404", w.Code)
+	}
+}
+
+func TestStaticFSPathTraversal(t *testing.T) {
+	// The MapFS has a file outside the subtree being served.
+	fsys := fstest.MapFS{
+		"secret.txt":      {Data: []byte("secret")},
+		"public/app.html": {Data: []byte("app")},
+	}
+	h := StaticFS(mustSub(t, fsys, "public"), StaticOptions{})
+
+	for _, target := range []string{"/../secret.txt", "/a/../../secret.txt", "/assets/../../../secret.txt"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = target // NewRequest would clean the path itself
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("%s: got %d %q, want 404", target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStaticFSMethods(t *testing.T) {
+	h := StaticFS(staticTestFS, StaticOptions{})
+
+	w := serveStatic(h, http.MethodHead, "/assets/app.js", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("HEAD: got %d with %d bytes, want 200 and no body", w.Code, w.Body.Len())
+	}
+
+	w = serveStatic(h, http.MethodPost, "/assets/app.js", nil)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("POST: got %d Allow=%q, want 405", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func mustSub(t *testing.T, fsys fstest.MapFS, dir string) fs.FS {
+	t.Helper()
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
@@ -0,0 +1,70 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrSubrequestLimit is returned by HTTPClient.Do, and the other outbound
+// calls listed on SubrequestLimit, when the request being served has used
+// up the budget SubrequestLimit gave it. WriteError answers
+// it with 429 Too Many Requests.
+var ErrSubrequestLimit = errors.New("functions: subrequest limit exceeded")
+
+// subrequestBudget counts the outbound requests of one incoming request. A
+// budget set inside another counts against both.
+type subrequestBudget struct {
+	max    int64
+	used   atomic.Int64
+	parent *subrequestBudget
+}
+
+type subrequestKey struct{}
+
+// SubrequestLimit returns middleware that allows each request at most max
+// outbound calls. Past that, they fail with ErrSubrequestLimit without
+// sending anything, so a handler gets an error it can return instead of
+// running into the platform's limit, which fails the whole invocation
+// (Workers allow 50 subrequests per request on the Free plan and 1000 on
+// paid plans).
+//
+// The calls counted are HTTPClient.Do, Proxy, Service.Fetch,
+// DurableObjectStub.Fetch, Dial, the JWKS fetches of JWTAuth, each KV and
+// R2 operation, and each operation on DefaultCacheStore in the Workers
+// runtime. Every attempt counts, retries included. The budget belongs to the request,
+// so concurrent requests never share it, and it can be tightened for some
+// routes by applying SubrequestLimit again on them:
+//
+//	r.Get("/report", functions.SubrequestLimit(10)(reportHandler))
+//
+// SubrequestLimit panics if max is negative.
+func SubrequestLimit(max int) Middleware {
+	if max < 0 {
+		panic("functions: SubrequestLimit needs a non-negative max")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent, _ := r.Context().Value(subrequestKey{}).(*subrequestBudget)
+			b := &subrequestBudget{max: int64(max), parent: parent}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), subrequestKey{}, b)))
+		})
+	}
+}
+
+// takeSubrequest charges one outbound request to the budget of ctx and to
+// those it is nested in, if any. If one of them is used up, none is charged.
+func takeSubrequest(ctx context.Context) error {
+	first, _ := ctx.Value(subrequestKey{}).(*subrequestBudget)
+	for b := first; b != nil; b = b.parent {
+		if b.used.Add(1) > b.max {
+			for u := first; u != b.parent; u = u.parent {
+				u.used.Add(-1)
+			}
+			return fmt.Errorf("%w (%d per request)", ErrSubrequestLimit, b.max)
+		}
+	}
+	return nil
+}
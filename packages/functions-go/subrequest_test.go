@@ -0,0 +1,158 @@
+package functions
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fetchN returns a handler that makes n GETs to url with client and records
+// the error of each.
+func fetchN(client *HTTPClient, url string, n int, errs *[]error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < n; i++ {
+			req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+			resp, err := client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			*errs = append(*errs, err)
+		}
+	})
+}
+
+func TestSubrequestLimit(t *testing.T) {
+	srv, calls := flakyServer(t, 0, http.StatusOK)
+	var errs []error
+	h := SubrequestLimit(3)(fetchN(fastRetries(-1), srv.URL, 4, &errs))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for i, err := range errs[:3] {
+		if err != nil {
+			t.Errorf("subrequest %d: %v", i+1, err)
+		}
+	}
+	if err := errs[3]; !errors.Is(err, ErrSubrequestLimit) {
+		t.Errorf("subrequest 4: %v, want ErrSubrequestLimit", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), errs[3])
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("WriteError status = %d, want 429", w.Code)
+	}
+}
+
+func TestSubrequestLimitCountsRetries(t *testing.T) {
+	srv, calls := flakyServer(t, 10, http.StatusServiceUnavailable)
+	var errs []error
+	h := SubrequestLimit(2)(fetchN(fastRetries(5), srv.URL, 1, &errs))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !errors.Is(errs[0], ErrSubrequestLimit) {
+		t.Errorf("err = %v, want ErrSubrequestLimit", errs[0])
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server saw %d attempts, want 2", n)
+	}
+}
+
+func TestSubrequestLimitPerRequest(t *testing.T) {
+	srv, _ := flakyServer(t, 0, http.StatusOK)
+	client := fastRetries(-1)
+	limit := SubrequestLimit(2)
+
+	var wg sync.WaitGroup
+	results := make([][]error, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limit(fetchN(client, srv.URL, 2, &results[i])).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}(i)
+	}
+	wg.Wait()
+	for i, errs := range results {
+		for _, err := range errs {
+			if err != nil {
+				t.Errorf("request %d: %v", i, err)
+			}
+		}
+	}
+}
+
+func TestSubrequestLimitNested(t *testing.T) {
+	srv, _ := flakyServer(t, 0, http.StatusOK)
+	client := fastRetries(-1)
+
+	var errs []error
+	h := SubrequestLimit(3)(SubrequestLimit(10)(fetchN(client, srv.URL, 4, &errs)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(errs[3], ErrSubrequestLimit) {
+		t.Errorf("outer limit not applied: %v", errs[3])
+	}
+
+	errs = nil
+	h = SubrequestLimit(10)(SubrequestLimit(1)(fetchN(client, srv.URL, 2, &errs)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if errs[0] != nil || !errors.Is(errs[1], ErrSubrequestLimit) {
+		t.Errorf("inner limit not applied: %v", errs)
+	}
+}
+
+func TestHTTPClientWithoutSubrequestLimit(t *testing.T) {
+	srv, _ := flakyServer(t, 0, http.StatusOK)
+	var errs []error
+	fetchN(fastRetries(-1), srv.URL, 3, &errs).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSubrequestLimitRejectionChargesNoBudget(t *testing.T) {
+	var inner *subrequestBudget
+	var errs []error
+	h := SubrequestLimit(1)(SubrequestLimit(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = r.Context().Value(subrequestKey{}).(*subrequestBudget)
+		for i := 0; i < 3; i++ {
+			errs = append(errs, takeSubrequest(r.Context()))
+		}
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if errs[0] != nil || !errors.Is(errs[1], ErrSubrequestLimit) || !errors.Is(errs[2], ErrSubrequestLimit) {
+		t.Fatalf("errs = %v", errs)
+	}
+	if n := inner.used.Load(); n != 1 {
+		t.Errorf("inner budget used = %d, want 1: rejected calls were charged", n)
+	}
+}
+
+func TestSubrequestLimitCountsBindings(t *testing.T) {
+	kv := &KV{binding: "CACHE", ns: &stubKV{found: true}}
+	svc := NewServiceWithHandler("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var errs []error
+	h := SubrequestLimit(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := kv.Get(r.Context(), "k")
+		errs = append(errs, err)
+		resp, err := svc.Get(r.Context(), "/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		errs = append(errs, err)
+		errs = append(errs, kv.Delete(r.Context(), "k"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], ErrSubrequestLimit) {
+		t.Fatalf("errs = %v, want the third call refused", errs)
+	}
+}
@@ -0,0 +1,128 @@
+package functions
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout returns middleware that bounds each request to d.
+//
+// The handler runs with a request context whose deadline is d from now, so
+// outbound requests built from it with http.NewRequestWithContext are
+// cancelled when time runs out. If the handler has not written anything by
+// then, the client gets a 503 Service Unavailable with a JSON error body.
+// If it has already started the response, that response is left as sent
+// and any further writes fail with http.ErrHandlerTimeout.
+//
+// A panic in the handler is re-raised on the calling goroutine, so Recover
+// still sees it.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{rec: NewResponseRecorder(w), h: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+			case <-ctx.Done():
+				tw.timeout()
+			}
+		})
+	}
+}
+
+// timeoutWriter lets the handler goroutine and the timeout race safely for
+// the underlying ResponseWriter. The handler writes headers into its own
+// map, which is copied to the real response when rec commits it.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	rec      *ResponseRecorder
+	h        http.Header
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(status)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	if tw.timedOut || tw.rec.Written() {
+		return
+	}
+	dst := tw.rec.Header()
+	for k, v := range tw.h {
+		dst[k] = append([]string(nil), v...)
+	}
+	tw.rec.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	return tw.rec.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || !canFlush(tw.rec) {
+		return
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	tw.rec.Flush()
+}
+
+// Hijack takes over the connection, as for a WebSocket, unless the timeout
+// has already fired. A WebSocket reads with the request's context, so it is
+// still closed when the timeout fires.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	return tw.rec.Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter { return tw.rec }
+
+// timeout stops the handler from writing and, unless it has already
+// committed a response, writes the timeout error.
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if tw.rec.Written() {
+		return
+	}
+	WriteJSON(tw.rec, http.StatusServiceUnavailable, map[string]string{"error": "request timed out"})
+}
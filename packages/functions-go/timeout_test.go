@@ -0,0 +1,146 @@
+package functions
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sleepHandler(d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(d):
+			w.Header().Set("X-Done", "yes")
+			io.WriteString(w, "finished")
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func TestTimeoutExpires(t *testing.T) {
+	h := Timeout(20 * time.Millisecond)(sleepHandler(time.Second))
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("took %s; timeout did not cut the request short", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+		t.Fatalf("body = %s", w.Body.String())
+	}
+}
+
+func TestTimeoutFinishesInTime(t *testing.T) {
+	h := Timeout(500 * time.Millisecond)(sleepHandler(10 * time.Millisecond))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "finished" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Done") != "yes" {
+		t.Fatal("handler headers were not copied to the response")
+	}
+}
+
+func TestTimeoutAfterResponseStarted(t *testing.T) {
+	writeErr := make(chan error, 1)
+	h := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "partial")
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := io.WriteString(w, " late")
+		writeErr <- err
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Fatalf("late write err = %v, want http.ErrHandlerTimeout", err)
+	}
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("got %d %q, want the handler's partial response only", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutCancelsOutboundRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	fetchErr := make(chan error, 1)
+	h := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		fetchErr <- err
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	select {
+	case err := <-fetchErr:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("fetch err = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("outbound request was not cancelled")
+	}
+}
+
+func TestTimeoutPropagatesPanic(t *testing.T) {
+	h := Timeout(time.Second)(panicHandler("boom"))
+	defer func() {
+		if recover() != "boom" {
+			t.Fatal("panic was not re-raised")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestTimeoutWebSocket(t *testing.T) {
+	done := make(chan error, 1)
+	srv := httptest.NewServer(Timeout(5 * time.Second)(echoHandler(done)))
+	defer srv.Close()
+
+	c := dialWS(t, srv.URL)
+	c.send(true, TextMessage, []byte("hello"))
+	if op, p := c.recv(); op != TextMessage || string(p) != "hello" {
+		t.Fatalf("got %d %q", op, p)
+	}
+	c.send(true, CloseMessage, binary.BigEndian.AppendUint16(nil, CloseNormalClosure))
+	c.recv()
+	<-done
+}
+
+func TestTimeoutFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Streamed", "yes")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	}))
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !rec.Flushed || rec.Header().Get("X-Streamed") != "yes" {
+		t.Errorf("flushed = %v, headers = %v", rec.Flushed, rec.Header())
+	}
+}
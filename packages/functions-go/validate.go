@@ -0,0 +1,370 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError reports a field that failed one of its validation rules.
+type FieldError struct {
+	// Field is the path of the field as it appears in JSON, such as "email",
+	// "address.zip" or "items[2].sku".
+	Field string `json:"field"`
+	// Rule is the rule that failed, such as "required" or "min", and Param
+	// its argument, such as "3"; Param is empty for rules without one.
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+	// Message describes the failure in English, such as "must be at least 3
+	// characters".
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string { return e.Field + " " + e.Message }
+
+// ValidationErrors is returned by Validate for a value with invalid fields.
+// It lists one FieldError per invalid field, in struct field order.
+//
+// It marshals to a JSON object suitable as a 400 response body:
+//
+//	{
+//	  "error": "validation failed",
+//	  "fields": [
+//	    {"field": "name", "rule": "required", "message": "is required"},
+//	    {"field": "age", "rule": "min", "param": "18", "message": "must be at least 18"}
+//	  ]
+//	}
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "functions: validation failed: " + strings.Join(msgs, "; ")
+}
+
+// MarshalJSON encodes e as an error body; see ValidationErrors.
+func (e ValidationErrors) MarshalJSON() ([]byte, error) {
+	fields := []FieldError(e)
+	if fields == nil {
+		fields = []FieldError{}
+	}
+	return json.Marshal(struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}{"validation failed", fields})
+}
+
+// Validate checks v, a struct or pointer to a struct, against the rules in
+// the validate tags of its fields:
+//
+//	type createUser struct {
+//		Name  string   `json:"name" validate:"required,max=64"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"omitempty,oneof=admin member"`
+//		Tags  []string `json:"tags" validate:"max=10"`
+//	}
+//
+// The rules, applied in the order given, are:
+//
+//	required   the field is not its zero value; slices and maps are not empty
+//	omitempty  skip the remaining rules if the field is empty
+//	min=N      strings have at least N characters, slices and maps at least
+//	           N elements, and numbers are at least N
+//	max=N      as min, but at most N
+//	len=N      strings have exactly N characters, slices and maps exactly N
+//	           elements
+//	email      a string is a bare email address, such as a@example.com
+//	oneof=A B  a string or integer is one of the space-separated values
+//
+// A nil pointer field passes every rule but required; otherwise rules apply
+// to the value it points to. Struct fields, pointers to structs and the
+// elements of slices, arrays and maps of structs are validated too, whether
+// or not they have a validate tag; a tag of "-" skips a field entirely.
+//
+// Only the first failing rule of each field is reported. If any field
+// fails, Validate returns a ValidationErrors listing all of them, each
+// named by its path in JSON: the json tag, or else the form or query tag,
+// or else the Go field name. A malformed tag is reported as a plain error.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("functions: Validate: want a struct or pointer to struct, got %T", v)
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeAndValidate decodes the JSON request body into v with DecodeJSON
+// and then checks it with Validate, for handlers that do both:
+//
+//	var in createUser
+//	if err := functions.DecodeAndValidate(r, &in); err != nil {
+//		functions.WriteError(w, r, err)
+//		return
+//	}
+//
+// WriteError answers both decoding errors and ValidationErrors with a 400,
+// or the 413 or 415 a decoding error stands for. Errors from decoding are
+// returned unchanged, and v is not validated.
+func DecodeAndValidate(r *http.Request, v any) error {
+	if err := DecodeJSON(r, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous {
+			// Embedded structs are flattened, as encoding/json does.
+			ev := fv
+			if ev.Kind() == reflect.Pointer && ev.Type().Elem().Kind() == reflect.Struct {
+				if ev.IsNil() {
+					continue
+				}
+				ev = ev.Elem()
+			}
+			if ev.Kind() == reflect.Struct && sf.Tag.Get("json") == "" {
+				if err := validateStruct(ev, prefix, errs); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		tag := sf.Tag.Get("validate")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+
+		name := prefix + validateFieldName(sf)
+		if tag != "" {
+			fe, err := validateField(fv, tag)
+			if err != nil {
+				return fmt.Errorf("functions: invalid validate tag on %s.%s: %w", t, sf.Name, err)
+			}
+			if fe != nil {
+				fe.Field = name
+				*errs = append(*errs, *fe)
+			}
+		}
+		if err := validateNested(fv, name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested validates the structs held by a field.
+func validateNested(fv reflect.Value, name string, errs *ValidationErrors) error {
+	switch fv.Kind() {
+	case reflect.Pointer:
+		if !fv.IsNil() {
+			return validateNested(fv.Elem(), name, errs)
+		}
+	case reflect.Struct:
+		return validateStruct(fv, name+".", errs)
+	case reflect.Slice, reflect.Array:
+		if !holdsStructs(fv.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < fv.Len(); i++ {
+			if err := validateNested(fv.Index(i), name+"["+strconv.Itoa(i)+"]", errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !holdsStructs(fv.Type().Elem()) {
+			return nil
+		}
+		// Sort the keys so repeated calls report errors in the same order.
+		keys := make([]string, 0, fv.Len())
+		values := make(map[string]reflect.Value, fv.Len())
+		iter := fv.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key())
+			keys = append(keys, k)
+			values[k] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := validateNested(values[k], name+"["+k+"]", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func holdsStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func validateFieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "form", "query"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// validateField applies the rules in tag to fv, returning the first that
+// fails.
+func validateField(fv reflect.Value, tag string) (*FieldError, error) {
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch rule {
+		case "required":
+			if isEmptyValue(fv) {
+				return &FieldError{Rule: rule, Message: "is required"}, nil
+			}
+			continue
+		case "omitempty":
+			if isEmptyValue(fv) {
+				return nil, nil
+			}
+			continue
+		}
+
+		v := fv
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		msg, err := checkRule(v, rule, param)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rule, err)
+		}
+		if msg != "" {
+			return &FieldError{Rule: rule, Param: param, Message: msg}, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkRule applies one rule other than required and omitempty to v,
+// returning a message if it fails.
+func checkRule(v reflect.Value, rule, param string) (string, error) {
+	switch rule {
+	case "min", "max", "len":
+		return checkBound(v, rule, param)
+	case "email":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("not supported for %s", v.Type())
+		}
+		if addr, err := mail.ParseAddress(v.String()); err != nil || addr.Address != v.String() {
+			return "must be a valid email address", nil
+		}
+		return "", nil
+	case "oneof":
+		options := strings.Fields(param)
+		if len(options) == 0 {
+			return "", fmt.Errorf("no values")
+		}
+		var s string
+		switch v.Kind() {
+		case reflect.String:
+			s = v.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(v.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(v.Uint(), 10)
+		default:
+			return "", fmt.Errorf("not supported for %s", v.Type())
+		}
+		for _, o := range options {
+			if s == o {
+				return "", nil
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", "), nil
+	default:
+		return "", fmt.Errorf("unknown rule")
+	}
+}
+
+// checkBound applies min, max or len to the length of a string, slice or
+// map, or to the value of a number.
+func checkBound(v reflect.Value, rule, param string) (string, error) {
+	var n float64
+	var unit string
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), "elements"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return "", fmt.Errorf("not supported for %s", v.Type())
+	}
+	if unit == "" && rule == "len" {
+		return "", fmt.Errorf("not supported for %s", v.Type())
+	}
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid number %q", param)
+	}
+
+	var ok bool
+	var msg string
+	switch rule {
+	case "min":
+		ok, msg = n >= bound, "must be at least "+param
+	case "max":
+		ok, msg = n <= bound, "must be at most "+param
+	case "len":
+		ok, msg = n == bound, "must be exactly "+param
+	}
+	if ok {
+		return "", nil
+	}
+	if unit != "" {
+		if unit == "elements" {
+			msg = strings.Replace(msg, "must be", "must have", 1)
+		}
+		msg += " " + unit
+	}
+	return msg, nil
+}
+
+// isEmptyValue reports whether v fails required: it is the zero value, or
+// an empty slice or map.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,158 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateAddress struct {
+	Street string `json:"street" validate:"required"`
+	Zip    string `json:"zip" validate:"len=5"`
+}
+
+type validateItem struct {
+	SKU      string `json:"sku" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1,max=100"`
+}
+
+type validateOrder struct {
+	Email    string           `json:"email" validate:"required,email"`
+	Status   string           `json:"status" validate:"omitempty,oneof=pending paid"`
+	Note     *string          `json:"note,omitempty" validate:"max=10"`
+	Address  validateAddress  `json:"address"`
+	Billing  *validateAddress `json:"billing,omitempty"`
+	Items    []validateItem   `json:"items" validate:"required,max=3"`
+	Internal string           `json:"-" validate:"-"`
+}
+
+func fieldRules(errs ValidationErrors) map[string]string {
+	m := make(map[string]string)
+	for _, fe := range errs {
+		m[fe.Field] = fe.Rule
+	}
+	return m
+}
+
+func TestValidateValid(t *testing.T) {
+	order := validateOrder{
+		Email:   "ada@example.com",
+		Address: validateAddress{Street: "1 Main St", Zip: "12345"},
+		Items:   []validateItem{{SKU: "a", Quantity: 1}},
+	}
+	if err := Validate(&order); err != nil {
+		t.Fatalf("Validate = %v", err)
+	}
+	order.Status = "paid"
+	if err := Validate(order); err != nil {
+		t.Fatalf("Validate(paid) = %v", err)
+	}
+}
+
+func TestValidateReportsAllFailures(t *testing.T) {
+	note := "much too long a note"
+	order := validateOrder{
+		Email:   "not an email",
+		Status:  "shipped",
+		Note:    &note,
+		Address: validateAddress{Zip: "123"},
+		Billing: &validateAddress{Street: "2 Side St", Zip: "1234567"},
+		Items:   []validateItem{{SKU: "a", Quantity: 1}, {Quantity: 500}},
+	}
+	err := Validate(&order)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	want := map[string]string{
+		"email":             "email",
+		"status":            "oneof",
+		"note":              "max",
+		"address.street":    "required",
+		"address.zip":       "len",
+		"billing.zip":       "len",
+		"items[1].sku":      "required",
+		"items[1].quantity": "max",
+	}
+	if got := fieldRules(errs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("failures = %v\nwant %v", got, want)
+	}
+	if errs[0].Field != "email" || errs[len(errs)-1].Field != "items[1].quantity" {
+		t.Errorf("failures not in field order: %v", errs)
+	}
+	if !strings.Contains(err.Error(), "address.zip must be exactly 5 characters") {
+		t.Errorf("Error() = %q", err)
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	err := Validate(validateOrder{Items: []validateItem{}})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	got := fieldRules(errs)
+	for _, f := range []string{"email", "items", "address.street"} {
+		if got[f] != "required" {
+			t.Errorf("%s: rule = %q, want required", f, got[f])
+		}
+	}
+	// A nil pointer to a struct is not descended into.
+	if _, ok := got["billing.street"]; ok {
+		t.Error("nil billing address was validated")
+	}
+}
+
+func TestValidationErrorsJSON(t *testing.T) {
+	errs := ValidationErrors{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "age", Rule: "min", Param: "18", Message: "must be at least 18"},
+	}
+	b, err := json.Marshal(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"error":"validation failed","fields":[` +
+		`{"field":"name","rule":"required","message":"is required"},` +
+		`{"field":"age","rule":"min","param":"18","message":"must be at least 18"}]}`
+	if string(b) != want {
+		t.Fatalf("got  %s\nwant %s", b, want)
+	}
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	var errs ValidationErrors
+	err := Validate(struct {
+		N int `validate:"email"`
+	}{})
+	if err == nil || errors.As(err, &errs) {
+		t.Fatalf("err = %v, want a tag error", err)
+	}
+	if err := Validate(42); err == nil {
+		t.Fatal("Validate accepted a non-struct")
+	}
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	req := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	}
+
+	var order validateOrder
+	err := DecodeAndValidate(req(`{"email":"ada@example.com","address":{"street":"x","zip":"12345"},"items":[{"sku":"a","quantity":2}]}`), &order)
+	if err != nil || order.Items[0].Quantity != 2 {
+		t.Fatalf("valid body: %+v, %v", order, err)
+	}
+
+	var errs ValidationErrors
+	if err := DecodeAndValidate(req(`{"email":"ada"}`), &validateOrder{}); !errors.As(err, &errs) {
+		t.Fatalf("invalid body: err = %v, want ValidationErrors", err)
+	}
+	if err := DecodeAndValidate(req(`{`), &validateOrder{}); err == nil || errors.As(err, &errs) {
+		t.Fatalf("malformed body: err = %v, want a decode error", err)
+	}
+}
@@ -0,0 +1,194 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Vectorize limits enforced before a request reaches the index.
+const (
+	// VectorizeMaxTopK is the largest QueryOptions.TopK.
+	VectorizeMaxTopK = 100
+	// VectorizeMaxTopKWithValues is the largest QueryOptions.TopK when the
+	// query returns values or metadata.
+	VectorizeMaxTopKWithValues = 20
+
+	vectorizeDefaultTopK    = 5
+	vectorizeMaxDimensions  = 1536
+	vectorizeMaxIDBytes     = 64
+	vectorizeMaxUpsertBatch = 1000
+)
+
+// VectorizeIndex is a Cloudflare Vectorize index, for similarity search
+// over embeddings.
+//
+// The binding name is the `binding` of a `[[vectorize]]` entry in
+// wrangler.toml:
+//
+//	[[vectorize]]
+//	binding = "DOCS"
+//	index_name = "docs"
+//
+// which is opened with NewVectorize("DOCS"). Handlers are tested against
+// functest.NewMockVectorize.
+type VectorizeIndex struct {
+	binding string
+	s       VectorStore
+}
+
+// Vector is a vector stored in an index.
+type Vector struct {
+	// ID identifies the vector in the index, in up to 64 bytes. Upserting a
+	// vector with an ID that is already stored replaces it.
+	ID string `json:"id"`
+	// Values has one entry per dimension of the index.
+	Values []float32 `json:"values"`
+	// Metadata is stored with the vector, returned by queries that ask for
+	// it and matched by the Filter of QueryOptions.
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// QueryOptions configures VectorizeIndex.Query.
+type QueryOptions struct {
+	// TopK is the number of matches to return, 5 if zero. It may be at most
+	// VectorizeMaxTopK, or VectorizeMaxTopKWithValues if ReturnValues or
+	// ReturnMetadata is set.
+	TopK int
+	// ReturnValues includes each match's values in the result.
+	ReturnValues bool
+	// ReturnMetadata includes each match's metadata in the result.
+	ReturnMetadata bool
+	// Filter, if set, restricts the matches to vectors whose metadata
+	// satisfies it. Each key names a metadata field and maps to a value the
+	// field must equal, or to an operator object:
+	//
+	//	Filter: map[string]any{
+	//		"lang": "en",
+	//		"year": map[string]any{"$gte": 2020},
+	//		"kind": map[string]any{"$in": []any{"guide", "faq"}},
+	//	}
+	//
+	// The operators are $eq, $ne, $in, $nin, $lt, $lte, $gt and $gte. In
+	// Vectorize a field can only be filtered on once a metadata index has
+	// been created for it.
+	Filter map[string]any
+}
+
+// QueryResult is the result of VectorizeIndex.Query.
+type QueryResult struct {
+	// Matches are the nearest vectors, most similar first.
+	Matches []VectorMatch `json:"matches"`
+	Count   int           `json:"count"`
+}
+
+// VectorMatch is a vector found by a query. Values and Metadata are only
+// set if the query asked for them.
+type VectorMatch struct {
+	ID string `json:"id"`
+	// Score is the similarity to the query vector, in the index's metric;
+	// for cosine, 1 is identical.
+	Score    float64        `json:"score"`
+	Values   []float32      `json:"values,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// VectorStore runs the operations of a VectorizeIndex created with
+// NewVectorizeWithStore. It is given requests that have already been
+// validated, with TopK defaulted.
+type VectorStore interface {
+	Upsert(ctx context.Context, vectors []Vector) error
+	Query(ctx context.Context, vector []float32, opts QueryOptions) (*QueryResult, error)
+	DeleteByIDs(ctx context.Context, ids []string) error
+}
+
+// NewVectorize opens the Vectorize index bound to the Worker under binding.
+// It returns ErrBindingNotFound if no such binding is configured.
+func NewVectorize(binding string) (*VectorizeIndex, error) {
+	s, err := openVectorize(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &VectorizeIndex{binding: binding, s: s}, nil
+}
+
+// NewVectorizeWithStore returns a VectorizeIndex backed by s instead of a
+// Workers binding, for tests and local development. name takes the place
+// of the binding name in errors.
+func NewVectorizeWithStore(name string, s VectorStore) *VectorizeIndex {
+	return &VectorizeIndex{binding: name, s: s}
+}
+
+// Upsert inserts vectors into the index, replacing any stored under the
+// same IDs. All of them must have the same number of dimensions, and at
+// most 1000 can be upserted at once. Vectorize applies upserts
+// asynchronously, so a query made straight afterwards may not see them.
+func (v *VectorizeIndex) Upsert(ctx context.Context, vectors []Vector) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	if err := validateVectors(vectors); err != nil {
+		return v.wrap("upsert", err)
+	}
+	return v.wrap("upsert", v.s.Upsert(ctx, vectors))
+}
+
+// Query returns the vectors in the index nearest to vector.
+func (v *VectorizeIndex) Query(ctx context.Context, vector []float32, opts QueryOptions) (*QueryResult, error) {
+	if len(vector) == 0 {
+		return nil, v.wrap("query", errors.New("query vector is empty"))
+	}
+	if opts.TopK == 0 {
+		opts.TopK = vectorizeDefaultTopK
+	}
+	limit := VectorizeMaxTopK
+	if opts.ReturnValues || opts.ReturnMetadata {
+		limit = VectorizeMaxTopKWithValues
+	}
+	if opts.TopK < 0 || opts.TopK > limit {
+		return nil, v.wrap("query", fmt.Errorf("topK is %d, it must be between 1 and %d", opts.TopK, limit))
+	}
+	res, err := v.s.Query(ctx, vector, opts)
+	if err != nil {
+		return nil, v.wrap("query", err)
+	}
+	return res, nil
+}
+
+// DeleteByIDs deletes the vectors stored under ids. IDs that are not in the
+// index are ignored.
+func (v *VectorizeIndex) DeleteByIDs(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return v.wrap("deleteByIds", v.s.DeleteByIDs(ctx, ids))
+}
+
+func (v *VectorizeIndex) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("functions: Vectorize %s %s: %w", v.binding, op, err)
+}
+
+func validateVectors(vectors []Vector) error {
+	if len(vectors) > vectorizeMaxUpsertBatch {
+		return fmt.Errorf("%d vectors, the limit is %d", len(vectors), vectorizeMaxUpsertBatch)
+	}
+	dims := len(vectors[0].Values)
+	if dims == 0 || dims > vectorizeMaxDimensions {
+		return fmt.Errorf("vector %q has %d dimensions, it must have between 1 and %d", vectors[0].ID, dims, vectorizeMaxDimensions)
+	}
+	for i, vec := range vectors {
+		if vec.ID == "" {
+			return fmt.Errorf("vector %d has no ID", i)
+		}
+		if len(vec.ID) > vectorizeMaxIDBytes {
+			return fmt.Errorf("vector ID %q is %d bytes, the limit is %d", vec.ID, len(vec.ID), vectorizeMaxIDBytes)
+		}
+		if len(vec.Values) != dims {
+			return fmt.Errorf("vector %q has %d dimensions, but vector %q has %d", vec.ID, len(vec.Values), vectors[0].ID, dims)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+)
+
+type jsVectorize struct {
+	index js.Value
+}
+
+func openVectorize(binding string) (VectorStore, error) {
+	index, err := lookupBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+	return &jsVectorize{index: index}, nil
+}
+
+func (v *jsVectorize) Upsert(ctx context.Context, vectors []Vector) error {
+	arg, err := valueToJS(vectors)
+	if err != nil {
+		return err
+	}
+	_, err = awaitPromise(ctx, v.index.Call("upsert", arg))
+	return err
+}
+
+func (v *jsVectorize) Query(ctx context.Context, vector []float32, opts QueryOptions) (*QueryResult, error) {
+	query, err := valueToJS(vector)
+	if err != nil {
+		return nil, err
+	}
+	o := map[string]any{
+		"topK":           opts.TopK,
+		"returnValues":   opts.ReturnValues,
+		"returnMetadata": opts.ReturnMetadata,
+	}
+	if len(opts.Filter) > 0 {
+		o["filter"] = opts.Filter
+	}
+	jsOpts, err := valueToJS(o)
+	if err != nil {
+		return nil, err
+	}
+	res, err := awaitPromise(ctx, v.index.Call("query", query, jsOpts))
+	if err != nil {
+		return nil, err
+	}
+	var out QueryResult
+	if raw := rawJSONFromJS(res); raw != nil {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil, err
+		}
+	}
+	return &out, nil
+}
+
+func (v *jsVectorize) DeleteByIDs(ctx context.Context, ids []string) error {
+	_, err := awaitPromise(ctx, v.index.Call("deleteByIds", stringsToJS(ids)))
+	return err
+}
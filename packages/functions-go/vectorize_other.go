@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package functions
+
+import "fmt"
+
+func openVectorize(binding string) (VectorStore, error) {
+	return nil, fmt.Errorf("%w: %q (Vectorize is only available in the Workers runtime; use NewVectorizeWithStore or functest.NewMockVectorize to run in-process)", ErrBindingNotFound, binding)
+}
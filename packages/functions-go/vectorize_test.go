@@ -0,0 +1,113 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recordingVectorStore records what reaches the store.
+type recordingVectorStore struct {
+	upserts [][]Vector
+	queries []QueryOptions
+	deletes [][]string
+}
+
+func (s *recordingVectorStore) Upsert(_ context.Context, vectors []Vector) error {
+	s.upserts = append(s.upserts, vectors)
+	return nil
+}
+
+func (s *recordingVectorStore) Query(_ context.Context, _ []float32, opts QueryOptions) (*QueryResult, error) {
+	s.queries = append(s.queries, opts)
+	return &QueryResult{}, nil
+}
+
+func (s *recordingVectorStore) DeleteByIDs(_ context.Context, ids []string) error {
+	s.deletes = append(s.deletes, ids)
+	return nil
+}
+
+func TestVectorizeUpsertValidation(t *testing.T) {
+	ctx := context.Background()
+	store := &recordingVectorStore{}
+	index := NewVectorizeWithStore("DOCS", store)
+
+	for _, tt := range []struct {
+		name    string
+		vectors []Vector
+		want    string
+	}{
+		{"mixed dimensions", []Vector{{ID: "a", Values: []float32{1, 0}}, {ID: "b", Values: []float32{1, 0, 0}}}, `"b" has 3 dimensions, but vector "a" has 2`},
+		{"no values", []Vector{{ID: "a"}}, "between 1 and 1536"},
+		{"no ID", []Vector{{ID: "a", Values: []float32{1}}, {Values: []float32{1}}}, "vector 1 has no ID"},
+		{"long ID", []Vector{{ID: strings.Repeat("x", 65), Values: []float32{1}}}, "65 bytes"},
+		{"too many", make([]Vector, 1001), "the limit is 1000"},
+	} {
+		err := index.Upsert(ctx, tt.vectors)
+		if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), "Vectorize DOCS upsert") {
+			t.Errorf("%s: err = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+	if len(store.upserts) != 0 {
+		t.Fatalf("invalid upserts reached the store: %v", store.upserts)
+	}
+
+	if err := index.Upsert(ctx, []Vector{{ID: "a", Values: []float32{1, 0}}, {ID: "b", Values: []float32{0, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Upsert(ctx, nil); err != nil || len(store.upserts) != 1 {
+		t.Errorf("empty upsert: err = %v, %d calls", err, len(store.upserts))
+	}
+}
+
+func TestVectorizeQueryTopK(t *testing.T) {
+	ctx := context.Background()
+	store := &recordingVectorStore{}
+	index := NewVectorizeWithStore("DOCS", store)
+	q := []float32{1, 0}
+
+	if _, err := index.Query(ctx, q, QueryOptions{}); err != nil || store.queries[0].TopK != 5 {
+		t.Errorf("default TopK: err = %v, queries = %v", err, store.queries)
+	}
+	if _, err := index.Query(ctx, q, QueryOptions{TopK: VectorizeMaxTopK}); err != nil {
+		t.Errorf("TopK %d: %v", VectorizeMaxTopK, err)
+	}
+	for _, opts := range []QueryOptions{
+		{TopK: VectorizeMaxTopK + 1},
+		{TopK: -1},
+		{TopK: VectorizeMaxTopKWithValues + 1, ReturnValues: true},
+		{TopK: VectorizeMaxTopKWithValues + 1, ReturnMetadata: true},
+	} {
+		if _, err := index.Query(ctx, q, opts); err == nil || !strings.Contains(err.Error(), "topK") {
+			t.Errorf("%+v: err = %v", opts, err)
+		}
+	}
+	if _, err := index.Query(ctx, nil, QueryOptions{}); err == nil {
+		t.Error("empty query vector accepted")
+	}
+	if len(store.queries) != 2 {
+		t.Errorf("%d queries reached the store, want 2", len(store.queries))
+	}
+}
+
+func TestVectorizeDeleteByIDs(t *testing.T) {
+	store := &recordingVectorStore{}
+	index := NewVectorizeWithStore("DOCS", store)
+	if err := index.DeleteByIDs(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.DeleteByIDs(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.deletes) != 1 || len(store.deletes[0]) != 2 {
+		t.Errorf("deletes = %v", store.deletes)
+	}
+}
+
+func TestNewVectorizeNative(t *testing.T) {
+	if _, err := NewVectorize("DOCS"); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("err = %v, want ErrBindingNotFound", err)
+	}
+}
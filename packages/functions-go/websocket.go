@@ -0,0 +1,181 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WebSocket message types, numbered as in RFC 6455 and gorilla/websocket.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// Close codes from RFC 6455.
+const (
+	CloseNormalClosure   = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// maxWebSocketMessage caps incoming messages on the native implementation;
+// Workers enforces its own limit.
+const maxWebSocketMessage = 1 << 20
+
+var (
+	// ErrNotWebSocket is returned by UpgradeWebSocket for requests that
+	// don't ask for a WebSocket upgrade.
+	ErrNotWebSocket = errors.New("functions: not a WebSocket upgrade request")
+
+	// ErrWebSocketUnsupported is returned by UpgradeWebSocket in the Workers
+	// runtime when there is no way to hand a WebSocketPair client back to
+	// the runtime: neither the ResponseWriter supports it nor has the entry
+	// module set up the bridge described there.
+	ErrWebSocketUnsupported = errors.New("functions: response writer cannot carry a WebSocket response")
+)
+
+// CloseError is returned by ReadMessage once the peer has closed the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("functions: WebSocket closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("functions: WebSocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WebSocket is a server-side WebSocket connection.
+//
+// One goroutine may call ReadMessage at a time. WriteMessage and Close may
+// be called from any goroutine, concurrently with ReadMessage.
+//
+// In the Workers runtime the connection is the server end of a
+// WebSocketPair. Go runs on the isolate's single JavaScript thread there:
+// incoming messages are queued by event listeners and handed to
+// ReadMessage whenever the Go scheduler yields, so a goroutine that spins
+// without blocking stalls the socket along with everything else.
+type WebSocket struct {
+	ctx  context.Context
+	conn wsConn
+}
+
+// wsConn is the platform-specific half of WebSocket. write and close must
+// be safe to call concurrently with each other and with read.
+type wsConn interface {
+	// read blocks until a data message arrives, the peer closes (returning
+	// a *CloseError), or ctx is done.
+	read(ctx context.Context) (messageType int, data []byte, err error)
+	write(messageType int, data []byte) error
+	close(code int, reason string) error
+}
+
+// UpgradeWebSocket accepts a WebSocket upgrade request and returns the
+// connection. Requests that aren't upgrades get a 426 Upgrade Required and
+// ErrNotWebSocket.
+//
+// ReadMessage stops with the request context, so serve the connection from
+// the handler itself and return when ReadMessage fails, as in the example.
+//
+// Locally, under ServeLocal or any net/http server, the handshake is done
+// on the hijacked connection. In the Workers runtime the fetch handler has
+// to resolve with a 101 Response carrying the client end of the
+// WebSocketPair, which the Response that github.com/syumai/workers builds
+// cannot hold. UpgradeWebSocket answers 200 with the client's ID in an
+// X-Functions-WebSocket header instead, and the Worker's entry module turns
+// that into the 101 by wrapping the worker.mjs that syumai/workers
+// generates:
+//
+//	import worker from "./build/worker.mjs";
+//
+//	export default {
+//	  ...worker,
+//	  async fetch(req, env, ctx) {
+//	    globalThis.functionsWebSockets ??= new Map();
+//	    const res = await worker.fetch(req, env, ctx);
+//	    const id = res.headers.get("X-Functions-WebSocket");
+//	    if (!id) return res;
+//	    const webSocket = globalThis.functionsWebSockets.get(id);
+//	    globalThis.functionsWebSockets.delete(id);
+//	    await res.body?.cancel();
+//	    const headers = new Headers(res.headers);
+//	    headers.delete("X-Functions-WebSocket");
+//	    return new Response(null, { status: 101, headers, webSocket });
+//	  },
+//	};
+//
+// Without it UpgradeWebSocket returns ErrWebSocketUnsupported and writes
+// nothing.
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	if !isWebSocketUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		WriteJSON(w, http.StatusUpgradeRequired, map[string]string{"error": "WebSocket upgrade required"})
+		return nil, ErrNotWebSocket
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return &WebSocket{ctx: r.Context(), conn: conn}, nil
+}
+
+// ReadMessage returns the next text or binary message. Ping and pong
+// frames are answered internally. Once the peer closes the connection it
+// returns a *CloseError; when the request context is done it returns the
+// context's error.
+func (ws *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	if err := ws.ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	return ws.conn.read(ws.ctx)
+}
+
+// WriteMessage sends data as a single message of the given type, which must
+// be TextMessage or BinaryMessage.
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("functions: invalid WebSocket message type %d", messageType)
+	}
+	return ws.conn.write(messageType, data)
+}
+
+// Close sends a close frame with code and reason and releases the
+// connection. Closing an already closed connection is not an error.
+func (ws *WebSocket) Close(code int, reason string) error {
+	if len(reason) > 123 {
+		return errors.New("functions: WebSocket close reason exceeds 123 bytes")
+	}
+	return ws.conn.close(code, reason)
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+//go:build js && wasm
+
+package functions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"syscall/js"
+)
+
+// webSocketResponder is implemented by Workers runtime adapters whose
+// ResponseWriter can complete a request with a 101 Response carrying the
+// client end of a WebSocketPair.
+type webSocketResponder interface {
+	WriteWebSocket(client js.Value) error
+}
+
+// The entry module bridge documented on UpgradeWebSocket: clients are left
+// in the global Map under an ID, which a 200 response carries in a header
+// for the entry module to turn into the 101.
+const (
+	webSocketBridge = "functionsWebSockets"
+	webSocketHeader = "X-Functions-WebSocket"
+)
+
+// findWebSocketResponder looks for a webSocketResponder through w's Unwrap
+// chain. Failing that, it adapts the ResponseWriter of syumai/workers, which
+// is told apart by its WriteRawJSBody method, if the entry module has set up
+// the bridge.
+func findWebSocketResponder(w http.ResponseWriter) (webSocketResponder, bool) {
+	for {
+		if r, ok := w.(webSocketResponder); ok {
+			return r, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	if _, ok := w.(interface{ WriteRawJSBody(js.Value) }); !ok {
+		return nil, false
+	}
+	clients := js.Global().Get(webSocketBridge)
+	if clients.Type() != js.TypeObject {
+		return nil, false
+	}
+	return &bridgeResponder{w: w, clients: clients}, true
+}
+
+// bridgeResponder hands a client to the entry module through the bridge.
+type bridgeResponder struct {
+	w       http.ResponseWriter
+	clients js.Value
+}
+
+func (b *bridgeResponder) WriteWebSocket(client js.Value) error {
+	id := js.Global().Get("crypto").Call("randomUUID").String()
+	b.clients.Call("set", id, client)
+	b.w.Header().Set(webSocketHeader, id)
+	// syumai/workers builds a Response with the status written here, and
+	// the runtime only accepts a 101 that carries a webSocket, so the
+	// bridge answers 200 and the entry module swaps in the 101.
+	b.w.WriteHeader(http.StatusOK)
+	// syumai/workers resolves the fetch at the first Write. The entry
+	// module cancels the body instead of reading it, so this Write only
+	// returns then or once the handler has returned; the handler itself
+	// keeps serving the socket.
+	go b.w.Write(nil)
+	return nil
+}
+
+// wsEvent is a message or close delivered by a server socket listener.
+type wsEvent struct {
+	messageType int
+	data        []byte
+	err         error
+}
+
+// jsWS is the server end of a WebSocketPair. Event listeners run on the
+// JavaScript thread and must not block, so they queue events for read.
+type jsWS struct {
+	server js.Value
+	funcs  []js.Func
+
+	mu     sync.Mutex
+	queue  []wsEvent
+	notify chan struct{}
+	closed bool
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (wsConn, error) {
+	responder, ok := findWebSocketResponder(w)
+	if !ok {
+		return nil, ErrWebSocketUnsupported
+	}
+
+	pair := js.Global().Get("WebSocketPair").New()
+	client, server := pair.Get("0"), pair.Get("1")
+	ws := &jsWS{server: server, notify: make(chan struct{}, 1)}
+	ws.listen("message", func(ev js.Value) wsEvent {
+		data := ev.Get("data")
+		if data.Type() == js.TypeString {
+			return wsEvent{messageType: TextMessage, data: []byte(data.String())}
+		}
+		return wsEvent{messageType: BinaryMessage, data: bytesFromJS(data)}
+	})
+	ws.listen("close", func(ev js.Value) wsEvent {
+		return wsEvent{err: &CloseError{Code: ev.Get("code").Int(), Reason: ev.Get("reason").String()}}
+	})
+	ws.listen("error", func(ev js.Value) wsEvent {
+		err := errors.New("functions: WebSocket error")
+		if e := ev.Get("error"); !isNullish(e) {
+			err = jsError(e)
+		}
+		return wsEvent{err: err}
+	})
+	server.Call("accept")
+
+	if err := responder.WriteWebSocket(client); err != nil {
+		ws.close(CloseInternalError, "")
+		return nil, err
+	}
+	return ws, nil
+}
+
+func (ws *jsWS) listen(event string, convert func(js.Value) wsEvent) {
+	fn := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ws.push(convert(args[0]))
+		return nil
+	})
+	ws.funcs = append(ws.funcs, fn)
+	ws.server.Call("addEventListener", event, fn)
+}
+
+func (ws *jsWS) push(ev wsEvent) {
+	ws.mu.Lock()
+	ws.queue = append(ws.queue, ev)
+	ws.mu.Unlock()
+	select {
+	case ws.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (ws *jsWS) read(ctx context.Context) (int, []byte, error) {
+	for {
+		ws.mu.Lock()
+		if len(ws.queue) > 0 {
+			ev := ws.queue[0]
+			ws.queue = ws.queue[1:]
+			ws.mu.Unlock()
+			if ev.err != nil {
+				ws.release()
+			}
+			return ev.messageType, ev.data, ev.err
+		}
+		ws.mu.Unlock()
+
+		select {
+		case <-ws.notify:
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	}
+}
+
+func (ws *jsWS) write(messageType int, data []byte) (err error) {
+	ws.mu.Lock()
+	closed := ws.closed
+	ws.mu.Unlock()
+	if closed {
+		return errors.New("functions: WebSocket is closed")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = jsPanicError(r)
+		}
+	}()
+	if messageType == TextMessage {
+		ws.server.Call("send", string(data))
+	} else {
+		ws.server.Call("send", bytesToJS(data))
+	}
+	return nil
+}
+
+func (ws *jsWS) close(code int, reason string) (err error) {
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return nil
+	}
+	ws.closed = true
+	ws.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = jsPanicError(r)
+		}
+	}()
+	if code == CloseNoStatus {
+		ws.server.Call("close")
+	} else {
+		ws.server.Call("close", code, reason)
+	}
+	return nil
+}
+
+// release drops the event listeners once no more events can arrive.
+func (ws *jsWS) release() {
+	ws.mu.Lock()
+	funcs := ws.funcs
+	ws.funcs = nil
+	ws.mu.Unlock()
+	for _, fn := range funcs {
+		fn.Release()
+	}
+}
+
+// jsPanicError converts the panic syscall/js raises for a thrown exception.
+func jsPanicError(r any) error {
+	if jsErr, ok := r.(js.Error); ok {
+		return jsError(jsErr.Value)
+	}
+	panic(r)
+}
@@ -0,0 +1,236 @@
+//go:build !js || !wasm
+
+package functions
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// wsGUID is appended to the client key to compute Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var errWebSocketClosed = errors.New("functions: WebSocket is closed")
+
+// upgradeWebSocket performs the RFC 6455 handshake on the hijacked
+// connection, for ServeLocal and other net/http servers.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (wsConn, error) {
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		WriteJSON(w, http.StatusUpgradeRequired, map[string]string{"error": "unsupported WebSocket version"})
+		return nil, errors.New("functions: unsupported WebSocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Sec-WebSocket-Key"})
+		return nil, errors.New("functions: invalid Sec-WebSocket-Key")
+	}
+
+	c, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &netWS{c: c, br: brw.Reader}, nil
+}
+
+// netWS speaks the WebSocket framing protocol over a raw connection.
+type netWS struct {
+	c  net.Conn
+	br *bufio.Reader
+
+	mu     sync.Mutex // serializes writes and guards closed
+	closed bool
+}
+
+func (ws *netWS) read(ctx context.Context) (int, []byte, error) {
+	// Unblock the pending read when ctx ends.
+	stop := context.AfterFunc(ctx, func() { ws.c.SetReadDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	var (
+		msgType int
+		msg     []byte
+	)
+	for {
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, nil, ctx.Err()
+			}
+			var ce *CloseError
+			if errors.As(err, &ce) {
+				ws.close(ce.Code, ce.Reason)
+			}
+			return 0, nil, err
+		}
+
+		switch op {
+		case PingMessage:
+			if err := ws.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: CloseNoStatus}
+			if len(payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Reason = string(payload[2:])
+			}
+			ws.close(ce.Code, "")
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+			if msgType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "expected continuation frame")
+			}
+			msgType = op
+		case 0: // continuation
+			if msgType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if len(msg)+len(payload) > maxWebSocketMessage {
+			return 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			if msgType == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, ws.fail(CloseInvalidPayload, "invalid UTF-8")
+			}
+			return msgType, msg, nil
+		}
+	}
+}
+
+// readFrame reads one frame and unmasks its payload. Protocol violations
+// are returned as a *CloseError naming the code to close with.
+func (ws *netWS) readFrame() (fin bool, op int, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(ws.br, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	op = int(hdr[0] & 0x0f)
+	if hdr[0]&0x70 != 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	}
+	if hdr[1]&0x80 == 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "client frames must be masked"}
+	}
+
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= CloseMessage && (n > 125 || !fin) {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+	if n > maxWebSocketMessage {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(ws.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+func (ws *netWS) write(messageType int, data []byte) error {
+	return ws.writeFrame(messageType, data)
+}
+
+func (ws *netWS) writeFrame(op int, data []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return errWebSocketClosed
+	}
+	return ws.writeFrameLocked(op, data)
+}
+
+func (ws *netWS) writeFrameLocked(op int, data []byte) error {
+	frame := make([]byte, 0, 10+len(data))
+	frame = append(frame, 0x80|byte(op))
+	switch n := len(data); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, data...)
+	_, err := ws.c.Write(frame)
+	return err
+}
+
+func (ws *netWS) close(code int, reason string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	var payload []byte
+	if code != CloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	ws.c.SetWriteDeadline(time.Now().Add(time.Second))
+	werr := ws.writeFrameLocked(CloseMessage, payload)
+	if err := ws.c.Close(); err != nil {
+		return err
+	}
+	return werr
+}
+
+// fail closes the connection after a protocol violation by the peer.
+func (ws *netWS) fail(code int, reason string) error {
+	ws.close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
@@ -0,0 +1,243 @@
+package functions
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal RFC 6455 client for exercising the native
+// WebSocket implementation, the one ServeLocal uses.
+type wsClient struct {
+	t  *testing.T
+	c  net.Conn
+	br *bufio.Reader
+}
+
+func dialWS(t *testing.T, url string) *wsClient {
+	t.Helper()
+	c, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var key [16]byte
+	rand.Read(key[:])
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key[:]))
+	if err := req.Write(c); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") == "" {
+		t.Fatal("missing Sec-WebSocket-Accept")
+	}
+	return &wsClient{t: t, c: c, br: br}
+}
+
+func (c *wsClient) send(fin bool, op int, payload []byte) {
+	c.t.Helper()
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.c.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsClient) recv() (op int, payload []byte) {
+	c.t.Helper()
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	n := int(hdr[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return int(hdr[0] & 0x0f), payload
+}
+
+func echoHandler(done chan<- error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer ws.Close(CloseNormalClosure, "")
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			if err := ws.WriteMessage(mt, data); err != nil {
+				done <- err
+				return
+			}
+		}
+	}
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	done := make(chan error, 1)
+	// Run behind middleware to check the upgrade reaches the connection
+	// through wrapped writers.
+	logger := Logger(LoggerOptions{Handler: slog.NewTextHandler(io.Discard, nil)})
+	srv := httptest.NewServer(Use(echoHandler(done), Recover(RecoverOptions{}), logger))
+	defer srv.Close()
+
+	c := dialWS(t, srv.URL)
+
+	c.send(true, TextMessage, []byte("hello"))
+	if op, p := c.recv(); op != TextMessage || string(p) != "hello" {
+		t.Fatalf("got %d %q", op, p)
+	}
+
+	big := []byte(strings.Repeat("b", 300))
+	c.send(true, BinaryMessage, big)
+	if op, p := c.recv(); op != BinaryMessage || string(p) != string(big) {
+		t.Fatalf("binary echo: got %d, %d bytes", op, len(p))
+	}
+
+	// A fragmented message with a ping in the middle.
+	c.send(false, TextMessage, []byte("frag"))
+	c.send(true, PingMessage, []byte("p"))
+	if op, p := c.recv(); op != PongMessage || string(p) != "p" {
+		t.Fatalf("pong: got %d %q", op, p)
+	}
+	c.send(true, 0, []byte("mented"))
+	if op, p := c.recv(); op != TextMessage || string(p) != "fragmented" {
+		t.Fatalf("fragmented echo: got %d %q", op, p)
+	}
+
+	// Closing from the client ends ReadMessage with a CloseError.
+	c.send(true, CloseMessage, append(binary.BigEndian.AppendUint16(nil, CloseGoingAway), "bye"...))
+	if op, p := c.recv(); op != CloseMessage || binary.BigEndian.Uint16(p) != CloseGoingAway {
+		t.Fatalf("close reply: got %d %v", op, p)
+	}
+	var ce *CloseError
+	if err := <-done; !errors.As(err, &ce) || ce.Code != CloseGoingAway || ce.Reason != "bye" {
+		t.Fatalf("handler err = %v", err)
+	}
+}
+
+func TestWebSocketRejectsInvalidUTF8(t *testing.T) {
+	done := make(chan error, 1)
+	srv := httptest.NewServer(echoHandler(done))
+	defer srv.Close()
+
+	c := dialWS(t, srv.URL)
+	c.send(true, TextMessage, []byte{0xff, 0xfe})
+	if op, p := c.recv(); op != CloseMessage || binary.BigEndian.Uint16(p) != CloseInvalidPayload {
+		t.Fatalf("got %d %v", op, p)
+	}
+	var ce *CloseError
+	if err := <-done; !errors.As(err, &ce) || ce.Code != CloseInvalidPayload {
+		t.Fatalf("handler err = %v", err)
+	}
+}
+
+func TestWebSocketReadRespectsContext(t *testing.T) {
+	done := make(chan error, 1)
+	withDeadline := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	srv := httptest.NewServer(withDeadline(echoHandler(done)))
+	defer srv.Close()
+
+	dialWS(t, srv.URL)
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ReadMessage did not return when the context ended")
+	}
+}
+
+func TestUpgradeWebSocketRejectsPlainRequests(t *testing.T) {
+	w := httptest.NewRecorder()
+	_, err := UpgradeWebSocket(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(err, ErrNotWebSocket) {
+		t.Fatalf("err = %v, want ErrNotWebSocket", err)
+	}
+	if w.Code != http.StatusUpgradeRequired || w.Header().Get("Upgrade") != "websocket" {
+		t.Fatalf("got %d, Upgrade = %q", w.Code, w.Header().Get("Upgrade"))
+	}
+}
+
+// This example echoes every message back to the client. Run it locally with
+// ServeLocal, or deploy it to Workers.
+func ExampleUpgradeWebSocket() {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r)
+		if err != nil {
+			return // the client already got an error response
+		}
+		defer ws.Close(CloseNormalClosure, "")
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := ws.WriteMessage(mt, data); err != nil {
+				return
+			}
+		}
+	}
+
+	rt := NewRouter()
+	rt.HandleFunc(http.MethodGet, "/ws", echo)
+	_ = rt // pass rt to workers.Serve, or ServeLocal("", rt) during development
+}
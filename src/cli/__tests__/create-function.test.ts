@@ -1,8 +1,67 @@
 import { describe, it, expect, beforeEach, afterEach } from 'vitest'
-import { execSync } from 'node:child_process'
-import { mkdtempSync, rmSync, existsSync, readFileSync } from 'node:fs'
+import { execSync, spawnSync } from 'node:child_process'
+import { mkdtempSync, mkdirSync, rmSync, existsSync, readFileSync, writeFileSync } from 'node:fs'
 import { tmpdir } from 'node:os'
 import { join } from 'node:path'
+import { pathToFileURL } from 'node:url'
+
+/**
+ * Parse the subset of TOML that create-function generates: tables, arrays
+ * of tables, and keys with string, integer, boolean or string-array values.
+ * Throws on any line it does not understand, so a malformed file fails the
+ * test that reads it.
+ */
+function parseToml(content: string): Record<string, any> {
+  const root: Record<string, any> = {}
+  let current = root
+  const descend = (path: string[], asArray: boolean) => {
+    let node = root
+    path.forEach((key, i) => {
+      const last = i === path.length - 1
+      if (last && asArray) {
+        node[key] ??= []
+        if (!Array.isArray(node[key])) throw new Error(`${path.join('.')} is not an array of tables`)
+        node[key].push({})
+        node = node[key][node[key].length - 1]
+        return
+      }
+      node[key] ??= {}
+      node = Array.isArray(node[key]) ? node[key][node[key].length - 1] : node[key]
+    })
+    return node
+  }
+  const parseValue = (raw: string): any => {
+    if (/^"[^"]*"$/.test(raw)) return raw.slice(1, -1)
+    if (/^-?\d+$/.test(raw)) return Number(raw)
+    if (raw === 'true' || raw === 'false') return raw === 'true'
+    const array = raw.match(/^\[(.*)\]$/)
+    if (array) {
+      return array[1].trim() === '' ? [] : array[1].split(',').map((item) => parseValue(item.trim()))
+    }
+    throw new Error(`Unsupported TOML value: ${raw}`)
+  }
+
+  for (const line of content.split('\n').map((l) => l.trim())) {
+    if (line === '' || line.startsWith('#')) continue
+    const arrayTable = line.match(/^\[\[([\w.]+)\]\]$/)
+    const table = line.match(/^\[([\w.]+)\]$/)
+    const pair = line.match(/^([\w-]+)\s*=\s*(.+)$/)
+    if (arrayTable) {
+      current = descend(arrayTable[1].split('.'), true)
+    } else if (table) {
+      current = descend(table[1].split('.'), false)
+    } else if (pair) {
+      if (pair[1] in current) throw new Error(`Duplicate key: ${pair[1]}`)
+      current[pair[1]] = parseValue(pair[2])
+    } else {
+      throw new Error(`Unparseable TOML line: ${line}`)
+    }
+  }
+  return root
+}
+
+// Building a scaffold needs a Go toolchain and access to the module proxy
+const hasGo = spawnSync('go', ['version']).status === 0
 
 describe('create-function CLI', () => {
   let tempDir: string
@@ -428,6 +487,8 @@ describe('create-function CLI', () => {
       const requiredFiles = [
         'go.mod',
         'main.go',
+        'handler.go',
+        'handler_test.go',
         'wrangler.toml',
         'Makefile',
         '.gitignore',
@@ -438,6 +499,291 @@ describe('create-function CLI', () => {
         expect(existsSync(filePath), `Expected ${file} to exist`).toBe(true)
       }
     })
+
+    it('should restrict main.go to js/wasm and keep handlers buildable natively', () => {
+      const projectDir = join(tempDir, 'hello-go')
+
+      execSync(`npx create-function hello-go --lang go`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+      expect(mainContent.startsWith('//go:build js && wasm\n')).toBe(true)
+
+      const handlerContent = readFileSync(join(projectDir, 'handler.go'), 'utf-8')
+      expect(handlerContent).not.toContain('go:build')
+      expect(handlerContent).not.toContain('github.com/syumai/workers')
+
+      const testContent = readFileSync(join(projectDir, 'handler_test.go'), 'utf-8')
+      expect(testContent).toContain('func TestHandleRequest(t *testing.T)')
+      expect(testContent).not.toContain('github.com/syumai/workers')
+
+      const makefileContent = readFileSync(join(projectDir, 'Makefile'), 'utf-8')
+      expect(makefileContent).toContain('go test ./...')
+    })
+  })
+
+  describe('building a Go scaffold', () => {
+    it.skipIf(!hasGo)('should pin functions-go to this checkout and fill in go.sum', () => {
+      const projectDir = join(tempDir, 'hello-go')
+      execSync(`npx create-function hello-go --lang go`, { cwd: tempDir, stdio: 'pipe' })
+
+      const goMod = readFileSync(join(projectDir, 'go.mod'), 'utf-8')
+      expect(goMod).toMatch(/^replace github\.com\/dot-do\/functions\/packages\/functions-go => .*packages\/functions-go$/m)
+      expect(existsSync(join(projectDir, 'go.sum'))).toBe(true)
+    }, 120_000)
+
+    it.skipIf(!hasGo)('should test natively and build for wasm with every trigger', () => {
+      const projectDir = join(tempDir, 'hello-go')
+      execSync(`npx create-function hello-go --lang go --trigger http,cron,queue,email`, { cwd: tempDir, stdio: 'pipe' })
+
+      execSync('go vet ./...', { cwd: projectDir, stdio: 'pipe' })
+      execSync('go test ./...', { cwd: projectDir, stdio: 'pipe' })
+      execSync('go build -o build/app.wasm .', {
+        cwd: projectDir,
+        stdio: 'pipe',
+        env: { ...process.env, GOOS: 'js', GOARCH: 'wasm' },
+      })
+      expect(existsSync(join(projectDir, 'build', 'app.wasm'))).toBe(true)
+    }, 120_000)
+  })
+
+  describe('npx create-function hello --lang go --trigger cron', () => {
+    it('should register a scheduled handler instead of an HTTP handler', () => {
+      const projectDir = join(tempDir, 'hello-cron')
+
+      execSync(`npx create-function hello-cron --lang go --trigger cron`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+
+      expect(mainContent).toContain('cron.ScheduleTask(runScheduled)')
+      expect(mainContent).toContain('functions.ScheduledEvent')
+      expect(mainContent).not.toContain('workers.Serve')
+      expect(existsSync(join(projectDir, 'scheduled.go'))).toBe(true)
+      expect(existsSync(join(projectDir, 'handler.go'))).toBe(false)
+    })
+
+    it('should add a cron trigger to wrangler.toml', () => {
+      const projectDir = join(tempDir, 'hello-cron')
+
+      execSync(`npx create-function hello-cron --lang go --trigger cron`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const wranglerContent = readFileSync(join(projectDir, 'wrangler.toml'), 'utf-8')
+
+      expect(wranglerContent).toContain('[triggers]')
+      expect(wranglerContent).toMatch(/crons = \[".+"\]/)
+    })
+
+    it('should register both HTTP and cron handlers in one main', () => {
+      const projectDir = join(tempDir, 'hello-both')
+
+      execSync(`npx create-function hello-both --lang go --trigger http,cron`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+
+      expect(mainContent).toContain('http.HandleFunc("/", handleRequest)')
+      expect(mainContent).toContain('workers.ServeNonBlock(nil)')
+      expect(mainContent).toContain('cron.ScheduleTaskNonBlock(runScheduled)')
+      expect(mainContent).toContain('workers.Ready()')
+      expect(existsSync(join(projectDir, 'handler.go'))).toBe(true)
+      expect(existsSync(join(projectDir, 'scheduled.go'))).toBe(true)
+    })
+
+    it('should fail for an unknown trigger', () => {
+      expect(() => {
+        execSync(`npx create-function hello-cron --lang go --trigger webhook`, {
+          cwd: tempDir,
+          stdio: 'pipe',
+        })
+      }).toThrow()
+    })
+
+    it('should fail for languages without trigger support', () => {
+      expect(() => {
+        execSync(`npx create-function hello-cron --lang rust --trigger cron`, {
+          cwd: tempDir,
+          stdio: 'pipe',
+        })
+      }).toThrow()
+    })
+  })
+
+  describe('npx create-function hello --lang go --trigger queue', () => {
+    it('should register a queue consumer', () => {
+      const projectDir = join(tempDir, 'hello-queue')
+
+      execSync(`npx create-function hello-queue --lang go --trigger queue`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+
+      expect(mainContent).toContain('queues.Consume(consumeQueue)')
+      expect(mainContent).toContain('functions.NewQueueMessage')
+      expect(mainContent).not.toContain('workers.Serve')
+
+      const consumerContent = readFileSync(join(projectDir, 'consumer.go'), 'utf-8')
+      expect(consumerContent).toContain('func handleQueue(ctx context.Context, batch *functions.QueueBatch) error')
+      expect(consumerContent).toContain('msg.Ack()')
+    })
+
+    it('should add queue producer and consumer entries to wrangler.toml', () => {
+      const projectDir = join(tempDir, 'hello-queue')
+
+      execSync(`npx create-function hello-queue --lang go --trigger queue`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const wranglerContent = readFileSync(join(projectDir, 'wrangler.toml'), 'utf-8')
+
+      expect(wranglerContent).toContain('[[queues.producers]]')
+      expect(wranglerContent).toContain('[[queues.consumers]]')
+      expect(wranglerContent).toContain('queue = "hello-queue-queue"')
+    })
+
+    it('should serve HTTP and consume the queue from one main', () => {
+      const projectDir = join(tempDir, 'hello-http-queue')
+
+      execSync(`npx create-function hello-http-queue --lang go --trigger http,queue`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+
+      expect(mainContent).toContain('workers.ServeNonBlock(nil)')
+      expect(mainContent).toContain('queues.ConsumeNonBlock(consumeQueue)')
+      expect(mainContent).toContain('workers.Ready()')
+    })
+  })
+
+  describe('npx create-function hello --lang go --trigger email', () => {
+    it('should register an email handler', () => {
+      const projectDir = join(tempDir, 'hello-email')
+
+      execSync(`npx create-function hello-email --lang go --trigger email`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+      expect(mainContent).toContain('functions.HandleEmail(handleEmail)')
+      expect(mainContent).toContain('workers.Ready()')
+      expect(mainContent).not.toContain('workers.Serve')
+
+      const handlerContent = readFileSync(join(projectDir, 'email.go'), 'utf-8')
+      expect(handlerContent).toContain('func handleEmail(ctx context.Context, msg *functions.EmailMessage) error')
+      expect(handlerContent).toContain('msg.Reject(')
+      expect(handlerContent).toContain('msg.Forward(')
+      expect(existsSync(join(projectDir, 'email_test.go'))).toBe(true)
+    })
+
+    it('should point wrangler.toml at the email entry module', () => {
+      const projectDir = join(tempDir, 'hello-email')
+
+      execSync(`npx create-function hello-email --lang go --trigger email`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const wranglerContent = readFileSync(join(projectDir, 'wrangler.toml'), 'utf-8')
+      expect(wranglerContent).toMatch(/^main = "\.\/email\.mjs"$/m)
+      expect(wranglerContent).toContain('Email Routing')
+      expect(wranglerContent).toContain('"hello-email" Worker')
+
+      const entryContent = readFileSync(join(projectDir, 'email.mjs'), 'utf-8')
+      expect(entryContent).toContain('import worker from "./build/worker.mjs"')
+      expect(entryContent).toContain('async email(message, env, ctx)')
+      expect(entryContent).toContain('createRuntimeContext({ env, ctx, binding })')
+      expect(entryContent).toContain('binding.handleEmail(message)')
+    })
+
+    it('should keep serving HTTP alongside email', () => {
+      const projectDir = join(tempDir, 'hello-http-email')
+
+      execSync(`npx create-function hello-http-email --lang go --trigger http,email`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const mainContent = readFileSync(join(projectDir, 'main.go'), 'utf-8')
+      expect(mainContent).toContain('workers.ServeNonBlock(nil)')
+      expect(mainContent).toContain('functions.HandleEmail(handleEmail)')
+      expect(existsSync(join(projectDir, 'handler.go'))).toBe(true)
+    })
+
+    it('should start a Go instance for every email event', async () => {
+      const projectDir = join(tempDir, 'hello-email-run')
+
+      execSync(`npx create-function hello-email-run --lang go --trigger email`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      // Stand-ins for the build that workers-assets-gen and the Go compiler
+      // produce. Each Go instance registers its email handler on the
+      // binding of its own runtime context, as functions.HandleEmail does.
+      const build = join(projectDir, 'build')
+      mkdirSync(build)
+      writeFileSync(
+        join(build, 'wasm_exec.js'),
+        `globalThis.goInstances = 0
+globalThis.Go = class {
+  importObject = {}
+  run(instance, context) {
+    const id = ++globalThis.goInstances
+    context.binding.handleEmail = async (message) => {
+      globalThis.handledEmails.push({ id, env: context.env, message })
+    }
+    instance.imports.workers.ready()
+  }
+}
+`
+      )
+      writeFileSync(
+        join(build, 'runtime.mjs'),
+        `export async function loadModule() { return {} }
+export function createRuntimeContext({ env, ctx, binding }) { return { env, ctx, binding } }
+`
+      )
+      writeFileSync(join(build, 'worker.mjs'), `export default { async fetch() { return new Response('ok') } }\n`)
+
+      const wasm = WebAssembly as any
+      const Instance = wasm.Instance
+      wasm.Instance = class {
+        imports: any
+        constructor(_mod: unknown, imports: any) {
+          this.imports = imports
+        }
+      }
+      const handled: any[] = ((globalThis as any).handledEmails = [])
+      try {
+        const { default: entry } = await import(pathToFileURL(join(projectDir, 'email.mjs')).href)
+        expect(typeof entry.fetch).toBe('function')
+
+        await entry.email('first', { NAME: 'a' }, {})
+        await entry.email('second', { NAME: 'b' }, {})
+        expect(handled).toEqual([
+          { id: 1, env: { NAME: 'a' }, message: 'first' },
+          { id: 2, env: { NAME: 'b' }, message: 'second' },
+        ])
+      } finally {
+        wasm.Instance = Instance
+        delete (globalThis as any).handledEmails
+      }
+    })
   })
 
   describe('npx create-function hello --lang assemblyscript', () => {
@@ -547,6 +893,137 @@ describe('create-function CLI', () => {
     })
   })
 
+  describe('language selection', () => {
+    const entryPoints: Record<string, string> = {
+      typescript: 'src/index.ts',
+      rust: 'src/lib.rs',
+      python: 'src/handler.py',
+      go: 'main.go',
+      assemblyscript: 'assembly/index.ts',
+    }
+
+    for (const [lang, entry] of Object.entries(entryPoints)) {
+      it(`should scaffold a hello-world and wrangler.toml for ${lang}`, () => {
+        const projectDir = join(tempDir, 'hello')
+
+        execSync(`npx create-function hello --lang ${lang}`, {
+          cwd: tempDir,
+          stdio: 'pipe',
+        })
+
+        expect(existsSync(join(projectDir, entry)), `Expected ${entry} to exist`).toBe(true)
+
+        const wranglerToml = readFileSync(join(projectDir, 'wrangler.toml'), 'utf-8')
+        expect(wranglerToml).toContain('name = "hello"')
+        expect(wranglerToml).not.toContain('{{')
+      })
+    }
+
+    it('should not offer shared template directories as languages', () => {
+      try {
+        execSync(`npx create-function hello --lang _triggers`, {
+          cwd: tempDir,
+          stdio: 'pipe',
+        })
+        expect.unreachable('expected create-function to fail')
+      } catch (error: any) {
+        const stderr = error.stderr?.toString() || ''
+        expect(stderr).toContain('Unsupported language "_triggers"')
+        expect(stderr).not.toMatch(/Supported languages:.*_triggers/)
+      }
+    })
+  })
+
+  describe('wrangler.toml generation', () => {
+    const readWrangler = (projectDir: string) => parseToml(readFileSync(join(projectDir, 'wrangler.toml'), 'utf-8'))
+
+    it('should generate a deployable Go config named after the directory', () => {
+      execSync(`npx create-function hello-go --lang go`, { cwd: tempDir, stdio: 'pipe' })
+
+      const config = readWrangler(join(tempDir, 'hello-go'))
+      expect(config.name).toBe('hello-go')
+      expect(config.main).toBe('./build/worker.mjs')
+      expect(config.compatibility_date).toMatch(/^\d{4}-\d{2}-\d{2}$/)
+      expect(config.build.command).toContain('tinygo build')
+      expect(config.build.command).toContain('-target wasm')
+      expect(config.kv_namespaces).toBeUndefined()
+      expect(config.r2_buckets).toBeUndefined()
+    })
+
+    it('should take the Worker name from --name', () => {
+      execSync(`npx create-function hello-go --name hello-api`, { cwd: tempDir, stdio: 'pipe' })
+
+      const projectDir = join(tempDir, 'hello-go')
+      expect(readWrangler(projectDir).name).toBe('hello-api')
+      // The Go module is still named after the directory
+      expect(readFileSync(join(projectDir, 'go.mod'), 'utf-8')).toContain('module hello-go')
+    })
+
+    it('should add KV and R2 bindings from --kv and --r2', () => {
+      execSync(`npx create-function hello-go --kv CACHE --kv SESSIONS --r2 UPLOADS`, { cwd: tempDir, stdio: 'pipe' })
+
+      const config = readWrangler(join(tempDir, 'hello-go'))
+      expect(config.kv_namespaces.map((ns: any) => ns.binding)).toEqual(['CACHE', 'SESSIONS'])
+      for (const ns of config.kv_namespaces) {
+        expect(typeof ns.id).toBe('string')
+      }
+      expect(config.r2_buckets).toEqual([{ binding: 'UPLOADS', bucket_name: 'hello-go-uploads' }])
+    })
+
+    it('should combine bindings with trigger sections', () => {
+      execSync(`npx create-function hello-go --trigger http,queue,cron --kv CACHE --r2 UPLOADS`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      const config = readWrangler(join(tempDir, 'hello-go'))
+      expect(config.triggers.crons).toHaveLength(1)
+      expect(config.queues.producers[0].queue).toBe('hello-go-queue')
+      expect(config.queues.consumers[0].queue).toBe('hello-go-queue')
+      expect(config.kv_namespaces[0].binding).toBe('CACHE')
+      expect(config.r2_buckets[0].binding).toBe('UPLOADS')
+    })
+
+    it('should add bindings for other languages too', () => {
+      execSync(`npx create-function hello-ts --lang typescript --kv CACHE`, { cwd: tempDir, stdio: 'pipe' })
+
+      const config = readWrangler(join(tempDir, 'hello-ts'))
+      expect(config.main).toBe('src/index.ts')
+      expect(config.kv_namespaces[0].binding).toBe('CACHE')
+    })
+
+    it('should reject invalid binding names', () => {
+      try {
+        execSync(`npx create-function hello-go --kv my-kv`, { cwd: tempDir, stdio: 'pipe' })
+        expect.unreachable('expected create-function to fail')
+      } catch (error: any) {
+        expect(error.stderr?.toString() || '').toContain('Invalid binding name "my-kv"')
+      }
+      expect(existsSync(join(tempDir, 'hello-go'))).toBe(false)
+    })
+
+    it('should refuse to overwrite an existing wrangler.toml without --force', () => {
+      const projectDir = join(tempDir, 'hello-go')
+      execSync(`npx create-function hello-go`, { cwd: tempDir, stdio: 'pipe' })
+      writeFileSync(join(projectDir, 'wrangler.toml'), 'name = "customized"\n')
+
+      try {
+        execSync(`npx create-function hello-go --kv CACHE`, { cwd: tempDir, stdio: 'pipe' })
+        expect.unreachable('expected create-function to fail')
+      } catch (error: any) {
+        const stderr = error.stderr?.toString() || ''
+        expect(stderr).toContain('wrangler.toml')
+        expect(stderr).toContain('--force')
+      }
+      expect(readWrangler(projectDir).name).toBe('customized')
+
+      execSync(`npx create-function hello-go --kv CACHE --force`, { cwd: tempDir, stdio: 'pipe' })
+      const config = readWrangler(projectDir)
+      expect(config.name).toBe('hello-go')
+      expect(config.kv_namespaces[0].binding).toBe('CACHE')
+    })
+  })
+
   describe('error handling', () => {
     it('should fail if project directory already exists', () => {
       const projectDir = join(tempDir, 'hello')
@@ -563,13 +1040,17 @@ describe('create-function CLI', () => {
       }).toThrow()
     })
 
-    it('should fail if --lang is not provided', () => {
-      expect(() => {
-        execSync(`npx create-function hello`, {
-          cwd: tempDir,
-          stdio: 'pipe',
-        })
-      }).toThrow()
+    it('should default to go if --lang is not provided', () => {
+      const projectDir = join(tempDir, 'hello')
+
+      execSync(`npx create-function hello`, {
+        cwd: tempDir,
+        stdio: 'pipe',
+      })
+
+      expect(existsSync(join(projectDir, 'go.mod'))).toBe(true)
+      expect(existsSync(join(projectDir, 'main.go'))).toBe(true)
+      expect(existsSync(join(projectDir, 'wrangler.toml'))).toBe(true)
     })
 
     it('should fail if unsupported language is provided', () => {